@@ -19,7 +19,10 @@ package options
 import (
 	"crypto/tls"
 	"devops.kubesphere.io/plugin/pkg/client/cache"
+	"devops.kubesphere.io/plugin/pkg/client/devops/cached"
 	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins"
+	"devops.kubesphere.io/plugin/pkg/client/devops/router"
+	"devops.kubesphere.io/plugin/pkg/client/storage"
 	"flag"
 	"fmt"
 
@@ -92,29 +95,42 @@ func (s *ServerRunOptions) NewAPIServer(stopCh <-chan struct{}) (*apiserver.APIS
 		kubernetesClient.ApiExtensions())
 	apiServer.InformerFactory = informerFactory
 
-	var cacheClient cache.Interface
-	if s.RedisOptions != nil && len(s.RedisOptions.Host) != 0 {
-		if s.RedisOptions.Host == fakeInterface && s.DebugMode {
-			apiServer.CacheClient = cache.NewSimpleCache()
-		} else {
-			cacheClient, err = cache.NewRedisClient(s.RedisOptions, stopCh)
-			if err != nil {
-				return nil, fmt.Errorf("failed to connect to redis service, please check redis status, error: %v", err)
-			}
-			apiServer.CacheClient = cacheClient
-		}
-	} else {
-		klog.Warning("ks-apiserver starts without redis provided, it will use in memory cache. " +
-			"This may cause inconsistencies when running ks-apiserver with multiple replicas.")
+	if s.RedisOptions.IsEnabled() && s.RedisOptions.Host == fakeInterface && s.DebugMode {
 		apiServer.CacheClient = cache.NewSimpleCache()
+	} else {
+		// the cache is replaced once the redis options are reloaded
+		cacheClient, err := cache.NewSwappable(s.RedisOptions, stopCh)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to redis service, please check redis status, error: %v", err)
+		}
+		apiServer.CacheClient = cacheClient
 	}
 
 	if s.JenkinsOptions.Host != "" {
-		devopsClient, err := jenkins.NewDevopsClient(s.JenkinsOptions)
+		registry, err := jenkins.NewBackendRegistry(s.JenkinsOptions)
+		if err != nil {
+			return nil, err
+		}
+		go registry.Run(router.DefaultHealthCheckInterval, stopCh)
+		apiServer.JenkinsBackends = registry
+
+		resolver := router.NewNamespaceResolver(informerFactory.KubernetesSharedInformerFactory().Core().V1().Namespaces().Lister(),
+			s.JenkinsOptions.Workspaces())
+		apiServer.JenkinsResolver = resolver
+		if s.JenkinsOptions.ResponseCacheTTL > 0 && !s.RedisOptions.IsEnabled() {
+			klog.Warning("the responses of Jenkins are cached in memory without redis, the replicas may respond differently until they expire")
+		}
+		// the responses are cached once the ttl is reloaded even if it is 0 now
+		apiServer.ResponseCache = cached.NewDevopsClient(router.NewDevopsClient(registry, resolver), apiServer.CacheClient,
+			s.JenkinsOptions.ResponseCacheTTL)
+		apiServer.DevopsClient = apiServer.ResponseCache
+	}
+
+	if s.S2iBinaryStorage.IsEnabled() {
+		apiServer.S2iBinaryStorage, err = storage.NewStorage(s.S2iBinaryStorage)
 		if err != nil {
-			return nil, fmt.Errorf("failed to connect to jenkins, please check jenkins status, error: %v", err)
+			return nil, fmt.Errorf("failed to create the storage of s2i binaries, error: %v", err)
 		}
-		apiServer.DevopsClient = devopsClient
 	}
 
 	server := &http.Server{
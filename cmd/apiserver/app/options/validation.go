@@ -23,6 +23,18 @@ func (s *ServerRunOptions) Validate() []error {
 
 	errors = append(errors, s.GenericServerRunOptions.Validate()...)
 	errors = append(errors, s.KubernetesOptions.Validate()...)
+	if s.RedisOptions.IsEnabled() && s.RedisOptions.Host != fakeInterface {
+		errors = append(errors, s.RedisOptions.Validate()...)
+	}
+	if s.S2iBinaryStorage.IsEnabled() {
+		errors = append(errors, s.S2iBinaryStorage.Validate()...)
+	}
+	errors = append(errors, s.S2iRunRetention.Validate()...)
+	errors = append(errors, s.NotificationOptions.Validate()...)
+	errors = append(errors, s.CommitStatusOptions.Validate()...)
+	errors = append(errors, s.PipelineRunOptions.Validate()...)
+	errors = append(errors, s.MultiClusterOptions.Validate()...)
+	errors = append(errors, s.AgentTemplateOptions.Validate()...)
 
 	return errors
 }
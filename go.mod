@@ -3,7 +3,6 @@ module devops.kubesphere.io/plugin
 go 1.13
 
 require (
-	github.com/PuerkitoBio/goquery v1.5.0
 	github.com/asaskevich/govalidator v0.0.0-20200428143746-21a406dcc535
 	github.com/beevik/etree v1.1.0
 	github.com/blang/semver v3.5.1+incompatible // indirect
@@ -88,7 +87,6 @@ replace (
 	github.com/NYTimes/gziphandler => github.com/NYTimes/gziphandler v1.1.1
 	github.com/Nvveen/Gotty => github.com/Nvveen/Gotty v0.0.0-20120604004816-cd527374f1e5
 	github.com/OneOfOne/xxhash => github.com/OneOfOne/xxhash v1.2.7
-	github.com/PuerkitoBio/goquery => github.com/PuerkitoBio/goquery v1.5.0
 	github.com/PuerkitoBio/purell => github.com/PuerkitoBio/purell v1.1.1
 	github.com/PuerkitoBio/urlesc => github.com/PuerkitoBio/urlesc v0.0.0-20170810143723-de5bf2ad4578
 	github.com/Shopify/logrus-bugsnag => github.com/Shopify/logrus-bugsnag v0.0.0-20171204204709-577dee27f20d
@@ -0,0 +1,98 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ResourceKindAgentTemplate     = "AgentTemplate"
+	ResourceSingularAgentTemplate = "agenttemplate"
+	ResourcePluralAgentTemplate   = "agenttemplates"
+)
+
+// The sync statuses of an agent template
+const (
+	AgentTemplateSyncSuccessful = "successful"
+	AgentTemplateSyncFailed     = "failed"
+)
+
+// AgentTemplateSpec defines the desired state of AgentTemplate
+type AgentTemplateSpec struct {
+	// Labels select the agents in the pipelines of the project. They are scoped to the project in Jenkins, e.g. the
+	// label maven of the project demo is selected by agent { label 'demo.maven' }. A label is owned by the oldest
+	// template of the project claiming it.
+	Labels []string `json:"labels" description:"jenkins labels of the agents"`
+	// Containers run next to the jnlp container of Jenkins, the steps run in them with container('name')
+	Containers []corev1.Container `json:"containers,omitempty" description:"containers of the agent pod"`
+	// Volumes are limited to emptyDir, and NodeSelector to the keys allowed by the plugin, unless the plugin allows
+	// the privileged templates
+	Volumes      []corev1.Volume   `json:"volumes,omitempty" description:"volumes of the agent pod"`
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" description:"node selector of the agent pod"`
+	// IdleMinutes keeps the agent for the next builds after it becomes idle, 0 means the agent is deleted
+	// after every build
+	IdleMinutes int `json:"idleMinutes,omitempty" description:"minutes to keep the idle agent"`
+}
+
+// AgentTemplateStatus defines the observed state of AgentTemplate
+type AgentTemplateStatus struct {
+	// SyncStatus is successful if the template is applied to the Kubernetes cloud of Jenkins
+	SyncStatus string `json:"syncStatus,omitempty"`
+	// Message is the reason of the failed sync
+	Message            string       `json:"message,omitempty"`
+	LastSyncTime       *metav1.Time `json:"lastSyncTime,omitempty"`
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AgentTemplate is the Schema for the agenttemplates API, it is a Kubernetes pod template of the Jenkins agents
+// of the DevOps project
+// +kubebuilder:resource:categories="devops"
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Labels",type="string",JSONPath=".spec.labels"
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.syncStatus"
+// +k8s:openapi-gen=true
+type AgentTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AgentTemplateSpec   `json:"spec,omitempty"`
+	Status AgentTemplateStatus `json:"status,omitempty"`
+}
+
+// AgentLabel returns the label of Jenkins selecting the agents of the template label, it is prefixed with the
+// namespace which never has a dot, so the labels of the projects never overlap
+func AgentLabel(namespace, label string) string {
+	return namespace + "." + label
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AgentTemplateList contains a list of AgentTemplate
+type AgentTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AgentTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AgentTemplate{}, &AgentTemplateList{})
+}
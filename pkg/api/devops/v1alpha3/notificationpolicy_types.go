@@ -0,0 +1,106 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ResourceKindNotificationPolicy     = "NotificationPolicy"
+	ResourceSingularNotificationPolicy = "notificationpolicy"
+	ResourcePluralNotificationPolicy   = "notificationpolicies"
+)
+
+// The events of pipeline runs which can be notified
+const (
+	NotificationEventStarted         = "started"
+	NotificationEventFailed          = "failed"
+	NotificationEventFixed           = "fixed"
+	NotificationEventSucceeded       = "succeeded"
+	NotificationEventWaitingForInput = "waiting-for-input"
+)
+
+// The types of notification sinks
+const (
+	NotificationSinkWebhook  = "webhook"
+	NotificationSinkSlack    = "slack"
+	NotificationSinkDingTalk = "dingtalk"
+	NotificationSinkWeCom    = "wecom"
+	NotificationSinkEmail    = "email"
+)
+
+// NotificationPolicySpec defines the desired state of NotificationPolicy
+type NotificationPolicySpec struct {
+	// Pipelines are the names of the pipelines notified, all the pipelines of the DevOps project are notified
+	// if it is empty
+	Pipelines []string `json:"pipelines,omitempty" description:"names of the pipelines, defaults to all"`
+	// Events are the events of the runs notified: started, failed, fixed, succeeded and waiting-for-input
+	Events []string           `json:"events" description:"events of the pipeline runs to notify"`
+	Sinks  []NotificationSink `json:"sinks" description:"where the notifications are sent to"`
+}
+
+// NotificationSink is a destination of the notifications
+type NotificationSink struct {
+	Name string `json:"name" description:"name of the sink, it is unique in the policy"`
+	// Type is one of webhook, slack, dingtalk, wecom and email
+	Type string `json:"type" description:"type of the sink"`
+	// URL is the address of the webhook, it is ignored by the email sink
+	URL string `json:"url,omitempty" description:"url of the webhook"`
+	// URLFrom reads the URL from a secret in the same namespace, the incoming webhooks of chat tools carry
+	// their tokens in the URLs
+	URLFrom *corev1.SecretKeySelector `json:"urlFrom,omitempty" description:"secret key holding the url of the webhook"`
+	// Headers are added to the requests of the generic webhook
+	Headers map[string]string `json:"headers,omitempty" description:"headers of the generic webhook"`
+	// Template is a Go template rendering the event, it is the payload of the generic webhook, the text of the
+	// chat message or the body of the email
+	Template string `json:"template,omitempty" description:"go template of the payload or the message"`
+	// To are the recipients of the email sink
+	To []string `json:"to,omitempty" description:"recipients of the email"`
+	// Subject is a Go template rendering the subject of the email
+	Subject string `json:"subject,omitempty" description:"go template of the email subject"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NotificationPolicy is the Schema for the notificationpolicies API, it sends the events of the pipeline runs
+// of the DevOps project to the sinks
+// +kubebuilder:resource:categories="devops"
+// +kubebuilder:printcolumn:name="Events",type="string",JSONPath=".spec.events"
+// +k8s:openapi-gen=true
+type NotificationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NotificationPolicySpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NotificationPolicyList contains a list of NotificationPolicy
+type NotificationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationPolicy{}, &NotificationPolicyList{})
+}
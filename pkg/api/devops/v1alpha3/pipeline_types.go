@@ -34,6 +34,23 @@ const (
 	PipelineSyncStatusAnnoKey = PipelinePrefix + "syncstatus"
 	PipelineSyncTimeAnnoKey   = PipelinePrefix + "synctime"
 	PipelineSyncMsgAnnoKey    = PipelinePrefix + "syncmsg"
+	// PipelineWebhookSecretAnnoKey is the name of the secret in the namespace of the pipeline, which holds the
+	// secret or token of the SCM webhooks under the key PipelineWebhookSecretKey
+	PipelineWebhookSecretAnnoKey = PipelinePrefix + "webhooksecret"
+	PipelineWebhookSecretKey     = "secret"
+	// PipelineInputQuorumAnnoKey is the number of the distinct approvers required to proceed an input, defaults to 1
+	PipelineInputQuorumAnnoKey = PipelinePrefix + "inputquorum"
+	// PipelineInputTimeoutAnnoKey is the duration, e.g. 24h, after which the pending inputs are aborted
+	PipelineInputTimeoutAnnoKey = PipelinePrefix + "inputtimeout"
+	// PipelineInputApprovalsAnnoKey is the approvals of the pending inputs in JSON, the approvals are kept until the
+	// inputs reach the quorum or are decided in Jenkins
+	PipelineInputApprovalsAnnoKey = PipelinePrefix + "inputapprovals"
+	// PipelineInputRecordsAnnoKey is the latest decided inputs in JSON
+	PipelineInputRecordsAnnoKey = PipelinePrefix + "inputrecords"
+	// PipelineCommitStatusAnnoKey enables reporting the runs of the multi-branch pipeline as the commit statuses. It is
+	// "true" for the GitHub, GitLab and Bitbucket Server sources, the git sources name the provider of the repository
+	// instead, one of github, gitlab, bitbucket_server and gitea
+	PipelineCommitStatusAnnoKey = PipelinePrefix + "commitstatus"
 )
 
 // PipelineSpec defines the desired state of Pipeline
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ResourceKindPipelineRun     = "PipelineRun"
+	ResourceSingularPipelineRun = "pipelinerun"
+	ResourcePluralPipelineRun   = "pipelineruns"
+	PipelineRunFinalizerName    = "pipelinerun.finalizers.kubesphere.io"
+	PipelineRunPrefix           = "pipelinerun.devops.kubesphere.io/"
+	// PipelineRunIDAnnoKey is the id of the run in Jenkins, the PipelineRuns having it are not triggered again
+	PipelineRunIDAnnoKey = PipelineRunPrefix + "runid"
+	// PipelineNameLabelKey is the name of the pipeline of the PipelineRun
+	PipelineNameLabelKey = PipelineRunPrefix + "pipeline"
+	// PipelineRunTriggeringAnnoKey is the time when the PipelineRun started to be triggered, the PipelineRuns
+	// having it but no run id are not triggered again, because their runs may have been started in Jenkins
+	PipelineRunTriggeringAnnoKey = PipelineRunPrefix + "triggering"
+	// PipelineDeletedRunsAnnoKey is the runs of the Pipeline whose PipelineRuns were deleted, they are not
+	// mirrored again. It is a comma separated list of ids, which are prefixed by the escaped branches.
+	PipelineDeletedRunsAnnoKey = PipelineRunPrefix + "deleted-runs"
+)
+
+// RunPhase is the phase of a PipelineRun
+type RunPhase string
+
+const (
+	// RunPending means the run is waiting to be triggered, or queued in Jenkins
+	RunPending RunPhase = "Pending"
+	// RunRunning means the run is running or waiting for inputs
+	RunRunning   RunPhase = "Running"
+	RunSucceeded RunPhase = "Succeeded"
+	// RunFailed means the run failed or was unstable, or it couldn't be triggered
+	RunFailed    RunPhase = "Failed"
+	RunCancelled RunPhase = "Cancelled"
+	// RunUnknown means the run is not found in Jenkins any more
+	RunUnknown RunPhase = "Unknown"
+)
+
+// PipelineRunSpec defines the desired state of PipelineRun
+type PipelineRunSpec struct {
+	// Pipeline is the name of the pipeline in the same namespace
+	Pipeline string `json:"pipeline" description:"name of the pipeline"`
+	// Branch is the branch, the tag or the pull request of the multi-branch pipeline, e.g. master or PR-1
+	Branch     string           `json:"branch,omitempty" description:"branch of the multi-branch pipeline"`
+	Parameters []ParameterValue `json:"parameters,omitempty" description:"parameters of the run"`
+}
+
+// ParameterValue is the value of a parameter of the pipeline
+type ParameterValue struct {
+	Name  string `json:"name" description:"name of the parameter"`
+	Value string `json:"value" description:"value of the parameter"`
+}
+
+// PipelineRunStatus defines the observed state of PipelineRun
+type PipelineRunStatus struct {
+	Phase RunPhase `json:"phase,omitempty"`
+	// Result is the result of the finished run in Jenkins, e.g. SUCCESS or UNSTABLE
+	Result         string        `json:"result,omitempty"`
+	StartTime      *metav1.Time  `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time  `json:"completionTime,omitempty"`
+	Stages         []StageStatus `json:"stages,omitempty"`
+	// Message is the reason why the run failed to be triggered or synced
+	Message    string       `json:"message,omitempty"`
+	UpdateTime *metav1.Time `json:"updateTime,omitempty"`
+}
+
+// StageStatus is the status of a stage or a parallel branch of the run
+type StageStatus struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state,omitempty"`
+	// Result is the result of the stage in Jenkins, e.g. SUCCESS or NOT_BUILT
+	Result           string       `json:"result,omitempty"`
+	StartTime        *metav1.Time `json:"startTime,omitempty"`
+	DurationInMillis int64        `json:"durationInMillis,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PipelineRun is the Schema for the pipelineruns API, it is a run of a pipeline in Jenkins. Creating it triggers
+// the run, and deleting it stops the run if it is not finished.
+// +kubebuilder:resource:categories="devops"
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Pipeline",type="string",JSONPath=".spec.pipeline"
+// +kubebuilder:printcolumn:name="Branch",type="string",JSONPath=".spec.branch"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +k8s:openapi-gen=true
+type PipelineRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PipelineRunSpec   `json:"spec,omitempty"`
+	Status PipelineRunStatus `json:"status,omitempty"`
+}
+
+// IsFinished checks if the run is finished, or will never be found in Jenkins
+func (r *PipelineRun) IsFinished() bool {
+	switch r.Status.Phase {
+	case RunSucceeded, RunFailed, RunCancelled, RunUnknown:
+		return true
+	}
+	return false
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PipelineRunList contains a list of PipelineRun
+type PipelineRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PipelineRun `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PipelineRun{}, &PipelineRunList{})
+}
@@ -24,6 +24,20 @@ import (
 type ListResult struct {
 	Items      []interface{} `json:"items"`
 	TotalItems int           `json:"totalItems"`
+
+	// Continue is the opaque token to fetch the next page, it is empty if this is the last page
+	Continue string `json:"continue,omitempty"`
+	// RemainingItemCount is the number of items after this page
+	RemainingItemCount *int64 `json:"remainingItemCount,omitempty"`
+
+	// FailedClusters are the member clusters whose items are missing in a list aggregated across the clusters
+	FailedClusters []ClusterFailure `json:"failedClusters,omitempty"`
+}
+
+// ClusterFailure is the reason why a member cluster is missing in an aggregated list
+type ClusterFailure struct {
+	Cluster string `json:"cluster"`
+	Reason  string `json:"reason"`
 }
 
 type ResourceQuota struct {
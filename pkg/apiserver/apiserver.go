@@ -20,37 +20,66 @@ import (
 	"bytes"
 	"context"
 	clusterv1alpha1 "devops.kubesphere.io/plugin/pkg/api/cluster/v1alpha1"
+	devopsapiv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	devopsapiv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
 	tenantv1alpha1 "devops.kubesphere.io/plugin/pkg/api/tenant/v1alpha1"
 	"devops.kubesphere.io/plugin/pkg/apiserver/authentication/authenticators/jwttoken"
+	authoptions "devops.kubesphere.io/plugin/pkg/apiserver/authentication/options"
 	"devops.kubesphere.io/plugin/pkg/apiserver/authentication/request/anonymous"
 	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/rbac"
+	"devops.kubesphere.io/plugin/pkg/apiserver/dispatch"
 	"devops.kubesphere.io/plugin/pkg/apiserver/filters"
 	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+	"devops.kubesphere.io/plugin/pkg/controller/agenttemplate"
+	"devops.kubesphere.io/plugin/pkg/controller/devopsrole"
+	"devops.kubesphere.io/plugin/pkg/controller/pipelinerun"
+	"devops.kubesphere.io/plugin/pkg/controller/s2ibinary"
+	"devops.kubesphere.io/plugin/pkg/controller/s2irun"
+	configv1alpha2 "devops.kubesphere.io/plugin/pkg/kapis/config/v1alpha2"
+	devopsv1alpha2 "devops.kubesphere.io/plugin/pkg/kapis/devops/v1alpha2"
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/kapis/devops/v1alpha3"
 	resourcesv1alpha2 "devops.kubesphere.io/plugin/pkg/kapis/resources/v1alpha2"
 	resourcev1alpha3 "devops.kubesphere.io/plugin/pkg/kapis/resources/v1alpha3"
 	tenantv1alpha2 "devops.kubesphere.io/plugin/pkg/kapis/tenant/v1alpha2"
 	"devops.kubesphere.io/plugin/pkg/models/auth"
+	"devops.kubesphere.io/plugin/pkg/models/devops/approval"
+	"devops.kubesphere.io/plugin/pkg/models/devops/commitstatus"
+	"devops.kubesphere.io/plugin/pkg/models/devops/notification"
+	"devops.kubesphere.io/plugin/pkg/models/devops/queue"
 	"devops.kubesphere.io/plugin/pkg/models/iam/am"
 	"fmt"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
 	unionauth "k8s.io/apiserver/pkg/authentication/request/union"
 	"net/http"
+	"os"
 	rt "runtime"
 	"time"
 
 	"github.com/emicklei/go-restful"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	urlruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	runtimecache "sigs.k8s.io/controller-runtime/pkg/cache"
 
 	"devops.kubesphere.io/plugin/pkg/client/cache"
+	ksscheme "devops.kubesphere.io/plugin/pkg/client/clientset/versioned/scheme"
 	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/cached"
+	"devops.kubesphere.io/plugin/pkg/client/devops/router"
 	"devops.kubesphere.io/plugin/pkg/client/k8s"
+	"devops.kubesphere.io/plugin/pkg/client/storage"
 	apiserverconfig "devops.kubesphere.io/plugin/pkg/config"
+	"devops.kubesphere.io/plugin/pkg/constants"
 	"devops.kubesphere.io/plugin/pkg/informers"
 	utilnet "devops.kubesphere.io/plugin/pkg/utils/net"
 )
@@ -64,6 +93,11 @@ const (
 
 	//
 	MimeJsonPatchJson = "application/json-patch+json"
+
+	// controllersLeaseName is the lease electing the replica running the controllers
+	controllersLeaseName = "devops-apiserver-controllers"
+	// controllersLeaseDuration is the duration the other replicas wait before they take over the controllers
+	controllersLeaseDuration = 15 * time.Second
 )
 
 type APIServer struct {
@@ -89,11 +123,53 @@ type APIServer struct {
 
 	DevopsClient devops.Interface
 
+	// JenkinsBackends holds the Jenkins servers which DevopsClient routes the requests to
+	JenkinsBackends *router.Registry
+
+	// JenkinsResolver maps the DevOps projects to JenkinsBackends
+	JenkinsResolver *router.NamespaceResolver
+
+	// ResponseCache caches the responses of DevopsClient
+	ResponseCache cached.Interface
+
 	// controller-runtime cache
 	RuntimeCache runtimecache.Cache
+
+	// S2iBinaryStorage stores the uploaded binaries of S2I, the uploads are disabled if it is nil
+	S2iBinaryStorage storage.Interface
+
+	// inputApprovals approves the inputs of pipeline runs, and aborts the expired ones while the server leads the
+	// controllers
+	inputApprovals approval.Operator
+
+	// notifications sends the state changes of pipeline runs to the sinks of the notification policies, it is nil
+	// unless the notification policies are installed
+	notifications notification.Operator
+
+	// commitStatuses reports the runs of the multi-branch pipelines as the commit statuses of their SCM providers
+	// while the server leads the controllers
+	commitStatuses commitstatus.Reporter
+
+	// dispatcher forwards the requests to the member clusters, it is nil unless multicluster is enabled
+	dispatcher dispatch.Dispatcher
+
+	// authenticator is replaced once the authentication options are reloaded
+	authenticator *reloadableAuthenticator
+
+	reloadState reloadState
+
+	// apiResources are the resources served by the cluster, the informers and the controllers are only created for
+	// the existing ones, otherwise waiting for their caches blocks forever
+	apiResources []*metav1.APIResourceList
 }
 
 func (s *APIServer) PrepareRun(stopCh <-chan struct{}) error {
+	_, apiResources, err := s.KubernetesClient.Kubernetes().Discovery().ServerGroupsAndResources()
+	if err != nil {
+		return err
+	}
+	s.apiResources = apiResources
+
 	s.container = restful.NewContainer()
 	s.container.Filter(logRequestAndResponse)
 	s.container.Router(restful.CurlyRouter{})
@@ -116,29 +192,175 @@ func (s *APIServer) PrepareRun(stopCh <-chan struct{}) error {
 
 // Install all kubesphere api groups
 // Installation happens before all informers start to cache objects, so
-//   any attempt to list objects using listers will get empty results.
+//
+//	any attempt to list objects using listers will get empty results.
 func (s *APIServer) installKubeSphereAPIs() {
 	amOperator := am.NewOperator(s.KubernetesClient.KubeSphere(),
 		s.KubernetesClient.Kubernetes(),
 		s.InformerFactory)
 	rbacAuthorizer := rbac.NewRBACAuthorizer(amOperator)
 
+	// the aggregator stays a nil interface in a single-cluster environment
+	var aggregator dispatch.Aggregator
+	if s.Config.MultiClusterOptions != nil && s.Config.MultiClusterOptions.Enable {
+		s.dispatcher = dispatch.NewClusterDispatcher(dispatch.NewClusterGetter(s.RuntimeCache), s.Config.MultiClusterOptions)
+		aggregator = s.dispatcher
+	}
+
 	urlruntime.Must(tenantv1alpha2.AddToContainer(s.container, s.InformerFactory,
 		s.KubernetesClient.Kubernetes(),
-		s.KubernetesClient.KubeSphere(), rbacAuthorizer, s.RuntimeCache))
+		s.KubernetesClient.KubeSphere(), rbacAuthorizer, s.RuntimeCache, aggregator))
 
 	urlruntime.Must(resourcev1alpha3.AddToContainer(s.container, s.InformerFactory, s.RuntimeCache))
+	urlruntime.Must(configv1alpha2.AddToContainer(s.container, s, rbacAuthorizer))
 	urlruntime.Must(resourcesv1alpha2.AddToContainer(s.container, s.KubernetesClient.Kubernetes(), s.InformerFactory,
 		s.KubernetesClient.Master()))
+	var downloadServer string
+	if s.Config.S2iBinaryStorage != nil {
+		downloadServer = s.Config.S2iBinaryStorage.DownloadServer
+	}
+	urlruntime.Must(devopsv1alpha2.AddToContainer(s.container, s.KubernetesClient.Kubernetes(), s.KubernetesClient.KubeSphere(),
+		s.InformerFactory, s.S2iBinaryStorage, downloadServer, rbacAuthorizer))
+
+	if s.JenkinsBackends != nil {
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+			Interface: s.KubernetesClient.Kubernetes().CoreV1().Events("")})
+		recorder := eventBroadcaster.NewRecorder(ksscheme.Scheme, corev1.EventSource{Component: "devops-apiserver"})
+		s.inputApprovals = approval.NewOperator(s.DevopsClient, s.KubernetesClient.KubeSphere(),
+			s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().Pipelines().Lister(),
+			rbacAuthorizer, recorder, approval.DefaultMaxRecords)
+		if s.isResourceExists(devopsapiv1alpha3.GroupVersion.WithResource(devopsapiv1alpha3.ResourcePluralNotificationPolicy)) {
+			s.notifications = notification.NewOperator(s.DevopsClient, s.KubernetesClient.Kubernetes(),
+				s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().Pipelines().Lister(),
+				s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().NotificationPolicies().Lister(),
+				s.InformerFactory.KubernetesSharedInformerFactory().Core().V1().Secrets().Lister(), s.Config.NotificationOptions)
+		}
+		s.commitStatuses = commitstatus.NewReporter(s.DevopsClient,
+			s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().Pipelines().Lister(),
+			s.InformerFactory.KubernetesSharedInformerFactory().Core().V1().Secrets().Lister(),
+			s.InformerFactory.KubernetesSharedInformerFactory().Core().V1().Namespaces().Lister(), s.Config.CommitStatusOptions)
+		queueOperator := queue.NewOperator(s.DevopsClient,
+			s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().Pipelines().Lister(), rbacAuthorizer)
+
+		urlruntime.Must(devopsv1alpha3.AddToContainer(s.container, s.JenkinsBackends, s.DevopsClient,
+			s.KubernetesClient.KubeSphere(), s.InformerFactory, s.inputApprovals, queueOperator, s.notifications, s.commitStatuses, rbacAuthorizer))
+	}
 }
 
 func (s *APIServer) Run(stopCh <-chan struct{}) (err error) {
+	// informers of controllers have to be registered before they start
+	var roleController *devopsrole.Controller
+	if s.DevopsClient != nil {
+		k8sInformerFactory := s.InformerFactory.KubernetesSharedInformerFactory()
+		ksInformerFactory := s.InformerFactory.KubeSphereSharedInformerFactory()
+		roleController = devopsrole.NewController(s.DevopsClient,
+			k8sInformerFactory.Core().V1().Namespaces(),
+			k8sInformerFactory.Rbac().V1().Roles(),
+			k8sInformerFactory.Rbac().V1().RoleBindings(),
+			ksInformerFactory.Iam().V1alpha2().WorkspaceRoles(),
+			ksInformerFactory.Iam().V1alpha2().WorkspaceRoleBindings(),
+			devopsrole.DefaultResyncPeriod)
+	}
+
+	var agentTemplateController *agenttemplate.Controller
+	if s.DevopsClient != nil &&
+		s.isResourceExists(devopsapiv1alpha3.GroupVersion.WithResource(devopsapiv1alpha3.ResourcePluralAgentTemplate)) {
+		agentTemplateController = agenttemplate.NewController(s.DevopsClient, s.KubernetesClient.KubeSphere(),
+			s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().AgentTemplates(),
+			s.Config.AgentTemplateOptions)
+	}
+
+	var pipelineRunController *pipelinerun.Controller
+	if s.DevopsClient != nil &&
+		s.isResourceExists(devopsapiv1alpha3.GroupVersion.WithResource(devopsapiv1alpha3.ResourcePluralPipelineRun)) {
+		pipelineRunController = pipelinerun.NewController(s.DevopsClient, s.KubernetesClient.KubeSphere(),
+			s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().Pipelines(),
+			s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().PipelineRuns(),
+			s.Config.PipelineRunOptions)
+	}
+
+	var s2iBinaryController *s2ibinary.Controller
+	if s.S2iBinaryStorage != nil {
+		s2iBinaryController = s2ibinary.NewController(s.KubernetesClient.KubeSphere(), s.S2iBinaryStorage,
+			s.InformerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha1().S2iBinaries())
+	}
+
+	var s2iRunRetentionController *s2irun.RetentionController
+	if s.DevopsClient != nil &&
+		s.isResourceExists(devopsapiv1alpha1.SchemeGroupVersion.WithResource(devopsapiv1alpha1.ResourcePluralS2iRun)) &&
+		s.isResourceExists(devopsapiv1alpha1.SchemeGroupVersion.WithResource(devopsapiv1alpha1.ResourcePluralS2iBuilder)) {
+		ksInformerFactory := s.InformerFactory.KubeSphereSharedInformerFactory()
+		s2iRunRetentionController = s2irun.NewRetentionController(s.KubernetesClient.Kubernetes(),
+			s.KubernetesClient.KubeSphere(), s.Config.S2iRunRetention,
+			s.InformerFactory.KubernetesSharedInformerFactory().Core().V1().Namespaces(),
+			ksInformerFactory.Devops().V1alpha1().S2iBuilders(),
+			ksInformerFactory.Devops().V1alpha1().S2iRuns())
+	}
 
 	err = s.waitForResourceSync(stopCh)
 	if err != nil {
 		return err
 	}
 
+	// the controllers change the shared objects and Jenkins, so only one replica runs them
+	go s.runLeading(stopCh, func(stopCh <-chan struct{}) {
+		if roleController != nil {
+			go func() {
+				if err := roleController.Run(1, stopCh); err != nil {
+					klog.Error(err)
+				}
+			}()
+		}
+		if s2iRunRetentionController != nil {
+			go func() {
+				if err := s2iRunRetentionController.Run(1, stopCh); err != nil {
+					klog.Error(err)
+				}
+			}()
+		}
+		if s.inputApprovals != nil {
+			go s.inputApprovals.Lead(stopCh)
+		}
+		if agentTemplateController != nil {
+			go func() {
+				if err := agentTemplateController.Run(1, stopCh); err != nil {
+					klog.Error(err)
+				}
+			}()
+		}
+		if s.commitStatuses != nil {
+			go s.commitStatuses.Run(stopCh)
+		}
+		if pipelineRunController != nil {
+			go pipelineRunController.Mirror(stopCh)
+		}
+	})
+	if pipelineRunController != nil {
+		go func() {
+			if err := pipelineRunController.Run(1, stopCh); err != nil {
+				klog.Error(err)
+			}
+		}()
+	}
+	if s2iBinaryController != nil {
+		go func() {
+			if err := s2iBinaryController.Run(1, stopCh); err != nil {
+				klog.Error(err)
+			}
+		}()
+	}
+	if s.inputApprovals != nil {
+		go s.inputApprovals.Run(approval.DefaultSyncPeriod, stopCh)
+	}
+	if s.notifications != nil {
+		go s.notifications.Run(stopCh)
+	}
+	go apiserverconfig.Watch(apiserverconfig.DefaultWatchInterval, func(conf *apiserverconfig.Config) {
+		// the error is recorded in the reload status
+		_ = s.Reload(conf)
+	}, stopCh)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -170,7 +392,18 @@ func (s *APIServer) buildHandlerChain(stopCh <-chan struct{}) {
 
 	handler := s.Server.Handler
 	handler = filters.WithKubeAPIServer(handler, s.KubernetesClient.Config(), &errorResponder{})
+	if s.dispatcher != nil {
+		handler = filters.WithMultipleClusterDispatcher(handler, s.dispatcher)
+	}
+
+	s.authenticator = newReloadableAuthenticator(s.newAuthenticator(s.Config.AuthenticationOptions))
+	handler = filters.WithAuthentication(handler, s.authenticator)
+	handler = filters.WithRequestInfo(handler, requestInfoResolver)
 
+	s.Server.Handler = handler
+}
+
+func (s *APIServer) newAuthenticator(options *authoptions.AuthenticationOptions) authenticator.Request {
 	authenticators := make([]authenticator.Request, 0)
 	authenticators = append(authenticators, anonymous.NewAuthenticator())
 
@@ -179,40 +412,21 @@ func (s *APIServer) buildHandlerChain(stopCh <-chan struct{}) {
 		authenticators = append(authenticators,
 			//bearertoken.New(devopsbearertoken.New()),
 			bearertoken.New(jwttoken.NewTokenAuthenticator(auth.NewTokenOperator(s.CacheClient,
-				s.Config.AuthenticationOptions),
+				options),
 				s.InformerFactory.KubeSphereSharedInformerFactory().Iam().V1alpha2().Users().Lister())),
 		)
 	default:
 		// TODO error handle
 	}
 
-	handler = filters.WithAuthentication(handler, unionauth.New(authenticators...))
-	handler = filters.WithRequestInfo(handler, requestInfoResolver)
-
-	s.Server.Handler = handler
+	return unionauth.New(authenticators...)
 }
 
 func (s *APIServer) waitForResourceSync(stopCh <-chan struct{}) error {
 	klog.V(0).Info("Start cache objects")
 
-	discoveryClient := s.KubernetesClient.Kubernetes().Discovery()
-	_, apiResourcesList, err := discoveryClient.ServerGroupsAndResources()
-	if err != nil {
-		return err
-	}
-
-	isResourceExists := func(resource schema.GroupVersionResource) bool {
-		for _, apiResource := range apiResourcesList {
-			if apiResource.GroupVersion == resource.GroupVersion().String() {
-				for _, rsc := range apiResource.APIResources {
-					if rsc.Name == resource.Resource {
-						return true
-					}
-				}
-			}
-		}
-		return false
-	}
+	isResourceExists := s.isResourceExists
+	var err error
 
 	// resources we have to create informer first
 	k8sGVRs := []schema.GroupVersionResource{
@@ -269,6 +483,9 @@ func (s *APIServer) waitForResourceSync(stopCh <-chan struct{}) error {
 		{Group: "devops.kubesphere.io", Version: "v1alpha1", Resource: "s2ibuilders"},
 		{Group: "devops.kubesphere.io", Version: "v1alpha3", Resource: "devopsprojects"},
 		{Group: "devops.kubesphere.io", Version: "v1alpha3", Resource: "pipelines"},
+		{Group: "devops.kubesphere.io", Version: "v1alpha3", Resource: "pipelineruns"},
+		{Group: "devops.kubesphere.io", Version: "v1alpha3", Resource: "agenttemplates"},
+		{Group: "devops.kubesphere.io", Version: "v1alpha3", Resource: "notificationpolicies"},
 	}
 
 	// skip caching devops resources if devops not enabled
@@ -318,6 +535,59 @@ func (s *APIServer) waitForResourceSync(stopCh <-chan struct{}) error {
 
 }
 
+// runLeading calls run once this replica holds the lease of the controllers, the controllers are stopped when stopCh
+// is closed. The controllers can't be started again, so the replica losing the lease exits and stands for the
+// election again after it restarts.
+func (s *APIServer) runLeading(stopCh <-chan struct{}, run func(stopCh <-chan struct{})) {
+	hostname, _ := os.Hostname()
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: constants.KubesphereDevOpsNamespace, Name: controllersLeaseName},
+		Client:     s.KubernetesClient.Kubernetes().CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: hostname + "_" + string(uuid.NewUUID())},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   controllersLeaseDuration,
+		RenewDeadline:   controllersLeaseDuration * 2 / 3,
+		RetryPeriod:     controllersLeaseDuration / 7,
+		ReleaseOnCancel: true,
+		Name:            controllersLeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Info("started leading the controllers")
+				run(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() == nil {
+					klog.Fatal("lost the lease of the controllers")
+				}
+				klog.Info("stopped leading the controllers")
+			},
+		},
+	})
+}
+
+// isResourceExists checks if the resource is served by the cluster when the server is prepared
+func (s *APIServer) isResourceExists(resource schema.GroupVersionResource) bool {
+	for _, apiResource := range s.apiResources {
+		if apiResource.GroupVersion == resource.GroupVersion().String() {
+			for _, rsc := range apiResource.APIResources {
+				if rsc.Name == resource.Resource {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func logStackOnRecover(panicReason interface{}, w http.ResponseWriter) {
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("recover from panic situation: - %v\r\n", panicReason))
@@ -0,0 +1,476 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	clusterv1alpha1 "devops.kubesphere.io/plugin/pkg/api/cluster/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+	"devops.kubesphere.io/plugin/pkg/constants"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+const (
+	// authorizationHeader carries the token through the service proxy of kube-apiserver which strips the
+	// authorization header, the member cluster restores it before the authentication
+	authorizationHeader = "X-KubeSphere-Authorization"
+	// rawQueryHeader carries the query of the websocket requests which kube-apiserver loses
+	rawQueryHeader = "X-KubeSphere-Rawquery"
+	// AggregatedHeader marks the requests sent by AggregateList, the member clusters don't aggregate them again
+	AggregatedHeader = "X-KubeSphere-Aggregated"
+)
+
+// Aggregator merges the lists of the member clusters into the lists of the host cluster
+type Aggregator interface {
+	// AggregateList requests the path of the request from every member cluster and appends their items to the
+	// unpaginated result of the host cluster, then sorts and paginates the merged list by the query. The member
+	// clusters failing to respond are reported in the FailedClusters of the result. It fails like the lists of
+	// the host cluster if the continue token of the query is invalid or expired.
+	AggregateList(req *http.Request, q *query.Query, result *api.ListResult) error
+}
+
+// Dispatcher forwards the requests of /clusters/{cluster} to the designated cluster. It should only be used in
+// the host cluster of a multi-cluster environment.
+type Dispatcher interface {
+	Aggregator
+
+	// Dispatch forwards the request to the member cluster, the requests of the host cluster are served by the
+	// handler without the /clusters/{cluster} prefix
+	Dispatch(w http.ResponseWriter, req *http.Request, handler http.Handler)
+}
+
+// ClusterGetter finds the clusters of the multi-cluster environment
+type ClusterGetter interface {
+	GetCluster(name string) (*clusterv1alpha1.Cluster, error)
+	ListClusters() ([]clusterv1alpha1.Cluster, error)
+}
+
+type clusterGetter struct {
+	reader client.Reader
+}
+
+// NewClusterGetter finds the clusters by the reader, e.g. the cache of controller-runtime
+func NewClusterGetter(reader client.Reader) ClusterGetter {
+	return &clusterGetter{reader: reader}
+}
+
+func (g *clusterGetter) GetCluster(name string) (*clusterv1alpha1.Cluster, error) {
+	cluster := &clusterv1alpha1.Cluster{}
+	if err := g.reader.Get(context.Background(), client.ObjectKey{Name: name}, cluster); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (g *clusterGetter) ListClusters() ([]clusterv1alpha1.Cluster, error) {
+	clusters := &clusterv1alpha1.ClusterList{}
+	if err := g.reader.List(context.Background(), clusters); err != nil {
+		return nil, err
+	}
+	return clusters.Items, nil
+}
+
+// memberCluster is how the plugin of a member cluster is reached
+type memberCluster struct {
+	resourceVersion string
+	// endpoint is the KubeSphere API server of the cluster, or its kube-apiserver when proxied is true
+	endpoint  *url.URL
+	transport http.RoundTripper
+	proxied   bool
+}
+
+type clusterDispatcher struct {
+	clusters ClusterGetter
+	options  *Options
+
+	mutex   sync.Mutex
+	members map[string]*memberCluster
+}
+
+func NewClusterDispatcher(clusters ClusterGetter, options *Options) Dispatcher {
+	if options == nil {
+		options = NewOptions()
+	}
+	if options.AggregateTimeout <= 0 {
+		options.AggregateTimeout = DefaultAggregateTimeout
+	}
+	return &clusterDispatcher{
+		clusters: clusters,
+		options:  options,
+		members:  map[string]*memberCluster{},
+	}
+}
+
+func (d *clusterDispatcher) Dispatch(w http.ResponseWriter, req *http.Request, handler http.Handler) {
+	info, _ := request.RequestInfoFrom(req.Context())
+	if info == nil || info.Cluster == "" {
+		http.Error(w, "bad request, empty cluster", http.StatusBadRequest)
+		return
+	}
+
+	cluster, err := d.clusters.GetCluster(info.Cluster)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			http.Error(w, fmt.Sprintf("cluster %s not found", info.Cluster), http.StatusNotFound)
+		} else {
+			klog.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	path := strings.Replace(req.URL.Path, "/clusters/"+info.Cluster, "", 1)
+	if isHostCluster(cluster) {
+		req.URL.Path = path
+		handler.ServeHTTP(w, req)
+		return
+	}
+	if !isClusterReady(cluster) {
+		http.Error(w, fmt.Sprintf("cluster %s is not ready", cluster.Name), http.StatusServiceUnavailable)
+		return
+	}
+	member, err := d.memberOf(cluster)
+	if err != nil {
+		klog.Error(err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	location := d.location(member, path)
+	location.RawQuery = req.URL.RawQuery
+	if member.proxied {
+		d.authorize(req.Header)
+		if httpstream.IsUpgradeRequest(req) && req.URL.RawQuery != "" {
+			req.Header.Set(rawQueryHeader, req.URL.RawQuery)
+		}
+	}
+
+	httpProxy := proxy.NewUpgradeAwareHandler(location, member.transport, false, false, &errorResponder{cluster: cluster.Name})
+	if member.proxied {
+		httpProxy.UpgradeTransport = proxy.NewUpgradeRequestRoundTripper(member.transport, member.transport)
+	}
+	httpProxy.ServeHTTP(w, req)
+}
+
+// memberOf returns how the member cluster is reached, it is cached until the cluster changes
+func (d *clusterDispatcher) memberOf(cluster *clusterv1alpha1.Cluster) (*memberCluster, error) {
+	d.mutex.Lock()
+	member, ok := d.members[cluster.Name]
+	d.mutex.Unlock()
+	if ok && member.resourceVersion == cluster.ResourceVersion {
+		return member, nil
+	}
+
+	connection := cluster.Spec.Connection
+	member = &memberCluster{resourceVersion: cluster.ResourceVersion}
+	if connection.KubeSphereAPIEndpoint != "" {
+		endpoint, err := url.Parse(connection.KubeSphereAPIEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KubeSphere API endpoint of cluster %s: %v", cluster.Name, err)
+		}
+		member.endpoint, member.transport = endpoint, http.DefaultTransport
+	} else if len(connection.KubeConfig) > 0 {
+		config, err := clientcmd.RESTConfigFromKubeConfig(connection.KubeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig of cluster %s: %v", cluster.Name, err)
+		}
+		transport, err := rest.TransportFor(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the transport of cluster %s: %v", cluster.Name, err)
+		}
+		endpoint, err := url.Parse(config.Host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubernetes API endpoint of cluster %s: %v", cluster.Name, err)
+		}
+		member.endpoint, member.transport, member.proxied = endpoint, transport, true
+	} else {
+		return nil, fmt.Errorf("cluster %s has neither the KubeSphere API endpoint nor the kubeconfig", cluster.Name)
+	}
+
+	d.mutex.Lock()
+	d.members[cluster.Name] = member
+	d.mutex.Unlock()
+	return member, nil
+}
+
+// location returns the url of the path in the member cluster, the proxied requests go to the service of the
+// plugin through kube-apiserver
+func (d *clusterDispatcher) location(member *memberCluster, path string) *url.URL {
+	location := *member.endpoint
+	location.Path = strings.TrimSuffix(location.Path, "/") + path
+	if member.proxied {
+		service := d.options.ServiceName
+		if d.options.ServicePort != "" {
+			service += ":" + d.options.ServicePort
+		}
+		location.Path = fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s/proxy%s",
+			strings.TrimSuffix(member.endpoint.Path, "/"), d.options.ServiceNamespace, service, path)
+	}
+	return &location
+}
+
+// authorize moves the token of the user to the header kept by kube-apiserver, the transport authenticates the
+// proxied requests by the kubeconfig of the cluster
+func (d *clusterDispatcher) authorize(header http.Header) {
+	if authorization := header.Get("Authorization"); authorization != "" {
+		header.Set(authorizationHeader, authorization)
+	}
+	header.Del("Authorization")
+}
+
+func (d *clusterDispatcher) AggregateList(req *http.Request, q *query.Query, result *api.ListResult) error {
+	// the requests sent by another aggregator are not aggregated again
+	if req.Header.Get(AggregatedHeader) == "" {
+		d.appendMembers(req, result)
+	}
+	return paginate(result, q)
+}
+
+// appendMembers appends the items of the member clusters to the result
+func (d *clusterDispatcher) appendMembers(req *http.Request, result *api.ListResult) {
+	clusters, err := d.clusters.ListClusters()
+	if err != nil {
+		klog.Errorf("failed to list the clusters: %v", err)
+		return
+	}
+
+	var members []clusterv1alpha1.Cluster
+	for _, cluster := range clusters {
+		if !isHostCluster(&cluster) {
+			members = append(members, cluster)
+		}
+	}
+	if len(members) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), d.options.AggregateTimeout)
+	defer cancel()
+
+	lists := make([]*api.ListResult, len(members))
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i := range members {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lists[i], errs[i] = d.listMember(ctx, req, &members[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, cluster := range members {
+		if errs[i] != nil {
+			klog.Errorf("failed to aggregate the list of cluster %s: %v", cluster.Name, errs[i])
+			result.FailedClusters = append(result.FailedClusters, api.ClusterFailure{
+				Cluster: cluster.Name,
+				Reason:  errs[i].Error(),
+			})
+			continue
+		}
+		for _, item := range lists[i].Items {
+			result.Items = append(result.Items, withClusterLabel(item, cluster.Name))
+		}
+	}
+}
+
+// paginate sorts the merged items by the query and cuts the page of it. The items are sorted by their metadata,
+// the lists sorted by the other fields keep the order of the clusters.
+func paginate(result *api.ListResult, q *query.Query) error {
+	metas := make([]metav1.ObjectMeta, len(result.Items))
+	for i, item := range result.Items {
+		metas[i] = objectMetaOf(item)
+	}
+	if q.IsMetadataSort() {
+		indexes := make([]int, len(result.Items))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		sort.SliceStable(indexes, func(i, j int) bool {
+			if q.Ascending {
+				return !v1alpha3.DefaultObjectMetaCompare(metas[indexes[i]], metas[indexes[j]], q.SortBy)
+			}
+			return v1alpha3.DefaultObjectMetaCompare(metas[indexes[i]], metas[indexes[j]], q.SortBy)
+		})
+		items := make([]interface{}, len(indexes))
+		sorted := make([]metav1.ObjectMeta, len(indexes))
+		for i, index := range indexes {
+			items[i], sorted[i] = result.Items[index], metas[index]
+		}
+		result.Items, metas = items, sorted
+	}
+
+	total := len(result.Items)
+	pagination := q.Pagination
+	if pagination == nil {
+		pagination = query.NoPagination
+	}
+	start, end := pagination.GetValidPagination(total)
+	token, err := q.ContinueToken()
+	if err != nil {
+		return errors.NewBadRequest(err.Error())
+	}
+	if token != nil {
+		if start, err = v1alpha3.ContinueIndex(metas, token, q); err != nil {
+			return err
+		}
+		end = total
+		if pagination.Limit >= 0 && start+pagination.Limit < total {
+			end = start + pagination.Limit
+		}
+	}
+
+	result.TotalItems = total
+	result.Items = result.Items[start:end]
+	result.Continue, result.RemainingItemCount = "", nil
+	if pagination.Limit > 0 && end > 0 && end < total {
+		result.Continue = query.NewContinueToken(q, &metas[end-1]).Encode()
+		remaining := int64(total - end)
+		result.RemainingItemCount = &remaining
+	}
+	return nil
+}
+
+// objectMetaOf returns the metadata of the item, which is an object of the host cluster or decoded from the
+// list of a member cluster
+func objectMetaOf(item interface{}) metav1.ObjectMeta {
+	if accessor, err := meta.Accessor(item); err == nil {
+		return metav1.ObjectMeta{
+			Namespace:         accessor.GetNamespace(),
+			Name:              accessor.GetName(),
+			UID:               accessor.GetUID(),
+			CreationTimestamp: accessor.GetCreationTimestamp(),
+			ResourceVersion:   accessor.GetResourceVersion(),
+		}
+	}
+	objectMeta := metav1.ObjectMeta{}
+	if object, ok := item.(map[string]interface{}); ok {
+		if data, err := json.Marshal(object["metadata"]); err == nil {
+			_ = json.Unmarshal(data, &objectMeta)
+		}
+	}
+	return objectMeta
+}
+
+// listMember requests the same path of the request from the member cluster. The member list isn't paginated,
+// it's merged with the lists of the other clusters before the pagination.
+func (d *clusterDispatcher) listMember(ctx context.Context, req *http.Request, cluster *clusterv1alpha1.Cluster) (*api.ListResult, error) {
+	if !isClusterReady(cluster) {
+		return nil, fmt.Errorf("cluster %s is not ready", cluster.Name)
+	}
+	member, err := d.memberOf(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	location := d.location(member, req.URL.Path)
+	values := req.URL.Query()
+	for _, parameter := range []string{query.ParameterContinue, query.ParameterLimit, query.ParameterPage} {
+		values.Del(parameter)
+	}
+	location.RawQuery = values.Encode()
+	memberReq, err := http.NewRequest(http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	memberReq = memberReq.WithContext(ctx)
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		memberReq.Header.Set("Authorization", authorization)
+	}
+	memberReq.Header.Set(AggregatedHeader, "true")
+	if member.proxied {
+		d.authorize(memberReq.Header)
+	}
+
+	resp, err := member.transport.RoundTrip(memberReq)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s is unreachable: %v", cluster.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cluster %s responded with %s", cluster.Name, resp.Status)
+	}
+
+	list := &api.ListResult{}
+	if err := json.NewDecoder(resp.Body).Decode(list); err != nil {
+		return nil, fmt.Errorf("invalid list of cluster %s: %v", cluster.Name, err)
+	}
+	return list, nil
+}
+
+// withClusterLabel labels the item decoded from the list of a member cluster with the name of the cluster
+func withClusterLabel(item interface{}, cluster string) interface{} {
+	object, ok := item.(map[string]interface{})
+	if !ok {
+		return item
+	}
+	metadata, ok := object["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		object["metadata"] = metadata
+	}
+	labels, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		labels = map[string]interface{}{}
+		metadata["labels"] = labels
+	}
+	labels[constants.ClusterNameLabelKey] = cluster
+	return object
+}
+
+func isHostCluster(cluster *clusterv1alpha1.Cluster) bool {
+	_, ok := cluster.Labels[clusterv1alpha1.HostCluster]
+	return ok
+}
+
+func isClusterReady(cluster *clusterv1alpha1.Cluster) bool {
+	for _, condition := range cluster.Status.Conditions {
+		if condition.Type == clusterv1alpha1.ClusterReady {
+			return condition.Status == "True"
+		}
+	}
+	return false
+}
+
+type errorResponder struct {
+	cluster string
+}
+
+func (e *errorResponder) Error(w http.ResponseWriter, req *http.Request, err error) {
+	klog.Errorf("failed to forward the request to cluster %s: %v", e.cluster, err)
+	http.Error(w, fmt.Sprintf("cluster %s is unreachable: %v", e.cluster, err), http.StatusBadGateway)
+}
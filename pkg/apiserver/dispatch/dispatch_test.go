@@ -0,0 +1,223 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dispatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	clusterv1alpha1 "devops.kubesphere.io/plugin/pkg/api/cluster/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+type fakeClusterGetter struct {
+	clusters []clusterv1alpha1.Cluster
+}
+
+func (g *fakeClusterGetter) GetCluster(name string) (*clusterv1alpha1.Cluster, error) {
+	for i := range g.clusters {
+		if g.clusters[i].Name == name {
+			return &g.clusters[i], nil
+		}
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Resource: clusterv1alpha1.ResourcesPluralCluster}, name)
+}
+
+func (g *fakeClusterGetter) ListClusters() ([]clusterv1alpha1.Cluster, error) {
+	return g.clusters, nil
+}
+
+func newCluster(name string, host, ready bool, endpoint string) clusterv1alpha1.Cluster {
+	cluster := clusterv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: "1"}}
+	if host {
+		cluster.Labels = map[string]string{clusterv1alpha1.HostCluster: ""}
+	}
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	cluster.Status.Conditions = []clusterv1alpha1.ClusterCondition{{Type: clusterv1alpha1.ClusterReady, Status: status}}
+	cluster.Spec.Connection.KubeSphereAPIEndpoint = endpoint
+	return cluster
+}
+
+// newMember serves a list of the devops projects of the cluster, or fails with the status
+func newMember(t *testing.T, projects []string, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(AggregatedHeader) == "" && req.URL.Path != "/kapis/devops.kubesphere.io/v1alpha3/devops" {
+			t.Errorf("unexpected request %s without the aggregated header", req.URL.Path)
+		}
+		if req.URL.Query().Get("limit") != "" {
+			t.Errorf("the member list of %s should not be paginated", req.URL)
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		result := api.ListResult{Items: []interface{}{}, TotalItems: len(projects)}
+		for _, project := range projects {
+			result.Items = append(result.Items, map[string]interface{}{"metadata": map[string]interface{}{"name": project}})
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+}
+
+func TestDispatch(t *testing.T) {
+	member := newMember(t, nil, http.StatusOK)
+	defer member.Close()
+
+	getter := &fakeClusterGetter{clusters: []clusterv1alpha1.Cluster{
+		newCluster("host", true, true, ""),
+		newCluster("member", false, true, member.URL),
+		newCluster("unready", false, false, member.URL),
+		newCluster("unreachable", false, true, "http://127.0.0.1:1"),
+	}}
+	dispatcher := NewClusterDispatcher(getter, nil)
+
+	tests := []struct {
+		name         string
+		cluster      string
+		expectedCode int
+		expectedPath string
+	}{{
+		name:         "host cluster",
+		cluster:      "host",
+		expectedCode: http.StatusOK,
+		expectedPath: "/kapis/devops.kubesphere.io/v1alpha3/devops",
+	}, {
+		name:         "member cluster",
+		cluster:      "member",
+		expectedCode: http.StatusOK,
+	}, {
+		name:         "cluster not found",
+		cluster:      "missing",
+		expectedCode: http.StatusNotFound,
+	}, {
+		name:         "cluster not ready",
+		cluster:      "unready",
+		expectedCode: http.StatusServiceUnavailable,
+	}, {
+		name:         "cluster unreachable",
+		cluster:      "unreachable",
+		expectedCode: http.StatusBadGateway,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/kapis/clusters/"+tt.cluster+"/devops.kubesphere.io/v1alpha3/devops", nil)
+			req = req.WithContext(request.WithRequestInfo(req.Context(), &request.RequestInfo{Cluster: tt.cluster}))
+
+			var servedPath string
+			local := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				servedPath = req.URL.Path
+			})
+			w := httptest.NewRecorder()
+			dispatcher.Dispatch(w, req, local)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("expected code %d, got %d: %s", tt.expectedCode, w.Code, w.Body.String())
+			}
+			if servedPath != tt.expectedPath {
+				t.Errorf("expected the host to serve %q, got %q", tt.expectedPath, servedPath)
+			}
+		})
+	}
+}
+
+func TestAggregateList(t *testing.T) {
+	first := newMember(t, []string{"foo"}, http.StatusOK)
+	defer first.Close()
+	second := newMember(t, []string{"bar", "baz"}, http.StatusOK)
+	defer second.Close()
+	failed := newMember(t, nil, http.StatusInternalServerError)
+	defer failed.Close()
+
+	getter := &fakeClusterGetter{clusters: []clusterv1alpha1.Cluster{
+		newCluster("host", true, true, ""),
+		newCluster("first", false, true, first.URL),
+		newCluster("second", false, true, second.URL),
+		newCluster("failed", false, true, failed.URL),
+		newCluster("unready", false, false, first.URL),
+	}}
+	dispatcher := NewClusterDispatcher(getter, &Options{AggregateTimeout: time.Second})
+
+	req := httptest.NewRequest(http.MethodGet, "/kapis/tenant.kubesphere.io/v1alpha2/workspaces/ws/devops?limit=2", nil)
+	q := &query.Query{SortBy: query.FieldName, Ascending: true, Pagination: &query.Pagination{Limit: 2}}
+	result := &api.ListResult{
+		Items:      []interface{}{&metav1.ObjectMeta{Name: "local"}},
+		TotalItems: 1,
+	}
+	dispatcher.AggregateList(req, q, result)
+
+	if result.TotalItems != 4 || len(result.Items) != 2 {
+		t.Fatalf("expected 2 items of 4 in total, got %d of %d", len(result.Items), result.TotalItems)
+	}
+	if result.RemainingItemCount == nil || *result.RemainingItemCount != 2 || result.Continue == "" {
+		t.Errorf("expected the continue token with 2 items remaining, got %q", result.Continue)
+	}
+	clusters := map[string]string{}
+	var names []string
+	for _, item := range result.Items {
+		metadata := item.(map[string]interface{})["metadata"].(map[string]interface{})
+		names = append(names, metadata["name"].(string))
+		clusters[metadata["name"].(string)] = metadata["labels"].(map[string]interface{})[constants.ClusterNameLabelKey].(string)
+	}
+	if !reflect.DeepEqual(names, []string{"bar", "baz"}) {
+		t.Errorf("expected the first page [bar baz] of the merged list, got %v", names)
+	}
+	if clusters["bar"] != "second" || clusters["baz"] != "second" {
+		t.Errorf("unexpected cluster labels %v", clusters)
+	}
+	if len(result.FailedClusters) != 2 || result.FailedClusters[0].Cluster != "failed" ||
+		result.FailedClusters[1].Cluster != "unready" {
+		t.Errorf("unexpected failed clusters %v", result.FailedClusters)
+	}
+
+	// the next page continues from the last item of the merged list
+	q.Continue = result.Continue
+	next := &api.ListResult{Items: []interface{}{&metav1.ObjectMeta{Name: "local"}}, TotalItems: 1}
+	dispatcher.AggregateList(req, q, next)
+	if len(next.Items) != 2 || next.Continue != "" {
+		t.Fatalf("expected the last 2 items without the continue token, got %v", next)
+	}
+	if name := next.Items[0].(map[string]interface{})["metadata"].(map[string]interface{})["name"]; name != "foo" {
+		t.Errorf("expected the second page starts from foo, got %v", name)
+	}
+	if local, ok := next.Items[1].(*metav1.ObjectMeta); !ok || local.Name != "local" {
+		t.Errorf("expected the item of the host cluster at last, got %v", next.Items[1])
+	}
+
+	// the requests sent by another aggregator are not aggregated again
+	aggregated := &api.ListResult{Items: []interface{}{}}
+	req.Header.Set(AggregatedHeader, "true")
+	dispatcher.AggregateList(req, &query.Query{Pagination: query.NoPagination}, aggregated)
+	if len(aggregated.Items) != 0 || len(aggregated.FailedClusters) != 0 {
+		t.Errorf("expected no aggregation, got %v", aggregated)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dispatch
+
+import (
+	"fmt"
+	"time"
+
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+const (
+	// DefaultServiceName is the service of the plugin in the member clusters
+	DefaultServiceName = "ks-devops-plugin"
+	// DefaultServicePort is the port of the service of the plugin
+	DefaultServicePort = "http"
+	// DefaultAggregateTimeout is the time to wait for the member clusters to respond the aggregated lists
+	DefaultAggregateTimeout = 10 * time.Second
+)
+
+// Options configures the multi-cluster environment. The requests of /kapis/clusters/{cluster} are forwarded to the
+// KubeSphere API server of the member cluster, or to the plugin through the service proxy of kube-apiserver if the
+// cluster has no KubeSphere API endpoint.
+type Options struct {
+	// Enable dispatches the requests to the member clusters, this is only for the host cluster
+	Enable bool `json:"enable" yaml:"enable" mapstructure:"enable"`
+	// ServiceNamespace, ServiceName and ServicePort locate the plugin in the member clusters
+	ServiceNamespace string        `json:"serviceNamespace,omitempty" yaml:"serviceNamespace,omitempty" mapstructure:"serviceNamespace"`
+	ServiceName      string        `json:"serviceName,omitempty" yaml:"serviceName,omitempty" mapstructure:"serviceName"`
+	ServicePort      string        `json:"servicePort,omitempty" yaml:"servicePort,omitempty" mapstructure:"servicePort"`
+	AggregateTimeout time.Duration `json:"aggregateTimeout,omitempty" yaml:"aggregateTimeout,omitempty" mapstructure:"aggregateTimeout"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		ServiceNamespace: constants.KubesphereDevOpsNamespace,
+		ServiceName:      DefaultServiceName,
+		ServicePort:      DefaultServicePort,
+		AggregateTimeout: DefaultAggregateTimeout,
+	}
+}
+
+// Validate check options
+func (o *Options) Validate() []error {
+	errors := make([]error, 0)
+	if o == nil || !o.Enable {
+		return errors
+	}
+
+	if o.ServiceNamespace == "" || o.ServiceName == "" {
+		errors = append(errors, fmt.Errorf("serviceNamespace and serviceName of multicluster are required"))
+	}
+	if o.AggregateTimeout < 0 {
+		errors = append(errors, fmt.Errorf("aggregateTimeout of multicluster must not be negative"))
+	}
+	return errors
+}
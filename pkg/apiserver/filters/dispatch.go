@@ -0,0 +1,47 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filters
+
+import (
+	"net/http"
+
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/apiserver/dispatch"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+	"devops.kubesphere.io/plugin/pkg/server/errors"
+)
+
+// WithMultipleClusterDispatcher forwards the requests of /clusters/{cluster} to the designated cluster
+func WithMultipleClusterDispatcher(handler http.Handler, dispatcher dispatch.Dispatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := request.RequestInfoFrom(req.Context())
+		if !ok {
+			err := errors.New("Unable to retrieve request info from request")
+			klog.Error(err)
+			responsewriters.InternalError(w, req, err)
+			return
+		}
+
+		if info.Cluster == "" {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		dispatcher.Dispatch(w, req, handler)
+	})
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// continueTokenVersion is increased if the format of ContinueToken is changed incompatibly
+const continueTokenVersion = 1
+
+// ContinueToken is the position after the last item of previous page. It is encoded into an opaque string
+// which is returned with the page and sent back by the continue parameter to fetch the next page.
+type ContinueToken struct {
+	Version   int   `json:"v"`
+	SortBy    Field `json:"sortBy,omitempty"`
+	Ascending bool  `json:"ascending,omitempty"`
+
+	// sort key and identity of the last item
+	UID               string      `json:"uid,omitempty"`
+	Namespace         string      `json:"namespace,omitempty"`
+	Name              string      `json:"name"`
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+	ResourceVersion   string      `json:"resourceVersion,omitempty"`
+}
+
+// NewContinueToken returns the token which starts after the object
+func NewContinueToken(q *Query, object metav1.Object) *ContinueToken {
+	return &ContinueToken{
+		Version:           continueTokenVersion,
+		SortBy:            q.SortBy,
+		Ascending:         q.Ascending,
+		UID:               string(object.GetUID()),
+		Namespace:         object.GetNamespace(),
+		Name:              object.GetName(),
+		CreationTimestamp: object.GetCreationTimestamp(),
+		ResourceVersion:   object.GetResourceVersion(),
+	}
+}
+
+func (t *ContinueToken) Encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ObjectMeta returns the sort key of the last item
+func (t *ContinueToken) ObjectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace:         t.Namespace,
+		Name:              t.Name,
+		CreationTimestamp: t.CreationTimestamp,
+	}
+}
+
+// IsMetadataSort returns true if the items are sorted by the metadata of them, so the position of
+// a deleted item can be found by its sort key
+func (t *ContinueToken) IsMetadataSort() bool {
+	return isMetadataSort(t.SortBy)
+}
+
+// IsMetadataSort returns true if the query sorts the items by the metadata of them
+func (q *Query) IsMetadataSort() bool {
+	return isMetadataSort(q.SortBy)
+}
+
+func isMetadataSort(sortBy Field) bool {
+	switch sortBy {
+	case "", FieldName, FieldCreationTimeStamp, FieldCreateTime:
+		return true
+	default:
+		return false
+	}
+}
+
+// DecodeContinueToken decodes the continue parameter, the sort of query must be the same as the
+// one when the token was issued
+func DecodeContinueToken(value string, q *Query) (*ContinueToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid continue token: %v", err)
+	}
+
+	token := &ContinueToken{}
+	if err = json.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("invalid continue token: %v", err)
+	}
+	if token.Version != continueTokenVersion {
+		return nil, fmt.Errorf("continue token version %d is not supported", token.Version)
+	}
+	if token.SortBy != q.SortBy || token.Ascending != q.Ascending {
+		return nil, fmt.Errorf("continue token was issued for sortBy=%s&ascending=%v, the sort can not be changed",
+			token.SortBy, token.Ascending)
+	}
+	return token, nil
+}
+
+// ContinueToken returns the decoded continue parameter, or nil if it is absent
+func (q *Query) ContinueToken() (*ContinueToken, error) {
+	if q.Continue == "" {
+		return nil, nil
+	}
+	return DecodeContinueToken(q.Continue, q)
+}
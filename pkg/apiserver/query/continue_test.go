@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestContinueToken(t *testing.T) {
+	q := &Query{SortBy: FieldName, Ascending: true}
+	object := &metav1.ObjectMeta{
+		Name:              "foo",
+		Namespace:         "bar",
+		UID:               "a8a8d6cf",
+		ResourceVersion:   "42",
+		CreationTimestamp: metav1.NewTime(time.Unix(1600000000, 0)),
+	}
+	token := NewContinueToken(q, object)
+
+	q.Continue = token.Encode()
+	got, err := q.ContinueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(token, got); diff != "" {
+		t.Errorf("%T differ (-expected, +got): %s", token, diff)
+	}
+
+	tests := []struct {
+		description string
+		query       *Query
+	}{
+		{"sort field changed", &Query{SortBy: FieldCreationTimeStamp, Ascending: true, Continue: q.Continue}},
+		{"order changed", &Query{SortBy: FieldName, Continue: q.Continue}},
+		{"not base64", &Query{SortBy: FieldName, Ascending: true, Continue: "foo!"}},
+		{"not json", &Query{SortBy: FieldName, Ascending: true, Continue: "Zm9v"}},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if _, err := test.query.ContinueToken(); err == nil {
+				t.Errorf("expected error, got nil")
+			}
+		})
+	}
+
+	if token, err := New().ContinueToken(); token != nil || err != nil {
+		t.Errorf("expected no token, got %v, %v", token, err)
+	}
+}
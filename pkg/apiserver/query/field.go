@@ -35,6 +35,19 @@ const (
 	FieldOwnerKind           = "ownerKind"
 
 	FieldType = "type"
+
+	// fields of devops resources
+	FieldSourceType     = "sourceType"
+	FieldSyncStatus     = "syncStatus"
+	FieldSyncTime       = "syncTime"
+	FieldState          = "state"
+	FieldBuilderName    = "builderName"
+	FieldTemplateName   = "templateName"
+	FieldCodeFramework  = "codeFramework"
+	FieldStartTime      = "startTime"
+	FieldCompletionTime = "completionTime"
+	FieldUploadTime     = "uploadTime"
+	FieldRunCount       = "runCount"
 )
 
 var SortableFields = []Field{
@@ -43,6 +56,11 @@ var SortableFields = []Field{
 	FieldUpdateTime,
 	FieldLastUpdateTimestamp,
 	FieldName,
+	FieldSyncTime,
+	FieldStartTime,
+	FieldCompletionTime,
+	FieldUploadTime,
+	FieldRunCount,
 }
 
 // Field contains all the query field that can be compared
@@ -55,4 +73,11 @@ var ComparableFields = []Field{
 	FieldStatus,
 	FieldOwnerReference,
 	FieldOwnerKind,
+	FieldType,
+	FieldSourceType,
+	FieldSyncStatus,
+	FieldState,
+	FieldBuilderName,
+	FieldTemplateName,
+	FieldCodeFramework,
 }
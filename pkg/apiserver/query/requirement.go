@@ -0,0 +1,255 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/klog"
+)
+
+type Operator string
+
+const (
+	// OperatorIn matches if the field equals to any of the values, the equality is decided by the resource,
+	// e.g. name=foo means the name contains foo in the v1alpha3 APIs
+	OperatorIn Operator = "in"
+	// OperatorPrefix matches if the value of field starts with the value
+	OperatorPrefix Operator = "prefix"
+	// OperatorRegex matches if the value of field matches the regular expression
+	OperatorRegex Operator = "regex"
+)
+
+// negationSuffix is the suffix of key which negates the requirement, e.g. status!=Running
+const negationSuffix = "!"
+
+var requirementRegex = regexp.MustCompile(`^(in|notin|prefix|regex)\((.*)\)$`)
+
+// Requirement is a filter which can not be expressed by a single key value pair. status=Running&status=Failed
+// or status=in(Running,Failed) matches if the field has any of the values, status=notin(Running,Failed) matches
+// if it has none of them. The suffix ! of key negates any requirement, e.g. status!=Running. name=prefix(foo)
+// matches if the value of field starts with foo, and name=regex(^foo-[0-9]+$) matches the regular expression.
+// Fields of prefix and regex are name, namespace, uid, ownerKind, ownerReference or the dot separated
+// JSON path of the object, such as spec.type or metadata.labels.app.
+type Requirement struct {
+	Field    Field
+	Operator Operator
+	Values   []string
+	Negated  bool
+}
+
+// ParseRequirement parses the filter of key with its values, ok is false if it is a plain key value pair
+func ParseRequirement(key string, values []string) (requirement Requirement, ok bool) {
+	requirement.Negated = strings.HasSuffix(key, negationSuffix)
+	requirement.Field = Field(strings.TrimSuffix(key, negationSuffix))
+	requirement.Operator = OperatorIn
+
+	if len(values) != 1 {
+		requirement.Values = values
+		return requirement, true
+	}
+
+	groups := requirementRegex.FindStringSubmatch(values[0])
+	if len(groups) != 3 {
+		requirement.Values = values
+		return requirement, requirement.Negated
+	}
+
+	switch groups[1] {
+	case "notin":
+		requirement.Negated = !requirement.Negated
+		fallthrough
+	case "in":
+		for _, value := range strings.Split(groups[2], ",") {
+			requirement.Values = append(requirement.Values, strings.TrimSpace(value))
+		}
+	default:
+		requirement.Operator = Operator(groups[1])
+		requirement.Values = []string{groups[2]}
+	}
+	return requirement, true
+}
+
+func (r Requirement) String() string {
+	key := string(r.Field)
+	if r.Negated {
+		key += negationSuffix
+	}
+	return fmt.Sprintf("%s=%s(%s)", key, r.Operator, strings.Join(r.Values, ","))
+}
+
+// Matcher evaluates the requirements and field selector against objects
+type Matcher struct {
+	requirements []Requirement
+	regexps      map[int]*regexp.Regexp
+	selector     fields.Selector
+}
+
+// NewMatcher compiles the requirements and field selector, an invalid regular expression or field selector
+// is an error, otherwise the filter would silently match more objects than requested.
+func NewMatcher(requirements []Requirement, fieldSelector string) (*Matcher, error) {
+	matcher := &Matcher{regexps: make(map[int]*regexp.Regexp)}
+
+	for _, requirement := range requirements {
+		if requirement.Operator == OperatorRegex {
+			expression, err := regexp.Compile(requirement.Values[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid requirement %s: %v", requirement, err)
+			}
+			matcher.regexps[len(matcher.requirements)] = expression
+		}
+		matcher.requirements = append(matcher.requirements, requirement)
+	}
+
+	if fieldSelector != "" {
+		selector, err := fields.ParseSelector(fieldSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field selector %s: %v", fieldSelector, err)
+		}
+		if !selector.Empty() {
+			matcher.selector = selector
+		}
+	}
+	return matcher, nil
+}
+
+// Empty returns true if the matcher matches everything
+func (m *Matcher) Empty() bool {
+	return len(m.requirements) == 0 && m.selector == nil
+}
+
+// Matches reports whether the object meets all the requirements and the field selector, test reports
+// whether the object matches the filter with the equality semantics of the resource.
+func (m *Matcher) Matches(object interface{}, test func(Filter) bool) bool {
+	values := newFieldValues(object)
+
+	for i, requirement := range m.requirements {
+		matched := false
+		switch requirement.Operator {
+		case OperatorPrefix:
+			for _, value := range values.get(string(requirement.Field)) {
+				if strings.HasPrefix(value, requirement.Values[0]) {
+					matched = true
+					break
+				}
+			}
+		case OperatorRegex:
+			for _, value := range values.get(string(requirement.Field)) {
+				if m.regexps[i].MatchString(value) {
+					matched = true
+					break
+				}
+			}
+		default:
+			for _, value := range requirement.Values {
+				if test(Filter{Field: requirement.Field, Value: Value(value)}) {
+					matched = true
+					break
+				}
+			}
+		}
+		if matched == requirement.Negated {
+			return false
+		}
+	}
+
+	if m.selector != nil {
+		for _, requirement := range m.selector.Requirements() {
+			matched := false
+			for _, value := range values.get(requirement.Field) {
+				if value == requirement.Value {
+					matched = true
+					break
+				}
+			}
+			if matched != (requirement.Operator != selection.NotEquals) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// aliases of the fields which are not JSON paths
+var fieldPaths = map[string]string{
+	FieldName:           "metadata.name",
+	FieldNamespace:      "metadata.namespace",
+	FieldUID:            "metadata.uid",
+	FieldOwnerKind:      "metadata.ownerReferences.kind",
+	FieldOwnerReference: "metadata.ownerReferences.uid",
+}
+
+// fieldValues converts the object into unstructured content lazily, then finds the values by JSON path
+type fieldValues struct {
+	object  interface{}
+	content map[string]interface{}
+}
+
+func newFieldValues(object interface{}) *fieldValues {
+	return &fieldValues{object: object}
+}
+
+// get returns the values of the dot separated JSON path, values of all the elements are returned
+// if there is an array in the path. Keys containing dots, like the labels app.kubernetes.io/name,
+// are matched by the longest key.
+func (f *fieldValues) get(path string) []string {
+	if alias, ok := fieldPaths[path]; ok {
+		path = alias
+	}
+
+	if f.content == nil {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(f.object)
+		if err != nil {
+			klog.V(4).Infof("failed to convert object %T: %v", f.object, err)
+			content = map[string]interface{}{}
+		}
+		f.content = content
+	}
+
+	var values []string
+	collectValues(f.content, strings.Split(path, "."), &values)
+	return values
+}
+
+func collectValues(node interface{}, segments []string, values *[]string) {
+	switch typed := node.(type) {
+	case []interface{}:
+		for _, element := range typed {
+			collectValues(element, segments, values)
+		}
+	case map[string]interface{}:
+		if len(segments) == 0 {
+			return
+		}
+		for i := len(segments); i > 0; i-- {
+			if child, ok := typed[strings.Join(segments[:i], ".")]; ok {
+				collectValues(child, segments[i:], values)
+				return
+			}
+		}
+	case nil:
+	default:
+		if len(segments) == 0 {
+			*values = append(*values, fmt.Sprint(typed))
+		}
+	}
+}
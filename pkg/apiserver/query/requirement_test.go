@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		key      string
+		values   []string
+		expected Requirement
+		ok       bool
+	}{
+		{"status", []string{"Running"}, Requirement{Field: "status", Operator: OperatorIn, Values: []string{"Running"}}, false},
+		{"status", []string{"Running", "Failed"}, Requirement{Field: "status", Operator: OperatorIn, Values: []string{"Running", "Failed"}}, true},
+		{"status", []string{"in(Running, Failed)"}, Requirement{Field: "status", Operator: OperatorIn, Values: []string{"Running", "Failed"}}, true},
+		{"status", []string{"notin(Running)"}, Requirement{Field: "status", Operator: OperatorIn, Values: []string{"Running"}, Negated: true}, true},
+		{"status!", []string{"Running"}, Requirement{Field: "status", Operator: OperatorIn, Values: []string{"Running"}, Negated: true}, true},
+		{"status!", []string{"notin(Running)"}, Requirement{Field: "status", Operator: OperatorIn, Values: []string{"Running"}}, true},
+		{"name", []string{"prefix(foo)"}, Requirement{Field: "name", Operator: OperatorPrefix, Values: []string{"foo"}}, true},
+		{"name!", []string{"regex(^foo-(a|b)$)"}, Requirement{Field: "name", Operator: OperatorRegex, Values: []string{"^foo-(a|b)$"}, Negated: true}, true},
+		{"label", []string{"app=in"}, Requirement{Field: "label", Operator: OperatorIn, Values: []string{"app=in"}}, false},
+	}
+
+	for _, test := range tests {
+		got, ok := ParseRequirement(test.key, test.values)
+		if ok != test.ok {
+			t.Errorf("%s=%v, expected ok %v, got %v", test.key, test.values, test.ok, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s=%v, expected %+v, got %+v", test.key, test.values, test.expected, got)
+		}
+	}
+}
+
+type testObject struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec testSpec `json:"spec,omitempty"`
+}
+
+type testSpec struct {
+	Type    string   `json:"type,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+func TestMatcher(t *testing.T) {
+	object := &testObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo-1",
+			Namespace: "bar",
+			Labels:    map[string]string{"app.kubernetes.io/name": "book"},
+		},
+		Spec: testSpec{
+			Type:    "pipeline",
+			Sources: []string{"git", "svn"},
+		},
+	}
+	// equality of the resource, name contains the value
+	test := func(filter Filter) bool {
+		return filter.Field == FieldName && strings.Contains(object.Name, string(filter.Value))
+	}
+
+	tests := []struct {
+		description   string
+		requirements  []Requirement
+		fieldSelector string
+		expected      bool
+	}{
+		{"empty", nil, "", true},
+		{"in", []Requirement{{Field: FieldName, Operator: OperatorIn, Values: []string{"xxx", "foo"}}}, "", true},
+		{"notin", []Requirement{{Field: FieldName, Operator: OperatorIn, Values: []string{"xxx", "foo"}, Negated: true}}, "", false},
+		{"prefix", []Requirement{{Field: FieldName, Operator: OperatorPrefix, Values: []string{"foo-"}}}, "", true},
+		{"negated prefix", []Requirement{{Field: FieldNamespace, Operator: OperatorPrefix, Values: []string{"ba"}, Negated: true}}, "", false},
+		{"regex", []Requirement{{Field: "spec.type", Operator: OperatorRegex, Values: []string{"^pipe.*$"}}}, "", true},
+		{"regex of array", []Requirement{{Field: "spec.sources", Operator: OperatorRegex, Values: []string{"^sv"}}}, "", true},
+		{"field selector", nil, "spec.type=pipeline,metadata.namespace!=default", true},
+		{"field selector of label with dots", nil, "metadata.labels.app.kubernetes.io/name=book", true},
+		{"field selector mismatch", nil, "spec.type=multi-branch-pipeline", false},
+		{"field selector of missing field", nil, "spec.missing!=foo", true},
+		{"requirements and field selector", []Requirement{{Field: FieldName, Operator: OperatorIn, Values: []string{"foo"}}}, "spec.type!=pipeline", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			matcher, err := NewMatcher(tt.requirements, tt.fieldSelector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matcher.Matches(object, test); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInvalidMatcher(t *testing.T) {
+	tests := []struct {
+		description   string
+		requirements  []Requirement
+		fieldSelector string
+	}{
+		{"invalid regex", []Requirement{{Field: FieldName, Operator: OperatorRegex, Values: []string{"(foo"}}}, ""},
+		{"invalid field selector", nil, "spec.type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if _, err := NewMatcher(tt.requirements, tt.fieldSelector); err == nil {
+				t.Errorf("expected the invalid filter is rejected")
+			}
+		})
+	}
+}
@@ -33,6 +33,7 @@ const (
 	ParameterLimit         = "limit"
 	ParameterOrderBy       = "sortBy"
 	ParameterAscending     = "ascending"
+	ParameterContinue      = "continue"
 )
 
 // Query represents api search terms
@@ -49,6 +50,16 @@ type Query struct {
 	Filters map[Field]Value
 
 	LabelSelector string
+
+	// Requirements are the filters with multiple values, negation, prefix or regex
+	Requirements []Requirement
+
+	// FieldSelector selects objects by the JSON paths of them, e.g. spec.type=pipeline,status.phase!=Ready
+	FieldSelector string
+
+	// Continue is the opaque token returned with the previous page, the next page starts after
+	// the last item of previous one instead of the offset
+	Continue string
 }
 
 type Pagination struct {
@@ -77,6 +88,11 @@ func (q *Query) Selector() labels.Selector {
 	}
 }
 
+// Matcher returns the matcher of requirements and field selector
+func (q *Query) Matcher() (*Matcher, error) {
+	return NewMatcher(q.Requirements, q.FieldSelector)
+}
+
 func (p *Pagination) GetValidPagination(total int) (startIndex, endIndex int) {
 
 	// no pagination
@@ -139,12 +155,16 @@ func ParseQueryParameter(request *restful.Request) *Query {
 	}
 
 	query.LabelSelector = request.QueryParameter(ParameterLabelSelector)
+	query.FieldSelector = request.QueryParameter(ParameterFieldSelector)
+	query.Continue = request.QueryParameter(ParameterContinue)
 
 	for key, values := range request.Request.URL.Query() {
-		if !sliceutil.HasString([]string{ParameterPage, ParameterLimit, ParameterOrderBy, ParameterAscending, ParameterLabelSelector}, key) {
+		if !sliceutil.HasString([]string{ParameterPage, ParameterLimit, ParameterOrderBy, ParameterAscending, ParameterLabelSelector, ParameterFieldSelector, ParameterContinue}, key) {
 			// support multiple query condition
-			for _, value := range values {
-				query.Filters[Field(key)] = Value(value)
+			if requirement, ok := ParseRequirement(key, values); ok {
+				query.Requirements = append(query.Requirements, requirement)
+			} else {
+				query.Filters[Field(key)] = Value(values[0])
 			}
 		}
 	}
@@ -45,6 +45,22 @@ func TestParseQueryParameter(t *testing.T) {
 				},
 			},
 		},
+		{
+			"test requirements and field selector",
+			"status=Running&status=Failed&name=foo&fieldSelector=spec.type%3Dpipeline",
+			&Query{
+				Pagination: NoPagination,
+				SortBy:     FieldCreationTimeStamp,
+				Ascending:  false,
+				Filters: map[Field]Value{
+					FieldName: Value("foo"),
+				},
+				Requirements: []Requirement{
+					{Field: FieldStatus, Operator: OperatorIn, Values: []string{"Running", "Failed"}},
+				},
+				FieldSelector: "spec.type=pipeline",
+			},
+		},
 		{
 			"test bad case",
 			"xxxx=xxxx&dsfsw=xxxx&page=abc&limit=add&ascending=ssss",
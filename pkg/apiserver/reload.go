@@ -0,0 +1,184 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/client/cache"
+	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins"
+	apiserverconfig "devops.kubesphere.io/plugin/pkg/config"
+)
+
+const (
+	sectionJenkins        = "devops"
+	sectionAuthentication = "authentication"
+	sectionRedis          = "redis"
+)
+
+// reloadableSections are the sections of the configuration applied without restarting the apiserver
+var reloadableSections = sets.NewString(sectionJenkins, sectionAuthentication, sectionRedis)
+
+// reloadState is the configuration in effect, which differs from Config once it is reloaded
+type reloadState struct {
+	// mutex serializes the reloads
+	mutex sync.Mutex
+
+	effective atomic.Value
+	status    apiserverconfig.ReloadStatus
+	// statusMutex guards status, which is read by the requests during a reload
+	statusMutex sync.RWMutex
+}
+
+// reloadableAuthenticator forwards to the authenticator built from the authentication options in effect
+type reloadableAuthenticator struct {
+	current atomic.Value
+}
+
+func newReloadableAuthenticator(current authenticator.Request) *reloadableAuthenticator {
+	a := &reloadableAuthenticator{}
+	a.current.Store(current)
+	return a
+}
+
+func (a *reloadableAuthenticator) AuthenticateRequest(req *http.Request) (*authenticator.Response, bool, error) {
+	return a.current.Load().(authenticator.Request).AuthenticateRequest(req)
+}
+
+// EffectiveConfig returns the configuration in effect, the sections which are not reloadable keep the values
+// loaded at startup
+func (s *APIServer) EffectiveConfig() *apiserverconfig.Config {
+	if conf, ok := s.reloadState.effective.Load().(*apiserverconfig.Config); ok {
+		return conf
+	}
+	return s.Config
+}
+
+// ReloadStatus returns the result of the last reload
+func (s *APIServer) ReloadStatus() apiserverconfig.ReloadStatus {
+	s.reloadState.statusMutex.RLock()
+	defer s.reloadState.statusMutex.RUnlock()
+	return s.reloadState.status
+}
+
+// Reload applies the changes of the Jenkins, authentication and redis options. The changed sections are
+// validated first, then the clients are created, and they replace the current ones at once only if all of them
+// are created. The Jenkins backends, the workspaces mapped to them and the ttl of the cached responses are all
+// applied. The changes of the other sections are logged and take effect after restart.
+func (s *APIServer) Reload(conf *apiserverconfig.Config) (err error) {
+	s.reloadState.mutex.Lock()
+	defer s.reloadState.mutex.Unlock()
+
+	current := s.EffectiveConfig()
+	changes := current.Diff(conf)
+	if len(changes) == 0 {
+		return nil
+	}
+	defer func() {
+		s.recordReload(err)
+	}()
+
+	klog.Infof("configuration changed: %s", strings.Join(changes, ", "))
+	sections := sets.NewString()
+	for _, change := range changes {
+		sections.Insert(strings.Split(change, ".")[0])
+	}
+	if restart := sections.Difference(reloadableSections); restart.Len() > 0 {
+		klog.Warningf("the changes of %s take effect after restarting the apiserver", strings.Join(restart.List(), ", "))
+	}
+	reloaded := sections.Intersection(reloadableSections)
+	if errs := conf.ValidateSections(reloaded.List()); len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %v", utilerrors.NewAggregate(errs))
+	}
+
+	effective := *current
+	if reloaded.Has(sectionJenkins) {
+		if s.JenkinsBackends == nil || s.JenkinsResolver == nil || s.ResponseCache == nil ||
+			conf.JenkinsOptions == nil || conf.JenkinsOptions.Host == "" {
+			return fmt.Errorf("jenkins can not be enabled or disabled without restarting the apiserver")
+		}
+		effective.JenkinsOptions = conf.JenkinsOptions
+	}
+	if reloaded.Has(sectionAuthentication) {
+		if conf.AuthenticationOptions == nil {
+			return fmt.Errorf("authentication options are required")
+		}
+		effective.AuthenticationOptions = conf.AuthenticationOptions
+	}
+	if reloaded.Has(sectionRedis) {
+		effective.RedisOptions = conf.RedisOptions
+	}
+
+	// nothing is replaced until all the clients are created
+	backends := s.JenkinsBackends
+	if reloaded.Has(sectionJenkins) {
+		if backends, err = jenkins.NewBackendRegistry(effective.JenkinsOptions); err != nil {
+			return err
+		}
+	}
+	var tokenAuthenticator authenticator.Request
+	if reloaded.Has(sectionAuthentication) && s.authenticator != nil {
+		tokenAuthenticator = s.newAuthenticator(effective.AuthenticationOptions)
+	}
+	// the cache is replaced last as it can't be rolled back, the other replacements never fail
+	if reloaded.Has(sectionRedis) {
+		swappable, ok := s.CacheClient.(*cache.Swappable)
+		if !ok {
+			return fmt.Errorf("the cache can not be replaced without restarting the apiserver")
+		}
+		if err = swappable.Reset(effective.RedisOptions); err != nil {
+			return fmt.Errorf("failed to connect to redis service, please check redis status, error: %v", err)
+		}
+	}
+
+	if backends != s.JenkinsBackends {
+		s.JenkinsBackends.Replace(backends)
+		if workspaces := effective.JenkinsOptions.Workspaces(); !reflect.DeepEqual(current.JenkinsOptions.Workspaces(), workspaces) {
+			s.JenkinsResolver.SetWorkspaces(workspaces)
+		}
+		s.ResponseCache.SetTTL(effective.JenkinsOptions.ResponseCacheTTL)
+	}
+	if tokenAuthenticator != nil {
+		s.authenticator.current.Store(tokenAuthenticator)
+	}
+	s.reloadState.effective.Store(&effective)
+	klog.Infof("configuration reloaded: %s", strings.Join(reloaded.List(), ", "))
+	return nil
+}
+
+func (s *APIServer) recordReload(err error) {
+	now := time.Now()
+	s.reloadState.statusMutex.Lock()
+	defer s.reloadState.statusMutex.Unlock()
+	s.reloadState.status.LastReloadTime = &now
+	s.reloadState.status.LastReloadError = ""
+	if err != nil {
+		klog.Errorf("failed to reload configuration: %v", err)
+		s.reloadState.status.LastReloadError = err.Error()
+	}
+}
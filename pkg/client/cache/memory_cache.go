@@ -0,0 +1,225 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key         string
+	value       string
+	neverExpire bool
+	expiredAt   time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.neverExpire && !now.Before(e.expiredAt)
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// memoryCache implements cache.Interface in memory, it is safe for concurrent use.
+// The least recently used entries are evicted once the number of entries or the
+// size of keys and values exceeds the limits, and expired entries are removed
+// by a background janitor.
+type memoryCache struct {
+	mutex sync.Mutex
+	// front of the list is the most recently used entry
+	lru   *list.List
+	items map[string]*list.Element
+	// total size of keys and values in bytes
+	memory int64
+
+	maxEntries int
+	maxMemory  int64
+}
+
+// NewMemoryCache creates an in memory cache, the janitor stops when stopCh is closed
+func NewMemoryCache(options *MemoryOptions, stopCh <-chan struct{}) Interface {
+	if options == nil {
+		options = NewMemoryOptions()
+	}
+
+	c := &memoryCache{
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		maxEntries: options.MaxEntries,
+		maxMemory:  options.MaxMemory,
+	}
+
+	if options.CleanupInterval > 0 && stopCh != nil {
+		go c.janitor(options.CleanupInterval, stopCh)
+	}
+
+	return c
+}
+
+func (c *memoryCache) janitor(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.deleteExpired()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (c *memoryCache) deleteExpired() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for _, element := range c.items {
+		if element.Value.(*memoryEntry).expired(now) {
+			c.removeElement(element)
+		}
+	}
+}
+
+func (c *memoryCache) Keys(pattern string) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	var keys []string
+	for key, element := range c.items {
+		if element.Value.(*memoryEntry).expired(now) {
+			continue
+		}
+		if globMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (c *memoryCache) Get(key string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.getElement(key)
+	if !ok {
+		return "", ErrNoSuchKey
+	}
+
+	c.lru.MoveToFront(element)
+	return element.Value.(*memoryEntry).value, nil
+}
+
+func (c *memoryCache) Set(key string, value string, duration time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &memoryEntry{
+		key:         key,
+		value:       value,
+		neverExpire: duration == NeverExpire,
+		expiredAt:   time.Now().Add(duration),
+	}
+
+	if element, ok := c.items[key]; ok {
+		c.memory -= element.Value.(*memoryEntry).size()
+		element.Value = entry
+		c.lru.MoveToFront(element)
+	} else {
+		c.items[key] = c.lru.PushFront(entry)
+	}
+	c.memory += entry.size()
+
+	c.evict()
+	return nil
+}
+
+func (c *memoryCache) Del(keys ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range keys {
+		if element, ok := c.items[key]; ok {
+			c.removeElement(element)
+		}
+	}
+	return nil
+}
+
+func (c *memoryCache) Exists(keys ...string) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range keys {
+		if _, ok := c.getElement(key); !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *memoryCache) Expire(key string, duration time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.getElement(key)
+	if !ok {
+		return ErrNoSuchKey
+	}
+
+	entry := element.Value.(*memoryEntry)
+	entry.neverExpire = duration == NeverExpire
+	entry.expiredAt = time.Now().Add(duration)
+	return nil
+}
+
+// getElement returns the element of key if it exists and not expired,
+// expired element will be removed. The caller must hold the lock.
+func (c *memoryCache) getElement(key string) (*list.Element, bool) {
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if element.Value.(*memoryEntry).expired(time.Now()) {
+		c.removeElement(element)
+		return nil, false
+	}
+	return element, true
+}
+
+// evict removes the least recently used entries until the cache is within the limits,
+// the most recently used entry is always kept. The caller must hold the lock.
+func (c *memoryCache) evict() {
+	for c.lru.Len() > 1 {
+		if (c.maxEntries <= 0 || c.lru.Len() <= c.maxEntries) && (c.maxMemory <= 0 || c.memory <= c.maxMemory) {
+			return
+		}
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *memoryCache) removeElement(element *list.Element) {
+	entry := c.lru.Remove(element).(*memoryEntry)
+	delete(c.items, entry.key)
+	c.memory -= entry.size()
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemoryCacheKeys(t *testing.T) {
+	var testCases = []struct {
+		description string
+		pattern     string
+		expected    []string
+	}{
+		{
+			description: "Should get all keys",
+			pattern:     "*",
+			expected:    []string{"bar1", "bar2", "foo1", "foo2", "foo3"},
+		},
+		{
+			description: "Should get keys start with foo",
+			pattern:     "foo*",
+			expected:    []string{"foo1", "foo2", "foo3"},
+		},
+		{
+			description: "Should match a single character",
+			pattern:     "ba?2",
+			expected:    []string{"bar2"},
+		},
+		{
+			description: "Should match a character class",
+			pattern:     "foo[12]",
+			expected:    []string{"foo1", "foo2"},
+		},
+		{
+			description: "Should match a negated range",
+			pattern:     "foo[^1-2]",
+			expected:    []string{"foo3"},
+		},
+		{
+			description: "Dot is not a wildcard",
+			pattern:     "foo.",
+			expected:    nil,
+		},
+	}
+
+	cacheClient := NewMemoryCache(&MemoryOptions{}, nil)
+	if err := load(cacheClient, dataSet); err != nil {
+		t.Fatalf("Unable to load dataset, got error %v", err)
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			got, err := cacheClient.Keys(testCase.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(got, testCase.expected); len(diff) != 0 {
+				t.Errorf("%T differ (-got, +expected) %v", testCase.expected, diff)
+			}
+		})
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	var testCases = []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{"*", "", true},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[!e]llo", "hallo", true},
+		{"h[a-b]llo", "hbllo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"session:*:token", "session:admin:token", true},
+	}
+
+	for _, testCase := range testCases {
+		if got := globMatch(testCase.pattern, testCase.key); got != testCase.expected {
+			t.Errorf("pattern %q key %q, expected %v, got %v", testCase.pattern, testCase.key, testCase.expected, got)
+		}
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	cacheClient := NewMemoryCache(&MemoryOptions{MaxEntries: 3}, nil)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := cacheClient.Set(key, key, NeverExpire); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a becomes the most recently used one, b should be evicted
+	if _, err := cacheClient.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheClient.Set("d", "d", NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := dump(cacheClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"a": "a", "c": "c", "d": "d"}
+	if diff := cmp.Diff(got, expected); len(diff) != 0 {
+		t.Errorf("%T differ (-got, +expected) %v", expected, diff)
+	}
+
+	// 4 bytes for each entry, only 2 entries could be kept
+	cacheClient = NewMemoryCache(&MemoryOptions{MaxMemory: 8}, nil)
+	for _, key := range []string{"k1", "k2", "k3"} {
+		if err := cacheClient.Set(key, "v1", NeverExpire); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exists, _ := cacheClient.Exists("k1"); exists {
+		t.Errorf("k1 should be evicted")
+	}
+	if exists, _ := cacheClient.Exists("k2", "k3"); !exists {
+		t.Errorf("k2 and k3 should be kept")
+	}
+}
+
+func TestMemoryCacheExpire(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	cacheClient := NewMemoryCache(&MemoryOptions{CleanupInterval: 10 * time.Millisecond}, stopCh)
+	if err := load(cacheClient, dataSet); err != nil {
+		t.Fatalf("Unable to load dataset, got error %v", err)
+	}
+	if err := cacheClient.Expire("foo1", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheClient.Set("foo4", "val4", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// the janitor should have removed the expired keys
+	memory := cacheClient.(*memoryCache)
+	memory.mutex.Lock()
+	count := len(memory.items)
+	memory.mutex.Unlock()
+	if count != len(dataSet)-1 {
+		t.Errorf("expected %d keys, got %d", len(dataSet)-1, count)
+	}
+
+	if _, err := cacheClient.Get("foo1"); err != ErrNoSuchKey {
+		t.Errorf("expected %v, got %v", ErrNoSuchKey, err)
+	}
+	if err := cacheClient.Expire("foo4", time.Second); err != ErrNoSuchKey {
+		t.Errorf("expected %v, got %v", ErrNoSuchKey, err)
+	}
+}
+
+func TestMemoryCacheConcurrency(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	cacheClient := NewMemoryCache(&MemoryOptions{MaxEntries: 50, CleanupInterval: time.Millisecond}, stopCh)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("key-%d-%d", worker, j%60)
+				_ = cacheClient.Set(key, key, time.Millisecond*time.Duration(j%5))
+				_, _ = cacheClient.Get(key)
+				_, _ = cacheClient.Exists(key)
+				_ = cacheClient.Expire(key, time.Second)
+				_, _ = cacheClient.Keys("key-*")
+				if j%10 == 0 {
+					_ = cacheClient.Del(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	keys, err := cacheClient.Keys("*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) > 50 {
+		t.Errorf("expected at most 50 keys, got %d", len(keys))
+	}
+}
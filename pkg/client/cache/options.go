@@ -18,15 +18,76 @@ package cache
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/pflag"
 )
 
+const (
+	// ModeStandalone connects to a single redis server by host and port
+	ModeStandalone = "standalone"
+	// ModeSentinel connects to the master which is monitored by the sentinels
+	ModeSentinel = "sentinel"
+	// ModeCluster connects to a redis cluster
+	ModeCluster = "cluster"
+)
+
 type Options struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
+	Username string `json:"username,omitempty"`
 	Password string `json:"password"`
 	DB       int    `json:"db"`
+
+	// Mode is one of standalone, sentinel and cluster, default is standalone
+	Mode string `json:"mode,omitempty"`
+	// Addrs are the addresses of sentinels in sentinel mode or the seed nodes in cluster mode
+	Addrs []string `json:"addrs,omitempty"`
+	// MasterName is the name of master in sentinel mode
+	MasterName string `json:"masterName,omitempty"`
+
+	TLS *TLSOptions `json:"tls,omitempty"`
+
+	PoolSize     int           `json:"poolSize,omitempty"`
+	MinIdleConns int           `json:"minIdleConns,omitempty"`
+	DialTimeout  time.Duration `json:"dialTimeout,omitempty"`
+	ReadTimeout  time.Duration `json:"readTimeout,omitempty"`
+	WriteTimeout time.Duration `json:"writeTimeout,omitempty"`
+	PoolTimeout  time.Duration `json:"poolTimeout,omitempty"`
+	IdleTimeout  time.Duration `json:"idleTimeout,omitempty"`
+
+	// Memory options are used when redis is not configured
+	Memory *MemoryOptions `json:"memory,omitempty"`
+}
+
+// TLSOptions enables TLS for the connections to redis
+type TLSOptions struct {
+	Enabled bool `json:"enabled"`
+	// CAFile is the CA bundle used to verify the server certificate, system roots are used if empty
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate for mutual TLS
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// MemoryOptions limits the in memory cache, zero value means unlimited
+type MemoryOptions struct {
+	// MaxEntries is the maximum number of keys
+	MaxEntries int `json:"maxEntries"`
+	// MaxMemory is the maximum size of keys and values in bytes
+	MaxMemory int64 `json:"maxMemory"`
+	// CleanupInterval is the interval of removing expired keys
+	CleanupInterval time.Duration `json:"cleanupInterval"`
+}
+
+func NewMemoryOptions() *MemoryOptions {
+	return &MemoryOptions{
+		MaxEntries:      100000,
+		MaxMemory:       256 << 20,
+		CleanupInterval: time.Minute,
+	}
 }
 
 // NewRedisOptions returns options points to nowhere,
@@ -37,15 +98,55 @@ func NewRedisOptions() *Options {
 		Port:     0,
 		Password: "",
 		DB:       0,
+		Memory:   NewMemoryOptions(),
+	}
+}
+
+// IsEnabled returns true if redis is configured
+func (r *Options) IsEnabled() bool {
+	if r == nil {
+		return false
 	}
+	return len(r.Host) != 0 || len(r.Addrs) != 0
 }
 
 // Validate check options
 func (r *Options) Validate() []error {
 	errors := make([]error, 0)
 
-	if r.Port == 0 {
-		errors = append(errors, fmt.Errorf("invalid service port number"))
+	switch r.Mode {
+	case "", ModeStandalone:
+		if r.Port == 0 {
+			errors = append(errors, fmt.Errorf("invalid service port number"))
+		}
+	case ModeSentinel:
+		if r.MasterName == "" {
+			errors = append(errors, fmt.Errorf("master name is required in sentinel mode"))
+		}
+		if len(r.Addrs) == 0 {
+			errors = append(errors, fmt.Errorf("sentinel addresses are required in sentinel mode"))
+		}
+	case ModeCluster:
+		if len(r.Addrs) == 0 {
+			errors = append(errors, fmt.Errorf("node addresses are required in cluster mode"))
+		}
+		if r.DB != 0 {
+			errors = append(errors, fmt.Errorf("only db 0 is available in cluster mode"))
+		}
+	default:
+		errors = append(errors, fmt.Errorf("unsupported redis mode %s", r.Mode))
+	}
+
+	if r.Username != "" && r.Password == "" {
+		errors = append(errors, fmt.Errorf("password is required if username is set"))
+	}
+
+	if r.TLS != nil && r.TLS.Enabled && (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+		errors = append(errors, fmt.Errorf("both client certificate and key are required for TLS"))
+	}
+
+	if r.PoolSize < 0 || r.MinIdleConns < 0 {
+		errors = append(errors, fmt.Errorf("pool size and min idle connections can not be negative"))
 	}
 
 	return errors
@@ -58,6 +159,47 @@ func (r *Options) AddFlags(fs *pflag.FlagSet, s *Options) {
 		"redis will be disabled.")
 
 	fs.IntVar(&r.Port, "redis-port", s.Port, "")
+	fs.StringVar(&r.Username, "redis-username", s.Username, "Username of redis ACL, requires redis 6.0 or later.")
 	fs.StringVar(&r.Password, "redis-password", s.Password, "")
 	fs.IntVar(&r.DB, "redis-db", s.DB, "")
+	fs.StringVar(&r.Mode, "redis-mode", s.Mode, "Mode of redis, one of standalone, sentinel and cluster.")
+	fs.StringSliceVar(&r.Addrs, "redis-addrs", s.Addrs, "Addresses of sentinels in sentinel mode, "+
+		"or addresses of seed nodes in cluster mode.")
+	fs.StringVar(&r.MasterName, "redis-master-name", s.MasterName, "Name of master in sentinel mode.")
+	fs.IntVar(&r.PoolSize, "redis-pool-size", s.PoolSize, "Maximum number of connections, "+
+		"0 means 10 connections per CPU.")
+	fs.IntVar(&r.MinIdleConns, "redis-min-idle-conns", s.MinIdleConns, "")
+	fs.DurationVar(&r.DialTimeout, "redis-dial-timeout", s.DialTimeout, "")
+	fs.DurationVar(&r.ReadTimeout, "redis-read-timeout", s.ReadTimeout, "")
+	fs.DurationVar(&r.WriteTimeout, "redis-write-timeout", s.WriteTimeout, "")
+	fs.DurationVar(&r.PoolTimeout, "redis-pool-timeout", s.PoolTimeout, "")
+	fs.DurationVar(&r.IdleTimeout, "redis-idle-timeout", s.IdleTimeout, "")
+
+	tlsOptions := s.TLS
+	if tlsOptions == nil {
+		tlsOptions = &TLSOptions{}
+	}
+	if r.TLS == nil {
+		r.TLS = &TLSOptions{}
+	}
+	fs.BoolVar(&r.TLS.Enabled, "redis-tls", tlsOptions.Enabled, "Connect to redis over TLS.")
+	fs.StringVar(&r.TLS.CAFile, "redis-tls-ca-file", tlsOptions.CAFile, "")
+	fs.StringVar(&r.TLS.CertFile, "redis-tls-cert-file", tlsOptions.CertFile, "")
+	fs.StringVar(&r.TLS.KeyFile, "redis-tls-key-file", tlsOptions.KeyFile, "")
+	fs.StringVar(&r.TLS.ServerName, "redis-tls-server-name", tlsOptions.ServerName, "")
+	fs.BoolVar(&r.TLS.InsecureSkipVerify, "redis-tls-insecure-skip-verify", tlsOptions.InsecureSkipVerify, "")
+
+	memory := s.Memory
+	if memory == nil {
+		memory = NewMemoryOptions()
+	}
+	if r.Memory == nil {
+		r.Memory = &MemoryOptions{}
+	}
+	fs.IntVar(&r.Memory.MaxEntries, "cache-max-entries", memory.MaxEntries, "Maximum number of keys of "+
+		"the in memory cache which is used if redis is disabled, 0 means unlimited.")
+	fs.Int64Var(&r.Memory.MaxMemory, "cache-max-memory", memory.MaxMemory, "Maximum size of keys and values "+
+		"in bytes of the in memory cache, 0 means unlimited.")
+	fs.DurationVar(&r.Memory.CleanupInterval, "cache-cleanup-interval", memory.CleanupInterval, "Interval of "+
+		"removing expired keys from the in memory cache.")
 }
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import "testing"
+
+func TestOptionsValidate(t *testing.T) {
+	var testCases = []struct {
+		description string
+		options     *Options
+		errors      int
+	}{
+		{
+			description: "standalone",
+			options:     &Options{Host: "redis", Port: 6379},
+		},
+		{
+			description: "standalone without port",
+			options:     &Options{Host: "redis"},
+			errors:      1,
+		},
+		{
+			description: "sentinel",
+			options:     &Options{Mode: ModeSentinel, MasterName: "mymaster", Addrs: []string{"sentinel:26379"}},
+		},
+		{
+			description: "sentinel without master name",
+			options:     &Options{Mode: ModeSentinel, Addrs: []string{"sentinel:26379"}},
+			errors:      1,
+		},
+		{
+			description: "cluster with db",
+			options:     &Options{Mode: ModeCluster, Addrs: []string{"node:6379"}, DB: 1},
+			errors:      1,
+		},
+		{
+			description: "username without password",
+			options:     &Options{Host: "redis", Port: 6379, Username: "devops"},
+			errors:      1,
+		},
+		{
+			description: "client certificate without key",
+			options:     &Options{Host: "redis", Port: 6379, TLS: &TLSOptions{Enabled: true, CertFile: "tls.crt"}},
+			errors:      1,
+		},
+		{
+			description: "unknown mode",
+			options:     &Options{Mode: "proxy"},
+			errors:      1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.description, func(t *testing.T) {
+			if errs := testCase.options.Validate(); len(errs) != testCase.errors {
+				t.Errorf("expected %d errors, got %v", testCase.errors, errs)
+			}
+		})
+	}
+}
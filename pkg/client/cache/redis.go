@@ -17,31 +17,102 @@ limitations under the License.
 package cache
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis"
 	"k8s.io/klog"
 )
 
+// scanCount is the hint of how many keys are returned by each SCAN
+const scanCount = 1000
+
 type Client struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
 func NewRedisClient(option *Options, stopCh <-chan struct{}) (Interface, error) {
 	var r Client
 
-	redisOptions := &redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", option.Host, option.Port),
-		Password: option.Password,
-		DB:       option.DB,
-	}
-
 	if stopCh == nil {
 		klog.Fatalf("no stop channel passed, redis connections will leak.")
 	}
 
-	r.client = redis.NewClient(redisOptions)
+	tlsConfig, err := newTLSConfig(option.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// the client authenticates with password only, so the ACL users are authenticated on connect, and the db is
+	// selected after it, the client would select it before OnConnect and be refused as unauthenticated
+	password := option.Password
+	var onConnect func(*redis.Conn) error
+	if option.Username != "" || option.DB != 0 {
+		if option.Username != "" {
+			password = ""
+		}
+		onConnect = func(conn *redis.Conn) error {
+			if option.Username != "" {
+				if err := conn.Do("AUTH", option.Username, option.Password).Err(); err != nil {
+					return err
+				}
+			}
+			if option.DB != 0 {
+				return conn.Select(option.DB).Err()
+			}
+			return nil
+		}
+	}
+
+	switch option.Mode {
+	case ModeSentinel:
+		r.client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    option.MasterName,
+			SentinelAddrs: option.Addrs,
+			OnConnect:     onConnect,
+			Password:      password,
+			DialTimeout:   option.DialTimeout,
+			ReadTimeout:   option.ReadTimeout,
+			WriteTimeout:  option.WriteTimeout,
+			PoolSize:      option.PoolSize,
+			MinIdleConns:  option.MinIdleConns,
+			PoolTimeout:   option.PoolTimeout,
+			IdleTimeout:   option.IdleTimeout,
+			TLSConfig:     tlsConfig,
+		})
+	case ModeCluster:
+		r.client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        option.Addrs,
+			OnConnect:    onConnect,
+			Password:     password,
+			DialTimeout:  option.DialTimeout,
+			ReadTimeout:  option.ReadTimeout,
+			WriteTimeout: option.WriteTimeout,
+			PoolSize:     option.PoolSize,
+			MinIdleConns: option.MinIdleConns,
+			PoolTimeout:  option.PoolTimeout,
+			IdleTimeout:  option.IdleTimeout,
+			TLSConfig:    tlsConfig,
+		})
+	default:
+		r.client = redis.NewClient(&redis.Options{
+			Addr:         fmt.Sprintf("%s:%d", option.Host, option.Port),
+			OnConnect:    onConnect,
+			Password:     password,
+			DialTimeout:  option.DialTimeout,
+			ReadTimeout:  option.ReadTimeout,
+			WriteTimeout: option.WriteTimeout,
+			PoolSize:     option.PoolSize,
+			MinIdleConns: option.MinIdleConns,
+			PoolTimeout:  option.PoolTimeout,
+			IdleTimeout:  option.IdleTimeout,
+			TLSConfig:    tlsConfig,
+		})
+	}
 
 	if err := r.client.Ping().Err(); err != nil {
 		r.client.Close()
@@ -61,12 +132,86 @@ func NewRedisClient(option *Options, stopCh <-chan struct{}) (Interface, error)
 	return &r, nil
 }
 
+func newTLSConfig(options *TLSOptions) (*tls.Config, error) {
+	if options == nil || !options.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CAFile != "" {
+		ca, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", options.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.CertFile != "" && options.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
 func (r *Client) Get(key string) (string, error) {
 	return r.client.Get(key).Result()
 }
 
+// Keys iterates the keyspace by SCAN instead of KEYS, which blocks the server on large keyspaces.
+// All the masters are scanned in cluster mode.
 func (r *Client) Keys(pattern string) ([]string, error) {
-	return r.client.Keys(pattern).Result()
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		var mutex sync.Mutex
+		var keys []string
+		err := cluster.ForEachMaster(func(client *redis.Client) error {
+			masterKeys, err := scanKeys(client, pattern)
+			if err != nil {
+				return err
+			}
+			mutex.Lock()
+			keys = append(keys, masterKeys...)
+			mutex.Unlock()
+			return nil
+		})
+		return keys, err
+	}
+
+	return scanKeys(r.client, pattern)
+}
+
+// scanKeys returns the keys match the pattern, SCAN may return a key multiple times so they are deduplicated
+func scanKeys(client redis.Cmdable, pattern string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var keys []string
+	var cursor uint64
+	for {
+		page, next, err := client.Scan(cursor, pattern, scanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range page {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+		if next == 0 {
+			return keys, nil
+		}
+		cursor = next
+	}
 }
 
 func (r *Client) Set(key string, value string, duration time.Duration) error {
@@ -74,10 +219,32 @@ func (r *Client) Set(key string, value string, duration time.Duration) error {
 }
 
 func (r *Client) Del(keys ...string) error {
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		// keys in different slots can not be deleted by one command in cluster mode
+		for _, key := range keys {
+			if err := r.client.Del(key).Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	return r.client.Del(keys...).Err()
 }
 
 func (r *Client) Exists(keys ...string) (bool, error) {
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		for _, key := range keys {
+			existedKeys, err := r.client.Exists(key).Result()
+			if err != nil {
+				return false, err
+			}
+			if existedKeys == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
 	existedKeys, err := r.client.Exists(keys...).Result()
 	if err != nil {
 		return false, err
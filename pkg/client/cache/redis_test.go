@@ -0,0 +1,180 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis speaks enough RESP for the client, SCAN returns the keys two at a time and repeats the last key of
+// every page in the next one, like SCAN may do while the keyspace is rehashed
+type fakeRedis struct {
+	listener net.Listener
+	keys     []string
+
+	mutex    sync.Mutex
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, keys ...string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{listener: listener, keys: keys}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) options() *Options {
+	addr := r.listener.Addr().(*net.TCPAddr)
+	options := NewRedisOptions()
+	options.Host = addr.IP.String()
+	options.Port = addr.Port
+	return options
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(args[0])
+		r.mutex.Lock()
+		r.commands = append(r.commands, strings.Join(append([]string{command}, args[1:]...), " "))
+		r.mutex.Unlock()
+
+		switch command {
+		case "PING":
+			fmt.Fprint(conn, "+PONG\r\n")
+		case "SCAN":
+			cursor, _ := strconv.Atoi(args[1])
+			var page []string
+			for i := cursor; i < len(r.keys) && i < cursor+2; i++ {
+				if globMatch(args[3], r.keys[i]) {
+					page = append(page, r.keys[i])
+				}
+			}
+			next := cursor + 1
+			if next >= len(r.keys)-1 {
+				next = 0
+			}
+			fmt.Fprintf(conn, "*2\r\n$%d\r\n%d\r\n*%d\r\n", len(strconv.Itoa(next)), next, len(page))
+			for _, key := range page {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(key), key)
+			}
+		default:
+			fmt.Fprint(conn, "+OK\r\n")
+		}
+	}
+}
+
+func (r *fakeRedis) received() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.commands...)
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if _, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(arg, "\r\n"))
+	}
+	return args, nil
+}
+
+func TestRedisKeys(t *testing.T) {
+	server := newFakeRedis(t, "kubesphere:user:alice", "kubesphere:user:bob", "kubesphere:token:1",
+		"kubesphere:user:carol", "kubesphere:user:dave")
+	defer server.listener.Close()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	client, err := NewRedisClient(server.options(), stopCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := client.Keys("kubesphere:user:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	expected := []string{"kubesphere:user:alice", "kubesphere:user:bob", "kubesphere:user:carol", "kubesphere:user:dave"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected the keys %v, got %v", expected, keys)
+	}
+
+	for _, command := range server.received() {
+		if strings.HasPrefix(command, "KEYS") {
+			t.Errorf("expected the keys are scanned, got %s", command)
+		}
+	}
+}
+
+func TestRedisSelectAfterAuth(t *testing.T) {
+	server := newFakeRedis(t)
+	defer server.listener.Close()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	options := server.options()
+	options.Username = "devops"
+	options.Password = "password"
+	options.DB = 2
+	if _, err := NewRedisClient(options, stopCh); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"AUTH devops password", "SELECT 2", "PING"}
+	if commands := server.received(); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("expected the commands %v, got %v", expected, commands)
+	}
+}
@@ -17,8 +17,6 @@ limitations under the License.
 package cache
 
 import (
-	"regexp"
-	"strings"
 	"time"
 
 	"devops.kubesphere.io/plugin/pkg/server/errors"
@@ -42,17 +40,9 @@ func NewSimpleCache() Interface {
 }
 
 func (s *simpleCache) Keys(pattern string) ([]string, error) {
-	// There is a little difference between go regexp and redis key pattern
-	// In redis, * means any character, while in go . means match everything.
-	pattern = strings.Replace(pattern, "*", ".", -1)
-
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return nil, err
-	}
 	var keys []string
 	for k := range s.store {
-		if re.MatchString(k) {
+		if globMatch(pattern, k) {
 			keys = append(keys, k)
 		}
 	}
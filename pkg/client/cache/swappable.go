@@ -0,0 +1,128 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// NewCache connects to redis if it is enabled in options, otherwise it creates an in memory cache.
+// The connections are closed when stopCh is closed.
+func NewCache(options *Options, stopCh <-chan struct{}) (Interface, error) {
+	if options.IsEnabled() {
+		return NewRedisClient(options, stopCh)
+	}
+
+	klog.Warning("ks-apiserver starts without redis provided, it will use in memory cache. " +
+		"This may cause inconsistencies when running ks-apiserver with multiple replicas.")
+	var memoryOptions *MemoryOptions
+	if options != nil {
+		memoryOptions = options.Memory
+	}
+	return NewMemoryCache(memoryOptions, stopCh), nil
+}
+
+// swapGracePeriod is how long the replaced cache is kept open for the calls which got it before the swap
+const swapGracePeriod = 30 * time.Second
+
+// Swappable forwards to a cache which is replaced when the options change, e.g. after the redis password is
+// rotated. It is safe for concurrent use.
+type Swappable struct {
+	mutex sync.RWMutex
+	cache Interface
+	// stopCh stops the current cache, it is closed after gracePeriod once the cache is replaced
+	stopCh      chan struct{}
+	stopped     bool
+	gracePeriod time.Duration
+}
+
+// NewSwappable creates the cache by options, the caches are stopped a while after they are replaced, or once
+// stopCh is closed
+func NewSwappable(options *Options, stopCh <-chan struct{}) (*Swappable, error) {
+	s := &Swappable{gracePeriod: swapGracePeriod}
+	if err := s.Reset(options); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-stopCh
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.stopped = true
+		close(s.stopCh)
+	}()
+	return s, nil
+}
+
+// Reset replaces the cache with a new one created by options, the current cache is kept if it fails.
+// The entries of the replaced cache are not copied.
+func (s *Swappable) Reset(options *Options) error {
+	stopCh := make(chan struct{})
+	cache, err := NewCache(options, stopCh)
+	if err != nil {
+		close(stopCh)
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopped {
+		close(stopCh)
+		return nil
+	}
+	if replaced := s.stopCh; replaced != nil {
+		// the calls which got the replaced cache before the swap may still be using its connections
+		time.AfterFunc(s.gracePeriod, func() {
+			close(replaced)
+		})
+	}
+	s.cache, s.stopCh = cache, stopCh
+	return nil
+}
+
+func (s *Swappable) current() Interface {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cache
+}
+
+func (s *Swappable) Keys(pattern string) ([]string, error) {
+	return s.current().Keys(pattern)
+}
+
+func (s *Swappable) Get(key string) (string, error) {
+	return s.current().Get(key)
+}
+
+func (s *Swappable) Set(key string, value string, duration time.Duration) error {
+	return s.current().Set(key, value, duration)
+}
+
+func (s *Swappable) Del(keys ...string) error {
+	return s.current().Del(keys...)
+}
+
+func (s *Swappable) Exists(keys ...string) (bool, error) {
+	return s.current().Exists(keys...)
+}
+
+func (s *Swappable) Expire(key string, duration time.Duration) error {
+	return s.current().Expire(key, duration)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSwappable(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	swappable, err := NewSwappable(NewRedisOptions(), stopCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = swappable.Set("foo", "bar", NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unreachable redis keeps the current cache
+	unreachable := NewRedisOptions()
+	unreachable.Host, unreachable.Port = "127.0.0.1", 1
+	unreachable.DialTimeout = time.Second
+	if err = swappable.Reset(unreachable); err == nil {
+		t.Fatalf("expected the unreachable redis to fail")
+	}
+	if value, err := swappable.Get("foo"); err != nil || value != "bar" {
+		t.Errorf("expected the current cache to be kept, got %q, %v", value, err)
+	}
+
+	// the replaced cache starts empty
+	swappable.gracePeriod = 100 * time.Millisecond
+	replaced := swappable.stopCh
+	if err = swappable.Reset(NewRedisOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := swappable.Exists("foo"); err != nil || exists {
+		t.Errorf("expected an empty cache after reset, got %v, %v", exists, err)
+	}
+
+	// the replaced cache is stopped after the grace period
+	select {
+	case <-replaced:
+		t.Fatalf("expected the replaced cache to be open during the grace period")
+	default:
+	}
+	select {
+	case <-replaced:
+	case <-time.After(time.Second):
+		t.Errorf("expected the replaced cache to be stopped after the grace period")
+	}
+}
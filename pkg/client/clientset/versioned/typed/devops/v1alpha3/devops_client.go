@@ -26,8 +26,11 @@ import (
 
 type DevopsV1alpha3Interface interface {
 	RESTClient() rest.Interface
+	AgentTemplatesGetter
 	DevOpsProjectsGetter
+	NotificationPoliciesGetter
 	PipelinesGetter
+	PipelineRunsGetter
 }
 
 // DevopsV1alpha3Client is used to interact with features provided by the devops.kubesphere.io group.
@@ -35,14 +38,26 @@ type DevopsV1alpha3Client struct {
 	restClient rest.Interface
 }
 
+func (c *DevopsV1alpha3Client) AgentTemplates(namespace string) AgentTemplateInterface {
+	return newAgentTemplates(c, namespace)
+}
+
 func (c *DevopsV1alpha3Client) DevOpsProjects() DevOpsProjectInterface {
 	return newDevOpsProjects(c)
 }
 
+func (c *DevopsV1alpha3Client) NotificationPolicies(namespace string) NotificationPolicyInterface {
+	return newNotificationPolicies(c, namespace)
+}
+
 func (c *DevopsV1alpha3Client) Pipelines(namespace string) PipelineInterface {
 	return newPipelines(c, namespace)
 }
 
+func (c *DevopsV1alpha3Client) PipelineRuns(namespace string) PipelineRunInterface {
+	return newPipelineRuns(c, namespace)
+}
+
 // NewForConfig creates a new DevopsV1alpha3Client for the given config.
 func NewForConfig(c *rest.Config) (*DevopsV1alpha3Client, error) {
 	config := *c
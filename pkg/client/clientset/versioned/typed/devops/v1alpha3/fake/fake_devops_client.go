@@ -28,14 +28,26 @@ type FakeDevopsV1alpha3 struct {
 	*testing.Fake
 }
 
+func (c *FakeDevopsV1alpha3) AgentTemplates(namespace string) v1alpha3.AgentTemplateInterface {
+	return &FakeAgentTemplates{c, namespace}
+}
+
 func (c *FakeDevopsV1alpha3) DevOpsProjects() v1alpha3.DevOpsProjectInterface {
 	return &FakeDevOpsProjects{c}
 }
 
+func (c *FakeDevopsV1alpha3) NotificationPolicies(namespace string) v1alpha3.NotificationPolicyInterface {
+	return &FakeNotificationPolicies{c, namespace}
+}
+
 func (c *FakeDevopsV1alpha3) Pipelines(namespace string) v1alpha3.PipelineInterface {
 	return &FakePipelines{c, namespace}
 }
 
+func (c *FakeDevopsV1alpha3) PipelineRuns(namespace string) v1alpha3.PipelineRunInterface {
+	return &FakePipelineRuns{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeDevopsV1alpha3) RESTClient() rest.Interface {
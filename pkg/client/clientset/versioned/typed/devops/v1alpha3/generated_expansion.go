@@ -18,6 +18,12 @@ limitations under the License.
 
 package v1alpha3
 
+type AgentTemplateExpansion interface{}
+
 type DevOpsProjectExpansion interface{}
 
+type NotificationPolicyExpansion interface{}
+
 type PipelineExpansion interface{}
+
+type PipelineRunExpansion interface{}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+// Agent is a node of Jenkins running the builds, such as a pod created by the Kubernetes cloud
+type Agent struct {
+	Name          string     `json:"name"`
+	Labels        []string   `json:"labels,omitempty"`
+	Offline       bool       `json:"offline"`
+	OfflineReason string     `json:"offlineReason,omitempty"`
+	Idle          bool       `json:"idle"`
+	NumExecutors  int        `json:"numExecutors"`
+	Executors     []Executor `json:"executors"`
+}
+
+// Executor is a slot of an agent running a build
+type Executor struct {
+	Number int  `json:"number"`
+	Idle   bool `json:"idle"`
+	// Progress is the estimated percentage of the build, it is -1 if unknown
+	Progress int `json:"progress"`
+	// Pipeline, Branch and RunID are the pipeline run being built, they are empty if the executor is idle
+	// or builds another project
+	Pipeline string `json:"pipeline,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	RunID    string `json:"runId,omitempty"`
+}
+
+// PodTemplate is a pod template of the Kubernetes cloud of Jenkins
+type PodTemplate struct {
+	Name string `json:"name"`
+	// Label is the labels separated by spaces
+	Label       string `json:"label"`
+	IdleMinutes int    `json:"idleMinutes"`
+	// Yaml is the pod merged into the pod of the agent, which has the jnlp container
+	Yaml string `json:"yaml"`
+}
+
+// AgentOperator provides API for the agents of Jenkins and the Kubernetes pod templates of the projects
+type AgentOperator interface {
+	// ListAgents returns the agents of the Jenkins serving the project, the builds of other projects are hidden
+	ListAgents(projectName string) ([]Agent, error)
+	// ApplyPodTemplates replaces the pod templates of the project in the Kubernetes cloud of Jenkins
+	ApplyPodTemplates(projectName string, templates []PodTemplate) error
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cached
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/cache"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+// BypassHeader skips the response cache if its value is true, it is used for debugging
+const BypassHeader = "X-Devops-Cache-Bypass"
+
+const (
+	keyPrefix = "kubesphere:devops:response"
+
+	kindPipelines = "pipelines"
+	kindRuns      = "runs"
+	kindBranches  = "branches"
+	kindSCMOrgs   = "scmorgs"
+	kindSCMRepos  = "scmrepos"
+
+	// placeholder of the empty segment of key
+	anySegment = "_"
+)
+
+// cachedDevops caches the responses of expensive reads of devops.Interface, other calls are passed through.
+// The cache keys contain the identity of caller, because the responses of Jenkins depend on the permissions
+// of the user who sends the request.
+type cachedDevops struct {
+	devops.Interface
+
+	cache cache.Interface
+	// ttl is the time.Duration in nanoseconds, it is accessed atomically
+	ttl int64
+}
+
+// Interface is the devops client whose responses are cached
+type Interface interface {
+	devops.Interface
+
+	// SetTTL changes the time to live of the responses cached afterwards, 0 disables the cache
+	SetTTL(ttl time.Duration)
+}
+
+// NewDevopsClient wraps the client with a read-through response cache, the responses live for ttl at most.
+// The cache is bypassed if ttl is 0.
+func NewDevopsClient(client devops.Interface, cacheClient cache.Interface, ttl time.Duration) Interface {
+	return &cachedDevops{
+		Interface: client,
+		cache:     cacheClient,
+		ttl:       int64(ttl),
+	}
+}
+
+func (c *cachedDevops) SetTTL(ttl time.Duration) {
+	atomic.StoreInt64(&c.ttl, int64(ttl))
+}
+
+func (c *cachedDevops) getTTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.ttl))
+}
+
+func (c *cachedDevops) ListPipelines(httpParameters *devops.HttpParameters) (*devops.PipelineList, error) {
+	result := &devops.PipelineList{}
+	err := c.readThrough(c.key(kindPipelines, devops.ProjectOfSearchQuery(httpParameters), anySegment, httpParameters), result, func() (interface{}, error) {
+		return c.Interface.ListPipelines(httpParameters)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *cachedDevops) ListPipelineRuns(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.PipelineRunList, error) {
+	result := &devops.PipelineRunList{}
+	err := c.readThrough(c.key(kindRuns, projectName, pipelineName, httpParameters), result, func() (interface{}, error) {
+		return c.Interface.ListPipelineRuns(projectName, pipelineName, httpParameters)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *cachedDevops) GetPipelineBranch(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.PipelineBranch, error) {
+	result := &devops.PipelineBranch{}
+	err := c.readThrough(c.key(kindBranches, projectName, pipelineName, httpParameters), result, func() (interface{}, error) {
+		return c.Interface.GetPipelineBranch(projectName, pipelineName, httpParameters)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *cachedDevops) GetSCMOrg(scmId string, httpParameters *devops.HttpParameters) ([]devops.SCMOrg, error) {
+	var result []devops.SCMOrg
+	err := c.readThrough(c.key(kindSCMOrgs, scmId, anySegment, httpParameters), &result, func() (interface{}, error) {
+		return c.Interface.GetSCMOrg(scmId, httpParameters)
+	})
+	return result, err
+}
+
+func (c *cachedDevops) GetOrgRepo(scmId, organizationId string, httpParameters *devops.HttpParameters) (devops.OrgRepo, error) {
+	var result devops.OrgRepo
+	err := c.readThrough(c.key(kindSCMRepos, scmId, organizationId, httpParameters), &result, func() (interface{}, error) {
+		return c.Interface.GetOrgRepo(scmId, organizationId, httpParameters)
+	})
+	return result, err
+}
+
+func (c *cachedDevops) CreateSCMServers(scmId string, httpParameters *devops.HttpParameters) (*devops.SCMServer, error) {
+	defer c.invalidateSCM()
+	return c.Interface.CreateSCMServers(scmId, httpParameters)
+}
+
+// the organizations and repositories are listed with the credentials, so the writes of credentials invalidate them
+func (c *cachedDevops) CreateCredentialInProject(projectId string, credential *v1.Secret) (string, error) {
+	defer c.invalidateSCM()
+	return c.Interface.CreateCredentialInProject(projectId, credential)
+}
+
+func (c *cachedDevops) UpdateCredentialInProject(projectId string, credential *v1.Secret) (string, error) {
+	defer c.invalidateSCM()
+	return c.Interface.UpdateCredentialInProject(projectId, credential)
+}
+
+func (c *cachedDevops) DeleteCredentialInProject(projectId, id string) (string, error) {
+	defer c.invalidateSCM()
+	return c.Interface.DeleteCredentialInProject(projectId, id)
+}
+
+func (c *cachedDevops) StopPipeline(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (*devops.StopPipeline, error) {
+	defer c.invalidate(projectName, pipelineName)
+	return c.Interface.StopPipeline(projectName, pipelineName, runId, httpParameters)
+}
+
+func (c *cachedDevops) ReplayPipeline(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (*devops.ReplayPipeline, error) {
+	defer c.invalidate(projectName, pipelineName)
+	return c.Interface.ReplayPipeline(projectName, pipelineName, runId, httpParameters)
+}
+
+func (c *cachedDevops) RunPipeline(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.RunPipeline, error) {
+	defer c.invalidate(projectName, pipelineName)
+	return c.Interface.RunPipeline(projectName, pipelineName, httpParameters)
+}
+
+func (c *cachedDevops) StopBranchPipeline(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (*devops.StopPipeline, error) {
+	defer c.invalidate(projectName, pipelineName)
+	return c.Interface.StopBranchPipeline(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (c *cachedDevops) ReplayBranchPipeline(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (*devops.ReplayPipeline, error) {
+	defer c.invalidate(projectName, pipelineName)
+	return c.Interface.ReplayBranchPipeline(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (c *cachedDevops) RunBranchPipeline(projectName, pipelineName, branchName string, httpParameters *devops.HttpParameters) (*devops.RunPipeline, error) {
+	defer c.invalidate(projectName, pipelineName)
+	return c.Interface.RunBranchPipeline(projectName, pipelineName, branchName, httpParameters)
+}
+
+func (c *cachedDevops) ScanBranch(projectName, pipelineName string, httpParameters *devops.HttpParameters) ([]byte, error) {
+	defer c.invalidate(projectName, pipelineName)
+	return c.Interface.ScanBranch(projectName, pipelineName, httpParameters)
+}
+
+func (c *cachedDevops) CreateProjectPipeline(projectId string, pipeline *devopsv1alpha3.Pipeline) (string, error) {
+	defer c.invalidate(projectId, pipeline.Name)
+	return c.Interface.CreateProjectPipeline(projectId, pipeline)
+}
+
+func (c *cachedDevops) DeleteProjectPipeline(projectId string, pipelineId string) (string, error) {
+	defer c.invalidate(projectId, pipelineId)
+	return c.Interface.DeleteProjectPipeline(projectId, pipelineId)
+}
+
+func (c *cachedDevops) UpdateProjectPipeline(projectId string, pipeline *devopsv1alpha3.Pipeline) (string, error) {
+	defer c.invalidate(projectId, pipeline.Name)
+	return c.Interface.UpdateProjectPipeline(projectId, pipeline)
+}
+
+// readThrough unmarshals the cached response into result if it exists, otherwise load is called
+// and its response is cached.
+func (c *cachedDevops) readThrough(key string, result interface{}, load func() (interface{}, error)) error {
+	ttl := c.getTTL()
+	bypass := key == "" || ttl <= 0
+	if !bypass {
+		if data, err := c.cache.Get(key); err == nil {
+			if err = json.Unmarshal([]byte(data), result); err == nil {
+				return nil
+			}
+			klog.Warningf("failed to unmarshal cached response %s: %v", key, err)
+		}
+	}
+
+	response, err := load()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	if !bypass {
+		if err := c.cache.Set(key, string(data), ttl); err != nil {
+			// the response is still valid even it can not be cached
+			klog.Warningf("failed to cache response %s: %v", key, err)
+		}
+	}
+	return json.Unmarshal(data, result)
+}
+
+// invalidate deletes the cached responses which might be changed by the mutations on the pipeline
+func (c *cachedDevops) invalidate(projectName, pipelineName string) {
+	c.deleteKeys(
+		fmt.Sprintf("%s:%s:%s:*", keyPrefix, kindPipelines, escape(projectName)),
+		fmt.Sprintf("%s:%s:%s:*", keyPrefix, kindPipelines, anySegment),
+		fmt.Sprintf("%s:%s:%s:%s:*", keyPrefix, kindRuns, escape(projectName), escape(pipelineName)),
+		fmt.Sprintf("%s:%s:%s:%s:*", keyPrefix, kindBranches, escape(projectName), escape(pipelineName)),
+	)
+}
+
+// invalidateSCM deletes the cached organizations and repositories of all SCM servers
+func (c *cachedDevops) invalidateSCM() {
+	c.deleteKeys(
+		fmt.Sprintf("%s:%s:*", keyPrefix, kindSCMOrgs),
+		fmt.Sprintf("%s:%s:*", keyPrefix, kindSCMRepos),
+	)
+}
+
+// deleteKeys deletes the cached responses matching any of the patterns
+func (c *cachedDevops) deleteKeys(patterns ...string) {
+	for _, pattern := range patterns {
+		keys, err := c.cache.Keys(pattern)
+		if err != nil {
+			klog.Warningf("failed to find cached responses %s: %v", pattern, err)
+			continue
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if err = c.cache.Del(keys...); err != nil {
+			klog.Warningf("failed to invalidate cached responses %s: %v", pattern, err)
+		}
+	}
+}
+
+// key returns the cache key of request, empty key means the cache should be bypassed
+func (c *cachedDevops) key(kind, first, second string, httpParameters *devops.HttpParameters) string {
+	if httpParameters == nil {
+		return ""
+	}
+	if httpParameters.Header != nil {
+		if bypass, _ := strconv.ParseBool(httpParameters.Header.Get(BypassHeader)); bypass {
+			return ""
+		}
+	}
+
+	hash := sha256.New()
+	if httpParameters.Header != nil {
+		// the identity of caller
+		hash.Write([]byte(httpParameters.Header.Get("Authorization")))
+	}
+	hash.Write([]byte{0})
+	if httpParameters.Url != nil {
+		hash.Write([]byte(httpParameters.Url.Query().Encode()))
+	}
+
+	if first == "" {
+		first = anySegment
+	}
+	if second == "" {
+		second = anySegment
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%s", keyPrefix, kind, escape(first), escape(second), hex.EncodeToString(hash.Sum(nil)))
+}
+
+// escape makes the segment of key safe in glob patterns and not containing the separator
+func escape(segment string) string {
+	return strings.NewReplacer(":", "%3A", "*", "%2A", "?", "%3F", "[", "%5B", "]", "%5D", "\\", "%5C").Replace(segment)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cached
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"devops.kubesphere.io/plugin/pkg/client/cache"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/fake"
+)
+
+// countingDevops counts the calls of ListPipelineRuns, ListPipelines and GetSCMOrg
+type countingDevops struct {
+	*fake.Devops
+	runCalls      int
+	pipelineCalls int
+	orgCalls      int
+}
+
+func (d *countingDevops) ListPipelineRuns(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.PipelineRunList, error) {
+	d.runCalls++
+	return &devops.PipelineRunList{Total: d.runCalls}, nil
+}
+
+func (d *countingDevops) ListPipelines(httpParameters *devops.HttpParameters) (*devops.PipelineList, error) {
+	d.pipelineCalls++
+	return &devops.PipelineList{Total: d.pipelineCalls}, nil
+}
+
+func (d *countingDevops) GetSCMOrg(scmId string, httpParameters *devops.HttpParameters) ([]devops.SCMOrg, error) {
+	d.orgCalls++
+	return make([]devops.SCMOrg, d.orgCalls), nil
+}
+
+func newParameters(user string, query string, bypass bool) *devops.HttpParameters {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+user)
+	if bypass {
+		header.Set(BypassHeader, "true")
+	}
+	return &devops.HttpParameters{
+		Method: http.MethodGet,
+		Header: header,
+		Url:    &url.URL{Path: "/", RawQuery: query},
+	}
+}
+
+func TestCachedDevops(t *testing.T) {
+	backend := &countingDevops{Devops: fake.New("project")}
+	client := NewDevopsClient(backend, cache.NewMemoryCache(nil, nil), time.Minute)
+
+	list := func(user string, bypass bool) int {
+		result, err := client.ListPipelineRuns("project", "pipeline", newParameters(user, "start=0&limit=10", bypass))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Total
+	}
+
+	if got := list("admin", false); got != 1 {
+		t.Fatalf("expected the first response, got %d", got)
+	}
+	if got := list("admin", false); got != 1 || backend.runCalls != 1 {
+		t.Fatalf("expected the cached response, got %d with %d calls", got, backend.runCalls)
+	}
+
+	// responses of different users are cached separately
+	if got := list("regular", false); got != 2 {
+		t.Fatalf("expected a new response for another user, got %d", got)
+	}
+
+	// bypass header skips the cache
+	if got := list("admin", true); got != 3 {
+		t.Fatalf("expected the cache to be bypassed, got %d", got)
+	}
+	if got := list("admin", false); got != 1 {
+		t.Fatalf("expected the cached response, got %d", got)
+	}
+
+	// running the pipeline invalidates the cached runs
+	if _, err := client.RunPipeline("project", "pipeline", newParameters("admin", "", false)); err != nil {
+		t.Fatal(err)
+	}
+	if got := list("admin", false); got != 4 {
+		t.Fatalf("expected the cache to be invalidated, got %d", got)
+	}
+}
+
+func TestCachedPipelineList(t *testing.T) {
+	backend := &countingDevops{Devops: fake.New("project")}
+	client := NewDevopsClient(backend, cache.NewMemoryCache(nil, nil), time.Minute)
+
+	// the semicolons have to be escaped, otherwise the query is dropped by url.ParseQuery
+	query := url.Values{
+		"q":     []string{"type:pipeline;organization:jenkins;pipeline:project/*"},
+		"start": []string{"0"},
+		"limit": []string{"10"},
+	}.Encode()
+	for i := 0; i < 3; i++ {
+		if _, err := client.ListPipelines(newParameters("admin", query, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backend.pipelineCalls != 1 {
+		t.Fatalf("expected 1 call, got %d", backend.pipelineCalls)
+	}
+
+	// scanning a pipeline of another project keeps the cache
+	if _, err := client.ScanBranch("another", "pipeline", newParameters("admin", "", false)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListPipelines(newParameters("admin", query, false)); err != nil {
+		t.Fatal(err)
+	}
+	if backend.pipelineCalls != 1 {
+		t.Fatalf("expected 1 call, got %d", backend.pipelineCalls)
+	}
+
+	if _, err := client.ScanBranch("project", "pipeline", newParameters("admin", "", false)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListPipelines(newParameters("admin", query, false)); err != nil {
+		t.Fatal(err)
+	}
+	if backend.pipelineCalls != 2 {
+		t.Fatalf("expected 2 calls, got %d", backend.pipelineCalls)
+	}
+}
+
+func TestCachedSCMOrg(t *testing.T) {
+	backend := &countingDevops{Devops: fake.NewWithCredentials("project")}
+	client := NewDevopsClient(backend, cache.NewMemoryCache(nil, nil), time.Minute)
+
+	list := func() int {
+		orgs, err := client.GetSCMOrg("github", newParameters("admin", "credentialId=github-token", false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(orgs)
+	}
+
+	if list(); list() != 1 {
+		t.Fatalf("expected the cached organizations, got %d calls", backend.orgCalls)
+	}
+
+	// the organizations are listed again with the new credential
+	if _, err := client.CreateCredentialInProject("project", &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "github-token"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(); got != 2 {
+		t.Fatalf("expected the cache to be invalidated, got %d", got)
+	}
+
+	if _, err := client.CreateSCMServers("github", newParameters("admin", "", false)); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(); got != 3 {
+		t.Fatalf("expected the cache to be invalidated, got %d", got)
+	}
+}
+
+func TestSetTTL(t *testing.T) {
+	backend := &countingDevops{Devops: fake.New("project")}
+	client := NewDevopsClient(backend, cache.NewMemoryCache(nil, nil), 0)
+
+	list := func() int {
+		result, err := client.ListPipelineRuns("project", "pipeline", newParameters("admin", "", false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Total
+	}
+
+	if list(); list() != 2 {
+		t.Fatalf("expected the cache to be disabled, got %d calls", backend.runCalls)
+	}
+	client.SetTTL(time.Minute)
+	if list(); list() != 3 {
+		t.Fatalf("expected the cache to be enabled, got %d calls", backend.runCalls)
+	}
+	client.SetTTL(0)
+	if got := list(); got != 4 {
+		t.Fatalf("expected the cache to be disabled again, got %d", got)
+	}
+}
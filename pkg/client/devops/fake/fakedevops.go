@@ -39,6 +39,15 @@ type Devops struct {
 	Pipelines map[string]map[string]*devopsv1alpha3.Pipeline
 
 	Credentials map[string]map[string]*v1.Secret
+
+	// Queue is the queued items of the projects
+	Queue map[string][]devops.QueueItem
+
+	// Agents is the agents of Jenkins
+	Agents []devops.Agent
+
+	// PodTemplates is the pod templates applied to the projects
+	PodTemplates map[string][]devops.PodTemplate
 }
 
 func New(projects ...string) *Devops {
@@ -575,6 +584,10 @@ func (d *Devops) DeleteProjectRoles(roleName ...string) error {
 	return nil
 }
 
+func (d *Devops) DescribeProjectRole(roleName string) (*devops.ProjectRole, error) {
+	return nil, nil
+}
+
 func (d *Devops) AssignProjectRole(roleName string, sid string) error {
 	return nil
 }
@@ -598,3 +611,37 @@ func (d *Devops) DeleteUserInProject(sid string) error {
 func (d *Devops) GetGlobalRole(roleName string) (string, error) {
 	return "", nil
 }
+
+func (d *Devops) ListQueueItems(projectNames ...string) ([]devops.QueueItem, error) {
+	items := make([]devops.QueueItem, 0)
+	for _, projectName := range projectNames {
+		for _, item := range d.Queue[projectName] {
+			item.Project = projectName
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (d *Devops) CancelQueueItem(projectName string, id int64) error {
+	items := d.Queue[projectName]
+	for i := range items {
+		if items[i].ID == id {
+			d.Queue[projectName] = append(items[:i:i], items[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%d", http.StatusNotFound)
+}
+
+func (d *Devops) ListAgents(projectName string) ([]devops.Agent, error) {
+	return d.Agents, nil
+}
+
+func (d *Devops) ApplyPodTemplates(projectName string, templates []devops.PodTemplate) error {
+	if d.PodTemplates == nil {
+		d.PodTemplates = map[string][]devops.PodTemplate{}
+	}
+	d.PodTemplates[projectName] = templates
+	return nil
+}
@@ -25,6 +25,8 @@ import (
 )
 
 type Interface interface {
+	AgentOperator
+
 	CredentialOperator
 
 	BuildGetter
@@ -35,6 +37,8 @@ type Interface interface {
 
 	ProjectOperator
 
+	QueueOperator
+
 	RoleOperator
 }
 
@@ -0,0 +1,168 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jenkins
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+const (
+	ComputerUrl = "/computer"
+
+	computerTree = "computer[displayName,offline,offlineCauseReason,idle,numExecutors,assignedLabels[name]," +
+		"executors[number,idle,progress,currentExecutable[url]]]"
+)
+
+// podTemplatesScript replaces the pod templates named with the prefix in the Kubernetes cloud. The templates are
+// passed as base64 encoded JSON, so nothing of them is evaluated as groovy.
+const podTemplatesScript = `import org.csanchez.jenkins.plugins.kubernetes.KubernetesCloud
+import org.csanchez.jenkins.plugins.kubernetes.PodTemplate
+
+def data = new groovy.json.JsonSlurper().parseText(new String('%s'.decodeBase64(), 'UTF-8'))
+def cloud = Jenkins.instance.clouds.find { it instanceof KubernetesCloud }
+if (cloud == null) {
+    println 'NO_KUBERNETES_CLOUD'
+    return
+}
+cloud.templates.findAll { it.name.startsWith(data.prefix) }.each { cloud.removeTemplate(it) }
+data.templates.each { t ->
+    def template = new PodTemplate()
+    template.name = t.name
+    template.label = t.label
+    template.idleMinutes = t.idleMinutes
+    template.yaml = t.yaml
+    cloud.addTemplate(template)
+}
+Jenkins.instance.save()
+println 'OK'
+`
+
+type computerResponse struct {
+	Computer []struct {
+		DisplayName        string `json:"displayName"`
+		Offline            bool   `json:"offline"`
+		OfflineCauseReason string `json:"offlineCauseReason"`
+		Idle               bool   `json:"idle"`
+		NumExecutors       int    `json:"numExecutors"`
+		AssignedLabels     []struct {
+			Name string `json:"name"`
+		} `json:"assignedLabels"`
+		Executors []struct {
+			Number            int  `json:"number"`
+			Idle              bool `json:"idle"`
+			Progress          int  `json:"progress"`
+			CurrentExecutable *struct {
+				URL string `json:"url"`
+			} `json:"currentExecutable"`
+		} `json:"executors"`
+	} `json:"computer"`
+}
+
+func (j *Jenkins) ListAgents(projectName string) ([]devops.Agent, error) {
+	computers := &computerResponse{}
+	response, err := j.Requester.GetJSON(ComputerUrl, computers, map[string]string{"tree": computerTree})
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New(strconv.Itoa(response.StatusCode))
+	}
+
+	agents := make([]devops.Agent, 0, len(computers.Computer))
+	for _, computer := range computers.Computer {
+		agent := devops.Agent{
+			Name:          computer.DisplayName,
+			Offline:       computer.Offline,
+			OfflineReason: computer.OfflineCauseReason,
+			Idle:          computer.Idle,
+			NumExecutors:  computer.NumExecutors,
+			Executors:     make([]devops.Executor, 0, len(computer.Executors)),
+		}
+		// every node has the label of its own name
+		for _, label := range computer.AssignedLabels {
+			if label.Name != computer.DisplayName {
+				agent.Labels = append(agent.Labels, label.Name)
+			}
+		}
+		for _, raw := range computer.Executors {
+			executor := devops.Executor{Number: raw.Number, Idle: raw.Idle, Progress: raw.Progress}
+			if raw.CurrentExecutable != nil {
+				project, pipeline, branch, runId, ok := parseTaskUrl(raw.CurrentExecutable.URL)
+				if ok && project == projectName {
+					executor.Pipeline, executor.Branch, executor.RunID = pipeline, branch, runId
+				}
+			}
+			agent.Executors = append(agent.Executors, executor)
+		}
+		agents = append(agents, agent)
+	}
+	return agents, nil
+}
+
+func (j *Jenkins) ApplyPodTemplates(projectName string, templates []devops.PodTemplate) error {
+	prefix := PodTemplatePrefix(projectName)
+	for _, template := range templates {
+		if !strings.HasPrefix(template.Name, prefix) {
+			return fmt.Errorf("pod template %s does not belong to project %s", template.Name, projectName)
+		}
+	}
+	if templates == nil {
+		templates = []devops.PodTemplate{}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"prefix":    prefix,
+		"templates": templates,
+	})
+	if err != nil {
+		return err
+	}
+
+	responseString := ""
+	_, err = j.Requester.PostForm(ScriptTextUrl, nil, &responseString, map[string]string{
+		"script": fmt.Sprintf(podTemplatesScript, base64.StdEncoding.EncodeToString(data)),
+	})
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	switch result := strings.TrimSpace(responseString); result {
+	case "OK":
+		return nil
+	case "NO_KUBERNETES_CLOUD":
+		return fmt.Errorf("there is no kubernetes cloud in jenkins")
+	default:
+		klog.Errorf("failed to apply the pod templates of project %s: %s", projectName, result)
+		return fmt.Errorf("failed to apply the pod templates: %s", result)
+	}
+}
+
+// PodTemplatePrefix is the prefix of the names of the pod templates of the project, the name of a namespace never
+// has a dot, so the prefixes of the projects never overlap
+func PodTemplatePrefix(projectName string) string {
+	return projectName + "."
+}
@@ -0,0 +1,93 @@
+package jenkins
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+const computerJson = `{"computer": [
+	{"displayName": "master", "offline": false, "idle": true, "numExecutors": 1,
+		"assignedLabels": [{"name": "master"}],
+		"executors": [{"number": 0, "idle": true, "progress": -1, "currentExecutable": null}]},
+	{"displayName": "maven-x1b2c", "offline": false, "idle": false, "numExecutors": 2,
+		"assignedLabels": [{"name": "maven"}, {"name": "maven-x1b2c"}],
+		"executors": [
+			{"number": 0, "idle": false, "progress": 42,
+				"currentExecutable": {"url": "http://jenkins/job/project/job/multi/job/master/3/"}},
+			{"number": 1, "idle": false, "progress": 10,
+				"currentExecutable": {"url": "http://jenkins/job/other/job/pipeline/7/"}}]},
+	{"displayName": "go-f9k3m", "offline": true, "offlineCauseReason": "Disconnected", "idle": true,
+		"numExecutors": 1, "assignedLabels": [{"name": "go-f9k3m"}, {"name": "go"}],
+		"executors": []}
+]}`
+
+var scriptPayload = regexp.MustCompile(`'([A-Za-z0-9+/=]+)'\.decodeBase64\(\)`)
+
+func newAgentServer(payloads *[]map[string]interface{}) (*Jenkins, *httptest.Server) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/computer/api/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(computerJson))
+	})
+	mux.HandleFunc("/scriptText", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		match := scriptPayload.FindStringSubmatch(r.PostForm.Get("script"))
+		if match == nil {
+			w.Write([]byte("groovy.lang.MissingMethodException"))
+			return
+		}
+		data, _ := base64.StdEncoding.DecodeString(match[1])
+		payload := map[string]interface{}{}
+		json.Unmarshal(data, &payload)
+		*payloads = append(*payloads, payload)
+		w.Write([]byte("OK\n"))
+	})
+	server := httptest.NewServer(mux)
+	return CreateJenkins(nil, server.URL, 10, "admin", "password"), server
+}
+
+func TestListAgents(t *testing.T) {
+	j, server := newAgentServer(&[]map[string]interface{}{})
+	defer server.Close()
+
+	agents, err := j.ListAgents("project")
+	assert.Nil(t, err)
+	assert.Equal(t, []devops.Agent{
+		{Name: "master", Idle: true, NumExecutors: 1,
+			Executors: []devops.Executor{{Number: 0, Idle: true, Progress: -1}}},
+		{Name: "maven-x1b2c", Labels: []string{"maven"}, NumExecutors: 2,
+			Executors: []devops.Executor{
+				{Number: 0, Progress: 42, Pipeline: "multi", Branch: "master", RunID: "3"},
+				{Number: 1, Progress: 10},
+			}},
+		{Name: "go-f9k3m", Labels: []string{"go"}, Offline: true, OfflineReason: "Disconnected", Idle: true,
+			NumExecutors: 1, Executors: []devops.Executor{}},
+	}, agents)
+}
+
+func TestApplyPodTemplates(t *testing.T) {
+	var payloads []map[string]interface{}
+	j, server := newAgentServer(&payloads)
+	defer server.Close()
+
+	err := j.ApplyPodTemplates("project", []devops.PodTemplate{{Name: "other.maven", Label: "maven"}})
+	assert.NotNil(t, err, "the template of other project is refused")
+
+	assert.Nil(t, j.ApplyPodTemplates("project", []devops.PodTemplate{
+		{Name: "project.maven", Label: "maven java", IdleMinutes: 5, Yaml: `{"kind":"Pod"}`},
+	}))
+	assert.Nil(t, j.ApplyPodTemplates("project", nil))
+	assert.Equal(t, []map[string]interface{}{
+		{"prefix": "project.", "templates": []interface{}{map[string]interface{}{
+			"name": "project.maven", "label": "maven java", "idleMinutes": float64(5), "yaml": `{"kind":"Pod"}`,
+		}}},
+		{"prefix": "project.", "templates": []interface{}{}},
+	}, payloads)
+}
@@ -0,0 +1,378 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cron implements the cron syntax of the Jenkins TimerTrigger, see
+// https://www.jenkins.io/doc/book/pipeline/syntax/#cron-syntax
+//
+// Unlike the Vixie cron, a time matches a line only if both the day of month and the day of week match, and the
+// H symbol is replaced by a value hashed from the full name of the job, so the jobs spread the load evenly.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchYears limits the search of the trigger times, a spec like "0 0 31 2 *" never triggers
+const maxSearchYears = 5
+
+const (
+	fieldMinute = iota
+	fieldHour
+	fieldDayOfMonth
+	fieldMonth
+	fieldDayOfWeek
+)
+
+var (
+	fieldNames  = [...]string{"minute", "hour", "day of month", "month", "day of week"}
+	lowerBounds = [...]int{0, 0, 1, 1, 0}
+	upperBounds = [...]int{59, 23, 31, 12, 7}
+)
+
+// aliases are the hashed shortcuts of Jenkins
+var aliases = map[string]string{
+	"@yearly":   "H H H H *",
+	"@annually": "H H H H *",
+	"@monthly":  "H H H * *",
+	"@weekly":   "H H * * H",
+	"@daily":    "H H * * *",
+	"@midnight": "H H(0-2) * * *",
+	"@hourly":   "H * * * *",
+}
+
+// Spec is a parsed cron spec of Jenkins, a time matches the spec if it matches any line of the spec
+type Spec struct {
+	tabs []*crontab
+}
+
+type crontab struct {
+	// bits are the sets of the allowed values of the fields
+	bits [5]uint64
+	// location is set by the TZ line before the line, the lines without it are evaluated in the location of the
+	// given times
+	location *time.Location
+}
+
+// Parse parses the multi-line spec, the blank lines and the comments starting with # are ignored, a line like
+// TZ=Europe/London sets the timezone of the lines after it. The seed is the full name of the job, e.g.
+// project/pipeline, H is always the lowest value if the seed is empty.
+func Parse(spec, seed string) (*Spec, error) {
+	h := newHash(seed)
+	result := &Spec{}
+	var location *time.Location
+	for i, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "TZ=") {
+			loc, err := time.LoadLocation(strings.TrimPrefix(line, "TZ="))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid timezone %s", i+1, strings.TrimPrefix(line, "TZ="))
+			}
+			location = loc
+			continue
+		}
+		tab, err := parseLine(line, h)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		tab.location = location
+		result.tabs = append(result.tabs, tab)
+	}
+	if len(result.tabs) == 0 {
+		return nil, fmt.Errorf("the cron spec is empty")
+	}
+	return result, nil
+}
+
+func parseLine(line string, h hash) (*crontab, error) {
+	if strings.HasPrefix(line, "@") {
+		alias, ok := aliases[line]
+		if !ok {
+			return nil, fmt.Errorf("unknown alias %s", line)
+		}
+		line = alias
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d in %q", len(fields), line)
+	}
+
+	tab := &crontab{}
+	for field, expr := range fields {
+		bits, err := parseField(expr, field, h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", fieldNames[field], expr, err)
+		}
+		tab.bits[field] = bits
+	}
+	// both 0 and 7 are Sunday
+	if tab.bits[fieldDayOfWeek]&(1<<7) != 0 {
+		tab.bits[fieldDayOfWeek] = tab.bits[fieldDayOfWeek]&^(1<<7) | 1
+	}
+	return tab, nil
+}
+
+// parseField parses the comma-separated terms: *, N, N-M, H, H(N-M), each can be followed by /step, except N
+func parseField(expr string, field int, h hash) (uint64, error) {
+	var bits uint64
+	for _, term := range strings.Split(expr, ",") {
+		base, step := term, 0
+		if i := strings.Index(term, "/"); i >= 0 {
+			base = term[:i]
+			n, err := strconv.Atoi(term[i+1:])
+			if err != nil {
+				return 0, fmt.Errorf("invalid step %q", term[i+1:])
+			}
+			if n <= 0 {
+				return 0, fmt.Errorf("step must be positive, got %d", n)
+			}
+			step = n
+		}
+
+		lower, upper := lowerBounds[field], upperBounds[field]
+		switch {
+		case base == "*":
+			bits |= rangeBits(lower, upper, step)
+		case strings.HasPrefix(base, "H"):
+			// the day of month is hashed in 1-28 which every month has, the day of week in 0-6
+			switch field {
+			case fieldDayOfMonth:
+				upper = 28
+			case fieldDayOfWeek:
+				upper = 6
+			}
+			if base != "H" {
+				if !strings.HasPrefix(base, "H(") || !strings.HasSuffix(base, ")") {
+					return 0, fmt.Errorf("invalid term %q", term)
+				}
+				var err error
+				if lower, upper, err = parseRange(base[2:len(base)-1], field); err != nil {
+					return 0, err
+				}
+			}
+			termBits, err := hashBits(lower, upper, step, h)
+			if err != nil {
+				return 0, err
+			}
+			bits |= termBits
+		case strings.Contains(base, "-"):
+			start, end, err := parseRange(base, field)
+			if err != nil {
+				return 0, err
+			}
+			bits |= rangeBits(start, end, step)
+		default:
+			if step != 0 {
+				return 0, fmt.Errorf("step is only allowed after *, H or a range, got %q", term)
+			}
+			n, err := parseNumber(base, field)
+			if err != nil {
+				return 0, err
+			}
+			bits |= 1 << uint(n)
+		}
+	}
+	return bits, nil
+}
+
+func parseRange(expr string, field int) (start, end int, err error) {
+	parts := strings.Split(expr, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q", expr)
+	}
+	if start, err = parseNumber(parts[0], field); err != nil {
+		return
+	}
+	if end, err = parseNumber(parts[1], field); err != nil {
+		return
+	}
+	if start > end {
+		err = fmt.Errorf("the start %d is greater than the end %d", start, end)
+	}
+	return
+}
+
+func parseNumber(expr string, field int) (int, error) {
+	n, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", expr)
+	}
+	if n < lowerBounds[field] || n > upperBounds[field] {
+		return 0, fmt.Errorf("%d is out of range %d-%d", n, lowerBounds[field], upperBounds[field])
+	}
+	return n, nil
+}
+
+func rangeBits(start, end, step int) uint64 {
+	if step == 0 {
+		step = 1
+	}
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits
+}
+
+// hashBits picks one hashed value in the range, or the values every step from a hashed offset, H/1 is H as Jenkins
+func hashBits(start, end, step int, h hash) (uint64, error) {
+	if step <= 1 {
+		return 1 << uint(start+h(end+1-start)), nil
+	}
+	if step > end-start+1 {
+		return 0, fmt.Errorf("step %d is out of range 1-%d", step, end-start+1)
+	}
+	var bits uint64
+	for i := h(step) + start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, nil
+}
+
+func (c *crontab) matches(t time.Time) bool {
+	return c.bits[fieldMinute]&(1<<uint(t.Minute())) != 0 &&
+		c.bits[fieldHour]&(1<<uint(t.Hour())) != 0 &&
+		c.matchesDay(t)
+}
+
+func (c *crontab) matchesDay(t time.Time) bool {
+	return c.bits[fieldMonth]&(1<<uint(t.Month())) != 0 &&
+		c.bits[fieldDayOfMonth]&(1<<uint(t.Day())) != 0 &&
+		c.bits[fieldDayOfWeek]&(1<<uint(t.Weekday())) != 0
+}
+
+// next returns the first matched minute after the time, it skips the unmatched months, days and hours
+func (c *crontab) next(after time.Time) (time.Time, bool) {
+	loc := after.Location()
+	if c.location != nil {
+		loc = c.location
+	}
+	t := after.In(loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+	for t.Before(limit) {
+		var skipped time.Time
+		switch {
+		case c.bits[fieldMonth]&(1<<uint(t.Month())) == 0:
+			skipped = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.matchesDay(t):
+			skipped = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.bits[fieldHour]&(1<<uint(t.Hour())) == 0:
+			skipped = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.bits[fieldMinute]&(1<<uint(t.Minute())) == 0:
+			skipped = t.Add(time.Minute)
+		default:
+			return t.In(after.Location()), true
+		}
+		// the daylight saving time might move the skipped time backward
+		if !skipped.After(t) {
+			skipped = t.Add(time.Minute)
+		}
+		t = skipped
+	}
+	return time.Time{}, false
+}
+
+// prev returns the last matched minute before the time
+func (c *crontab) prev(before time.Time) (time.Time, bool) {
+	loc := before.Location()
+	if c.location != nil {
+		loc = c.location
+	}
+	t := before.In(loc).Truncate(time.Minute)
+	if !t.Before(before) {
+		t = t.Add(-time.Minute)
+	}
+	limit := t.AddDate(-maxSearchYears, 0, 0)
+	for t.After(limit) {
+		var skipped time.Time
+		switch {
+		case c.bits[fieldMonth]&(1<<uint(t.Month())) == 0:
+			skipped = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc).Add(-time.Minute)
+		case !c.matchesDay(t):
+			skipped = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).Add(-time.Minute)
+		case c.bits[fieldHour]&(1<<uint(t.Hour())) == 0:
+			skipped = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc).Add(-time.Minute)
+		case c.bits[fieldMinute]&(1<<uint(t.Minute())) == 0:
+			skipped = t.Add(-time.Minute)
+		default:
+			return t.In(before.Location()), true
+		}
+		if !skipped.Before(t) {
+			skipped = t.Add(-time.Minute)
+		}
+		t = skipped
+	}
+	return time.Time{}, false
+}
+
+// Matches checks if the spec triggers at the minute of the time
+func (s *Spec) Matches(t time.Time) bool {
+	for _, tab := range s.tabs {
+		tt := t
+		if tab.location != nil {
+			tt = t.In(tab.location)
+		}
+		if tab.matches(tt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the first trigger time after the time, in the location of the time. It returns false if the spec
+// does not trigger in the next 5 years.
+func (s *Spec) Next(after time.Time) (time.Time, bool) {
+	var result time.Time
+	found := false
+	for _, tab := range s.tabs {
+		if t, ok := tab.next(after); ok && (!found || t.Before(result)) {
+			result, found = t, true
+		}
+	}
+	return result, found
+}
+
+// Prev returns the last trigger time before the time, in the location of the time. It returns false if the spec
+// did not trigger in the last 5 years.
+func (s *Spec) Prev(before time.Time) (time.Time, bool) {
+	var result time.Time
+	found := false
+	for _, tab := range s.tabs {
+		if t, ok := tab.prev(before); ok && (!found || t.After(result)) {
+			result, found = t, true
+		}
+	}
+	return result, found
+}
+
+// NextN returns at most n trigger times after the time
+func (s *Spec) NextN(after time.Time, n int) []time.Time {
+	times := make([]time.Time, 0, n)
+	for len(times) < n {
+		t, ok := s.Next(after)
+		if !ok {
+			break
+		}
+		times = append(times, t)
+		after = t
+	}
+	return times
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseTime(t *testing.T, value string, location string) time.Time {
+	loc, err := time.LoadLocation(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"# nothing but comments",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5/10 * * * *",
+		"10-5 * * * *",
+		"*/0 * * * *",
+		"H/61 * * * *",
+		"H(0-5 * * * *",
+		"H(30-70) * * * *",
+		"a * * * *",
+		"@often",
+		"TZ=Mars/Olympus\n* * * * *",
+	} {
+		if _, err := Parse(spec, "project/pipeline"); err == nil {
+			t.Errorf("expected spec %q is invalid", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		description string
+		spec        string
+		location    string
+		from        string
+		expected    []string
+	}{
+		{
+			description: "every 15 minutes",
+			spec:        "*/15 * * * *",
+			from:        "2020-06-01 10:07",
+			expected:    []string{"2020-06-01 10:15", "2020-06-01 10:30", "2020-06-01 10:45"},
+		},
+		{
+			description: "workdays skip the weekend",
+			spec:        "0 9 * * 1-5",
+			from:        "2020-06-05 10:00",
+			expected:    []string{"2020-06-08 09:00", "2020-06-09 09:00"},
+		},
+		{
+			description: "both the day of month and the day of week must match",
+			spec:        "0 0 13 * 5",
+			from:        "2020-01-01 00:00",
+			expected:    []string{"2020-03-13 00:00", "2020-11-13 00:00"},
+		},
+		{
+			description: "7 is Sunday",
+			spec:        "0 0 * * 7",
+			from:        "2020-06-01 00:00",
+			expected:    []string{"2020-06-07 00:00"},
+		},
+		{
+			description: "leap day",
+			spec:        "0 0 29 2 *",
+			from:        "2020-03-01 00:00",
+			expected:    []string{"2024-02-29 00:00"},
+		},
+		{
+			description: "never",
+			spec:        "0 0 31 2 *",
+			from:        "2020-03-01 00:00",
+			expected:    []string{},
+		},
+		{
+			description: "the earliest of the lines",
+			spec:        "# twice a day\n0 8 * * *\n\n0 20 * * *",
+			from:        "2020-06-01 12:00",
+			expected:    []string{"2020-06-01 20:00", "2020-06-02 08:00"},
+		},
+		{
+			description: "H is the lowest value without a seed",
+			spec:        "@daily",
+			from:        "2020-06-01 12:00",
+			expected:    []string{"2020-06-02 00:00"},
+		},
+		{
+			description: "the timezone of the lines",
+			spec:        "TZ=Asia/Shanghai\n0 9 * * *",
+			from:        "2020-06-01 00:00",
+			expected:    []string{"2020-06-01 01:00", "2020-06-02 01:00"},
+		},
+		{
+			description: "the time skipped by the daylight saving time",
+			spec:        "30 2 * * *",
+			location:    "America/New_York",
+			from:        "2020-03-07 12:00",
+			expected:    []string{"2020-03-09 02:30"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			location := test.location
+			if location == "" {
+				location = "UTC"
+			}
+			spec, err := Parse(test.spec, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := spec.NextN(mustParseTime(t, test.from, location), len(test.expected)+1)
+			if len(test.expected) == 0 && len(actual) != 0 {
+				t.Fatalf("expected no trigger, got %v", actual)
+			}
+			for i, expected := range test.expected {
+				if i >= len(actual) || !actual[i].Equal(mustParseTime(t, expected, location)) {
+					t.Fatalf("expected %v, got %v", test.expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestPrev(t *testing.T) {
+	spec, err := Parse("0 * * * *\n30 10 * * *", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for from, expected := range map[string]string{
+		"2020-06-01 10:45": "2020-06-01 10:30",
+		"2020-06-01 10:30": "2020-06-01 10:00",
+		"2020-06-01 00:00": "2020-05-31 23:00",
+	} {
+		if actual, ok := spec.Prev(mustParseTime(t, from, "UTC")); !ok || !actual.Equal(mustParseTime(t, expected, "UTC")) {
+			t.Errorf("expected the trigger before %s is %s, got %v", from, expected, actual)
+		}
+	}
+	if !spec.Matches(mustParseTime(t, "2020-06-01 10:30", "UTC")) || spec.Matches(mustParseTime(t, "2020-06-01 10:31", "UTC")) {
+		t.Error("expected the spec matches 10:30 only")
+	}
+}
+
+func TestHash(t *testing.T) {
+	// the well known first value of new java.util.Random(42).nextInt()
+	if actual := newJavaRandom(42).next(32); actual != -1170105035 {
+		t.Errorf("expected the java random generates -1170105035, got %d", actual)
+	}
+
+	first, err := Parse("H H(8-10) * * H", "project/pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := Parse("H H(8-10) * * H", "project/pipeline")
+	from := mustParseTime(t, "2020-06-01 00:00", "UTC")
+	times := first.NextN(from, 3)
+	if len(times) != 3 {
+		t.Fatalf("expected 3 triggers, got %v", times)
+	}
+	for i, actual := range times {
+		if hour := actual.Hour(); hour < 8 || hour > 10 {
+			t.Errorf("expected the hashed hour in 8-10, got %v", actual)
+		}
+		if i > 0 && actual.Sub(times[i-1]) != 7*24*time.Hour {
+			t.Errorf("expected the triggers are weekly, got %v", times)
+		}
+	}
+	if next, _ := second.Next(from); !next.Equal(times[0]) {
+		t.Errorf("expected the same seed hashes the same values, got %v and %v", next, times[0])
+	}
+
+	spread, _ := Parse("H/15 * * * *", "project/pipeline")
+	times = spread.NextN(from, 4)
+	for i := 1; i < len(times); i++ {
+		if times[i].Sub(times[i-1]) != 15*time.Minute || times[i].Minute() >= 60 {
+			t.Errorf("expected the triggers every 15 minutes from a hashed offset, got %v", times)
+		}
+	}
+}
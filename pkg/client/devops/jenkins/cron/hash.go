@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cron
+
+import (
+	"crypto/md5"
+)
+
+// hash returns the next hashed value in [0, n)
+type hash func(n int) int
+
+// newHash works as hudson.scheduler.Hash, the java.util.Random is seeded by the folded MD5 of the seed, so the H
+// symbols are resolved to the same values as Jenkins does
+func newHash(seed string) hash {
+	if seed == "" {
+		return func(n int) int {
+			return 0
+		}
+	}
+
+	digest := md5.Sum([]byte(seed))
+	for i := 8; i < len(digest); i++ {
+		digest[i%8] ^= digest[i]
+	}
+	var l int64
+	for i := 0; i < 8; i++ {
+		l = (l << 8) + int64(digest[i])
+	}
+	random := newJavaRandom(l)
+	return func(n int) int {
+		return int(random.nextInt(int32(n)))
+	}
+}
+
+// javaRandom is the linear congruential generator of java.util.Random
+type javaRandom struct {
+	seed int64
+}
+
+const (
+	javaRandomMultiplier = 0x5DEECE66D
+	javaRandomAddend     = 0xB
+	javaRandomMask       = (1 << 48) - 1
+)
+
+func newJavaRandom(seed int64) *javaRandom {
+	return &javaRandom{seed: (seed ^ javaRandomMultiplier) & javaRandomMask}
+}
+
+func (r *javaRandom) next(bits uint) int32 {
+	r.seed = (r.seed*javaRandomMultiplier + javaRandomAddend) & javaRandomMask
+	return int32(uint64(r.seed) >> (48 - bits))
+}
+
+func (r *javaRandom) nextInt(n int32) int32 {
+	if n&(-n) == n {
+		return int32((int64(n) * int64(r.next(31))) >> 31)
+	}
+	for {
+		bits := r.next(31)
+		val := bits % n
+		// the overflow rejects the values making the distribution uneven
+		if bits-val+(n-1) >= 0 {
+			return val
+		}
+	}
+}
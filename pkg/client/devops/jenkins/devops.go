@@ -14,7 +14,10 @@ limitations under the License.
 package jenkins
 
 import (
+	"fmt"
+
 	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/router"
 )
 
 func NewDevopsClient(options *Options) (devops.Interface, error) {
@@ -22,3 +25,37 @@ func NewDevopsClient(options *Options) (devops.Interface, error) {
 
 	return jenkins, nil
 }
+
+// NewBackendClient creates the client of an additional Jenkins backend, the maximum connections
+// of the default server is used if it is not specified
+func NewBackendClient(options *BackendOptions, defaultMaxConnections int) (devops.Interface, error) {
+	maxConnections := options.MaxConnections
+	if maxConnections <= 0 {
+		maxConnections = defaultMaxConnections
+	}
+	jenkins := CreateJenkins(nil, options.Host, maxConnections, options.Username, options.Password)
+
+	return jenkins, nil
+}
+
+// NewBackendRegistry creates the clients of the default Jenkins server and the additional backends
+func NewBackendRegistry(options *Options) (*router.Registry, error) {
+	devopsClient, err := NewDevopsClient(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to jenkins, please check jenkins status, error: %v", err)
+	}
+
+	registry := router.NewRegistry(DefaultBackendName)
+	registry.Add(DefaultBackendName, options.Host, devopsClient)
+	for _, backend := range options.Backends {
+		if backend == nil {
+			continue
+		}
+		backendClient, err := NewBackendClient(backend, options.MaxConnections)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to jenkins backend %s, error: %v", backend.Name, err)
+		}
+		registry.Add(backend.Name, backend.Host, backendClient)
+	}
+	return registry, nil
+}
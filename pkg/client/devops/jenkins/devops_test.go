@@ -17,40 +17,76 @@ limitations under the License.
 package jenkins
 
 import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
 )
 
-func Test_parseCronJobTime(t *testing.T) {
-	type Except struct {
-		Last string
-		Next string
-	}
+func Test_checkCron(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
 
 	Items := []struct {
-		Input    string
-		Expected Except
+		Spec     string
+		Result   string
+		Message  string
+		LastTime string
+		NextTime string
 	}{
-		{"上次运行的时间 Tuesday, September 10, 2019 8:59:09 AM UTC; 下次运行的时间 Tuesday, September 10, 2019 9:14:09 AM UTC.", Except{Last: "2019-09-10T08:59:09Z", Next: "2019-09-10T09:14:09Z"}},
-		{"上次运行的时间 Thursday, January 3, 2019 11:56:30 PM UTC; 下次运行的时间 Friday, January 3, 2020 12:11:30 AM UTC.", Except{Last: "2019-01-03T23:56:30Z", Next: "2020-01-03T00:11:30Z"}},
-		{"上次运行的时间 Tuesday, September 10, 2019 8:41:34 AM UTC; 下次运行的时间 Tuesday, September 10, 2019 9:41:34 AM UTC.", Except{Last: "2019-09-10T08:41:34Z", Next: "2019-09-10T09:41:34Z"}},
-		{"上次运行的时间 Tuesday, September 10, 2019 9:15:26 AM UTC; 下次运行的时间 Tuesday, September 10, 2019 10:03:26 AM UTC.", Except{Last: "2019-09-10T09:15:26Z", Next: "2019-09-10T10:03:26Z"}},
-		{"Would last have run at Tuesday, September 10, 2019 9:15:26 AM UTC; would next run at Tuesday, September 10, 2019 10:03:26 AM UTC.", Except{Last: "2019-09-10T09:15:26Z", Next: "2019-09-10T10:03:26Z"}},
-		{"Would last have run at Tuesday, September 10, 2019 8:41:34 AM UTC; would next run at Tuesday, September 10, 2019 9:41:34 AM UTC.", Except{Last: "2019-09-10T08:41:34Z", Next: "2019-09-10T09:41:34Z"}},
+		{"0 12 * * *", "ok", "Would last have run at Sunday, May 31, 2020 12:00:00 PM UTC; would next run at Monday, June 1, 2020 12:00:00 PM UTC.",
+			"2020-05-31T12:00:00Z", "2020-06-01T12:00:00Z"},
+		{"TZ=Asia/Shanghai\n30 8 * * 1-5", "ok", "Would last have run at Monday, June 1, 2020 12:30:00 AM UTC; would next run at Tuesday, June 2, 2020 12:30:00 AM UTC.",
+			"2020-06-01T00:30:00Z", "2020-06-02T00:30:00Z"},
+		{"0 0 31 2 *", "warning", "the cron spec never triggers", "", ""},
+		{"0 25 * * *", "error", "", "", ""},
 	}
 
 	for _, item := range Items {
-		last, next, err := parseCronJobTime(item.Input)
-		if err != nil {
-			t.Fatalf("should not get error %+v", err)
+		res := checkCron(item.Spec, "project/pipeline", now)
+		if res.Result != item.Result {
+			t.Fatalf("got result %#v of spec %#v, expected %#v: %s", res.Result, item.Spec, item.Result, res.Message)
 		}
-
-		if last != item.Expected.Last {
-			t.Errorf("got %#v, expected %#v", last, item.Expected.Last)
+		if item.Message != "" && res.Message != item.Message {
+			t.Errorf("got %#v, expected %#v", res.Message, item.Message)
 		}
-
-		if next != item.Expected.Next {
-			t.Errorf("got %#v, expected %#v", next, item.Expected.Next)
+		if res.LastTime != item.LastTime {
+			t.Errorf("got %#v, expected %#v", res.LastTime, item.LastTime)
+		}
+		if res.NextTime != item.NextTime {
+			t.Errorf("got %#v, expected %#v", res.NextTime, item.NextTime)
 		}
+	}
+}
+
+func TestCheckCron(t *testing.T) {
+	scripts := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scriptText", func(w http.ResponseWriter, r *http.Request) {
+		scripts++
+		w.Write([]byte("Asia/Shanghai\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	j := CreateJenkins(nil, server.URL, 10, "admin", "password")
 
+	for i := 0; i < 2; i++ {
+		httpParameters := &devops.HttpParameters{
+			Body: ioutil.NopCloser(bytes.NewBufferString(`{"cron": "0 9 * * *", "pipelineName": "pipeline"}`)),
+		}
+		res, err := j.CheckCron("project", httpParameters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Result != "ok" || !strings.HasSuffix(res.NextTime, "T09:00:00+08:00") {
+			t.Errorf("expected the spec is evaluated in the timezone of jenkins, got %+v", res)
+		}
+	}
+	if scripts != 1 {
+		t.Errorf("expected the timezone of jenkins is fetched once, got %d times", scripts)
 	}
 }
@@ -19,15 +19,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"k8s.io/klog"
 
@@ -44,6 +44,10 @@ type Jenkins struct {
 	Server    string
 	Version   string
 	Requester *Requester
+
+	locationMutex sync.Mutex
+	// location is the timezone of Jenkins, it is fetched once
+	location *time.Location
 }
 
 // Loggers
@@ -337,6 +341,54 @@ func (j *Jenkins) GetProjectRole(roleName string) (*ProjectRole, error) {
 	}, nil
 }
 
+// DescribeProjectRole returns the project role with its users, the users are the names before role-strategy 3.0
+// and the typed entries since, the groups are left out
+func (j *Jenkins) DescribeProjectRole(roleName string) (*devops.ProjectRole, error) {
+	stringResponse := ""
+	response, err := j.Requester.Get("/role-strategy/strategy/getRole",
+		&stringResponse,
+		map[string]string{
+			"roleName": roleName,
+			"type":     PROJECT_ROLE,
+		})
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New(strconv.Itoa(response.StatusCode))
+	}
+	if stringResponse == "{}" {
+		return nil, nil
+	}
+	roleResponse := struct {
+		PermissionIds devops.ProjectPermissionIds `json:"permissionIds"`
+		Pattern       string                      `json:"pattern"`
+		Sids          []json.RawMessage           `json:"sids"`
+	}{}
+	if err = json.Unmarshal([]byte(stringResponse), &roleResponse); err != nil {
+		return nil, err
+	}
+	role := &devops.ProjectRole{Pattern: roleResponse.Pattern, PermissionIds: roleResponse.PermissionIds}
+	for _, raw := range roleResponse.Sids {
+		var sid string
+		if json.Unmarshal(raw, &sid) != nil {
+			entry := struct {
+				Type string `json:"type"`
+				Sid  string `json:"sid"`
+			}{}
+			if err = json.Unmarshal(raw, &entry); err != nil {
+				return nil, err
+			}
+			if entry.Type == "GROUP" {
+				continue
+			}
+			sid = entry.Sid
+		}
+		role.Sids = append(role.Sids, sid)
+	}
+	return role, nil
+}
+
 // assign a project roleName to username(sid)
 func (j *Jenkins) AssignProjectRole(roleName string, sid string) error {
 	projectRole, err := j.GetProjectRole(roleName)
@@ -841,37 +893,58 @@ func (j *Jenkins) CheckScriptCompile(projectName, pipelineName string, httpParam
 	return res, err
 }
 
+// CheckCron checks the cron spec without running it in Jenkins, the lines without TZ are evaluated in the timezone
+// of Jenkins
 func (j *Jenkins) CheckCron(projectName string, httpParameters *devops.HttpParameters) (*devops.CheckCronRes, error) {
-	var cron = new(devops.CronData)
-	var reader io.ReadCloser
-	var path string
-
-	reader = httpParameters.Body
-	cronData, err := ioutil.ReadAll(reader)
-	err = json.Unmarshal(cronData, cron)
+	var cronData = new(devops.CronData)
+	data, err := ioutil.ReadAll(httpParameters.Body)
 	if err != nil {
 		klog.Error(err)
 		return nil, err
 	}
-
-	query := url.Values{
-		"value": []string{cron.Cron},
+	if err = json.Unmarshal(data, cronData); err != nil {
+		klog.Error(err)
+		return nil, err
 	}
 
-	if cron.PipelineName != "" {
-		path = fmt.Sprintf(CheckPipelienCronUrl, projectName, cron.PipelineName, query.Encode())
-	} else {
-		path = fmt.Sprintf(CheckCronUrl, projectName, query.Encode())
+	// H is hashed by the full name of the job, which is the folder of the project if the pipeline is not created yet
+	seed := projectName
+	if cronData.PipelineName != "" {
+		seed = projectName + "/" + cronData.PipelineName
+	}
+	location, err := j.timeZone()
+	if err != nil {
+		return nil, err
 	}
+	return checkCron(cronData.Cron, seed, time.Now().In(location)), nil
+}
 
-	PipelineOjb := &Pipeline{
-		HttpParameters: httpParameters,
-		Jenkins:        j,
-		Path:           path,
+// timeZoneScript prints the default timezone of the JVM, which is the timezone of cron unless TZ is set
+const timeZoneScript = `println TimeZone.getDefault().getID()`
+
+// timeZone returns the default timezone of Jenkins, which the cron specs are evaluated in
+func (j *Jenkins) timeZone() (*time.Location, error) {
+	j.locationMutex.Lock()
+	defer j.locationMutex.Unlock()
+	if j.location != nil {
+		return j.location, nil
 	}
 
-	res, err := PipelineOjb.CheckCron()
-	return res, err
+	responseString := ""
+	_, err := j.Requester.PostForm(ScriptTextUrl, nil, &responseString, map[string]string{
+		"script": timeZoneScript,
+	})
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	location, err := time.LoadLocation(strings.TrimSpace(responseString))
+	if err != nil {
+		klog.Errorf("failed to load the timezone of jenkins %q: %v", strings.TrimSpace(responseString), err)
+		return nil, err
+	}
+	j.location = location
+	return location, nil
 }
 
 func (j *Jenkins) ToJenkinsfile(httpParameters *devops.HttpParameters) (*devops.ResJenkinsfile, error) {
@@ -19,6 +19,7 @@ package jenkins
 import (
 	"devops.kubesphere.io/plugin/pkg/utils/reflectutils"
 	"fmt"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -28,6 +29,40 @@ type Options struct {
 	Username       string `json:",omitempty" yaml:"username" description:"Jenkins admin username"`
 	Password       string `json:",omitempty" yaml:"password" description:"Jenkins admin password"`
 	MaxConnections int    `json:"maxConnections,omitempty" yaml:"maxConnections" description:"Maximum connections allowed to connect to Jenkins"`
+
+	ResponseCacheTTL time.Duration `json:"responseCacheTTL,omitempty" yaml:"responseCacheTTL" description:"Time to live of the cached responses of expensive Jenkins reads, 0 means disabled. The replicas share the cache only if redis is enabled"`
+
+	// Backends are the additional Jenkins servers, the server above is the default one
+	Backends []*BackendOptions `json:"backends,omitempty" yaml:"backends" description:"Additional Jenkins backends which serve the DevOps projects of some workspaces"`
+}
+
+// BackendOptions is a named Jenkins server, DevOps projects in Workspaces are served by it.
+// A DevOps project could also choose the backend by the annotation devops.kubesphere.io/jenkins-backend
+// of its namespace.
+type BackendOptions struct {
+	Name           string   `json:"name" yaml:"name" description:"Name of Jenkins backend"`
+	Host           string   `json:"host" yaml:"host" description:"Jenkins service host address"`
+	Username       string   `json:",omitempty" yaml:"username" description:"Jenkins admin username"`
+	Password       string   `json:",omitempty" yaml:"password" description:"Jenkins admin password"`
+	MaxConnections int      `json:"maxConnections,omitempty" yaml:"maxConnections" description:"Maximum connections allowed to connect to Jenkins"`
+	Workspaces     []string `json:"workspaces,omitempty" yaml:"workspaces" description:"Workspaces whose DevOps projects are served by this backend"`
+}
+
+// DefaultBackendName is the name of the Jenkins server defined by the top level options
+const DefaultBackendName = "default"
+
+// Workspaces maps the workspaces to the names of the backends serving them
+func (s *Options) Workspaces() map[string]string {
+	workspaces := make(map[string]string)
+	for _, backend := range s.Backends {
+		if backend == nil {
+			continue
+		}
+		for _, workspace := range backend.Workspaces {
+			workspaces[workspace] = backend.Name
+		}
+	}
+	return workspaces
 }
 
 // NewDevopsOptions returns a `zero` instance
@@ -37,6 +72,8 @@ func NewDevopsOptions() *Options {
 		Username:       "",
 		Password:       "",
 		MaxConnections: 100,
+		// the cache is opt-in, the replicas without redis cache the responses separately
+		ResponseCacheTTL: 0,
 	}
 }
 
@@ -64,6 +101,40 @@ func (s *Options) Validate() []error {
 		errors = append(errors, fmt.Errorf("jenkins's maximum connections should be greater than 0"))
 	}
 
+	if s.ResponseCacheTTL < 0 {
+		errors = append(errors, fmt.Errorf("jenkins's response cache ttl should not be negative"))
+	}
+
+	names := make(map[string]bool)
+	workspaces := make(map[string]string)
+	for _, backend := range s.Backends {
+		if backend == nil {
+			continue
+		}
+		if backend.Name == "" || backend.Name == DefaultBackendName {
+			errors = append(errors, fmt.Errorf("jenkins backend's name should not be empty or %s", DefaultBackendName))
+		} else if names[backend.Name] {
+			errors = append(errors, fmt.Errorf("jenkins backend %s is duplicated", backend.Name))
+		}
+		names[backend.Name] = true
+
+		if backend.Host == "" {
+			errors = append(errors, fmt.Errorf("jenkins backend %s's host is empty", backend.Name))
+		}
+		if backend.Username == "" || backend.Password == "" {
+			errors = append(errors, fmt.Errorf("jenkins backend %s's username or password is empty", backend.Name))
+		}
+		if backend.MaxConnections < 0 {
+			errors = append(errors, fmt.Errorf("jenkins backend %s's maximum connections should not be negative", backend.Name))
+		}
+		for _, workspace := range backend.Workspaces {
+			if other, ok := workspaces[workspace]; ok && other != backend.Name {
+				errors = append(errors, fmt.Errorf("workspace %s is mapped to both jenkins backend %s and %s", workspace, other, backend.Name))
+			}
+			workspaces[workspace] = backend.Name
+		}
+	}
+
 	return errors
 }
 
@@ -81,4 +152,8 @@ func (s *Options) AddFlags(fs *pflag.FlagSet, c *Options) {
 	fs.IntVar(&s.MaxConnections, "jenkins-max-connections", c.MaxConnections, ""+
 		"Maximum allowed connections to Jenkins. ")
 
+	fs.DurationVar(&s.ResponseCacheTTL, "jenkins-response-cache-ttl", c.ResponseCacheTTL, ""+
+		"Time to live of the cached responses of pipeline, run, branch and SCM listings, 0 means disabled. "+
+		"Without redis, every replica caches the responses in its own memory, so they may respond differently.")
+
 }
@@ -17,19 +17,17 @@ limitations under the License.
 package jenkins
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"k8s.io/klog"
 
 	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins/cron"
 )
 
 type Pipeline struct {
@@ -80,12 +78,11 @@ const (
 	GenericWebhookUrl     = "/generic-webhook-trigger/invoke?"
 	CheckScriptCompileUrl = "/job/%s/job/%s/descriptorByName/org.jenkinsci.plugins.workflow.cps.CpsFlowDefinition/checkScriptCompile"
 
-	CheckPipelienCronUrl = "/job/%s/job/%s/descriptorByName/hudson.triggers.TimerTrigger/checkSpec?%s"
-	CheckCronUrl         = "/job/%s/descriptorByName/hudson.triggers.TimerTrigger/checkSpec?%s"
-	ToJenkinsfileUrl     = "/pipeline-model-converter/toJenkinsfile"
-	ToJsonUrl            = "/pipeline-model-converter/toJson"
+	ToJenkinsfileUrl = "/pipeline-model-converter/toJenkinsfile"
+	ToJsonUrl        = "/pipeline-model-converter/toJson"
 
-	cronJobLayout = "Monday, January 2, 2006 15:04:05 PM"
+	// cronMessageLayout is the layout of the trigger times in the message of the cron check, as Jenkins shows them
+	cronMessageLayout = "Monday, January 2, 2006 3:04:05 PM MST"
 )
 
 func (p *Pipeline) GetPipeline() (*devops.Pipeline, error) {
@@ -746,110 +743,27 @@ func (p *Pipeline) CheckScriptCompile() (*devops.CheckScript, error) {
 
 }
 
-func (p *Pipeline) CheckCron() (*devops.CheckCronRes, error) {
-	var res = new(devops.CheckCronRes)
-
-	reqJenkins := &http.Request{
-		Method: http.MethodGet,
-		Header: p.HttpParameters.Header,
-	}
-	if cronServiceURL, err := url.Parse(p.Jenkins.Server + p.Path); err != nil {
-		klog.Errorf(fmt.Sprintf("cannot parse Jenkins cronService URL, error: %#v", err))
-		return interanlErrorMessage(), err
-	} else {
-		reqJenkins.URL = cronServiceURL
-	}
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	reqJenkins.SetBasicAuth(p.Jenkins.Requester.BasicAuth.Username, p.Jenkins.Requester.BasicAuth.Password)
-	resp, err := client.Do(reqJenkins)
+// checkCron validates the cron spec of the TimerTrigger, and gives the last and the next trigger times around now.
+// The seed is the full name of the job hashing the H symbols.
+func checkCron(spec, seed string, now time.Time) *devops.CheckCronRes {
+	parsed, err := cron.Parse(spec, seed)
 	if err != nil {
-		klog.Error(err)
-		return interanlErrorMessage(), err
+		return &devops.CheckCronRes{Result: "error", Message: err.Error()}
 	}
 
-	var responseText string
-	if resp != nil {
-		if responseData, err := getRespBody(resp); err == nil {
-			responseText = string(responseData)
-		} else {
-			klog.Error(err)
-			return interanlErrorMessage(), fmt.Errorf("cannot get the response body from the Jenkins cron service request, %#v", err)
-		}
-
-		defer func() {
-			_ = resp.Body.Close()
-		}()
-
-		statusCode := resp.StatusCode
-		if statusCode != http.StatusOK && statusCode != http.StatusBadRequest {
-			// the response body is meaningless for the users, but it's useful for a contributor
-			klog.Errorf("cron service from Jenkins is unavailable, error response: %v, status code: %d", responseText, statusCode)
-			return interanlErrorMessage(), err
-		}
+	next, ok := parsed.Next(now)
+	if !ok {
+		return &devops.CheckCronRes{Result: "warning", Message: "the cron spec never triggers"}
 	}
-	klog.V(8).Infof("response text: %s", responseText)
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader([]byte(responseText)))
-	if err != nil {
-		klog.Error(err)
-		return interanlErrorMessage(), err
-	}
-	// it gives a message which located in <div>...</div> when the status code is 200
-	doc.Find("div").Each(func(i int, selection *goquery.Selection) {
-		res.Message = selection.Text()
-		res.Result, _ = selection.Attr("class")
-	})
-	// it gives a message which located in <pre>...</pre> when the status code is 400
-	doc.Find("pre").Each(func(i int, selection *goquery.Selection) {
-		res.Message = selection.Text()
-		res.Result = "error"
-	})
-	if res.Result == "ok" {
-		res.LastTime, res.NextTime, err = parseCronJobTime(res.Message)
-		if err != nil {
-			klog.Error(err)
-			return interanlErrorMessage(), err
-		}
-	}
-
-	return res, err
-}
-
-func interanlErrorMessage() *devops.CheckCronRes {
-	return &devops.CheckCronRes{
-		Result:  "error",
-		Message: "internal errors happened, get more details by checking ks-apiserver log output",
-	}
-}
-
-func parseCronJobTime(msg string) (string, string, error) {
-
-	times := strings.Split(msg, ";")
-
-	lastTmp := strings.Split(times[0], " ")
-	lastCount := len(lastTmp)
-	lastTmp = lastTmp[lastCount-7 : lastCount-1]
-	lastTime := strings.Join(lastTmp, " ")
-	lastUinx, err := time.Parse(cronJobLayout, lastTime)
-	if err != nil {
-		klog.Error(err)
-		return "", "", err
-	}
-	last := lastUinx.Format(time.RFC3339)
-
-	nextTmp := strings.Split(times[1], " ")
-	nextCount := len(nextTmp)
-	nextTmp = nextTmp[nextCount-7 : nextCount-1]
-	nextTime := strings.Join(nextTmp, " ")
-	nextUinx, err := time.Parse(cronJobLayout, nextTime)
-	if err != nil {
-		klog.Error(err)
-		return "", "", err
+	res := &devops.CheckCronRes{Result: "ok", NextTime: next.Format(time.RFC3339)}
+	if last, ok := parsed.Prev(now); ok {
+		res.LastTime = last.Format(time.RFC3339)
+		res.Message = fmt.Sprintf("Would last have run at %s; would next run at %s.",
+			last.Format(cronMessageLayout), next.Format(cronMessageLayout))
+	} else {
+		res.Message = fmt.Sprintf("Would next run at %s.", next.Format(cronMessageLayout))
 	}
-	next := nextUinx.Format(time.RFC3339)
-
-	return last, next, nil
+	return res
 }
 
 func (p *Pipeline) ToJenkinsfile() (*devops.ResJenkinsfile, error) {
@@ -0,0 +1,188 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jenkins
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+const (
+	QueueUrl           = "/queue"
+	QueueItemUrl       = "/queue/item/%d"
+	CancelQueueItemUrl = "/queue/cancelItem"
+	ScriptTextUrl      = "/scriptText"
+
+	queueTree = "items[id,why,blocked,buildable,stuck,inQueueSince,task[name,url],assignedLabel[name]]"
+)
+
+// quotedLabel matches the labels in the reasons of the blockage, such as "Waiting for next available executor on ‘maven’"
+var quotedLabel = regexp.MustCompile(`‘([^’]+)’`)
+
+type queueResponse struct {
+	Items []struct {
+		ID           int64  `json:"id"`
+		Why          string `json:"why"`
+		Blocked      bool   `json:"blocked"`
+		Buildable    bool   `json:"buildable"`
+		Stuck        bool   `json:"stuck"`
+		InQueueSince int64  `json:"inQueueSince"`
+		Task         struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"task"`
+		AssignedLabel *struct {
+			Name string `json:"name"`
+		} `json:"assignedLabel"`
+	} `json:"items"`
+}
+
+// ListQueueItems fetches the queue once and keeps the items of the projects
+func (j *Jenkins) ListQueueItems(projectNames ...string) ([]devops.QueueItem, error) {
+	projects := make(map[string]bool, len(projectNames))
+	for _, projectName := range projectNames {
+		projects[projectName] = true
+	}
+	queue := &queueResponse{}
+	response, err := j.Requester.GetJSON(QueueUrl, queue, map[string]string{"tree": queueTree})
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New(strconv.Itoa(response.StatusCode))
+	}
+
+	// the items most likely built sooner are at the end of the queue
+	items := make([]devops.QueueItem, 0)
+	for i := len(queue.Items) - 1; i >= 0; i-- {
+		raw := queue.Items[i]
+		project, pipeline, branch, runId, ok := parseTaskUrl(raw.Task.URL)
+		if !ok || !projects[project] {
+			continue
+		}
+		item := devops.QueueItem{
+			ID:           raw.ID,
+			Project:      project,
+			Pipeline:     pipeline,
+			Branch:       branch,
+			RunID:        runId,
+			Why:          strings.TrimSpace(raw.Why),
+			Blocked:      raw.Blocked,
+			Buildable:    raw.Buildable,
+			Stuck:        raw.Stuck,
+			InQueueSince: raw.InQueueSince,
+			Position:     len(queue.Items) - i,
+		}
+		if raw.AssignedLabel != nil && raw.AssignedLabel.Name != "" {
+			item.Labels = append(item.Labels, raw.AssignedLabel.Name)
+		}
+		for _, match := range quotedLabel.FindAllStringSubmatch(raw.Why, -1) {
+			if !containsString(item.Labels, match[1]) {
+				item.Labels = append(item.Labels, match[1])
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (j *Jenkins) CancelQueueItem(projectName string, id int64) error {
+	if err := j.checkQueueItem(projectName, id); err != nil {
+		return err
+	}
+	responseString := ""
+	_, err := j.Requester.Post(CancelQueueItemUrl, nil, &responseString, map[string]string{
+		"id": strconv.FormatInt(id, 10),
+	})
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	return nil
+}
+
+// checkQueueItem makes sure the item is queued by the project, so the items of other projects are not touched
+func (j *Jenkins) checkQueueItem(projectName string, id int64) error {
+	item := &struct {
+		Task struct {
+			URL string `json:"url"`
+		} `json:"task"`
+	}{}
+	response, err := j.Requester.GetJSON(fmt.Sprintf(QueueItemUrl, id), item, map[string]string{"tree": "task[url]"})
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return errors.New(strconv.Itoa(response.StatusCode))
+	}
+	if project, _, _, _, ok := parseTaskUrl(item.Task.URL); !ok || project != projectName {
+		return errors.New(strconv.Itoa(http.StatusNotFound))
+	}
+	return nil
+}
+
+// parseTaskUrl extracts the pipeline from the url of the task, such as http://jenkins/job/project/job/pipeline/,
+// http://jenkins/job/project/job/pipeline/job/branch/ or http://jenkins/job/project/job/pipeline/5/ of a node block
+func parseTaskUrl(taskUrl string) (project, pipeline, branch, runId string, ok bool) {
+	u, err := url.Parse(taskUrl)
+	if err != nil {
+		return
+	}
+	index := strings.Index(u.Path, "/job/")
+	if index < 0 {
+		return
+	}
+	var jobs []string
+	segments := strings.Split(strings.Trim(u.Path[index:], "/"), "/")
+	for i := 0; i < len(segments); i++ {
+		if segments[i] == "job" && i+1 < len(segments) {
+			jobs = append(jobs, segments[i+1])
+			i++
+			continue
+		}
+		runId = segments[i]
+		break
+	}
+	if len(jobs) < 2 || len(jobs) > 3 {
+		return "", "", "", "", false
+	}
+	project, pipeline = jobs[0], jobs[1]
+	if len(jobs) == 3 {
+		branch = jobs[2]
+	}
+	return project, pipeline, branch, runId, true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
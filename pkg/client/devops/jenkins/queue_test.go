@@ -0,0 +1,90 @@
+package jenkins
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+const queueJson = `{"items": [
+	{"id": 15, "why": "Build #3 is already in progress (ETA: 1 min 2 sec)", "blocked": true, "buildable": false,
+		"stuck": false, "inQueueSince": 1590000060000,
+		"task": {"name": "pipeline", "url": "http://jenkins/job/project/job/pipeline/"}},
+	{"id": 14, "why": "In the quiet period. Expires in 4.9 sec", "blocked": false, "buildable": false,
+		"inQueueSince": 1590000030000,
+		"task": {"name": "pipeline", "url": "http://jenkins/job/other/job/pipeline/"}},
+	{"id": 13, "why": "There are no nodes with the label ‘go’", "blocked": false, "buildable": true, "stuck": true,
+		"inQueueSince": 1590000010000, "assignedLabel": {"name": "go"},
+		"task": {"name": "master", "url": "http://jenkins/job/project/job/multi/job/master/"}},
+	{"id": 12, "why": "Waiting for next available executor on ‘maven’", "blocked": false, "buildable": true,
+		"inQueueSince": 1590000000000,
+		"task": {"name": "part of project » pipeline #2", "url": "http://jenkins/job/project/job/pipeline/2/"}}
+]}`
+
+func newQueueServer(requests *[]string) (*Jenkins, *httptest.Server) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/queue/api/json", func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+		w.Write([]byte(queueJson))
+	})
+	mux.HandleFunc("/queue/item/", func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/queue/item/12/api/json":
+			w.Write([]byte(`{"task": {"url": "http://jenkins/job/project/job/pipeline/2/"}}`))
+		case "/queue/item/14/api/json":
+			w.Write([]byte(`{"task": {"url": "http://jenkins/job/other/job/pipeline/"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("/queue/cancelItem", func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.RequestURI())
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	return CreateJenkins(nil, server.URL, 10, "admin", "password"), server
+}
+
+func TestListQueueItems(t *testing.T) {
+	var requests []string
+	j, server := newQueueServer(&requests)
+	defer server.Close()
+
+	items, err := j.ListQueueItems("project")
+	assert.Nil(t, err)
+	assert.Equal(t, []devops.QueueItem{
+		{ID: 12, Project: "project", Pipeline: "pipeline", RunID: "2", Why: "Waiting for next available executor on ‘maven’",
+			Buildable: true, InQueueSince: 1590000000000, Labels: []string{"maven"}, Position: 1},
+		{ID: 13, Project: "project", Pipeline: "multi", Branch: "master", Why: "There are no nodes with the label ‘go’",
+			Buildable: true, Stuck: true, InQueueSince: 1590000010000, Labels: []string{"go"}, Position: 2},
+		{ID: 15, Project: "project", Pipeline: "pipeline", Why: "Build #3 is already in progress (ETA: 1 min 2 sec)",
+			Blocked: true, InQueueSince: 1590000060000, Position: 4},
+	}, items)
+
+	// the queue is fetched once for all the projects
+	requests = nil
+	items, err = j.ListQueueItems("project", "other")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(items))
+	assert.Equal(t, "other", items[2].Project)
+	assert.Equal(t, []string{"GET /queue/api/json"}, requests)
+}
+
+func TestCancelQueueItem(t *testing.T) {
+	var requests []string
+	j, server := newQueueServer(&requests)
+	defer server.Close()
+
+	err := j.CancelQueueItem("project", 14)
+	assert.Equal(t, http.StatusNotFound, devops.GetDevOpsStatusCode(err), "the item of other project is not cancelled")
+	err = j.CancelQueueItem("project", 16)
+	assert.Equal(t, http.StatusNotFound, devops.GetDevOpsStatusCode(err), "the item left the queue")
+	assert.Nil(t, j.CancelQueueItem("project", 12))
+	assert.Equal(t, []string{"GET /queue/item/14/api/json", "GET /queue/item/16/api/json",
+		"GET /queue/item/12/api/json", "POST /queue/cancelItem?id=12"}, requests)
+}
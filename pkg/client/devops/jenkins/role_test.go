@@ -0,0 +1,47 @@
+package jenkins
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+func TestDescribeProjectRole(t *testing.T) {
+	roles := map[string]string{
+		// role-strategy before 3.0
+		"foo/viewer/project": `{"permissionIds": {"hudson.model.Item.Read": true, "hudson.model.Item.Build": false},
+			"pattern": "^foo(/.*)?$", "sids": ["alice", "bob"]}`,
+		// role-strategy since 3.0
+		"bar/viewer/project": `{"permissionIds": {"hudson.model.Item.Read": true}, "pattern": "^bar(/.*)?$",
+			"sids": [{"type": "USER", "sid": "alice"}, {"type": "GROUP", "sid": "admins"}, {"type": "EITHER", "sid": "bob"}]}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/role-strategy/strategy/getRole/", func(w http.ResponseWriter, r *http.Request) {
+		if role, ok := roles[r.URL.Query().Get("roleName")]; ok && r.URL.Query().Get("type") == PROJECT_ROLE {
+			w.Write([]byte(role))
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	j := CreateJenkins(nil, server.URL, 10, "admin", "password")
+
+	role, err := j.DescribeProjectRole("foo/viewer/project")
+	assert.Nil(t, err)
+	assert.Equal(t, &devops.ProjectRole{Pattern: "^foo(/.*)?$", PermissionIds: devops.ProjectPermissionIds{ItemRead: true},
+		Sids: []string{"alice", "bob"}}, role)
+
+	role, err = j.DescribeProjectRole("bar/viewer/project")
+	assert.Nil(t, err)
+	assert.Equal(t, &devops.ProjectRole{Pattern: "^bar(/.*)?$", PermissionIds: devops.ProjectPermissionIds{ItemRead: true},
+		Sids: []string{"alice", "bob"}}, role)
+
+	role, err = j.DescribeProjectRole("missing")
+	assert.Nil(t, err)
+	assert.Nil(t, role)
+}
@@ -100,19 +100,33 @@ func ParseJenkinsQuery(query string) (result url.Values, err error) {
 	return
 }
 
+// JenkinsBlueTimeLayout is the layout of the timestamps in the BlueOcean API
+const JenkinsBlueTimeLayout = "2006-01-02T15:04:05.000-0700"
+
 type JenkinsBlueTime time.Time
 
+// ParseJenkinsBlueTime parses a timestamp of BlueOcean, the RFC3339 timestamps written by MarshalJSON are accepted too
+func ParseJenkinsBlueTime(value string) (JenkinsBlueTime, error) {
+	j, err := time.Parse(JenkinsBlueTimeLayout, value)
+	if err != nil {
+		var rfcErr error
+		if j, rfcErr = time.Parse(time.RFC3339, value); rfcErr != nil {
+			return JenkinsBlueTime{}, err
+		}
+	}
+	return JenkinsBlueTime(j), nil
+}
+
 func (t *JenkinsBlueTime) UnmarshalJSON(b []byte) error {
 	if b == nil || strings.Trim(string(b), "\"") == "null" {
 		*t = JenkinsBlueTime(time.Time{})
 		return nil
 	}
-	j, err := time.Parse("2006-01-02T15:04:05.000-0700", strings.Trim(string(b), "\""))
-
+	j, err := ParseJenkinsBlueTime(strings.Trim(string(b), "\""))
 	if err != nil {
 		return err
 	}
-	*t = JenkinsBlueTime(j)
+	*t = j
 	return nil
 }
 
@@ -1,8 +1,10 @@
 package jenkins
 
 import (
+	"encoding/json"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -51,6 +53,24 @@ func TestParseJenkinsQuery(t *testing.T) {
 	}
 }
 
+func TestJenkinsBlueTime(t *testing.T) {
+	var blueTime JenkinsBlueTime
+	err := json.Unmarshal([]byte(`"2020-10-01T16:00:00.123+0800"`), &blueTime)
+	assert.Nil(t, err)
+	assert.True(t, time.Time(blueTime).Equal(time.Date(2020, 10, 1, 8, 0, 0, 123000000, time.UTC)))
+
+	// the marshalled time can be read back
+	data, err := json.Marshal(blueTime)
+	assert.Nil(t, err)
+	var readBack JenkinsBlueTime
+	assert.Nil(t, json.Unmarshal(data, &readBack))
+	assert.True(t, time.Time(readBack).Equal(time.Time(blueTime)))
+
+	assert.Nil(t, json.Unmarshal([]byte(`null`), &readBack))
+	assert.True(t, time.Time(readBack).IsZero())
+	assert.NotNil(t, json.Unmarshal([]byte(`"yesterday"`), &readBack))
+}
+
 type testData struct {
 	param    string
 	expected interface{}
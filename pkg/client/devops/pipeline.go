@@ -17,9 +17,11 @@ limitations under the License.
 package devops
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
@@ -1111,6 +1113,24 @@ func (i *Input) Approvable(identify string) (ok bool) {
 	return
 }
 
+// ApprovableBy returns the result if the user or any of the groups of the user is one of the submitters
+func (i *Input) ApprovableBy(name string, groups []string) bool {
+	for _, submitter := range i.GetSubmitters() {
+		if submitter == "" {
+			continue
+		}
+		if submitter == name {
+			return true
+		}
+		for _, group := range groups {
+			if submitter == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type HttpParameters struct {
 	Method   string        `json:"method,omitempty"`
 	Header   http.Header   `json:"header,omitempty"`
@@ -1120,6 +1140,38 @@ type HttpParameters struct {
 	Url      *url.URL      `json:"url,omitempty"`
 }
 
+// NewHttpParameters returns the parameters of a request which the plugin sends to Jenkins on its own rather than
+// proxies for a user, the body is sent as JSON if it is not nil
+func NewHttpParameters(method string, body []byte) *HttpParameters {
+	httpParameters := &HttpParameters{
+		Method: method,
+		Header: http.Header{},
+		Url:    &url.URL{},
+	}
+	if body != nil {
+		httpParameters.Header.Set("Content-Type", "application/json")
+		httpParameters.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return httpParameters
+}
+
+// ProjectOfSearchQuery returns the project name in the BlueOcean search query, like
+// q=type:pipeline;organization:jenkins;pipeline:project/*
+func ProjectOfSearchQuery(httpParameters *HttpParameters) string {
+	if httpParameters == nil || httpParameters.Url == nil {
+		return ""
+	}
+	for _, term := range strings.Split(httpParameters.Url.Query().Get("q"), ";") {
+		if strings.HasPrefix(term, "pipeline:") {
+			pipeline := strings.TrimPrefix(term, "pipeline:")
+			if i := strings.Index(pipeline, "/"); i > 0 {
+				return pipeline[:i]
+			}
+		}
+	}
+	return ""
+}
+
 type PipelineOperator interface {
 	// Pipelinne operator interface
 	GetPipeline(projectName, pipelineName string, httpParameters *HttpParameters) (*Pipeline, error)
@@ -35,6 +35,17 @@ func TestApprovable(t *testing.T) {
 	assert.Equal(t, input.Approvable("bad"), true, "should be approvable")
 }
 
+func TestApprovableBy(t *testing.T) {
+	input := &Input{}
+	assert.Equal(t, input.ApprovableBy("", nil), false, "should not approve by nobody if there's no submitter given")
+
+	input.Submitter = "fake, release-managers"
+	assert.Equal(t, input.ApprovableBy("fake", nil), true, "should be approvable by the submitter")
+	assert.Equal(t, input.ApprovableBy("rick", []string{"developers"}), false, "should not approve by who is not in the groups")
+	assert.Equal(t, input.ApprovableBy("rick", []string{"developers", "release-managers"}), true, "should be approvable by the group")
+	assert.Equal(t, input.ApprovableBy("", []string{""}), false, "should not approve by the empty group")
+}
+
 func TestPipelineJsonMarshall(t *testing.T) {
 	const name = "fakeName"
 	var err error
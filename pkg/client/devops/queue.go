@@ -0,0 +1,49 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+// QueueItem is a pipeline, or a node block of a running pipeline, waiting in the build queue of Jenkins
+type QueueItem struct {
+	ID       int64  `json:"id"`
+	Project  string `json:"project"`
+	Pipeline string `json:"pipeline"`
+	Branch   string `json:"branch,omitempty"`
+	// RunID is the pipeline run waiting for an executor of its node block, it is empty if the pipeline is not started
+	RunID string `json:"runId,omitempty"`
+	// Why is the reason of the blockage given by Jenkins
+	Why       string `json:"why,omitempty"`
+	Blocked   bool   `json:"blocked"`
+	Buildable bool   `json:"buildable"`
+	Stuck     bool   `json:"stuck"`
+	// InQueueSince is the timestamp in milliseconds
+	InQueueSince int64 `json:"inQueueSince"`
+	// Labels is the requested labels of the agents
+	Labels []string `json:"labels,omitempty"`
+	// Position is the place in the queue of Jenkins, the item at position 1 is likely built first
+	Position int `json:"position"`
+}
+
+// QueueOperator provides API for inspecting and managing the build queue of the projects.
+// The queue is global in Jenkins, the items of other projects are never returned or touched.
+// Reordering the items is not supported: Jenkins has no API for the priority of a queued item, the Priority
+// Sorter plugin only prioritizes the jobs by its global strategies, and replacing the QueueSorter of Jenkins
+// by a script affects every tenant of the shared queue.
+type QueueOperator interface {
+	// ListQueueItems returns the queued items of the projects, the queue is fetched once for all of them
+	ListQueueItems(projectNames ...string) ([]QueueItem, error)
+	CancelQueueItem(projectName string, id int64) error
+}
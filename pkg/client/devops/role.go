@@ -71,6 +71,13 @@ type ProjectPermissionIds struct {
 	SCMTag                  bool `json:"hudson.scm.SCM.Tag"`
 }
 
+// ProjectRole is a project role of the role-strategy with the users assigned to it
+type ProjectRole struct {
+	Pattern       string
+	PermissionIds ProjectPermissionIds
+	Sids          []string
+}
+
 // describe the interface of DevOps to operator role
 type RoleOperator interface {
 	AddGlobalRole(roleName string, ids GlobalPermissionIds, overwrite bool) error
@@ -78,6 +85,8 @@ type RoleOperator interface {
 
 	AddProjectRole(roleName string, pattern string, ids ProjectPermissionIds, overwrite bool) error
 	DeleteProjectRoles(roleName ...string) error
+	// DescribeProjectRole returns the project role with its users, it is nil if the role doesn't exist
+	DescribeProjectRole(roleName string) (*ProjectRole, error)
 
 	AssignProjectRole(roleName string, sid string) error
 	UnAssignProjectRole(roleName string, sid string) error
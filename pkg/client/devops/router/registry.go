@@ -0,0 +1,228 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+// DefaultHealthCheckInterval is the interval of checking the health of backends
+const DefaultHealthCheckInterval = 30 * time.Second
+
+// poller is implemented by the Jenkins client, it is used to check the health of backend
+type poller interface {
+	Poll() (int, error)
+}
+
+// Backend is a named Jenkins server
+type Backend struct {
+	Name   string
+	Host   string
+	Client devops.Interface
+
+	mutex           sync.Mutex
+	healthy         bool
+	lastCheckTime   time.Time
+	lastCheckError  string
+	requests        int64
+	errors          int64
+	totalLatency    time.Duration
+	lastError       string
+	lastRequestTime time.Time
+}
+
+// BackendStatus is the health and metrics of a Jenkins backend
+type BackendStatus struct {
+	Name             string    `json:"name" description:"name of Jenkins backend"`
+	Host             string    `json:"host" description:"Jenkins service host address"`
+	Default          bool      `json:"default" description:"whether it is the default backend"`
+	Healthy          bool      `json:"healthy" description:"result of the last health check"`
+	LastCheckTime    time.Time `json:"lastCheckTime,omitempty" description:"time of the last health check"`
+	LastCheckError   string    `json:"lastCheckError,omitempty" description:"error of the last health check"`
+	Requests         int64     `json:"requests" description:"number of requests sent to the backend"`
+	Errors           int64     `json:"errors" description:"number of failed requests"`
+	AverageLatencyMs int64     `json:"averageLatencyMs" description:"average latency of requests in milliseconds"`
+	LastError        string    `json:"lastError,omitempty" description:"error of the last failed request"`
+	LastRequestTime  time.Time `json:"lastRequestTime,omitempty" description:"time of the last request"`
+}
+
+// observe records the result of a request which started at start
+func (b *Backend) observe(start time.Time, err *error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.requests++
+	b.totalLatency += time.Since(start)
+	b.lastRequestTime = start
+	if err != nil && *err != nil {
+		b.errors++
+		b.lastError = (*err).Error()
+	}
+}
+
+func (b *Backend) check() {
+	p, ok := b.Client.(poller)
+	if !ok {
+		return
+	}
+
+	status, err := p.Poll()
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("unexpected status code %d", status)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.lastCheckTime = time.Now()
+	b.healthy = err == nil
+	if err != nil {
+		b.lastCheckError = err.Error()
+		klog.Warningf("jenkins backend %s is unhealthy: %v", b.Name, err)
+	} else {
+		b.lastCheckError = ""
+	}
+}
+
+func (b *Backend) status() BackendStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := BackendStatus{
+		Name:            b.Name,
+		Host:            b.Host,
+		Healthy:         b.healthy,
+		LastCheckTime:   b.lastCheckTime,
+		LastCheckError:  b.lastCheckError,
+		Requests:        b.requests,
+		Errors:          b.errors,
+		LastError:       b.lastError,
+		LastRequestTime: b.lastRequestTime,
+	}
+	if b.requests > 0 {
+		status.AverageLatencyMs = (b.totalLatency / time.Duration(b.requests)).Milliseconds()
+	}
+	return status
+}
+
+// Registry holds all the Jenkins backends
+type Registry struct {
+	defaultBackend string
+
+	mutex    sync.RWMutex
+	backends map[string]*Backend
+}
+
+func NewRegistry(defaultBackend string) *Registry {
+	return &Registry{
+		defaultBackend: defaultBackend,
+		backends:       make(map[string]*Backend),
+	}
+}
+
+// Add registers the backend, the backend is treated as healthy until the first health check
+func (r *Registry) Add(name, host string, client devops.Interface) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.backends[name] = &Backend{
+		Name:    name,
+		Host:    host,
+		Client:  client,
+		healthy: true,
+	}
+}
+
+// Get returns the backend by name, empty name means the default backend. A backend which does not exist is
+// an error rather than the default backend, the projects of a missing backend must not leak into another one.
+func (r *Registry) Get(name string) (*Backend, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if name == "" {
+		name = r.defaultBackend
+	}
+	if backend, ok := r.backends[name]; ok {
+		return backend, nil
+	}
+	return nil, fmt.Errorf("jenkins backend %s not found", name)
+}
+
+// Default returns the default backend
+func (r *Registry) Default() *Backend {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.backends[r.defaultBackend]
+}
+
+// List returns all the backends sorted by name
+func (r *Registry) List() []*Backend {
+	r.mutex.RLock()
+	backends := make([]*Backend, 0, len(r.backends))
+	for _, backend := range r.backends {
+		backends = append(backends, backend)
+	}
+	r.mutex.RUnlock()
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].Name < backends[j].Name
+	})
+	return backends
+}
+
+// Replace swaps the backends with the ones of other at once, e.g. after the credentials of Jenkins change.
+// The requests in flight finish with the replaced clients, and the metrics start over.
+func (r *Registry) Replace(other *Registry) {
+	other.mutex.RLock()
+	backends := make(map[string]*Backend, len(other.backends))
+	for name, backend := range other.backends {
+		backends[name] = backend
+	}
+	other.mutex.RUnlock()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.backends = backends
+}
+
+// Status returns the health and metrics of all the backends
+func (r *Registry) Status() []BackendStatus {
+	var statuses []BackendStatus
+	for _, backend := range r.List() {
+		status := backend.status()
+		status.Default = backend.Name == r.defaultBackend
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Run checks the health of backends periodically until stopCh is closed
+func (r *Registry) Run(interval time.Duration, stopCh <-chan struct{}) {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	wait.Until(func() {
+		for _, backend := range r.List() {
+			backend.check()
+		}
+	}, interval, stopCh)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+
+	tenantv1alpha1 "kubesphere.io/api/tenant/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+// Resolver finds the name of Jenkins backend which serves the DevOps project,
+// empty name means the default backend.
+type Resolver interface {
+	Resolve(project string) string
+}
+
+// NamespaceResolver resolves the backend by the namespace of DevOps project. It is safe for concurrent use.
+type NamespaceResolver struct {
+	namespaceLister corev1lister.NamespaceLister
+
+	mutex sync.RWMutex
+	// workspace -> backend
+	workspaces map[string]string
+}
+
+// NewNamespaceResolver resolves the backend by the namespace of DevOps project. The annotation or
+// label JenkinsBackendAnnotationKey of namespace has the highest priority, then the backend which
+// the workspace of namespace is mapped to.
+func NewNamespaceResolver(namespaceLister corev1lister.NamespaceLister, workspaces map[string]string) *NamespaceResolver {
+	return &NamespaceResolver{
+		namespaceLister: namespaceLister,
+		workspaces:      workspaces,
+	}
+}
+
+// SetWorkspaces replaces the mapping from the workspaces to the backends
+func (r *NamespaceResolver) SetWorkspaces(workspaces map[string]string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.workspaces = workspaces
+}
+
+func (r *NamespaceResolver) Resolve(project string) string {
+	if project == "" {
+		return ""
+	}
+
+	namespace, err := r.namespaceLister.Get(project)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			klog.Error(err)
+		}
+		return ""
+	}
+
+	if backend := namespace.Annotations[constants.JenkinsBackendAnnotationKey]; backend != "" {
+		return backend
+	}
+	if backend := namespace.Labels[constants.JenkinsBackendAnnotationKey]; backend != "" {
+		return backend
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.workspaces[namespace.Labels[tenantv1alpha1.WorkspaceLabel]]
+}
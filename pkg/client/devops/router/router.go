@@ -0,0 +1,752 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+// ProjectQueryParameter is the query parameter of the DevOps project which routes the calls without a project
+const ProjectQueryParameter = "devops"
+
+// routingDevops dispatches every call to the Jenkins backend which serves the DevOps project.
+// The webhooks and role operations are sent to all the backends. The other calls without a project in
+// their arguments are routed by the DevOps project in the query parameter devops, they are refused if
+// it is missing and there is more than one backend.
+type routingDevops struct {
+	registry *Registry
+	resolver Resolver
+}
+
+func NewDevopsClient(registry *Registry, resolver Resolver) devops.Interface {
+	return &routingDevops{
+		registry: registry,
+		resolver: resolver,
+	}
+}
+
+func (r *routingDevops) backendOf(project string) (*Backend, error) {
+	return r.registry.Get(r.resolver.Resolve(project))
+}
+
+// backendOfRequest returns the backend serving the DevOps project in the query parameter devops, the only
+// backend serves the requests without it
+func (r *routingDevops) backendOfRequest(httpParameters *devops.HttpParameters) (*Backend, error) {
+	if httpParameters != nil && httpParameters.Url != nil {
+		if project := httpParameters.Url.Query().Get(ProjectQueryParameter); project != "" {
+			return r.backendOf(project)
+		}
+	}
+	if backends := r.registry.List(); len(backends) == 1 {
+		return backends[0], nil
+	}
+	return nil, fmt.Errorf("the devops project is required to choose the jenkins backend")
+}
+
+func (r *routingDevops) ListPipelines(httpParameters *devops.HttpParameters) (res *devops.PipelineList, err error) {
+	backend, err := r.backendOf(devops.ProjectOfSearchQuery(httpParameters))
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ListPipelines(httpParameters)
+}
+
+// broadcastWebhook sends the webhook to all the backends, because any of them might have the
+// pipelines which are interested in it. The response of default backend is preferred.
+func (r *routingDevops) broadcastWebhook(httpParameters *devops.HttpParameters,
+	send func(client devops.Interface, httpParameters *devops.HttpParameters) ([]byte, error)) ([]byte, error) {
+	var body []byte
+	if httpParameters.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(httpParameters.Body)
+		httpParameters.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var response []byte
+	var errs []error
+	succeeded := false
+	for _, backend := range r.registry.List() {
+		parameters := *httpParameters
+		if body != nil {
+			parameters.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		res, err := func() (res []byte, err error) {
+			defer backend.observe(time.Now(), &err)
+			return send(backend.Client, &parameters)
+		}()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !succeeded || backend == r.registry.Default() {
+			response = res
+		}
+		succeeded = true
+	}
+
+	if !succeeded {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return response, nil
+}
+
+// broadcast applies the operation to all the backends
+func (r *routingDevops) broadcast(operation func(client devops.Interface) error) error {
+	var errs []error
+	for _, backend := range r.registry.List() {
+		err := func() (err error) {
+			defer backend.observe(time.Now(), &err)
+			return operation(backend.Client)
+		}()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *routingDevops) GetNotifyCommit(httpParameters *devops.HttpParameters) ([]byte, error) {
+	return r.broadcastWebhook(httpParameters, func(client devops.Interface, httpParameters *devops.HttpParameters) ([]byte, error) {
+		return client.GetNotifyCommit(httpParameters)
+	})
+}
+
+func (r *routingDevops) GithubWebhook(httpParameters *devops.HttpParameters) ([]byte, error) {
+	return r.broadcastWebhook(httpParameters, func(client devops.Interface, httpParameters *devops.HttpParameters) ([]byte, error) {
+		return client.GithubWebhook(httpParameters)
+	})
+}
+
+func (r *routingDevops) GenericWebhook(httpParameters *devops.HttpParameters) ([]byte, error) {
+	return r.broadcastWebhook(httpParameters, func(client devops.Interface, httpParameters *devops.HttpParameters) ([]byte, error) {
+		return client.GenericWebhook(httpParameters)
+	})
+}
+
+func (r *routingDevops) AddGlobalRole(roleName string, ids devops.GlobalPermissionIds, overwrite bool) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.AddGlobalRole(roleName, ids, overwrite)
+	})
+}
+
+func (r *routingDevops) AddProjectRole(roleName string, pattern string, ids devops.ProjectPermissionIds, overwrite bool) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.AddProjectRole(roleName, pattern, ids, overwrite)
+	})
+}
+
+func (r *routingDevops) DeleteProjectRoles(roleName ...string) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.DeleteProjectRoles(roleName...)
+	})
+}
+
+// DescribeProjectRole returns the project role of the backends, the roles are written to all of them. The role is nil if
+// any backend misses it or has different permissions, and its users are the ones assigned in all the backends, so
+// the differences are written again.
+func (r *routingDevops) DescribeProjectRole(roleName string) (*devops.ProjectRole, error) {
+	var result *devops.ProjectRole
+	missing := false
+	err := r.broadcast(func(client devops.Interface) error {
+		role, err := client.DescribeProjectRole(roleName)
+		if err != nil {
+			return err
+		}
+		switch {
+		case role == nil || (result != nil && (result.Pattern != role.Pattern || result.PermissionIds != role.PermissionIds)):
+			missing = true
+		case result == nil:
+			result = role
+		default:
+			result.Sids = sets.NewString(result.Sids...).Intersection(sets.NewString(role.Sids...)).List()
+		}
+		return nil
+	})
+	if err != nil || missing {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *routingDevops) AssignProjectRole(roleName string, sid string) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.AssignProjectRole(roleName, sid)
+	})
+}
+
+func (r *routingDevops) UnAssignProjectRole(roleName string, sid string) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.UnAssignProjectRole(roleName, sid)
+	})
+}
+
+func (r *routingDevops) AssignGlobalRole(roleName string, sid string) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.AssignGlobalRole(roleName, sid)
+	})
+}
+
+func (r *routingDevops) UnAssignGlobalRole(roleName string, sid string) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.UnAssignGlobalRole(roleName, sid)
+	})
+}
+
+func (r *routingDevops) DeleteUserInProject(sid string) error {
+	return r.broadcast(func(client devops.Interface) error {
+		return client.DeleteUserInProject(sid)
+	})
+}
+
+func (r *routingDevops) GetPipeline(projectName, pipelineName string, httpParameters *devops.HttpParameters) (res *devops.Pipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetPipeline(projectName, pipelineName, httpParameters)
+}
+
+func (r *routingDevops) GetPipelineRun(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (res *devops.PipelineRun, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetPipelineRun(projectName, pipelineName, runId, httpParameters)
+}
+
+func (r *routingDevops) ListPipelineRuns(projectName, pipelineName string, httpParameters *devops.HttpParameters) (res *devops.PipelineRunList, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ListPipelineRuns(projectName, pipelineName, httpParameters)
+}
+
+func (r *routingDevops) StopPipeline(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (res *devops.StopPipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.StopPipeline(projectName, pipelineName, runId, httpParameters)
+}
+
+func (r *routingDevops) ReplayPipeline(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (res *devops.ReplayPipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ReplayPipeline(projectName, pipelineName, runId, httpParameters)
+}
+
+func (r *routingDevops) RunPipeline(projectName, pipelineName string, httpParameters *devops.HttpParameters) (res *devops.RunPipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.RunPipeline(projectName, pipelineName, httpParameters)
+}
+
+func (r *routingDevops) GetArtifacts(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (res []devops.Artifacts, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetArtifacts(projectName, pipelineName, runId, httpParameters)
+}
+
+func (r *routingDevops) GetRunLog(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (res []byte, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetRunLog(projectName, pipelineName, runId, httpParameters)
+}
+
+func (r *routingDevops) GetStepLog(projectName, pipelineName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) (res []byte, header http.Header, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetStepLog(projectName, pipelineName, runId, nodeId, stepId, httpParameters)
+}
+
+func (r *routingDevops) GetNodeSteps(projectName, pipelineName, runId, nodeId string, httpParameters *devops.HttpParameters) (res []devops.NodeSteps, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetNodeSteps(projectName, pipelineName, runId, nodeId, httpParameters)
+}
+
+func (r *routingDevops) GetPipelineRunNodes(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (res []devops.PipelineRunNodes, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetPipelineRunNodes(projectName, pipelineName, runId, httpParameters)
+}
+
+func (r *routingDevops) SubmitInputStep(projectName, pipelineName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) (res []byte, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.SubmitInputStep(projectName, pipelineName, runId, nodeId, stepId, httpParameters)
+}
+
+func (r *routingDevops) GetBranchPipeline(projectName, pipelineName, branchName string, httpParameters *devops.HttpParameters) (res *devops.BranchPipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetBranchPipeline(projectName, pipelineName, branchName, httpParameters)
+}
+
+func (r *routingDevops) GetBranchPipelineRun(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (res *devops.PipelineRun, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetBranchPipelineRun(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (r *routingDevops) StopBranchPipeline(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (res *devops.StopPipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.StopBranchPipeline(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (r *routingDevops) ReplayBranchPipeline(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (res *devops.ReplayPipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ReplayBranchPipeline(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (r *routingDevops) RunBranchPipeline(projectName, pipelineName, branchName string, httpParameters *devops.HttpParameters) (res *devops.RunPipeline, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.RunBranchPipeline(projectName, pipelineName, branchName, httpParameters)
+}
+
+func (r *routingDevops) GetBranchArtifacts(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (res []devops.Artifacts, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetBranchArtifacts(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (r *routingDevops) GetBranchRunLog(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (res []byte, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetBranchRunLog(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (r *routingDevops) GetBranchStepLog(projectName, pipelineName, branchName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) (res []byte, header http.Header, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetBranchStepLog(projectName, pipelineName, branchName, runId, nodeId, stepId, httpParameters)
+}
+
+func (r *routingDevops) GetBranchNodeSteps(projectName, pipelineName, branchName, runId, nodeId string, httpParameters *devops.HttpParameters) (res []devops.NodeSteps, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetBranchNodeSteps(projectName, pipelineName, branchName, runId, nodeId, httpParameters)
+}
+
+func (r *routingDevops) GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (res []devops.BranchPipelineRunNodes, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId, httpParameters)
+}
+
+func (r *routingDevops) SubmitBranchInputStep(projectName, pipelineName, branchName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) (res []byte, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.SubmitBranchInputStep(projectName, pipelineName, branchName, runId, nodeId, stepId, httpParameters)
+}
+
+func (r *routingDevops) GetPipelineBranch(projectName, pipelineName string, httpParameters *devops.HttpParameters) (res *devops.PipelineBranch, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetPipelineBranch(projectName, pipelineName, httpParameters)
+}
+
+func (r *routingDevops) ScanBranch(projectName, pipelineName string, httpParameters *devops.HttpParameters) (res []byte, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ScanBranch(projectName, pipelineName, httpParameters)
+}
+
+func (r *routingDevops) GetConsoleLog(projectName, pipelineName string, httpParameters *devops.HttpParameters) (res []byte, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetConsoleLog(projectName, pipelineName, httpParameters)
+}
+
+func (r *routingDevops) GetCrumb(httpParameters *devops.HttpParameters) (res *devops.Crumb, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetCrumb(httpParameters)
+}
+
+func (r *routingDevops) GetSCMServers(scmId string, httpParameters *devops.HttpParameters) (res []devops.SCMServer, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetSCMServers(scmId, httpParameters)
+}
+
+func (r *routingDevops) GetSCMOrg(scmId string, httpParameters *devops.HttpParameters) (res []devops.SCMOrg, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetSCMOrg(scmId, httpParameters)
+}
+
+func (r *routingDevops) GetOrgRepo(scmId, organizationId string, httpParameters *devops.HttpParameters) (res devops.OrgRepo, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetOrgRepo(scmId, organizationId, httpParameters)
+}
+
+func (r *routingDevops) CreateSCMServers(scmId string, httpParameters *devops.HttpParameters) (res *devops.SCMServer, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.CreateSCMServers(scmId, httpParameters)
+}
+
+func (r *routingDevops) Validate(scmId string, httpParameters *devops.HttpParameters) (res *devops.Validates, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.Validate(scmId, httpParameters)
+}
+
+func (r *routingDevops) CheckScriptCompile(projectName, pipelineName string, httpParameters *devops.HttpParameters) (res *devops.CheckScript, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.CheckScriptCompile(projectName, pipelineName, httpParameters)
+}
+
+func (r *routingDevops) CheckCron(projectName string, httpParameters *devops.HttpParameters) (res *devops.CheckCronRes, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.CheckCron(projectName, httpParameters)
+}
+
+func (r *routingDevops) ToJenkinsfile(httpParameters *devops.HttpParameters) (res *devops.ResJenkinsfile, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ToJenkinsfile(httpParameters)
+}
+
+func (r *routingDevops) ToJson(httpParameters *devops.HttpParameters) (res map[string]interface{}, err error) {
+	backend, err := r.backendOfRequest(httpParameters)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ToJson(httpParameters)
+}
+
+func (r *routingDevops) CreateCredentialInProject(projectId string, credential *v1.Secret) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.CreateCredentialInProject(projectId, credential)
+}
+
+func (r *routingDevops) UpdateCredentialInProject(projectId string, credential *v1.Secret) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.UpdateCredentialInProject(projectId, credential)
+}
+
+func (r *routingDevops) GetCredentialInProject(projectId, id string) (res *devops.Credential, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetCredentialInProject(projectId, id)
+}
+
+func (r *routingDevops) DeleteCredentialInProject(projectId, id string) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.DeleteCredentialInProject(projectId, id)
+}
+
+func (r *routingDevops) GetProjectPipelineBuildByType(projectId, pipelineId, status string) (res *devops.Build, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetProjectPipelineBuildByType(projectId, pipelineId, status)
+}
+
+func (r *routingDevops) GetMultiBranchPipelineBuildByType(projectId, pipelineId, branch, status string) (res *devops.Build, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetMultiBranchPipelineBuildByType(projectId, pipelineId, branch, status)
+}
+
+func (r *routingDevops) CreateProjectPipeline(projectId string, pipeline *devopsv1alpha3.Pipeline) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.CreateProjectPipeline(projectId, pipeline)
+}
+
+func (r *routingDevops) DeleteProjectPipeline(projectId, pipelineId string) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.DeleteProjectPipeline(projectId, pipelineId)
+}
+
+func (r *routingDevops) UpdateProjectPipeline(projectId string, pipeline *devopsv1alpha3.Pipeline) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.UpdateProjectPipeline(projectId, pipeline)
+}
+
+func (r *routingDevops) GetProjectPipelineConfig(projectId, pipelineId string) (res *devopsv1alpha3.Pipeline, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetProjectPipelineConfig(projectId, pipelineId)
+}
+
+func (r *routingDevops) CreateDevOpsProject(projectId string) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.CreateDevOpsProject(projectId)
+}
+
+func (r *routingDevops) DeleteDevOpsProject(projectId string) (err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.DeleteDevOpsProject(projectId)
+}
+
+func (r *routingDevops) GetDevOpsProject(projectId string) (res string, err error) {
+	backend, err := r.backendOf(projectId)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.GetDevOpsProject(projectId)
+}
+
+func (r *routingDevops) ListAgents(projectName string) (res []devops.Agent, err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ListAgents(projectName)
+}
+
+func (r *routingDevops) ApplyPodTemplates(projectName string, templates []devops.PodTemplate) (err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ApplyPodTemplates(projectName, templates)
+}
+
+// ListQueueItems fetches the queue of every backend once for all of its projects, the backends failing or missing
+// do not hide the items of the others
+func (r *routingDevops) ListQueueItems(projectNames ...string) ([]devops.QueueItem, error) {
+	var backends []*Backend
+	projects := make(map[*Backend][]string)
+	var errs []error
+	for _, projectName := range projectNames {
+		backend, err := r.backendOf(projectName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := projects[backend]; !ok {
+			backends = append(backends, backend)
+		}
+		projects[backend] = append(projects[backend], projectName)
+	}
+
+	items := make([]devops.QueueItem, 0)
+	for _, backend := range backends {
+		queued, err := listQueueItems(backend, projects[backend])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		items = append(items, queued...)
+	}
+	return items, utilerrors.NewAggregate(errs)
+}
+
+func listQueueItems(backend *Backend, projectNames []string) (res []devops.QueueItem, err error) {
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.ListQueueItems(projectNames...)
+}
+
+func (r *routingDevops) CancelQueueItem(projectName string, id int64) (err error) {
+	backend, err := r.backendOf(projectName)
+	if err != nil {
+		return
+	}
+	defer backend.observe(time.Now(), &err)
+	return backend.Client.CancelQueueItem(projectName, id)
+}
+
+// GetGlobalRole returns the name of the global role only if all the backends have it, like the other role
+// operations it is not answered by the default backend alone
+func (r *routingDevops) GetGlobalRole(roleName string) (string, error) {
+	missing := false
+	err := r.broadcast(func(client devops.Interface) error {
+		name, err := client.GetGlobalRole(roleName)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			missing = true
+		}
+		return nil
+	})
+	if err != nil || missing {
+		return "", err
+	}
+	return roleName, nil
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	tenantv1alpha1 "kubesphere.io/api/tenant/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+// webhookDevops records the bodies of webhooks, it fails all the requests if broken is true
+type webhookDevops struct {
+	*fake.Devops
+	broken bool
+	bodies []string
+	roles  []string
+	// role is the project role described by the backend
+	role *devops.ProjectRole
+}
+
+func (d *webhookDevops) GithubWebhook(httpParameters *devops.HttpParameters) ([]byte, error) {
+	if d.broken {
+		return nil, fmt.Errorf("jenkins is down")
+	}
+	body, err := ioutil.ReadAll(httpParameters.Body)
+	if err != nil {
+		return nil, err
+	}
+	d.bodies = append(d.bodies, string(body))
+	return []byte("ok"), nil
+}
+
+func (d *webhookDevops) AssignProjectRole(roleName string, sid string) error {
+	if d.broken {
+		return fmt.Errorf("jenkins is down")
+	}
+	d.roles = append(d.roles, roleName+"/"+sid)
+	return nil
+}
+
+func (d *webhookDevops) DescribeProjectRole(roleName string) (*devops.ProjectRole, error) {
+	if d.broken {
+		return nil, fmt.Errorf("jenkins is down")
+	}
+	return d.role, nil
+}
+
+func newNamespaceLister(namespaces ...*v1.Namespace) corev1lister.NamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, namespace := range namespaces {
+		_ = indexer.Add(namespace)
+	}
+	return corev1lister.NewNamespaceLister(indexer)
+}
+
+func TestNamespaceResolver(t *testing.T) {
+	lister := newNamespaceLister(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "annotated",
+			Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws1"},
+			Annotations: map[string]string{
+				constants.JenkinsBackendAnnotationKey: "b2",
+			},
+		}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "labeled",
+			Labels: map[string]string{constants.JenkinsBackendAnnotationKey: "b3"},
+		}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "mapped",
+			Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws1"},
+		}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "unmapped",
+			Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws2"},
+		}},
+	)
+	resolver := NewNamespaceResolver(lister, map[string]string{"ws1": "b1"})
+
+	var testCases = []struct {
+		project  string
+		expected string
+	}{
+		{"annotated", "b2"},
+		{"labeled", "b3"},
+		{"mapped", "b1"},
+		{"unmapped", ""},
+		{"missing", ""},
+		{"", ""},
+	}
+
+	for _, testCase := range testCases {
+		if got := resolver.Resolve(testCase.project); got != testCase.expected {
+			t.Errorf("project %q, expected backend %q, got %q", testCase.project, testCase.expected, got)
+		}
+	}
+
+	resolver.SetWorkspaces(map[string]string{"ws2": "b1"})
+	if got := resolver.Resolve("mapped"); got != "" {
+		t.Errorf("expected the remapped workspace has no backend, got %q", got)
+	}
+	if got := resolver.Resolve("unmapped"); got != "b1" {
+		t.Errorf("expected the remapped workspace is served by b1, got %q", got)
+	}
+}
+
+func TestRoutingDevops(t *testing.T) {
+	defaultBackend := &webhookDevops{Devops: fake.New("p0", "p3")}
+	otherBackend := &webhookDevops{Devops: fake.New("p1")}
+
+	registry := NewRegistry("default")
+	registry.Add("default", "http://default", defaultBackend)
+	registry.Add("other", "http://other", otherBackend)
+
+	lister := newNamespaceLister(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "p0"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "p1",
+			Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws1"},
+		}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "p3",
+			Annotations: map[string]string{constants.JenkinsBackendAnnotationKey: "removed"},
+		}},
+	)
+	client := NewDevopsClient(registry, NewNamespaceResolver(lister, map[string]string{"ws1": "other"}))
+
+	// each project is served by its own backend
+	for _, project := range []string{"p0", "p1"} {
+		if _, err := client.GetDevOpsProject(project); err != nil {
+			t.Errorf("project %s should be found in its backend, got %v", project, err)
+		}
+	}
+	if _, err := client.GetDevOpsProject("p2"); err == nil {
+		t.Errorf("project p2 should not be found")
+	}
+	// the project of a missing backend is not sent to the default backend
+	if _, err := client.GetDevOpsProject("p3"); err == nil || !strings.Contains(err.Error(), "jenkins backend removed not found") {
+		t.Errorf("project p3 should fail with its missing backend, got %v", err)
+	}
+	if _, err := client.ListQueueItems("p0", "p3"); err == nil || !strings.Contains(err.Error(), "jenkins backend removed not found") {
+		t.Errorf("the queue of project p3 should fail with its missing backend, got %v", err)
+	}
+
+	// webhooks are sent to all the backends
+	response, err := client.GithubWebhook(&devops.HttpParameters{
+		Method: http.MethodPost,
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(strings.NewReader("payload")),
+	})
+	if err != nil || string(response) != "ok" {
+		t.Fatalf("expected ok, got %s, %v", response, err)
+	}
+	for _, backend := range []*webhookDevops{defaultBackend, otherBackend} {
+		if len(backend.bodies) != 1 || backend.bodies[0] != "payload" {
+			t.Errorf("expected the payload sent to every backend, got %v", backend.bodies)
+		}
+	}
+
+	// a broken backend does not fail the webhook, but fails the role operations
+	otherBackend.broken = true
+	if _, err = client.GithubWebhook(&devops.HttpParameters{
+		Method: http.MethodPost,
+		Body:   ioutil.NopCloser(strings.NewReader("payload")),
+	}); err != nil {
+		t.Errorf("webhook should succeed when one backend is healthy, got %v", err)
+	}
+	if err = client.AssignProjectRole("role", "admin"); err == nil {
+		t.Errorf("role assignment should fail when one backend is broken")
+	}
+	if len(defaultBackend.roles) != 1 {
+		t.Errorf("role should be assigned in the healthy backend, got %v", defaultBackend.roles)
+	}
+
+	statuses := registry.Status()
+	if len(statuses) != 2 || !statuses[0].Default || statuses[1].Errors != 2 {
+		t.Errorf("unexpected backend statuses %+v", statuses)
+	}
+}
+
+func TestDescribeProjectRole(t *testing.T) {
+	defaultBackend := &webhookDevops{Devops: fake.New()}
+	otherBackend := &webhookDevops{Devops: fake.New()}
+	registry := NewRegistry("default")
+	registry.Add("default", "http://default", defaultBackend)
+	registry.Add("other", "http://other", otherBackend)
+	client := NewDevopsClient(registry, NewNamespaceResolver(newNamespaceLister(), nil))
+
+	viewer := devops.ProjectPermissionIds{ItemRead: true}
+	defaultBackend.role = &devops.ProjectRole{Pattern: "^p0(/.*)?$", PermissionIds: viewer, Sids: []string{"alice", "bob"}}
+	otherBackend.role = &devops.ProjectRole{Pattern: "^p0(/.*)?$", PermissionIds: viewer, Sids: []string{"bob", "carol"}}
+
+	// the users assigned in all the backends
+	role, err := client.DescribeProjectRole("role")
+	if err != nil || role == nil || !reflect.DeepEqual(role.Sids, []string{"bob"}) {
+		t.Errorf("expected the users of all the backends, got %+v, %v", role, err)
+	}
+
+	// the role is written again if any backend misses it or has other permissions
+	otherBackend.role = &devops.ProjectRole{Pattern: "^p0(/.*)?$", Sids: []string{"bob"}}
+	if role, err = client.DescribeProjectRole("role"); err != nil || role != nil {
+		t.Errorf("expected no role if the permissions differ, got %+v, %v", role, err)
+	}
+	otherBackend.role = nil
+	if role, err = client.DescribeProjectRole("role"); err != nil || role != nil {
+		t.Errorf("expected no role if a backend misses it, got %+v, %v", role, err)
+	}
+
+	otherBackend.broken = true
+	if _, err = client.DescribeProjectRole("role"); err == nil {
+		t.Errorf("expected the error of the broken backend")
+	}
+}
+
+func TestRoutingRequestsWithoutProject(t *testing.T) {
+	registry := NewRegistry("default")
+	registry.Add("default", "http://default", &webhookDevops{Devops: fake.New("p0")})
+	client := NewDevopsClient(registry, NewNamespaceResolver(newNamespaceLister(), map[string]string{"ws1": "other"}))
+
+	// the only backend serves the requests without project
+	if _, err := client.ToJson(devops.NewHttpParameters(http.MethodPost, nil)); err != nil {
+		t.Errorf("expected the request is sent to the only backend, got %v", err)
+	}
+
+	registry.Add("other", "http://other", &webhookDevops{Devops: fake.New("p1")})
+	client = NewDevopsClient(registry, NewNamespaceResolver(newNamespaceLister(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "p1",
+			Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws1"},
+		}},
+	), map[string]string{"ws1": "other"}))
+	if _, err := client.ToJson(devops.NewHttpParameters(http.MethodPost, nil)); err == nil {
+		t.Errorf("expected the request without project is refused if there are several backends")
+	}
+
+	httpParameters := devops.NewHttpParameters(http.MethodPost, nil)
+	httpParameters.Url.RawQuery = ProjectQueryParameter + "=p1"
+	if _, err := client.ToJson(httpParameters); err != nil {
+		t.Errorf("expected the request is routed by its project, got %v", err)
+	}
+	for _, backend := range registry.Status() {
+		if calls := map[string]int64{"default": 1, "other": 1}[backend.Name]; backend.Requests != calls {
+			t.Errorf("expected %d requests to backend %s, got %+v", calls, backend.Name, backend)
+		}
+	}
+}
+
+func TestReplaceBackends(t *testing.T) {
+	registry := NewRegistry("default")
+	registry.Add("default", "http://default", &webhookDevops{Devops: fake.New("p0")})
+	registry.Add("other", "http://other", &webhookDevops{Devops: fake.New("p1")})
+	client := NewDevopsClient(registry, NewNamespaceResolver(newNamespaceLister(), nil))
+
+	// the rotated default backend serves the new projects, and the removed backend is gone
+	reloaded := NewRegistry("default")
+	reloaded.Add("default", "http://reloaded", &webhookDevops{Devops: fake.New("p2")})
+	registry.Replace(reloaded)
+
+	if _, err := client.GetDevOpsProject("p2"); err != nil {
+		t.Errorf("project p2 should be found in the replaced backend, got %v", err)
+	}
+	if _, err := client.GetDevOpsProject("p0"); err == nil {
+		t.Errorf("project p0 should not be found after the backend is replaced")
+	}
+	statuses := registry.Status()
+	if len(statuses) != 1 || statuses[0].Host != "http://reloaded" || !statuses[0].Default {
+		t.Errorf("unexpected backend statuses %+v", statuses)
+	}
+}
@@ -24,10 +24,16 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// AgentTemplates returns a AgentTemplateInformer.
+	AgentTemplates() AgentTemplateInformer
 	// DevOpsProjects returns a DevOpsProjectInformer.
 	DevOpsProjects() DevOpsProjectInformer
+	// NotificationPolicies returns a NotificationPolicyInformer.
+	NotificationPolicies() NotificationPolicyInformer
 	// Pipelines returns a PipelineInformer.
 	Pipelines() PipelineInformer
+	// PipelineRuns returns a PipelineRunInformer.
+	PipelineRuns() PipelineRunInformer
 }
 
 type version struct {
@@ -41,12 +47,27 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// AgentTemplates returns a AgentTemplateInformer.
+func (v *version) AgentTemplates() AgentTemplateInformer {
+	return &agentTemplateInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // DevOpsProjects returns a DevOpsProjectInformer.
 func (v *version) DevOpsProjects() DevOpsProjectInformer {
 	return &devOpsProjectInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
 
+// NotificationPolicies returns a NotificationPolicyInformer.
+func (v *version) NotificationPolicies() NotificationPolicyInformer {
+	return &notificationPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Pipelines returns a PipelineInformer.
 func (v *version) Pipelines() PipelineInformer {
 	return &pipelineInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// PipelineRuns returns a PipelineRunInformer.
+func (v *version) PipelineRuns() PipelineRunInformer {
+	return &pipelineRunInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
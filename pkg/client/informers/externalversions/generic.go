@@ -64,10 +64,16 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Devops().V1alpha1().S2iRuns().Informer()}, nil
 
 		// Group=devops.kubesphere.io, Version=v1alpha3
+	case v1alpha3.GroupVersion.WithResource("agenttemplates"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Devops().V1alpha3().AgentTemplates().Informer()}, nil
 	case v1alpha3.GroupVersion.WithResource("devopsprojects"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Devops().V1alpha3().DevOpsProjects().Informer()}, nil
+	case v1alpha3.GroupVersion.WithResource("notificationpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Devops().V1alpha3().NotificationPolicies().Informer()}, nil
 	case v1alpha3.GroupVersion.WithResource("pipelines"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Devops().V1alpha3().Pipelines().Informer()}, nil
+	case v1alpha3.GroupVersion.WithResource("pipelineruns"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Devops().V1alpha3().PipelineRuns().Informer()}, nil
 
 	}
 
@@ -18,10 +18,26 @@ limitations under the License.
 
 package v1alpha3
 
+// AgentTemplateListerExpansion allows custom methods to be added to
+// AgentTemplateLister.
+type AgentTemplateListerExpansion interface{}
+
+// AgentTemplateNamespaceListerExpansion allows custom methods to be added to
+// AgentTemplateNamespaceLister.
+type AgentTemplateNamespaceListerExpansion interface{}
+
 // DevOpsProjectListerExpansion allows custom methods to be added to
 // DevOpsProjectLister.
 type DevOpsProjectListerExpansion interface{}
 
+// NotificationPolicyListerExpansion allows custom methods to be added to
+// NotificationPolicyLister.
+type NotificationPolicyListerExpansion interface{}
+
+// NotificationPolicyNamespaceListerExpansion allows custom methods to be added to
+// NotificationPolicyNamespaceLister.
+type NotificationPolicyNamespaceListerExpansion interface{}
+
 // PipelineListerExpansion allows custom methods to be added to
 // PipelineLister.
 type PipelineListerExpansion interface{}
@@ -29,3 +45,11 @@ type PipelineListerExpansion interface{}
 // PipelineNamespaceListerExpansion allows custom methods to be added to
 // PipelineNamespaceLister.
 type PipelineNamespaceListerExpansion interface{}
+
+// PipelineRunListerExpansion allows custom methods to be added to
+// PipelineRunLister.
+type PipelineRunListerExpansion interface{}
+
+// PipelineRunNamespaceListerExpansion allows custom methods to be added to
+// PipelineRunNamespaceLister.
+type PipelineRunNamespaceListerExpansion interface{}
@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"devops.kubesphere.io/plugin/pkg/client/storage"
+)
+
+// Storage keeps the objects in memory, it is the stand-in of real storages in tests
+type Storage struct {
+	mutex   sync.RWMutex
+	Objects map[string][]byte
+}
+
+func NewFakeStorage() *Storage {
+	return &Storage{Objects: make(map[string][]byte)}
+}
+
+func (s *Storage) Upload(key string, body io.Reader, size int64) error {
+	data, err := ioutil.ReadAll(io.LimitReader(body, size))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("unexpected EOF of object %s, %d of %d bytes are read", key, len(data), size)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Objects[key] = data
+	return nil
+}
+
+func (s *Storage) Read(key string) (io.ReadCloser, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	data, ok := s.Objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *Storage) List(prefix string) ([]storage.Object, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	objects := make([]storage.Object, 0)
+	for key, data := range s.Objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, storage.Object{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+func (s *Storage) Delete(keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, key := range keys {
+		delete(s.Objects, key)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// temporaryPrefix is the prefix of files being uploaded, they are invisible until the uploads complete
+const temporaryPrefix = ".upload-"
+
+type filesystemStorage struct {
+	directory string
+}
+
+// NewFilesystemStorage stores the objects under the directory, the directory is created if it doesn't exist
+func NewFilesystemStorage(directory string) (Interface, error) {
+	if err := os.MkdirAll(directory, 0750); err != nil {
+		return nil, err
+	}
+	return &filesystemStorage{directory: directory}, nil
+}
+
+// path returns the file path of key, keys escaping the directory are rejected
+func (s *filesystemStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned != "/"+key || strings.HasPrefix(path.Base(cleaned), temporaryPrefix) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.directory, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *filesystemStorage) Upload(key string, body io.Reader, size int64) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0750); err != nil {
+		return err
+	}
+
+	// write into a temporary file then rename it, so the readers never see a partial object
+	file, err := ioutil.TempFile(filepath.Dir(name), temporaryPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, io.LimitReader(body, size))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("unexpected EOF of object %s, %d of %d bytes are read", key, written, size)
+	}
+	return os.Rename(file.Name(), name)
+}
+
+func (s *filesystemStorage) Read(key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s *filesystemStorage) List(prefix string) ([]Object, error) {
+	// only walk the deepest directory containing all the keys of prefix
+	root := s.directory
+	if index := strings.LastIndex(prefix, "/"); index >= 0 {
+		root = filepath.Join(s.directory, filepath.FromSlash(path.Clean("/"+prefix[:index])))
+	}
+
+	objects := make([]Object, 0)
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), temporaryPrefix) {
+			return nil
+		}
+		relative, err := filepath.Rel(s.directory, name)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(relative); strings.HasPrefix(key, prefix) {
+			objects = append(objects, Object{Key: key, Size: info.Size()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	return objects, nil
+}
+
+func (s *filesystemStorage) Delete(keys ...string) error {
+	for _, key := range keys {
+		name, err := s.path(key)
+		if err != nil {
+			return err
+		}
+		if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// remove the empty parent directories, it stops at the first non-empty one
+		for dir := filepath.Dir(name); dir != filepath.Clean(s.directory); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilesystemStorage(t *testing.T) {
+	directory, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	storage, err := NewFilesystemStorage(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, storage)
+
+	for _, key := range []string{"", "../escape", "foo/../../escape", "foo//bar", "/foo"} {
+		if err := storage.Upload(key, strings.NewReader("foo"), 3); err == nil {
+			t.Errorf("expected error of invalid key %q", key)
+		}
+	}
+	if err := storage.Upload("foo/short", strings.NewReader("foo"), 4); err == nil {
+		t.Errorf("expected error of the short body")
+	}
+	if _, err := storage.Read("foo/short"); err != ErrNotFound {
+		t.Errorf("expected the short object is not stored, got %v", err)
+	}
+}
+
+// testStorage tests the common behaviors of storages
+func testStorage(t *testing.T, storage Interface) {
+	objects := map[string]string{
+		"s2ibinary/bar/foo":                   "binary",
+		"s2ibinary/bar/foo.parts/00000000000": "bin",
+		"s2ibinary/bar/foo.parts/00000000003": "ary",
+		"s2ibinary/bar/foobar":                "",
+	}
+	for key, content := range objects {
+		if err := storage.Upload(key, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("failed to upload %s: %v", key, err)
+		}
+	}
+
+	reader, err := storage.Read("s2ibinary/bar/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(reader)
+	reader.Close()
+	if string(data) != "binary" {
+		t.Errorf("expected binary, got %s", data)
+	}
+	if _, err = storage.Read("s2ibinary/bar/missing"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	listed, err := storage.List("s2ibinary/bar/foo.parts/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Object{{"s2ibinary/bar/foo.parts/00000000000", 3}, {"s2ibinary/bar/foo.parts/00000000003", 3}}
+	if !reflect.DeepEqual(listed, expected) {
+		t.Errorf("expected %v, got %v", expected, listed)
+	}
+	if listed, _ = storage.List("s2ibinary/bar/foo"); len(listed) != 4 {
+		t.Errorf("expected all the objects are listed, got %v", listed)
+	}
+
+	if err = storage.Delete("s2ibinary/bar/foo.parts/00000000000", "s2ibinary/bar/foo.parts/00000000003", "s2ibinary/bar/missing"); err != nil {
+		t.Fatal(err)
+	}
+	if listed, _ = storage.List("s2ibinary/bar/"); len(listed) != 2 {
+		t.Errorf("expected 2 objects are left, got %v", listed)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	// TypeFilesystem stores objects as the files in a directory, the directory has to be shared
+	// if there are multiple replicas of apiserver
+	TypeFilesystem = "filesystem"
+	// TypeS3 stores objects in a bucket of Amazon S3 or any S3 compatible service, such as MinIO
+	TypeS3 = "s3"
+)
+
+// DefaultDownloadServer is the in-cluster address of apiserver
+const DefaultDownloadServer = "http://ks-devops-apiserver.kubesphere-devops-system.svc"
+
+type Options struct {
+	// Type is filesystem or s3, the storage is disabled if it is empty
+	Type string `json:"type,omitempty"`
+
+	// Directory is the root directory of objects of the filesystem storage
+	Directory string `json:"directory,omitempty"`
+
+	S3 *S3Options `json:"s3,omitempty"`
+
+	// DownloadServer is the address of apiserver which serves the downloads of objects,
+	// it has to be reachable from the pods which download them, e.g. the S2I builders
+	DownloadServer string `json:"downloadServer,omitempty"`
+}
+
+type S3Options struct {
+	// Endpoint is the URL of service, e.g. https://s3.amazonaws.com or http://minio.kubesphere-system.svc:9000
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region,omitempty"`
+	Bucket   string `json:"bucket"`
+
+	AccessKeyID     string `json:"accessKeyID"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+
+	// VirtualHostedStyle puts the bucket in host name instead of path, path style is the default
+	// because it is supported by most of the S3 compatible services
+	VirtualHostedStyle bool `json:"virtualHostedStyle,omitempty"`
+}
+
+// NewStorageOptions returns options of the disabled storage
+func NewStorageOptions() *Options {
+	return &Options{
+		DownloadServer: DefaultDownloadServer,
+	}
+}
+
+// IsEnabled returns true if the storage is configured
+func (o *Options) IsEnabled() bool {
+	return o != nil && o.Type != ""
+}
+
+// Validate check options
+func (o *Options) Validate() []error {
+	errors := make([]error, 0)
+
+	switch o.Type {
+	case TypeFilesystem:
+		if o.Directory == "" {
+			errors = append(errors, fmt.Errorf("directory is required by filesystem storage"))
+		}
+	case TypeS3:
+		if o.S3 == nil {
+			errors = append(errors, fmt.Errorf("s3 options are required by s3 storage"))
+			break
+		}
+		if endpoint, err := url.Parse(o.S3.Endpoint); err != nil || endpoint.Host == "" {
+			errors = append(errors, fmt.Errorf("invalid s3 endpoint %q", o.S3.Endpoint))
+		}
+		if o.S3.Bucket == "" {
+			errors = append(errors, fmt.Errorf("s3 bucket is required"))
+		}
+		if o.S3.AccessKeyID == "" || o.S3.SecretAccessKey == "" {
+			errors = append(errors, fmt.Errorf("both access key id and secret access key are required by s3 storage"))
+		}
+	default:
+		errors = append(errors, fmt.Errorf("unsupported storage type %s", o.Type))
+	}
+
+	if o.DownloadServer != "" {
+		if server, err := url.Parse(o.DownloadServer); err != nil || server.Host == "" {
+			errors = append(errors, fmt.Errorf("invalid download server %q", o.DownloadServer))
+		}
+	}
+	return errors
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound is returned if the object doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// Object is the metadata of a stored object
+type Object struct {
+	Key  string
+	Size int64
+}
+
+// Interface stores the objects, such as the binaries of S2I, keys are slash separated paths
+type Interface interface {
+	// Upload stores size bytes read from body as the object of key, the object is replaced if it exists
+	Upload(key string, body io.Reader, size int64) error
+
+	// Read returns the content of the object, ErrNotFound is returned if it doesn't exist
+	Read(key string) (io.ReadCloser, error)
+
+	// List returns the objects whose keys start with prefix, in lexical order of the keys
+	List(prefix string) ([]Object, error)
+
+	// Delete deletes the objects, no error returned if they don't exist
+	Delete(keys ...string) error
+}
+
+// NewStorage creates the storage of options.Type
+func NewStorage(options *Options) (Interface, error) {
+	switch options.Type {
+	case TypeFilesystem:
+		return NewFilesystemStorage(options.Directory)
+	case TypeS3:
+		return NewS3Storage(options.S3)
+	default:
+		return nil, fmt.Errorf("unsupported storage type %s", options.Type)
+	}
+}
@@ -18,9 +18,18 @@ package config
 
 import (
 	authoptions "devops.kubesphere.io/plugin/pkg/apiserver/authentication/options"
+	"devops.kubesphere.io/plugin/pkg/apiserver/dispatch"
 	"devops.kubesphere.io/plugin/pkg/client/cache"
 	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins"
 	"devops.kubesphere.io/plugin/pkg/client/k8s"
+	"devops.kubesphere.io/plugin/pkg/client/storage"
+	"devops.kubesphere.io/plugin/pkg/controller/agenttemplate"
+	"devops.kubesphere.io/plugin/pkg/controller/pipelinerun"
+	"devops.kubesphere.io/plugin/pkg/controller/s2irun"
+	"devops.kubesphere.io/plugin/pkg/models/devops/commitstatus"
+	"devops.kubesphere.io/plugin/pkg/models/devops/notification"
+	"devops.kubesphere.io/plugin/pkg/utils/sliceutil"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -55,6 +64,13 @@ type Config struct {
 	AuthenticationOptions *authoptions.AuthenticationOptions `json:"authentication,omitempty" yaml:"authentication,omitempty" mapstructure:"authentication"`
 	AuthMode              AuthMode                           `json:"authMode,omitempty" yaml:"authMode,omitempty" mapstructure:"authMode"`
 	JWTSecret             string                             `json:"jwtSecret,omitempty" yaml:"jwtSecret,omitempty" mapstructure:"jwtSecret"`
+	S2iBinaryStorage      *storage.Options                   `json:"s2iBinaryStorage,omitempty" yaml:"s2iBinaryStorage,omitempty" mapstructure:"s2iBinaryStorage"`
+	S2iRunRetention       *s2irun.RetentionOptions           `json:"s2iRunRetention,omitempty" yaml:"s2iRunRetention,omitempty" mapstructure:"s2iRunRetention"`
+	NotificationOptions   *notification.Options              `json:"notification,omitempty" yaml:"notification,omitempty" mapstructure:"notification"`
+	CommitStatusOptions   *commitstatus.Options              `json:"commitStatus,omitempty" yaml:"commitStatus,omitempty" mapstructure:"commitStatus"`
+	PipelineRunOptions    *pipelinerun.Options               `json:"pipelineRun,omitempty" yaml:"pipelineRun,omitempty" mapstructure:"pipelineRun"`
+	MultiClusterOptions   *dispatch.Options                  `json:"multicluster,omitempty" yaml:"multicluster,omitempty" mapstructure:"multicluster"`
+	AgentTemplateOptions  *agenttemplate.Options             `json:"agentTemplate,omitempty" yaml:"agentTemplate,omitempty" mapstructure:"agentTemplate"`
 }
 
 // newConfig creates a default non-empty Config
@@ -64,6 +80,13 @@ func New() *Config {
 		KubernetesOptions:     k8s.NewKubernetesOptions(),
 		AuthMode:              AuthModeToken,
 		AuthenticationOptions: authoptions.NewAuthenticateOptions(),
+		S2iBinaryStorage:      storage.NewStorageOptions(),
+		S2iRunRetention:       s2irun.NewRetentionOptions(),
+		NotificationOptions:   notification.NewOptions(),
+		CommitStatusOptions:   commitstatus.NewOptions(),
+		PipelineRunOptions:    pipelinerun.NewOptions(),
+		MultiClusterOptions:   dispatch.NewOptions(),
+		AgentTemplateOptions:  agenttemplate.NewOptions(),
 	}
 }
 
@@ -84,6 +107,11 @@ func TryLoadFromDisk() (*Config, error) {
 		}
 	}
 
+	return load()
+}
+
+// load unmarshals the configuration read by viper into the defaults
+func load() (*Config, error) {
 	conf := New()
 
 	if err := viper.Unmarshal(conf); err != nil {
@@ -110,12 +138,138 @@ func (conf *Config) ToMap() map[string]bool {
 			continue
 		}
 
-		if c.Field(i).IsNil() {
-			result[name] = false
-		} else {
-			result[name] = true
+		switch c.Field(i).Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			result[name] = !c.Field(i).IsNil()
+		default:
+			result[name] = !c.Field(i).IsZero()
 		}
 	}
 
 	return result
 }
+
+// RedactedValue replaces the values of the sensitive fields in ToRedactedMap
+const RedactedValue = "<redacted>"
+
+// sensitiveKeys are the substrings of the lower-cased keys whose string values are redacted
+var sensitiveKeys = []string{"password", "secret", "token"}
+
+// ToRedactedMap converts config to its json form, the values of passwords, secrets and tokens are replaced by
+// RedactedValue so it is safe to be shown to the administrators
+func (conf *Config) ToRedactedMap() (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	if conf == nil {
+		return result, nil
+	}
+
+	data, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	redact(result)
+	return result, nil
+}
+
+func redact(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if s, ok := field.(string); ok && s != "" && isSensitive(key) {
+				value[key] = RedactedValue
+				continue
+			}
+			redact(field)
+		}
+	case []interface{}:
+		for _, item := range value {
+			redact(item)
+		}
+	}
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the paths of the fields which differ in other, like devops.password. The paths are named by
+// the yaml tags as in the configuration file, and the values are not included as they may be secrets.
+func (conf *Config) Diff(other *Config) []string {
+	var changes []string
+	diff("", reflect.ValueOf(conf), reflect.ValueOf(other), &changes)
+	return changes
+}
+
+func diff(path string, a, b reflect.Value, changes *[]string) {
+	switch a.Kind() {
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				*changes = append(*changes, path)
+			}
+			return
+		}
+		diff(path, a.Elem(), b.Elem(), changes)
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			field := a.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := fieldName(field)
+			if path != "" {
+				name = path + "." + name
+			}
+			diff(name, a.Field(i), b.Field(i), changes)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*changes = append(*changes, path)
+		}
+	}
+}
+
+// fieldName returns the name of the field in the configuration file
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"yaml", "json"} {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// validator is implemented by the options which validate themselves
+type validator interface {
+	Validate() []error
+}
+
+// ValidateSections validates the options of the top level sections, like devops or redis. The options
+// which are disabled are not validated.
+func (conf *Config) ValidateSections(sections []string) []error {
+	var errs []error
+	c := reflect.Indirect(reflect.ValueOf(conf))
+	for i := 0; i < c.NumField(); i++ {
+		if !sliceutil.HasString(sections, fieldName(c.Type().Field(i))) {
+			continue
+		}
+		options, ok := c.Field(i).Interface().(validator)
+		if !ok || (c.Field(i).Kind() == reflect.Ptr && c.Field(i).IsNil()) {
+			continue
+		}
+		if enabler, ok := options.(interface{ IsEnabled() bool }); ok && !enabler.IsEnabled() {
+			continue
+		}
+		errs = append(errs, options.Validate()...)
+	}
+	return errs
+}
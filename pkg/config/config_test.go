@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToMap(t *testing.T) {
+	conf := New()
+	conf.RedisOptions = nil
+	conf.JWTSecret = "secret"
+
+	result := conf.ToMap()
+	if !result["devops"] || result["redis"] || !result["jwtSecret"] || !result["authMode"] {
+		t.Errorf("unexpected sections %v", result)
+	}
+}
+
+func TestToRedactedMap(t *testing.T) {
+	conf := New()
+	conf.JenkinsOptions.Host = "http://jenkins"
+	conf.JenkinsOptions.Password = "jenkins-password"
+	conf.JWTSecret = "jwt-secret"
+	conf.NotificationOptions.SMTP.Password = "smtp-password"
+	conf.AuthenticationOptions.OAuthOptions.AccessTokenMaxAge = time.Hour
+
+	result, err := conf.ToRedactedMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	devops := result["devops"].(map[string]interface{})
+	if devops["Password"] != RedactedValue || devops["Host"] != "http://jenkins" {
+		t.Errorf("expected the jenkins password only to be redacted, got %v", devops)
+	}
+	if result["jwtSecret"] != RedactedValue {
+		t.Errorf("expected the jwt secret to be redacted, got %v", result["jwtSecret"])
+	}
+	smtp := result["notification"].(map[string]interface{})["smtp"].(map[string]interface{})
+	if smtp["password"] != RedactedValue {
+		t.Errorf("expected the smtp password to be redacted, got %v", smtp)
+	}
+	oauth := result["authentication"].(map[string]interface{})["oauthOptions"].(map[string]interface{})
+	if oauth["accessTokenMaxAge"] != float64(time.Hour) {
+		t.Errorf("expected the durations to be kept, got %v", oauth["accessTokenMaxAge"])
+	}
+}
+
+func TestDiff(t *testing.T) {
+	conf := New()
+	other := New()
+	if changes := conf.Diff(other); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v", changes)
+	}
+
+	other.JenkinsOptions.Password = "rotated"
+	other.AuthenticationOptions.JwtSecret = "rotated"
+	other.RedisOptions = nil
+	expected := []string{"devops.password", "redis", "authentication.jwtSecret"}
+	if changes := conf.Diff(other); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+}
+
+func TestValidateSections(t *testing.T) {
+	conf := New()
+	conf.AuthenticationOptions.JwtSecret = ""
+	conf.JenkinsOptions.Host = "http://jenkins"
+
+	// redis is disabled, and the jenkins section is not validated
+	if errs := conf.ValidateSections([]string{"redis", "authentication"}); len(errs) != 1 {
+		t.Errorf("expected the empty jwt secret to be reported, got %v", errs)
+	}
+	if errs := conf.ValidateSections([]string{"devops"}); len(errs) != 1 {
+		t.Errorf("expected the empty jenkins credentials to be reported, got %v", errs)
+	}
+}
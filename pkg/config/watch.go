@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// DefaultWatchInterval is how often the configuration file is checked for changes
+const DefaultWatchInterval = 10 * time.Second
+
+// ReloadStatus is the result of the last reload of the configuration
+type ReloadStatus struct {
+	LastReloadTime  *time.Time `json:"lastReloadTime,omitempty" description:"time of the last reload"`
+	LastReloadError string     `json:"lastReloadError,omitempty" description:"error of the last reload, the previous configuration stays in effect if it failed"`
+}
+
+// Watch checks the configuration file loaded by TryLoadFromDisk every interval until stopCh is closed, the
+// configuration is passed to onChange once the content of the file changes. The file is compared by content
+// rather than watched by inotify, so it also works when the file is mounted from a ConfigMap whose symlinks
+// are replaced by kubelet.
+func Watch(interval time.Duration, onChange func(*Config), stopCh <-chan struct{}) {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		klog.Warning("no configuration file is loaded, the configuration will not be reloaded")
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		klog.Errorf("failed to read configuration file %s: %v", path, err)
+	}
+	wait.Until(func() {
+		current, err := ioutil.ReadFile(path)
+		if err != nil {
+			klog.Errorf("failed to read configuration file %s: %v", path, err)
+			return
+		}
+		if bytes.Equal(current, content) {
+			return
+		}
+		// the content is remembered even if it is invalid, so the error is reported once for each change
+		content = current
+
+		klog.Infof("configuration file %s changed, reloading", path)
+		if err = viper.ReadConfig(bytes.NewReader(current)); err != nil {
+			klog.Errorf("error parsing configuration file %s: %v", path, err)
+			return
+		}
+		conf, err := load()
+		if err != nil {
+			klog.Errorf("error parsing configuration file %s: %v", path, err)
+			return
+		}
+		onChange(conf)
+	}, interval, stopCh)
+}
@@ -44,6 +44,9 @@ const (
 	CreatorAnnotationKey              = "kubesphere.io/creator"
 	UsernameLabelKey                  = "kubesphere.io/username"
 	DevOpsProjectLabelKey             = "devops.kubesphere.io/pluginproject"
+	JenkinsBackendAnnotationKey       = "devops.kubesphere.io/jenkins-backend"
+	S2iRunMaxRunsAnnotationKey        = "devops.kubesphere.io/s2irun-retention-max-runs"
+	S2iRunMaxAgeAnnotationKey         = "devops.kubesphere.io/s2irun-retention-max-age"
 	KubefedManagedLabel               = "kubefed.io/managed"
 
 	UserNameHeader = "X-Token-Username"
@@ -77,6 +80,7 @@ const (
 	DevOpsJenkinsfileTag = "DevOps Jenkinsfile"
 	DevOpsScmTag         = "DevOps Scm"
 	DevOpsJenkinsTag     = "Jenkins"
+	DevOpsS2iTag         = "DevOps S2I"
 
 	ToolboxTag      = "Toolbox"
 	RegistryTag     = "Docker Registry"
@@ -0,0 +1,432 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agenttemplate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	devopsinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/devops/v1alpha3"
+	devopslisters "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha3"
+)
+
+// DefaultResyncPeriod is the interval to apply all the templates again, the pod templates are lost if Jenkins
+// is restored from its configuration as code
+const DefaultResyncPeriod = 10 * time.Minute
+
+const maxRetries = 15
+
+// Controller applies the AgentTemplates of every DevOps project to the Kubernetes cloud of Jenkins as pod
+// templates named <namespace>.<name>, whose labels are prefixed with the namespace.
+type Controller struct {
+	agentOperator devops.AgentOperator
+	ksclient      kubesphere.Interface
+
+	agentTemplateLister devopslisters.AgentTemplateLister
+	agentTemplateSynced cache.InformerSynced
+
+	workqueue        workqueue.RateLimitingInterface
+	options          *Options
+	reserved         sets.String
+	nodeSelectorKeys sets.String
+	now              func() time.Time
+
+	mutex sync.Mutex
+	// applied pod templates of every namespace
+	synced map[string][]devops.PodTemplate
+	// namespaces whose templates have to be applied again even if nothing changed
+	stale sets.String
+}
+
+func NewController(agentOperator devops.AgentOperator, ksclient kubesphere.Interface,
+	agentTemplateInformer devopsinformers.AgentTemplateInformer, options *Options) *Controller {
+
+	if options == nil {
+		options = NewOptions()
+	}
+	if options.ResyncPeriod <= 0 {
+		options.ResyncPeriod = DefaultResyncPeriod
+	}
+
+	c := &Controller{
+		agentOperator:       agentOperator,
+		ksclient:            ksclient,
+		agentTemplateLister: agentTemplateInformer.Lister(),
+		agentTemplateSynced: agentTemplateInformer.Informer().HasSynced,
+		workqueue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "agent-template"),
+		options:             options,
+		reserved:            sets.NewString(options.ReservedLabels...),
+		nodeSelectorKeys:    sets.NewString(options.NodeSelectorKeys...),
+		now:                 time.Now,
+		synced:              map[string][]devops.PodTemplate{},
+		stale:               sets.NewString(),
+	}
+
+	agentTemplateInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueAgentTemplate,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueAgentTemplate(oldObj)
+			c.enqueueAgentTemplate(newObj)
+		},
+		DeleteFunc: c.enqueueAgentTemplate,
+	})
+	return c
+}
+
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Info("starting agent template controller")
+	defer klog.Info("shutting down agent template controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.agentTemplateSynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	// the first resync happens immediately, it makes sure Jenkins is in step after restart
+	go wait.Until(c.resync, c.options.ResyncPeriod, stopCh)
+
+	<-stopCh
+	return nil
+}
+
+// resync forces the templates of all the namespaces to be applied again
+func (c *Controller) resync() {
+	agentTemplates, err := c.agentTemplateLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, agentTemplate := range agentTemplates {
+		c.stale.Insert(agentTemplate.Namespace)
+		c.workqueue.Add(agentTemplate.Namespace)
+	}
+	// namespaces whose templates have gone, their pod templates need to be removed
+	for namespace := range c.synced {
+		c.workqueue.Add(namespace)
+	}
+}
+
+// enqueueAgentTemplate enqueues the namespace of the template, the labels are owned within the namespace
+func (c *Controller) enqueueAgentTemplate(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	agentTemplate, ok := obj.(*devopsv1alpha3.AgentTemplate)
+	if !ok {
+		return
+	}
+	c.workqueue.Add(agentTemplate.Namespace)
+}
+
+func (c *Controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	namespace, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		return true
+	}
+
+	if err := c.syncHandler(namespace); err != nil {
+		if c.workqueue.NumRequeues(obj) < maxRetries {
+			klog.Warningf("failed to sync agent templates of namespace %s, retrying: %v", namespace, err)
+			c.workqueue.AddRateLimited(obj)
+			return true
+		}
+		klog.Errorf("dropping namespace %s out of the queue: %v", namespace, err)
+	}
+	c.workqueue.Forget(obj)
+	return true
+}
+
+// syncHandler applies the valid templates of the namespace to Jenkins, and records the result in their status
+func (c *Controller) syncHandler(namespace string) error {
+	agentTemplates, err := c.agentTemplateLister.AgentTemplates(namespace).List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	owners := labelOwners(agentTemplates)
+
+	var podTemplates []devops.PodTemplate
+	statuses := map[string]devopsv1alpha3.AgentTemplateStatus{}
+	var valid []*devopsv1alpha3.AgentTemplate
+	for _, agentTemplate := range agentTemplates {
+		if agentTemplate.DeletionTimestamp != nil {
+			continue
+		}
+		if message := c.validate(agentTemplate, owners); message != "" {
+			statuses[agentTemplate.Name] = devopsv1alpha3.AgentTemplateStatus{
+				SyncStatus: devopsv1alpha3.AgentTemplateSyncFailed,
+				Message:    message,
+			}
+			continue
+		}
+		podTemplate, err := PodTemplateOf(agentTemplate)
+		if err != nil {
+			statuses[agentTemplate.Name] = devopsv1alpha3.AgentTemplateStatus{
+				SyncStatus: devopsv1alpha3.AgentTemplateSyncFailed,
+				Message:    err.Error(),
+			}
+			continue
+		}
+		podTemplates = append(podTemplates, podTemplate)
+		valid = append(valid, agentTemplate)
+	}
+	sort.Slice(podTemplates, func(i, j int) bool {
+		return podTemplates[i].Name < podTemplates[j].Name
+	})
+
+	c.mutex.Lock()
+	synced, exists := c.synced[namespace]
+	force := c.stale.Has(namespace)
+	c.mutex.Unlock()
+
+	var applyErr error
+	if force || !exists || !reflect.DeepEqual(synced, podTemplates) {
+		if exists || len(podTemplates) > 0 {
+			applyErr = c.agentOperator.ApplyPodTemplates(namespace, podTemplates)
+		}
+		c.mutex.Lock()
+		if applyErr == nil {
+			if len(podTemplates) == 0 {
+				delete(c.synced, namespace)
+			} else {
+				c.synced[namespace] = podTemplates
+			}
+			c.stale.Delete(namespace)
+		}
+		c.mutex.Unlock()
+	}
+
+	for _, agentTemplate := range valid {
+		status := devopsv1alpha3.AgentTemplateStatus{SyncStatus: devopsv1alpha3.AgentTemplateSyncSuccessful}
+		if applyErr != nil {
+			status = devopsv1alpha3.AgentTemplateStatus{
+				SyncStatus: devopsv1alpha3.AgentTemplateSyncFailed,
+				Message:    fmt.Sprintf("failed to apply the pod template to jenkins: %v", applyErr),
+			}
+		}
+		statuses[agentTemplate.Name] = status
+	}
+	for _, agentTemplate := range agentTemplates {
+		status, ok := statuses[agentTemplate.Name]
+		if !ok || agentTemplate.Namespace != namespace {
+			continue
+		}
+		if err := c.updateStatus(agentTemplate, status); err != nil {
+			return err
+		}
+	}
+	return applyErr
+}
+
+// updateStatus writes the status only if it changes, so the periodical resync doesn't touch the templates
+func (c *Controller) updateStatus(agentTemplate *devopsv1alpha3.AgentTemplate, status devopsv1alpha3.AgentTemplateStatus) error {
+	current := agentTemplate.Status
+	if current.SyncStatus == status.SyncStatus && current.Message == status.Message &&
+		current.ObservedGeneration == agentTemplate.Generation {
+		return nil
+	}
+
+	now := metav1.NewTime(c.now())
+	status.LastSyncTime = &now
+	status.ObservedGeneration = agentTemplate.Generation
+
+	agentTemplate = agentTemplate.DeepCopy()
+	agentTemplate.Status = status
+	_, err := c.ksclient.DevopsV1alpha3().AgentTemplates(agentTemplate.Namespace).UpdateStatus(context.Background(),
+		agentTemplate, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Error(err)
+	}
+	return err
+}
+
+// labelOwners returns the template owning every label of Jenkins, it is the oldest template of the project
+// claiming the label
+func labelOwners(agentTemplates []*devopsv1alpha3.AgentTemplate) map[string]*devopsv1alpha3.AgentTemplate {
+	owners := map[string]*devopsv1alpha3.AgentTemplate{}
+	for _, agentTemplate := range agentTemplates {
+		if agentTemplate.DeletionTimestamp != nil {
+			continue
+		}
+		for _, label := range agentTemplate.Spec.Labels {
+			agentLabel := devopsv1alpha3.AgentLabel(agentTemplate.Namespace, label)
+			if owner, ok := owners[agentLabel]; !ok || isOlder(agentTemplate, owner) {
+				owners[agentLabel] = agentTemplate
+			}
+		}
+	}
+	return owners
+}
+
+func isOlder(a, b *devopsv1alpha3.AgentTemplate) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// validate returns the reason why the template can't be applied, it is empty if the template is valid
+func (c *Controller) validate(agentTemplate *devopsv1alpha3.AgentTemplate, owners map[string]*devopsv1alpha3.AgentTemplate) string {
+	if len(agentTemplate.Spec.Labels) == 0 {
+		return "at least one label is required"
+	}
+	if agentTemplate.Spec.IdleMinutes < 0 {
+		return "idleMinutes must not be negative"
+	}
+	for _, label := range agentTemplate.Spec.Labels {
+		if label == "" || strings.ContainsAny(label, " \t\n&|!()<>=\"") {
+			return fmt.Sprintf("invalid label '%s'", label)
+		}
+		if c.reserved.Has(label) {
+			return fmt.Sprintf("label '%s' is reserved for the built-in agents", label)
+		}
+		if owner := owners[devopsv1alpha3.AgentLabel(agentTemplate.Namespace, label)]; owner != nil && owner != agentTemplate {
+			return fmt.Sprintf("label '%s' is owned by agent template %s/%s", label, owner.Namespace, owner.Name)
+		}
+	}
+	if !c.options.AllowPrivileged {
+		return c.validateIsolation(&agentTemplate.Spec)
+	}
+	return ""
+}
+
+// validateIsolation returns the reason why the pod of the template could escape to the node, or read the secrets
+// of other projects in the shared namespace of the agents, it is empty if the pod is isolated
+func (c *Controller) validateIsolation(spec *devopsv1alpha3.AgentTemplateSpec) string {
+	for _, container := range spec.Containers {
+		if securityContext := container.SecurityContext; securityContext != nil {
+			if securityContext.Privileged != nil && *securityContext.Privileged {
+				return fmt.Sprintf("container '%s' must not be privileged", container.Name)
+			}
+			if securityContext.AllowPrivilegeEscalation != nil && *securityContext.AllowPrivilegeEscalation {
+				return fmt.Sprintf("container '%s' must not allow privilege escalation", container.Name)
+			}
+			if securityContext.Capabilities != nil && len(securityContext.Capabilities.Add) > 0 {
+				return fmt.Sprintf("container '%s' must not add capabilities", container.Name)
+			}
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				return fmt.Sprintf("container '%s' must not use host ports", container.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && (env.ValueFrom.SecretKeyRef != nil || env.ValueFrom.ConfigMapKeyRef != nil) {
+				return fmt.Sprintf("env '%s' of container '%s' must not refer to secrets or config maps", env.Name, container.Name)
+			}
+		}
+		if len(container.EnvFrom) > 0 {
+			return fmt.Sprintf("container '%s' must not load env from secrets or config maps", container.Name)
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if volume.EmptyDir == nil {
+			return fmt.Sprintf("volume '%s' must be an emptyDir", volume.Name)
+		}
+	}
+	keys := make([]string, 0, len(spec.NodeSelector))
+	for key := range spec.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !c.nodeSelectorKeys.Has(key) {
+			return fmt.Sprintf("node selector '%s' is not allowed", key)
+		}
+	}
+	return ""
+}
+
+// PodTemplateName returns the name of the pod template of the agent template in Jenkins
+func PodTemplateName(agentTemplate *devopsv1alpha3.AgentTemplate) string {
+	return agentTemplate.Namespace + "." + agentTemplate.Name
+}
+
+// PodTemplateOf converts the agent template to the pod template of the Kubernetes cloud, the pod is serialized
+// as JSON which is valid YAML for the kubernetes plugin
+func PodTemplateOf(agentTemplate *devopsv1alpha3.AgentTemplate) (devops.PodTemplate, error) {
+	containers := agentTemplate.Spec.Containers
+	if containers == nil {
+		containers = []corev1.Container{}
+	}
+	pod := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"spec": corev1.PodSpec{
+			Containers:   containers,
+			Volumes:      agentTemplate.Spec.Volumes,
+			NodeSelector: agentTemplate.Spec.NodeSelector,
+		},
+	}
+	data, err := json.Marshal(pod)
+	if err != nil {
+		return devops.PodTemplate{}, err
+	}
+	labels := make([]string, 0, len(agentTemplate.Spec.Labels))
+	for _, label := range agentTemplate.Spec.Labels {
+		labels = append(labels, devopsv1alpha3.AgentLabel(agentTemplate.Namespace, label))
+	}
+	return devops.PodTemplate{
+		Name:        PodTemplateName(agentTemplate),
+		Label:       strings.Join(labels, " "),
+		IdleMinutes: agentTemplate.Spec.IdleMinutes,
+		Yaml:        string(data),
+	}, nil
+}
@@ -0,0 +1,313 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agenttemplate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+var testNow = time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+func newAgentTemplate(namespace, name string, age time.Duration, labels ...string) *devopsv1alpha3.AgentTemplate {
+	return &devopsv1alpha3.AgentTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			Generation:        1,
+			CreationTimestamp: metav1.NewTime(testNow.Add(-age)),
+		},
+		Spec: devopsv1alpha3.AgentTemplateSpec{
+			Labels: labels,
+			Containers: []corev1.Container{
+				{Name: name, Image: name + ":latest", Command: []string{"cat"}, TTY: true},
+			},
+			IdleMinutes: 5,
+		},
+	}
+}
+
+type testController struct {
+	*Controller
+	client  *fake.Clientset
+	devops  *fakedevops.Devops
+	indexer cache.Indexer
+}
+
+func newTestController(agentTemplates ...*devopsv1alpha3.AgentTemplate) *testController {
+	var objects []runtime.Object
+	for _, agentTemplate := range agentTemplates {
+		objects = append(objects, agentTemplate)
+	}
+	client := fake.NewSimpleClientset(objects...)
+	informerFactory := ksinformers.NewSharedInformerFactory(client, 0)
+	informer := informerFactory.Devops().V1alpha3().AgentTemplates()
+	for _, agentTemplate := range agentTemplates {
+		informer.Informer().GetIndexer().Add(agentTemplate)
+	}
+	devopsClient := fakedevops.New("foo", "bar")
+	c := NewController(devopsClient, client, informer, nil)
+	c.now = func() time.Time {
+		return testNow
+	}
+	return &testController{Controller: c, client: client, devops: devopsClient, indexer: informer.Informer().GetIndexer()}
+}
+
+func (c *testController) status(namespace, name string) devopsv1alpha3.AgentTemplateStatus {
+	agentTemplate, err := c.client.DevopsV1alpha3().AgentTemplates(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return devopsv1alpha3.AgentTemplateStatus{}
+	}
+	return agentTemplate.Status
+}
+
+func TestSyncHandler(t *testing.T) {
+	c := newTestController(
+		newAgentTemplate("foo", "maven", time.Hour, "mvn", "java"),
+		newAgentTemplate("foo", "nodejs", time.Hour, "yarn"),
+		newAgentTemplate("foo", "jdk", time.Minute, "java"),
+		newAgentTemplate("bar", "java", time.Minute, "java"),
+		newAgentTemplate("bar", "go", time.Minute, "golang"),
+		newAgentTemplate("bar", "invalid", time.Minute),
+	)
+
+	for _, namespace := range []string{"foo", "bar"} {
+		if err := c.syncHandler(namespace); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	foo := c.devops.PodTemplates["foo"]
+	if len(foo) != 2 || foo[0].Name != "foo.maven" || foo[0].Label != "foo.mvn foo.java" || foo[1].Name != "foo.nodejs" {
+		t.Fatalf("unexpected pod templates of foo: %+v", foo)
+	}
+	if foo[0].IdleMinutes != 5 ||
+		foo[0].Yaml != `{"apiVersion":"v1","kind":"Pod","spec":{"containers":[{"name":"maven","image":"maven:latest",`+
+			`"command":["cat"],"resources":{},"tty":true}]}}` {
+		t.Errorf("unexpected pod template %+v", foo[0])
+	}
+	bar := c.devops.PodTemplates["bar"]
+	if len(bar) != 2 || bar[0].Name != "bar.go" || bar[1].Name != "bar.java" || bar[1].Label != "bar.java" {
+		t.Errorf("expected the labels are scoped to the project, got %+v", bar)
+	}
+
+	if status := c.status("foo", "maven"); status.SyncStatus != devopsv1alpha3.AgentTemplateSyncSuccessful ||
+		status.ObservedGeneration != 1 || status.LastSyncTime == nil {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status := c.status("foo", "jdk"); status.SyncStatus != devopsv1alpha3.AgentTemplateSyncFailed ||
+		status.Message != "label 'java' is owned by agent template foo/maven" {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status := c.status("bar", "invalid"); status.SyncStatus != devopsv1alpha3.AgentTemplateSyncFailed {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestSyncHandlerRemovesTemplates(t *testing.T) {
+	maven := newAgentTemplate("foo", "maven", time.Hour, "mvn")
+	c := newTestController(maven)
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.devops.PodTemplates["foo"]) != 1 {
+		t.Fatalf("expected the template is applied, got %+v", c.devops.PodTemplates)
+	}
+
+	if err := c.indexer.Delete(maven); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if templates, ok := c.devops.PodTemplates["foo"]; !ok || len(templates) != 0 {
+		t.Errorf("expected the pod templates are removed, got %+v", c.devops.PodTemplates)
+	}
+	if _, ok := c.synced["foo"]; ok {
+		t.Errorf("expected foo is forgotten after its templates are removed")
+	}
+}
+
+func TestLabelOwners(t *testing.T) {
+	older := newAgentTemplate("foo", "maven", time.Hour, "mvn")
+	newer := newAgentTemplate("foo", "maven3", time.Minute, "mvn")
+	sameAge := newAgentTemplate("foo", "gradle", time.Hour, "mvn")
+	otherProject := newAgentTemplate("bar", "maven", time.Minute, "mvn")
+
+	owners := labelOwners([]*devopsv1alpha3.AgentTemplate{newer, sameAge, older, otherProject})
+	if owners["foo.mvn"] != sameAge {
+		t.Errorf("expected the template gradle owns the label, got %s", owners["foo.mvn"].Name)
+	}
+	if owners["bar.mvn"] != otherProject {
+		t.Errorf("expected the label of another project is owned by its own template")
+	}
+	c := newTestController()
+	if message := c.validate(older, owners); message != "label 'mvn' is owned by agent template foo/gradle" {
+		t.Errorf("unexpected message %s", message)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	privileged := true
+	tests := []struct {
+		name             string
+		labels           []string
+		mutate           func(spec *devopsv1alpha3.AgentTemplateSpec)
+		nodeSelectorKeys []string
+		allowPrivileged  bool
+		expected         string
+	}{{
+		name:     "valid",
+		labels:   []string{"mvn"},
+		expected: "",
+	}, {
+		name:     "reserved label",
+		labels:   []string{"mvn", "maven"},
+		expected: "label 'maven' is reserved for the built-in agents",
+	}, {
+		name:   "privileged container",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: &privileged}
+		},
+		expected: "container 'maven' must not be privileged",
+	}, {
+		name:   "added capabilities",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Containers[0].SecurityContext = &corev1.SecurityContext{
+				Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+			}
+		},
+		expected: "container 'maven' must not add capabilities",
+	}, {
+		name:   "host port",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 8080, HostPort: 8080}}
+		},
+		expected: "container 'maven' must not use host ports",
+	}, {
+		name:   "host path",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Volumes = []corev1.Volume{{
+				Name:         "docker",
+				VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}},
+			}}
+		},
+		expected: "volume 'docker' must be an emptyDir",
+	}, {
+		name:   "secret volume",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Volumes = []corev1.Volume{{
+				Name:         "settings",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "maven-settings"}},
+			}}
+		},
+		expected: "volume 'settings' must be an emptyDir",
+	}, {
+		name:   "empty dir",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Volumes = []corev1.Volume{{
+				Name:         "cache",
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			}}
+		},
+		expected: "",
+	}, {
+		name:   "secret env",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Containers[0].Env = []corev1.EnvVar{{
+				Name: "TOKEN",
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "token"},
+					Key:                  "token",
+				}},
+			}}
+		},
+		expected: "env 'TOKEN' of container 'maven' must not refer to secrets or config maps",
+	}, {
+		name:   "env from config map",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env"}},
+			}}
+		},
+		expected: "container 'maven' must not load env from secrets or config maps",
+	}, {
+		name:   "node selector",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.NodeSelector = map[string]string{"node-role.kubernetes.io/master": ""}
+		},
+		nodeSelectorKeys: []string{"node.kubesphere.io/pool"},
+		expected:         "node selector 'node-role.kubernetes.io/master' is not allowed",
+	}, {
+		name:   "allowed node selector",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.NodeSelector = map[string]string{"node.kubesphere.io/pool": "ci"}
+		},
+		nodeSelectorKeys: []string{"node.kubesphere.io/pool"},
+		expected:         "",
+	}, {
+		name:   "host path allowed",
+		labels: []string{"mvn"},
+		mutate: func(spec *devopsv1alpha3.AgentTemplateSpec) {
+			spec.Volumes = []corev1.Volume{{
+				Name:         "docker",
+				VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}},
+			}}
+		},
+		allowPrivileged: true,
+		expected:        "",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agentTemplate := newAgentTemplate("foo", "maven", time.Hour, tt.labels...)
+			if tt.mutate != nil {
+				tt.mutate(&agentTemplate.Spec)
+			}
+			c := newTestController(agentTemplate)
+			c.options.AllowPrivileged = tt.allowPrivileged
+			c.nodeSelectorKeys = sets.NewString(tt.nodeSelectorKeys...)
+
+			owners := labelOwners([]*devopsv1alpha3.AgentTemplate{agentTemplate})
+			if message := c.validate(agentTemplate, owners); message != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agenttemplate
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultReservedLabels are the labels of the pod templates in the configuration as code of Jenkins, and the
+// label of the Jenkins master
+var DefaultReservedLabels = []string{"base", "nodejs", "maven", "go", "python", "master", "built-in"}
+
+// Options configures the AgentTemplates. The agent pods of every DevOps project run in the shared namespace of
+// the Jenkins workers, so unless AllowPrivileged is set, the templates are not allowed to run privileged containers,
+// to mount any volume but emptyDir, to read secrets or config maps, or to select nodes by other keys than
+// NodeSelectorKeys. The templates have no tolerations, so the agents never run on the tainted nodes.
+type Options struct {
+	ResyncPeriod time.Duration `json:"resyncPeriod,omitempty" yaml:"resyncPeriod,omitempty" mapstructure:"resyncPeriod"`
+	// ReservedLabels are the names of the built-in agents, the templates can't be labeled with them to avoid
+	// confusing them with the built-in agents
+	ReservedLabels []string `json:"reservedLabels,omitempty" yaml:"reservedLabels,omitempty" mapstructure:"reservedLabels"`
+	// NodeSelectorKeys are the node labels which the node selectors of the templates may use, e.g. the label
+	// of the node pool of the agents
+	NodeSelectorKeys []string `json:"nodeSelectorKeys,omitempty" yaml:"nodeSelectorKeys,omitempty" mapstructure:"nodeSelectorKeys"`
+	// AllowPrivileged allows the privileged containers, added capabilities, host ports, all the volumes, the
+	// references to secrets and config maps, and any node selector
+	AllowPrivileged bool `json:"allowPrivileged" yaml:"allowPrivileged" mapstructure:"allowPrivileged"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		ResyncPeriod:   DefaultResyncPeriod,
+		ReservedLabels: DefaultReservedLabels,
+	}
+}
+
+// Validate check options
+func (o *Options) Validate() []error {
+	errors := make([]error, 0)
+	if o == nil {
+		return errors
+	}
+
+	if o.ResyncPeriod < 0 {
+		errors = append(errors, fmt.Errorf("resyncPeriod of agentTemplate must not be negative"))
+	}
+	return errors
+}
@@ -0,0 +1,510 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsrole
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1informer "k8s.io/client-go/informers/core/v1"
+	rbacv1informer "k8s.io/client-go/informers/rbac/v1"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	rbacv1lister "k8s.io/client-go/listers/rbac/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	iamv1alpha2 "kubesphere.io/api/iam/v1alpha2"
+	tenantv1alpha1 "kubesphere.io/api/tenant/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	iamv1alpha2informers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/iam/v1alpha2"
+	iamv1alpha2listers "devops.kubesphere.io/plugin/pkg/client/listers/iam/v1alpha2"
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+// DefaultResyncPeriod is the interval of the full synchronization between KubeSphere and Jenkins
+const DefaultResyncPeriod = 10 * time.Minute
+
+const maxRetries = 15
+
+// projectRole is the desired or synced state of a Jenkins project role
+type projectRole struct {
+	pattern string
+	ids     devops.ProjectPermissionIds
+	sids    sets.String
+}
+
+// Controller keeps the Jenkins role-strategy in step with the roles and role bindings
+// of DevOps project namespaces and the workspace role bindings of their workspaces.
+type Controller struct {
+	roleOperator devops.RoleOperator
+
+	namespaceLister corev1lister.NamespaceLister
+	namespaceSynced cache.InformerSynced
+
+	roleLister rbacv1lister.RoleLister
+	roleSynced cache.InformerSynced
+
+	roleBindingLister rbacv1lister.RoleBindingLister
+	roleBindingSynced cache.InformerSynced
+
+	workspaceRoleLister iamv1alpha2listers.WorkspaceRoleLister
+	workspaceRoleSynced cache.InformerSynced
+
+	workspaceRoleBindingLister iamv1alpha2listers.WorkspaceRoleBindingLister
+	workspaceRoleBindingSynced cache.InformerSynced
+
+	workqueue    workqueue.RateLimitingInterface
+	resyncPeriod time.Duration
+
+	// mutex guards the maps below, it is never held during the calls to Jenkins
+	mutex sync.Mutex
+	// synced project roles of every namespace, namespace -> role name -> role
+	synced map[string]map[string]*projectRole
+	// namespaces whose roles have to be compared with Jenkins again even if nothing changed
+	stale sets.String
+	// namespaces whose roles of the legacy names are deleted by this controller
+	migrated sets.String
+}
+
+func NewController(roleOperator devops.RoleOperator,
+	namespaceInformer corev1informer.NamespaceInformer,
+	roleInformer rbacv1informer.RoleInformer,
+	roleBindingInformer rbacv1informer.RoleBindingInformer,
+	workspaceRoleInformer iamv1alpha2informers.WorkspaceRoleInformer,
+	workspaceRoleBindingInformer iamv1alpha2informers.WorkspaceRoleBindingInformer,
+	resyncPeriod time.Duration) *Controller {
+
+	if resyncPeriod <= 0 {
+		resyncPeriod = DefaultResyncPeriod
+	}
+
+	c := &Controller{
+		roleOperator:               roleOperator,
+		namespaceLister:            namespaceInformer.Lister(),
+		namespaceSynced:            namespaceInformer.Informer().HasSynced,
+		roleLister:                 roleInformer.Lister(),
+		roleSynced:                 roleInformer.Informer().HasSynced,
+		roleBindingLister:          roleBindingInformer.Lister(),
+		roleBindingSynced:          roleBindingInformer.Informer().HasSynced,
+		workspaceRoleLister:        workspaceRoleInformer.Lister(),
+		workspaceRoleSynced:        workspaceRoleInformer.Informer().HasSynced,
+		workspaceRoleBindingLister: workspaceRoleBindingInformer.Lister(),
+		workspaceRoleBindingSynced: workspaceRoleBindingInformer.Informer().HasSynced,
+		workqueue:                  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "devops-role"),
+		resyncPeriod:               resyncPeriod,
+		synced:                     map[string]map[string]*projectRole{},
+		stale:                      sets.NewString(),
+		migrated:                   sets.NewString(),
+	}
+
+	namespaceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueNamespace,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueNamespace(newObj)
+		},
+		DeleteFunc: c.enqueueNamespace,
+	})
+
+	namespacedHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueObjectNamespace,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueObjectNamespace(newObj)
+		},
+		DeleteFunc: c.enqueueObjectNamespace,
+	}
+	roleInformer.Informer().AddEventHandler(namespacedHandler)
+	roleBindingInformer.Informer().AddEventHandler(namespacedHandler)
+
+	workspaceHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueWorkspace,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueWorkspace(newObj)
+		},
+		DeleteFunc: c.enqueueWorkspace,
+	}
+	workspaceRoleInformer.Informer().AddEventHandler(workspaceHandler)
+	workspaceRoleBindingInformer.Informer().AddEventHandler(workspaceHandler)
+
+	return c
+}
+
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Info("starting devops role controller")
+	defer klog.Info("shutting down devops role controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.namespaceSynced, c.roleSynced, c.roleBindingSynced,
+		c.workspaceRoleSynced, c.workspaceRoleBindingSynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	// the first resync happens immediately, it makes sure Jenkins is in step after restart
+	go wait.Until(c.resync, c.resyncPeriod, stopCh)
+
+	<-stopCh
+	return nil
+}
+
+// resync compares all the DevOps project roles with Jenkins again, the roles changed in Jenkins are written again
+func (c *Controller) resync() {
+	namespaces, err := c.namespaceLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	c.mutex.Lock()
+	for _, namespace := range namespaces {
+		if isDevOpsNamespace(namespace) {
+			c.stale.Insert(namespace.Name)
+			c.workqueue.Add(namespace.Name)
+		}
+	}
+	// namespaces that have gone, their roles need to be removed
+	for namespace := range c.synced {
+		c.workqueue.Add(namespace)
+	}
+	c.mutex.Unlock()
+}
+
+func (c *Controller) enqueueNamespace(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+	if isDevOpsNamespace(namespace) || c.isTracked(namespace.Name) {
+		c.workqueue.Add(namespace.Name)
+	}
+}
+
+func (c *Controller) enqueueObjectNamespace(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil || namespace == "" {
+		return
+	}
+	c.workqueue.Add(namespace)
+}
+
+// enqueueWorkspace enqueues all the DevOps project namespaces in the workspace of object
+func (c *Controller) enqueueWorkspace(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	var workspace string
+	switch o := obj.(type) {
+	case *iamv1alpha2.WorkspaceRole:
+		workspace = o.Labels[tenantv1alpha1.WorkspaceLabel]
+	case *iamv1alpha2.WorkspaceRoleBinding:
+		workspace = o.Labels[tenantv1alpha1.WorkspaceLabel]
+	}
+	if workspace == "" {
+		return
+	}
+
+	namespaces, err := c.namespaceLister.List(labels.SelectorFromSet(labels.Set{tenantv1alpha1.WorkspaceLabel: workspace}))
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	for _, namespace := range namespaces {
+		if isDevOpsNamespace(namespace) {
+			c.workqueue.Add(namespace.Name)
+		}
+	}
+}
+
+func (c *Controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	namespace, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		return true
+	}
+
+	if err := c.syncHandler(namespace); err != nil {
+		if c.workqueue.NumRequeues(obj) < maxRetries {
+			klog.Warningf("failed to sync jenkins roles of namespace %s, retrying: %v", namespace, err)
+			c.workqueue.AddRateLimited(obj)
+			return true
+		}
+		klog.Errorf("dropping namespace %s out of the queue: %v", namespace, err)
+	}
+	c.workqueue.Forget(obj)
+	return true
+}
+
+// syncHandler compares the desired project roles of the namespace with the synced ones,
+// and applies the difference to Jenkins.
+func (c *Controller) syncHandler(namespace string) error {
+	desired, err := c.desiredProjectRoles(namespace)
+	if err != nil {
+		return err
+	}
+
+	// the workqueue never hands a namespace to two workers at a time, so only this worker changes the roles
+	// of the namespace, they are copied out to call Jenkins without the lock
+	c.mutex.Lock()
+	synced := copyProjectRoles(c.synced[namespace])
+	force := c.stale.Has(namespace)
+	c.mutex.Unlock()
+
+	err = c.applyProjectRoles(desired, synced, force)
+	if err == nil {
+		err = c.deleteLegacyRoles(namespace, desired)
+	}
+
+	// the progress is kept even if Jenkins fails halfway, the retry only applies the rest
+	c.mutex.Lock()
+	if len(synced) == 0 {
+		delete(c.synced, namespace)
+	} else {
+		c.synced[namespace] = synced
+	}
+	if err == nil {
+		c.stale.Delete(namespace)
+	}
+	c.mutex.Unlock()
+	return err
+}
+
+// applyProjectRoles writes the difference between the desired and synced roles into Jenkins, synced is updated
+// with every change applied. The users are only unassigned from the roles of this controller, the users are never
+// deleted from the project matrix of Jenkins, which has the roles created by others.
+func (c *Controller) applyProjectRoles(desired, synced map[string]*projectRole, force bool) error {
+	for name, role := range desired {
+		current, exists := synced[name]
+		overwrite := exists && (current.pattern != role.pattern || current.ids != role.ids)
+		if !overwrite && (force || !exists) {
+			// the role is compared with Jenkins first, the users assigned to it are kept unless it is overwritten
+			actual, err := c.roleOperator.DescribeProjectRole(name)
+			if err != nil {
+				klog.Error(err)
+				return err
+			}
+			overwrite = actual == nil || actual.Pattern != role.pattern || actual.PermissionIds != role.ids
+			if !overwrite {
+				current = &projectRole{pattern: role.pattern, ids: role.ids, sids: sets.NewString(actual.Sids...)}
+				synced[name] = current
+			}
+		}
+		if overwrite {
+			// overwriting a role drops its assigned sids, so all the members have to be assigned again
+			if err := c.roleOperator.AddProjectRole(name, role.pattern, role.ids, true); err != nil {
+				klog.Error(err)
+				return err
+			}
+			current = &projectRole{pattern: role.pattern, ids: role.ids, sids: sets.NewString()}
+			synced[name] = current
+		}
+
+		for _, sid := range role.sids.Difference(current.sids).List() {
+			if err := c.roleOperator.AssignProjectRole(name, sid); err != nil {
+				klog.Error(err)
+				return err
+			}
+			current.sids.Insert(sid)
+		}
+		for _, sid := range current.sids.Difference(role.sids).List() {
+			if err := c.roleOperator.UnAssignProjectRole(name, sid); err != nil {
+				klog.Error(err)
+				return err
+			}
+			current.sids.Delete(sid)
+		}
+	}
+
+	var removedRoles []string
+	for name := range synced {
+		if _, ok := desired[name]; !ok {
+			removedRoles = append(removedRoles, name)
+		}
+	}
+	if len(removedRoles) > 0 {
+		if err := c.roleOperator.DeleteProjectRoles(removedRoles...); err != nil {
+			klog.Error(err)
+			return err
+		}
+		for _, name := range removedRoles {
+			delete(synced, name)
+		}
+	}
+	return nil
+}
+
+// deleteLegacyRoles deletes the roles of the legacy names once, the legacy names of different namespaces may collide,
+// so they are not synced any more
+func (c *Controller) deleteLegacyRoles(namespace string, desired map[string]*projectRole) error {
+	c.mutex.Lock()
+	migrated := c.migrated.Has(namespace)
+	if len(desired) == 0 {
+		// the namespace is gone or has no roles
+		c.migrated.Delete(namespace)
+	}
+	c.mutex.Unlock()
+	if migrated || len(desired) == 0 {
+		return nil
+	}
+
+	legacyNames := sets.NewString()
+	for name := range desired {
+		legacyNames.Insert(legacyRoleName(name))
+	}
+	if err := c.roleOperator.DeleteProjectRoles(legacyNames.List()...); err != nil {
+		klog.Error(err)
+		return err
+	}
+	c.mutex.Lock()
+	c.migrated.Insert(namespace)
+	c.mutex.Unlock()
+	return nil
+}
+
+// desiredProjectRoles returns the Jenkins project roles should exist for the namespace,
+// it is empty if the namespace is not a DevOps project.
+func (c *Controller) desiredProjectRoles(name string) (map[string]*projectRole, error) {
+	desired := map[string]*projectRole{}
+
+	namespace, err := c.namespaceLister.Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return desired, nil
+		}
+		klog.Error(err)
+		return nil, err
+	}
+	if !isDevOpsNamespace(namespace) || namespace.DeletionTimestamp != nil {
+		return desired, nil
+	}
+
+	pattern := ProjectPattern(name)
+
+	roleBindings, err := c.roleBindingLister.RoleBindings(name).List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	for _, roleBinding := range roleBindings {
+		if roleBinding.RoleRef.Kind != "Role" {
+			continue
+		}
+		role, err := c.roleLister.Roles(name).Get(roleBinding.RoleRef.Name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			klog.Error(err)
+			return nil, err
+		}
+		addProjectRole(desired, ProjectRoleName(name, role.Name), pattern, role.Rules, roleBinding.Subjects)
+	}
+
+	workspace := namespace.Labels[tenantv1alpha1.WorkspaceLabel]
+	if workspace == "" {
+		return desired, nil
+	}
+
+	workspaceRoleBindings, err := c.workspaceRoleBindingLister.List(labels.SelectorFromSet(labels.Set{tenantv1alpha1.WorkspaceLabel: workspace}))
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	for _, workspaceRoleBinding := range workspaceRoleBindings {
+		workspaceRole, err := c.workspaceRoleLister.Get(workspaceRoleBinding.RoleRef.Name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			klog.Error(err)
+			return nil, err
+		}
+		addProjectRole(desired, WorkspaceRoleName(name, workspaceRole.Name), pattern, workspaceRole.Rules, workspaceRoleBinding.Subjects)
+	}
+
+	return desired, nil
+}
+
+func addProjectRole(roles map[string]*projectRole, name, pattern string, rules []rbacv1.PolicyRule, subjects []rbacv1.Subject) {
+	ids := ProjectPermissionIdsFromRules(rules)
+	if IsEmpty(ids) {
+		return
+	}
+	role, ok := roles[name]
+	if !ok {
+		role = &projectRole{pattern: pattern, ids: ids, sids: sets.NewString()}
+		roles[name] = role
+	}
+	for _, subject := range subjects {
+		if subject.Kind == rbacv1.UserKind {
+			role.sids.Insert(subject.Name)
+		}
+	}
+}
+
+// isTracked returns true if the namespace has synced roles
+func (c *Controller) isTracked(namespace string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, synced := c.synced[namespace]
+	return synced
+}
+
+// copyProjectRoles returns a deep copy of the roles, it is never nil
+func copyProjectRoles(roles map[string]*projectRole) map[string]*projectRole {
+	copied := make(map[string]*projectRole, len(roles))
+	for name, role := range roles {
+		copied[name] = &projectRole{pattern: role.pattern, ids: role.ids, sids: role.sids.Union(nil)}
+	}
+	return copied
+}
+
+func isDevOpsNamespace(namespace *corev1.Namespace) bool {
+	return namespace.Labels[constants.DevOpsProjectLabelKey] != ""
+}
@@ -0,0 +1,369 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsrole
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	iamv1alpha2 "kubesphere.io/api/iam/v1alpha2"
+	tenantv1alpha1 "kubesphere.io/api/tenant/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+var viewerRules = []rbacv1.PolicyRule{{
+	APIGroups: []string{"*"},
+	Resources: []string{"*"},
+	Verbs:     []string{"get", "list", "watch"},
+}}
+
+// roleDevops records the role operations and keeps the roles, the operations fail while broken is true
+type roleDevops struct {
+	*fakedevops.Devops
+
+	lock   sync.Mutex
+	broken bool
+	calls  []string
+	roles  map[string]*devops.ProjectRole
+}
+
+func (d *roleDevops) record(call string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.broken {
+		return fmt.Errorf("jenkins is down")
+	}
+	d.calls = append(d.calls, call)
+	return nil
+}
+
+func (d *roleDevops) flush() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	calls := d.calls
+	d.calls = nil
+	return calls
+}
+
+func (d *roleDevops) AddProjectRole(roleName string, pattern string, ids devops.ProjectPermissionIds, overwrite bool) error {
+	if err := d.record("add " + roleName); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.roles[roleName] = &devops.ProjectRole{Pattern: pattern, PermissionIds: ids}
+	return nil
+}
+
+func (d *roleDevops) DeleteProjectRoles(roleName ...string) error {
+	if err := d.record("delete " + strings.Join(roleName, ",")); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, name := range roleName {
+		delete(d.roles, name)
+	}
+	return nil
+}
+
+func (d *roleDevops) DescribeProjectRole(roleName string) (*devops.ProjectRole, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.broken {
+		return nil, fmt.Errorf("jenkins is down")
+	}
+	role, ok := d.roles[roleName]
+	if !ok {
+		return nil, nil
+	}
+	copied := *role
+	copied.Sids = append([]string(nil), role.Sids...)
+	return &copied, nil
+}
+
+func (d *roleDevops) AssignProjectRole(roleName string, sid string) error {
+	if err := d.record("assign " + roleName + " " + sid); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if role, ok := d.roles[roleName]; ok {
+		role.Sids = append(role.Sids, sid)
+	}
+	return nil
+}
+
+func (d *roleDevops) UnAssignProjectRole(roleName string, sid string) error {
+	if err := d.record("unassign " + roleName + " " + sid); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if role, ok := d.roles[roleName]; ok {
+		sids := role.Sids[:0]
+		for _, item := range role.Sids {
+			if item != sid {
+				sids = append(sids, item)
+			}
+		}
+		role.Sids = sids
+	}
+	return nil
+}
+
+func (d *roleDevops) DeleteUserInProject(sid string) error {
+	return d.record("delete user " + sid)
+}
+
+type testController struct {
+	*Controller
+	devops *roleDevops
+
+	namespaces            cache.Indexer
+	roles                 cache.Indexer
+	roleBindings          cache.Indexer
+	workspaceRoles        cache.Indexer
+	workspaceRoleBindings cache.Indexer
+}
+
+func newTestController() *testController {
+	k8sInformerFactory := k8sinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	ksInformerFactory := ksinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	namespaceInformer := k8sInformerFactory.Core().V1().Namespaces()
+	roleInformer := k8sInformerFactory.Rbac().V1().Roles()
+	roleBindingInformer := k8sInformerFactory.Rbac().V1().RoleBindings()
+	workspaceRoleInformer := ksInformerFactory.Iam().V1alpha2().WorkspaceRoles()
+	workspaceRoleBindingInformer := ksInformerFactory.Iam().V1alpha2().WorkspaceRoleBindings()
+
+	roleOperator := &roleDevops{Devops: fakedevops.New(), roles: map[string]*devops.ProjectRole{}}
+	c := NewController(roleOperator, namespaceInformer, roleInformer, roleBindingInformer,
+		workspaceRoleInformer, workspaceRoleBindingInformer, 0)
+	return &testController{
+		Controller:            c,
+		devops:                roleOperator,
+		namespaces:            namespaceInformer.Informer().GetIndexer(),
+		roles:                 roleInformer.Informer().GetIndexer(),
+		roleBindings:          roleBindingInformer.Informer().GetIndexer(),
+		workspaceRoles:        workspaceRoleInformer.Informer().GetIndexer(),
+		workspaceRoleBindings: workspaceRoleBindingInformer.Informer().GetIndexer(),
+	}
+}
+
+// addProject adds the DevOps project with the viewer role bound to the users
+func (c *testController) addProject(name, workspace string, users ...string) {
+	c.namespaces.Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: name,
+		Labels: map[string]string{
+			constants.DevOpsProjectLabelKey: "true",
+			tenantv1alpha1.WorkspaceLabel:   workspace,
+		},
+	}})
+	c.roles.Add(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "viewer", Namespace: name}, Rules: viewerRules})
+	c.roleBindings.Add(newRoleBinding(name, users...))
+}
+
+func newRoleBinding(namespace string, users ...string) *rbacv1.RoleBinding {
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: namespace},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "viewer"},
+	}
+	for _, user := range users {
+		roleBinding.Subjects = append(roleBinding.Subjects, rbacv1.Subject{Kind: rbacv1.UserKind, Name: user})
+	}
+	return roleBinding
+}
+
+func TestSyncHandler(t *testing.T) {
+	c := newTestController()
+	c.addProject("foo", "ws", "alice", "bob")
+	c.workspaceRoles.Add(&iamv1alpha2.WorkspaceRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws-viewer", Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws"}},
+		Rules:      viewerRules,
+	})
+	c.workspaceRoleBindings.Add(&iamv1alpha2.WorkspaceRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws-viewer-carol", Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "WorkspaceRole", Name: "ws-viewer"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "carol"}},
+	})
+
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	calls := c.devops.flush()
+	for _, expected := range []string{"add foo/viewer/project", "assign foo/viewer/project alice", "assign foo/viewer/project bob",
+		"add foo/ws-viewer/workspace", "assign foo/ws-viewer/workspace carol",
+		// the roles of the legacy names are deleted once
+		"delete foo-viewer-project,foo-ws-viewer-workspace"} {
+		if !hasCall(calls, expected) {
+			t.Errorf("expected %q in the calls %v", expected, calls)
+		}
+	}
+
+	// nothing changed
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := c.devops.flush(); len(calls) != 0 {
+		t.Errorf("expected no calls, got %v", calls)
+	}
+
+	// bob leaves the project, and is only unassigned from the roles of the controller
+	c.roleBindings.Update(newRoleBinding("foo", "alice"))
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if calls, expected := c.devops.flush(), []string{"unassign foo/viewer/project bob"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+
+	// the project is deleted
+	c.namespaces.Delete(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	calls = c.devops.flush()
+	if len(calls) != 1 || !strings.HasPrefix(calls[0], "delete foo/") {
+		t.Errorf("expected the roles of the project are deleted, got %v", calls)
+	}
+	if c.isTracked("foo") {
+		t.Errorf("expected the deleted project is not tracked")
+	}
+}
+
+func TestSyncHandlerKeepsOtherRoles(t *testing.T) {
+	c := newTestController()
+	c.addProject("foo", "ws", "alice")
+	// the role created in Jenkins by the administrator
+	c.devops.roles["release-managers"] = &devops.ProjectRole{Pattern: ".*", Sids: []string{"alice"}}
+
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	c.devops.flush()
+
+	c.roleBindings.Delete(newRoleBinding("foo"))
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if calls, expected := c.devops.flush(), []string{"delete foo/viewer/project"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+	if sids := c.devops.roles["release-managers"].Sids; !reflect.DeepEqual(sids, []string{"alice"}) {
+		t.Errorf("expected alice is kept in the roles not synced by the controller, got %v", sids)
+	}
+}
+
+func TestSyncHandlerRetries(t *testing.T) {
+	c := newTestController()
+	c.addProject("foo", "ws", "alice")
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	c.devops.flush()
+
+	// the deletion of the role fails, it is retried until it succeeds
+	c.roleBindings.Delete(newRoleBinding("foo"))
+	c.devops.broken = true
+	if err := c.syncHandler("foo"); err == nil {
+		t.Fatal("expected the error of Jenkins")
+	}
+	c.devops.broken = false
+	if err := c.syncHandler("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if calls, expected := c.devops.flush(), []string{"delete foo/viewer/project"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+	if c.isTracked("foo") {
+		t.Errorf("expected nothing is left to sync of project foo")
+	}
+}
+
+func TestController(t *testing.T) {
+	c := newTestController()
+	c.addProject("foo", "ws", "alice")
+	c.namespaces.Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+
+	// the namespaces which are not DevOps projects are left out
+	c.resync()
+	if c.workqueue.Len() != 1 {
+		t.Fatalf("expected only the DevOps project is queued, got %d", c.workqueue.Len())
+	}
+	if !c.processNextWorkItem() {
+		t.Fatal("expected the queue is not shut down")
+	}
+	if calls, expected := c.devops.flush(), []string{"add foo/viewer/project", "assign foo/viewer/project alice",
+		"delete foo-viewer-project"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+
+	// the resync compares the roles with Jenkins, nothing is written if they are in step
+	c.resync()
+	c.processNextWorkItem()
+	if calls := c.devops.flush(); len(calls) != 0 {
+		t.Errorf("expected no calls, got %v", calls)
+	}
+
+	// the users unassigned in Jenkins are assigned again, the users of the role are kept
+	c.devops.roles["foo/viewer/project"].Sids = []string{"mallory"}
+	c.resync()
+	c.processNextWorkItem()
+	if calls, expected := c.devops.flush(), []string{"assign foo/viewer/project alice", "unassign foo/viewer/project mallory"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+
+	// the role changed in Jenkins is written again
+	c.devops.roles["foo/viewer/project"].PermissionIds = devops.ProjectPermissionIds{}
+	c.resync()
+	c.processNextWorkItem()
+	if calls, expected := c.devops.flush(), []string{"add foo/viewer/project", "assign foo/viewer/project alice"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the roles are written again, got %v", calls)
+	}
+
+	// the workspace role bindings enqueue the projects of the workspace
+	c.enqueueWorkspace(&iamv1alpha2.WorkspaceRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ws-viewer-bob", Labels: map[string]string{tenantv1alpha1.WorkspaceLabel: "ws"}},
+	})
+	if c.workqueue.Len() != 1 {
+		t.Errorf("expected the project of the workspace is queued, got %d", c.workqueue.Len())
+	}
+}
+
+func hasCall(calls []string, call string) bool {
+	for _, c := range calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
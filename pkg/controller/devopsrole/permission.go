@@ -0,0 +1,207 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsrole
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+type grantFunc func(ids *devops.ProjectPermissionIds)
+
+var (
+	readPipeline = func(ids *devops.ProjectPermissionIds) {
+		ids.ItemRead = true
+		ids.ItemDiscover = true
+		ids.ItemWorkspace = true
+	}
+	createPipeline = func(ids *devops.ProjectPermissionIds) {
+		ids.ItemCreate = true
+	}
+	updatePipeline = func(ids *devops.ProjectPermissionIds) {
+		ids.ItemConfigure = true
+		ids.ItemMove = true
+		ids.SCMTag = true
+	}
+	deletePipeline = func(ids *devops.ProjectPermissionIds) {
+		ids.ItemDelete = true
+	}
+	createRun = func(ids *devops.ProjectPermissionIds) {
+		ids.ItemBuild = true
+		ids.RunReplay = true
+	}
+	updateRun = func(ids *devops.ProjectPermissionIds) {
+		ids.ItemCancel = true
+		ids.RunUpdate = true
+	}
+	deleteRun = func(ids *devops.ProjectPermissionIds) {
+		ids.ItemCancel = true
+		ids.RunDelete = true
+	}
+	readCredential = func(ids *devops.ProjectPermissionIds) {
+		ids.CredentialView = true
+	}
+	createCredential = func(ids *devops.ProjectPermissionIds) {
+		ids.CredentialCreate = true
+	}
+	updateCredential = func(ids *devops.ProjectPermissionIds) {
+		ids.CredentialUpdate = true
+		ids.CredentialManageDomains = true
+	}
+	deleteCredential = func(ids *devops.ProjectPermissionIds) {
+		ids.CredentialDelete = true
+	}
+	all = func(ids *devops.ProjectPermissionIds) {
+		for _, grant := range []grantFunc{readPipeline, createPipeline, updatePipeline, deletePipeline,
+			createRun, updateRun, deleteRun, readCredential, createCredential, updateCredential, deleteCredential} {
+			grant(ids)
+		}
+	}
+	readAll = func(ids *devops.ProjectPermissionIds) {
+		readPipeline(ids)
+		readCredential(ids)
+	}
+)
+
+// resourcePermissions describes which Jenkins permissions a verb on a DevOps resource implies
+var resourcePermissions = map[string]map[string][]grantFunc{
+	"pipelines": {
+		"get":              {readPipeline},
+		"list":             {readPipeline},
+		"watch":            {readPipeline},
+		"create":           {createPipeline},
+		"update":           {updatePipeline},
+		"patch":            {updatePipeline},
+		"delete":           {deletePipeline},
+		"deletecollection": {deletePipeline},
+	},
+	"pipelines/runs": {
+		"get":    {readPipeline},
+		"list":   {readPipeline},
+		"create": {createRun},
+		"update": {updateRun},
+		"patch":  {updateRun},
+		"delete": {deleteRun},
+	},
+	"pipelineruns": {
+		"get":    {readPipeline},
+		"list":   {readPipeline},
+		"watch":  {readPipeline},
+		"create": {createRun},
+		"update": {updateRun},
+		"patch":  {updateRun},
+		"delete": {deleteRun},
+	},
+	"credentials": {
+		"get":              {readCredential},
+		"list":             {readCredential},
+		"watch":            {readCredential},
+		"create":           {createCredential},
+		"update":           {updateCredential},
+		"patch":            {updateCredential},
+		"delete":           {deleteCredential},
+		"deletecollection": {deleteCredential},
+	},
+	"secrets": {
+		"get":              {readCredential},
+		"list":             {readCredential},
+		"watch":            {readCredential},
+		"create":           {createCredential},
+		"update":           {updateCredential},
+		"patch":            {updateCredential},
+		"delete":           {deleteCredential},
+		"deletecollection": {deleteCredential},
+	},
+	// the devops resource is used by workspace roles, it covers everything inside the project
+	"devops": {
+		"get":    {readAll},
+		"list":   {readAll},
+		"watch":  {readAll},
+		"create": {all},
+		"update": {all},
+		"patch":  {all},
+		"delete": {all},
+	},
+}
+
+// ProjectPermissionIdsFromRules derives the Jenkins project permissions granted by the given policy rules
+func ProjectPermissionIdsFromRules(rules []rbacv1.PolicyRule) devops.ProjectPermissionIds {
+	ids := devops.ProjectPermissionIds{}
+	for _, rule := range rules {
+		if !matchAPIGroups(rule.APIGroups) {
+			continue
+		}
+		for resource, verbs := range resourcePermissions {
+			if !contains(rule.Resources, resource) {
+				continue
+			}
+			for verb, grants := range verbs {
+				if !contains(rule.Verbs, verb) {
+					continue
+				}
+				for _, grant := range grants {
+					grant(&ids)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// IsEmpty returns true if none of permissions is granted
+func IsEmpty(ids devops.ProjectPermissionIds) bool {
+	return ids == devops.ProjectPermissionIds{}
+}
+
+// ProjectRoleName returns the name of Jenkins project role which mapped from the role in namespace. The names are
+// joined by '/', which can't appear in the names of namespaces and roles, so the roles of namespaces never collide.
+func ProjectRoleName(namespace, role string) string {
+	return fmt.Sprintf("%s/%s/project", namespace, role)
+}
+
+// WorkspaceRoleName returns the name of Jenkins project role which mapped from the workspace role
+func WorkspaceRoleName(namespace, workspaceRole string) string {
+	return fmt.Sprintf("%s/%s/workspace", namespace, workspaceRole)
+}
+
+// legacyRoleName returns the name of the Jenkins project role before the names were joined by '/'
+func legacyRoleName(name string) string {
+	return strings.ReplaceAll(name, "/", "-")
+}
+
+// ProjectPattern returns the pattern matches the Jenkins folder of project and all the items in it
+func ProjectPattern(namespace string) string {
+	return fmt.Sprintf("^%s(/.*)?$", regexp.QuoteMeta(namespace))
+}
+
+func matchAPIGroups(groups []string) bool {
+	return contains(groups, "") || contains(groups, "devops.kubesphere.io")
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == rbacv1.ResourceAll || i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devopsrole
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+func TestProjectPermissionIdsFromRules(t *testing.T) {
+	tests := []struct {
+		description string
+		rules       []rbacv1.PolicyRule
+		expected    devops.ProjectPermissionIds
+	}{
+		{
+			"viewer",
+			[]rbacv1.PolicyRule{{
+				APIGroups: []string{"*"},
+				Resources: []string{"*"},
+				Verbs:     []string{"get", "list", "watch"},
+			}},
+			devops.ProjectPermissionIds{
+				ItemRead:       true,
+				ItemDiscover:   true,
+				ItemWorkspace:  true,
+				CredentialView: true,
+			},
+		},
+		{
+			"operator",
+			[]rbacv1.PolicyRule{{
+				APIGroups: []string{"devops.kubesphere.io"},
+				Resources: []string{"pipelines", "pipelines/runs"},
+				Verbs:     []string{"get", "create"},
+			}},
+			devops.ProjectPermissionIds{
+				ItemRead:      true,
+				ItemDiscover:  true,
+				ItemWorkspace: true,
+				ItemCreate:    true,
+				ItemBuild:     true,
+				RunReplay:     true,
+			},
+		},
+		{
+			"unrelated api group",
+			[]rbacv1.PolicyRule{{
+				APIGroups: []string{"apps"},
+				Resources: []string{"*"},
+				Verbs:     []string{"*"},
+			}},
+			devops.ProjectPermissionIds{},
+		},
+		{
+			"workspace admin",
+			[]rbacv1.PolicyRule{{
+				APIGroups: []string{"*"},
+				Resources: []string{"devops"},
+				Verbs:     []string{"*"},
+			}},
+			devops.ProjectPermissionIds{
+				CredentialCreate:        true,
+				CredentialUpdate:        true,
+				CredentialView:          true,
+				CredentialDelete:        true,
+				CredentialManageDomains: true,
+				ItemBuild:               true,
+				ItemCreate:              true,
+				ItemRead:                true,
+				ItemConfigure:           true,
+				ItemCancel:              true,
+				ItemMove:                true,
+				ItemDiscover:            true,
+				ItemWorkspace:           true,
+				ItemDelete:              true,
+				RunUpdate:               true,
+				RunDelete:               true,
+				RunReplay:               true,
+				SCMTag:                  true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := ProjectPermissionIdsFromRules(test.rules)
+			if diff := cmp.Diff(got, test.expected); diff != "" {
+				t.Errorf("%T differ (-got, +want): %s", test.expected, diff)
+			}
+		})
+	}
+}
+
+func TestProjectPattern(t *testing.T) {
+	pattern := regexp.MustCompile(ProjectPattern("demo"))
+
+	for name, expected := range map[string]bool{
+		"demo":           true,
+		"demo/pipeline":  true,
+		"demo/a/b":       true,
+		"demo-2":         false,
+		"demo2/pipeline": false,
+		"other/demo":     false,
+	} {
+		if got := pattern.MatchString(name); got != expected {
+			t.Errorf("expected %v for %s, got %v", expected, name, got)
+		}
+	}
+}
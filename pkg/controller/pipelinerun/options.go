@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultSyncPeriod is the interval to sync the unfinished PipelineRuns
+	DefaultSyncPeriod = 30 * time.Second
+	// DefaultMirrorPeriod is the interval to mirror the runs started in Jenkins
+	DefaultMirrorPeriod = 5 * time.Minute
+	// DefaultMaxRuns is the number of the newest finished PipelineRuns kept for every pipeline or branch
+	DefaultMaxRuns = 20
+)
+
+// Options configures the PipelineRuns. The finished PipelineRuns are deleted unless they are among the newest
+// MaxRuns runs of their pipeline or branch, or younger than MaxAge, a zero value disables the criterion. The
+// runs started by cron or SCM events are mirrored as PipelineRuns if they would be kept.
+type Options struct {
+	SyncPeriod time.Duration `json:"syncPeriod,omitempty" yaml:"syncPeriod,omitempty" mapstructure:"syncPeriod"`
+	// MirrorRuns creates the PipelineRuns for the runs started in Jenkins
+	MirrorRuns bool `json:"mirrorRuns" yaml:"mirrorRuns" mapstructure:"mirrorRuns"`
+	// MirrorPeriod is the interval to list the newest runs of every pipeline in Jenkins
+	MirrorPeriod time.Duration `json:"mirrorPeriod,omitempty" yaml:"mirrorPeriod,omitempty" mapstructure:"mirrorPeriod"`
+	// MaxRuns is the number of the newest finished runs kept for every pipeline or branch
+	MaxRuns int `json:"maxRuns,omitempty" yaml:"maxRuns,omitempty" mapstructure:"maxRuns"`
+	// MaxAge is the duration which the finished runs are kept for after they start
+	MaxAge time.Duration `json:"maxAge,omitempty" yaml:"maxAge,omitempty" mapstructure:"maxAge"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		SyncPeriod:   DefaultSyncPeriod,
+		MirrorRuns:   true,
+		MirrorPeriod: DefaultMirrorPeriod,
+		MaxRuns:      DefaultMaxRuns,
+	}
+}
+
+// Validate check options
+func (o *Options) Validate() []error {
+	errors := make([]error, 0)
+	if o == nil {
+		return errors
+	}
+
+	if o.SyncPeriod < 0 {
+		errors = append(errors, fmt.Errorf("syncPeriod of pipelinerun must not be negative"))
+	}
+	if o.MirrorPeriod < 0 {
+		errors = append(errors, fmt.Errorf("mirrorPeriod of pipelinerun must not be negative"))
+	}
+	if o.MaxRuns < 0 {
+		errors = append(errors, fmt.Errorf("maxRuns of pipelinerun must not be negative"))
+	}
+	if o.MaxAge < 0 {
+		errors = append(errors, fmt.Errorf("maxAge of pipelinerun must not be negative"))
+	}
+	return errors
+}
@@ -0,0 +1,956 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins"
+	devopsinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/devops/v1alpha3"
+	devopslisters "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha3"
+)
+
+const maxRetries = 15
+
+// mirrorLimit is the number of the newest runs of every pipeline checked by the mirroring
+const mirrorLimit = 50
+
+// triggerTimeout is how long a PipelineRun being triggered by another worker or replica is waited for, it is
+// given up afterwards rather than triggered twice
+const triggerTimeout = time.Minute
+
+const (
+	stateQueued   = "QUEUED"
+	stateFinished = "FINISHED"
+
+	resultSuccess = "SUCCESS"
+	resultAborted = "ABORTED"
+)
+
+// Controller triggers the PipelineRuns in Jenkins, tracks their status, stops them when they are deleted, and
+// mirrors the runs started in Jenkins as PipelineRuns. The finished PipelineRuns are deleted by the retention.
+type Controller struct {
+	devopsClient devops.Interface
+	ksclient     kubesphere.Interface
+	options      *Options
+
+	pipelineLister    devopslisters.PipelineLister
+	pipelineSynced    cache.InformerSynced
+	pipelineRunLister devopslisters.PipelineRunLister
+	pipelineRunSynced cache.InformerSynced
+
+	workqueue workqueue.RateLimitingInterface
+	now       func() time.Time
+
+	mutex sync.Mutex
+	// ids of the runs triggered for the PipelineRuns whose annotation isn't in the cache yet, it prevents the
+	// PipelineRuns from being triggered twice and their runs from being mirrored
+	triggered map[string]string
+}
+
+func NewController(devopsClient devops.Interface, ksclient kubesphere.Interface,
+	pipelineInformer devopsinformers.PipelineInformer, pipelineRunInformer devopsinformers.PipelineRunInformer,
+	options *Options) *Controller {
+
+	if options == nil {
+		options = NewOptions()
+	}
+	if options.SyncPeriod <= 0 {
+		options.SyncPeriod = DefaultSyncPeriod
+	}
+	if options.MirrorPeriod <= 0 {
+		options.MirrorPeriod = DefaultMirrorPeriod
+	}
+
+	c := &Controller{
+		devopsClient:      devopsClient,
+		ksclient:          ksclient,
+		options:           options,
+		pipelineLister:    pipelineInformer.Lister(),
+		pipelineSynced:    pipelineInformer.Informer().HasSynced,
+		pipelineRunLister: pipelineRunInformer.Lister(),
+		pipelineRunSynced: pipelineRunInformer.Informer().HasSynced,
+		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pipeline-run"),
+		now:               time.Now,
+		triggered:         map[string]string{},
+	}
+
+	pipelineRunInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePipelineRun,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueuePipelineRun(newObj)
+		},
+		DeleteFunc: c.enqueuePipelineRun,
+	})
+	return c
+}
+
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Info("starting pipeline run controller")
+	defer klog.Info("shutting down pipeline run controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.pipelineSynced, c.pipelineRunSynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	go wait.Until(c.resync, c.options.SyncPeriod, stopCh)
+
+	<-stopCh
+	return nil
+}
+
+// Mirror mirrors the runs started in Jenkins every MirrorPeriod until stopCh is closed. It lists the runs of all
+// the pipelines, so only the replica holding the lease of the controllers runs it.
+func (c *Controller) Mirror(stopCh <-chan struct{}) {
+	if !c.options.MirrorRuns {
+		return
+	}
+	if !cache.WaitForCacheSync(stopCh, c.pipelineSynced, c.pipelineRunSynced) {
+		klog.Error("failed to wait for caches to sync")
+		return
+	}
+	wait.Until(c.mirrorRuns, c.options.MirrorPeriod, stopCh)
+}
+
+// resync enqueues the PipelineRuns which are unfinished or being deleted, and deletes the finished PipelineRuns
+// out of retention
+func (c *Controller) resync() {
+	pipelineRuns, err := c.pipelineRunLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	for _, pipelineRun := range pipelineRuns {
+		if !pipelineRun.IsFinished() || pipelineRun.DeletionTimestamp != nil {
+			c.enqueuePipelineRun(pipelineRun)
+		}
+	}
+	c.cleanup(pipelineRuns)
+}
+
+func (c *Controller) mirrorRuns() {
+	pipelineRuns, err := c.pipelineRunLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	c.mirror(pipelineRuns)
+}
+
+func (c *Controller) enqueuePipelineRun(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
+
+func (c *Controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	key, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		return true
+	}
+
+	if err := c.syncHandler(key); err != nil {
+		if c.workqueue.NumRequeues(obj) < maxRetries {
+			klog.Warningf("failed to sync pipeline run %s, retrying: %v", key, err)
+			c.workqueue.AddRateLimited(obj)
+			return true
+		}
+		klog.Errorf("dropping pipeline run %s out of the queue: %v", key, err)
+	}
+	c.workqueue.Forget(obj)
+	return true
+}
+
+// syncHandler triggers the PipelineRun if it has no run in Jenkins, otherwise syncs its status from the run
+func (c *Controller) syncHandler(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Error(err)
+		return nil
+	}
+	pipelineRun, err := c.pipelineRunLister.PipelineRuns(namespace).Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			c.setTriggered(key, "")
+			return nil
+		}
+		klog.Error(err)
+		return err
+	}
+
+	runID := pipelineRun.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey]
+	if runID != "" {
+		c.setTriggered(key, "")
+	} else {
+		runID = c.triggeredRun(key)
+	}
+
+	if pipelineRun.DeletionTimestamp != nil {
+		return c.finalize(pipelineRun, runID)
+	}
+	if runID == "" {
+		if pipelineRun.IsFinished() {
+			return c.removeFinalizer(pipelineRun)
+		}
+		if triggering, ok := pipelineRun.Annotations[devopsv1alpha3.PipelineRunTriggeringAnnoKey]; ok {
+			return c.giveUpTrigger(key, pipelineRun, triggering)
+		}
+		return c.trigger(key, pipelineRun)
+	}
+	if pipelineRun.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey] == "" {
+		// the run was triggered but recording it failed
+		return c.record(pipelineRun, runID)
+	}
+	if pipelineRun.IsFinished() {
+		// the finalizer is kept to remember the run once the PipelineRun is deleted
+		return nil
+	}
+	return c.syncStatus(pipelineRun, runID)
+}
+
+// trigger starts the run of the PipelineRun, the PipelineRuns which can never be triggered fail
+func (c *Controller) trigger(key string, pipelineRun *devopsv1alpha3.PipelineRun) error {
+	pipeline, err := c.pipelineLister.Pipelines(pipelineRun.Namespace).Get(pipelineRun.Spec.Pipeline)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return c.fail(pipelineRun, fmt.Sprintf("pipeline %s not found", pipelineRun.Spec.Pipeline))
+		}
+		klog.Error(err)
+		return err
+	}
+	multiBranch := pipeline.Spec.Type == devopsv1alpha3.MultiBranchPipelineType
+	if multiBranch && pipelineRun.Spec.Branch == "" {
+		return c.fail(pipelineRun, "branch is required by the multi-branch pipeline")
+	}
+	if !multiBranch && pipelineRun.Spec.Branch != "" {
+		return c.fail(pipelineRun, "only the multi-branch pipelines have branches")
+	}
+
+	parameters := pipelineRun.Spec.Parameters
+	if parameters == nil {
+		parameters = []devopsv1alpha3.ParameterValue{}
+	}
+	body, err := json.Marshal(map[string]interface{}{"parameters": parameters})
+	if err != nil {
+		return err
+	}
+
+	// the intent is persisted first, so the PipelineRun is not triggered again if the run fails to be recorded
+	// and the controller restarts, or if another replica syncs it
+	if pipelineRun, err = c.markTriggering(pipelineRun); err != nil {
+		return err
+	}
+
+	var run *devops.RunPipeline
+	httpParameters := devops.NewHttpParameters(http.MethodPost, body)
+	if multiBranch {
+		run, err = c.devopsClient.RunBranchPipeline(pipelineRun.Namespace, pipeline.Name, jobPath(pipelineRun.Spec.Branch), httpParameters)
+	} else {
+		run, err = c.devopsClient.RunPipeline(pipelineRun.Namespace, pipeline.Name, httpParameters)
+	}
+	if err == nil && (run == nil || run.ID == "") {
+		err = fmt.Errorf("jenkins returned no run")
+	}
+	if err != nil {
+		klog.Error(err)
+		code, responded := statusCodeOf(err)
+		if responded && code >= http.StatusBadRequest && code < http.StatusInternalServerError {
+			return c.fail(pipelineRun, fmt.Sprintf("failed to trigger the run: %v", err))
+		}
+		if responded && code >= http.StatusInternalServerError {
+			// jenkins refused the run, so it is triggered again
+			if _, clearErr := c.clearTriggering(pipelineRun); clearErr != nil {
+				return clearErr
+			}
+		}
+		// the run might have been started if jenkins didn't respond, the annotation is kept so it is given up
+		// rather than triggered twice
+		return err
+	}
+
+	c.setTriggered(key, run.ID)
+	return c.record(pipelineRun, run.ID)
+}
+
+// statusCodeOf returns the status code of the response from Jenkins, it returns false if no response was received,
+// e.g. the connection failed or timed out
+func statusCodeOf(err error) (int, bool) {
+	switch err := err.(type) {
+	case *jenkins.JkError:
+		return err.Code, true
+	case *devops.ErrorResponse:
+		return err.Response.StatusCode, true
+	}
+	if code, convErr := strconv.Atoi(err.Error()); convErr == nil && http.StatusText(code) != "" {
+		return code, true
+	}
+	return 0, false
+}
+
+// markTriggering records the time when the PipelineRun starts to be triggered, the update conflicts if another
+// replica is triggering it
+func (c *Controller) markTriggering(pipelineRun *devopsv1alpha3.PipelineRun) (*devopsv1alpha3.PipelineRun, error) {
+	pipelineRun = pipelineRun.DeepCopy()
+	if pipelineRun.Annotations == nil {
+		pipelineRun.Annotations = map[string]string{}
+	}
+	pipelineRun.Annotations[devopsv1alpha3.PipelineRunTriggeringAnnoKey] = c.now().UTC().Format(time.RFC3339)
+	if !hasFinalizer(pipelineRun) {
+		pipelineRun.Finalizers = append(pipelineRun.Finalizers, devopsv1alpha3.PipelineRunFinalizerName)
+	}
+
+	pipelineRun, err := c.ksclient.DevopsV1alpha3().PipelineRuns(pipelineRun.Namespace).Update(context.Background(),
+		pipelineRun, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Error(err)
+	}
+	return pipelineRun, err
+}
+
+func (c *Controller) clearTriggering(pipelineRun *devopsv1alpha3.PipelineRun) (*devopsv1alpha3.PipelineRun, error) {
+	pipelineRun = pipelineRun.DeepCopy()
+	delete(pipelineRun.Annotations, devopsv1alpha3.PipelineRunTriggeringAnnoKey)
+	pipelineRun, err := c.ksclient.DevopsV1alpha3().PipelineRuns(pipelineRun.Namespace).Update(context.Background(),
+		pipelineRun, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Error(err)
+	}
+	return pipelineRun, err
+}
+
+// giveUpTrigger handles the PipelineRun which started to be triggered somewhere else, or before restart, but its
+// run is unknown. It is waited for until triggerTimeout, then it becomes unknown rather than triggered twice.
+func (c *Controller) giveUpTrigger(key string, pipelineRun *devopsv1alpha3.PipelineRun, triggering string) error {
+	if since, err := time.Parse(time.RFC3339, triggering); err == nil {
+		if elapsed := c.now().Sub(since); elapsed < triggerTimeout {
+			c.workqueue.AddAfter(key, triggerTimeout-elapsed)
+			return nil
+		}
+	}
+	status := pipelineRun.Status.DeepCopy()
+	status.Phase = devopsv1alpha3.RunUnknown
+	status.Message = fmt.Sprintf("the run was triggered at %s but it was not recorded, it is not triggered again", triggering)
+	return c.updateStatus(pipelineRun, *status)
+}
+
+// record binds the PipelineRun to its run in Jenkins, and to its pipeline
+func (c *Controller) record(pipelineRun *devopsv1alpha3.PipelineRun, runID string) error {
+	pipelineRun = pipelineRun.DeepCopy()
+	if pipelineRun.Annotations == nil {
+		pipelineRun.Annotations = map[string]string{}
+	}
+	pipelineRun.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey] = runID
+	delete(pipelineRun.Annotations, devopsv1alpha3.PipelineRunTriggeringAnnoKey)
+	if pipelineRun.Labels == nil {
+		pipelineRun.Labels = map[string]string{}
+	}
+	pipelineRun.Labels[devopsv1alpha3.PipelineNameLabelKey] = pipelineRun.Spec.Pipeline
+	if !hasFinalizer(pipelineRun) {
+		pipelineRun.Finalizers = append(pipelineRun.Finalizers, devopsv1alpha3.PipelineRunFinalizerName)
+	}
+	if len(pipelineRun.OwnerReferences) == 0 {
+		if pipeline, err := c.pipelineLister.Pipelines(pipelineRun.Namespace).Get(pipelineRun.Spec.Pipeline); err == nil {
+			pipelineRun.OwnerReferences = ownerReferences(pipeline)
+		}
+	}
+
+	_, err := c.ksclient.DevopsV1alpha3().PipelineRuns(pipelineRun.Namespace).Update(context.Background(),
+		pipelineRun, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Error(err)
+	}
+	return err
+}
+
+// fail marks the PipelineRun which can't be triggered as failed
+func (c *Controller) fail(pipelineRun *devopsv1alpha3.PipelineRun, message string) error {
+	now := metav1.NewTime(c.now())
+	status := pipelineRun.Status.DeepCopy()
+	status.Phase = devopsv1alpha3.RunFailed
+	status.Message = message
+	if status.CompletionTime == nil {
+		status.CompletionTime = &now
+	}
+	return c.updateStatus(pipelineRun, *status)
+}
+
+// finalize stops the unfinished run of the deleted PipelineRun, and records the run in its pipeline so it is not
+// mirrored again
+func (c *Controller) finalize(pipelineRun *devopsv1alpha3.PipelineRun, runID string) error {
+	if !hasFinalizer(pipelineRun) {
+		return nil
+	}
+	if runID != "" && !pipelineRun.IsFinished() {
+		var err error
+		httpParameters := devops.NewHttpParameters(http.MethodPut, nil)
+		httpParameters.Url.RawQuery = "blocking=true&timeOutInSecs=10"
+		if pipelineRun.Spec.Branch != "" {
+			_, err = c.devopsClient.StopBranchPipeline(pipelineRun.Namespace, pipelineRun.Spec.Pipeline,
+				jobPath(pipelineRun.Spec.Branch), runID, httpParameters)
+		} else {
+			_, err = c.devopsClient.StopPipeline(pipelineRun.Namespace, pipelineRun.Spec.Pipeline, runID, httpParameters)
+		}
+		if err != nil && devops.GetDevOpsStatusCode(err) != http.StatusNotFound {
+			klog.Error(err)
+			return err
+		}
+	}
+	if runID != "" {
+		err := c.updateDeletedRuns(pipelineRun.Namespace, pipelineRun.Spec.Pipeline, func(deleted sets.String) {
+			deleted.Insert(deletedRun(pipelineRun.Spec.Branch, runID))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return c.removeFinalizer(pipelineRun)
+}
+
+func (c *Controller) removeFinalizer(pipelineRun *devopsv1alpha3.PipelineRun) error {
+	if !hasFinalizer(pipelineRun) {
+		return nil
+	}
+	pipelineRun = pipelineRun.DeepCopy()
+	finalizers := pipelineRun.Finalizers[:0]
+	for _, finalizer := range pipelineRun.Finalizers {
+		if finalizer != devopsv1alpha3.PipelineRunFinalizerName {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	pipelineRun.Finalizers = finalizers
+
+	_, err := c.ksclient.DevopsV1alpha3().PipelineRuns(pipelineRun.Namespace).Update(context.Background(),
+		pipelineRun, metav1.UpdateOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Error(err)
+		return err
+	}
+	return nil
+}
+
+// syncStatus copies the status of the run in Jenkins, the runs not found any more are unknown
+func (c *Controller) syncStatus(pipelineRun *devopsv1alpha3.PipelineRun, runID string) error {
+	status, err := c.fetchStatus(pipelineRun, runID)
+	if err != nil {
+		if devops.GetDevOpsStatusCode(err) != http.StatusNotFound {
+			klog.Error(err)
+			return err
+		}
+		status = pipelineRun.Status.DeepCopy()
+		status.Phase = devopsv1alpha3.RunUnknown
+		status.Message = fmt.Sprintf("run %s is not found in jenkins", runID)
+	}
+	return c.updateStatus(pipelineRun, *status)
+}
+
+func (c *Controller) fetchStatus(pipelineRun *devopsv1alpha3.PipelineRun, runID string) (*devopsv1alpha3.PipelineRunStatus, error) {
+	namespace, pipeline, branch := pipelineRun.Namespace, pipelineRun.Spec.Pipeline, pipelineRun.Spec.Branch
+	httpParameters := devops.NewHttpParameters(http.MethodGet, nil)
+
+	var run *devops.PipelineRun
+	var err error
+	if branch != "" {
+		run, err = c.devopsClient.GetBranchPipelineRun(namespace, pipeline, jobPath(branch), runID, httpParameters)
+	} else {
+		run, err = c.devopsClient.GetPipelineRun(namespace, pipeline, runID, httpParameters)
+	}
+	if err == nil && run == nil {
+		err = fmt.Errorf("jenkins returned no run")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status := &devopsv1alpha3.PipelineRunStatus{
+		Phase:     phaseOf(run.State, run.Result),
+		StartTime: parseTime(run.StartTime),
+	}
+	if run.State == stateFinished {
+		status.Result = run.Result
+		status.CompletionTime = parseTime(run.EndTime)
+	}
+	if run.State == stateQueued {
+		return status, nil
+	}
+
+	if branch != "" {
+		nodes, err := c.devopsClient.GetBranchPipelineRunNodes(namespace, pipeline, jobPath(branch), runID, devops.NewHttpParameters(http.MethodGet, nil))
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			status.Stages = append(status.Stages, stageOf(node.ID, node.DisplayName, node.State, node.Result, node.StartTime, node.DurationInMillis))
+		}
+	} else {
+		nodes, err := c.devopsClient.GetPipelineRunNodes(namespace, pipeline, runID, devops.NewHttpParameters(http.MethodGet, nil))
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			status.Stages = append(status.Stages, stageOf(node.ID, node.DisplayName, node.State, node.Result, node.StartTime, node.DurationInMillis))
+		}
+	}
+	return status, nil
+}
+
+// updateStatus writes the status only if it changes, so the periodical resync doesn't touch the PipelineRuns
+func (c *Controller) updateStatus(pipelineRun *devopsv1alpha3.PipelineRun, status devopsv1alpha3.PipelineRunStatus) error {
+	current := pipelineRun.Status
+	current.UpdateTime, status.UpdateTime = nil, nil
+	if equality.Semantic.DeepEqual(current, status) {
+		return nil
+	}
+
+	now := metav1.NewTime(c.now())
+	status.UpdateTime = &now
+
+	pipelineRun = pipelineRun.DeepCopy()
+	pipelineRun.Status = status
+	_, err := c.ksclient.DevopsV1alpha3().PipelineRuns(pipelineRun.Namespace).UpdateStatus(context.Background(),
+		pipelineRun, metav1.UpdateOptions{})
+	if err != nil {
+		klog.Error(err)
+	}
+	return err
+}
+
+// mirror creates the PipelineRuns for the runs started by cron, SCM events or Jenkins itself, the finished runs
+// which would be deleted by the retention are not mirrored
+func (c *Controller) mirror(pipelineRuns []*devopsv1alpha3.PipelineRun) {
+	pipelines, err := c.pipelineLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	known := sets.NewString()
+	// the pipelines whose runs are being triggered, their new runs might not be recorded yet
+	triggering := sets.NewString()
+	for _, pipelineRun := range pipelineRuns {
+		runID := pipelineRun.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey]
+		if runID == "" {
+			key, _ := cache.MetaNamespaceKeyFunc(pipelineRun)
+			runID = c.triggeredRun(key)
+		}
+		if runID != "" {
+			known.Insert(runRef(pipelineRun.Namespace, pipelineRun.Spec.Pipeline, pipelineRun.Spec.Branch, runID))
+		} else if _, ok := pipelineRun.Annotations[devopsv1alpha3.PipelineRunTriggeringAnnoKey]; ok && !pipelineRun.IsFinished() {
+			triggering.Insert(pipelineRun.Namespace + "/" + pipelineRun.Spec.Pipeline)
+		}
+	}
+
+	for _, pipeline := range pipelines {
+		if pipeline.DeletionTimestamp != nil || triggering.Has(pipeline.Namespace+"/"+pipeline.Name) {
+			continue
+		}
+		httpParameters := devops.NewHttpParameters(http.MethodGet, nil)
+		httpParameters.Url.RawQuery = fmt.Sprintf("start=0&limit=%d", mirrorLimit)
+		runList, err := c.devopsClient.ListPipelineRuns(pipeline.Namespace, pipeline.Name, httpParameters)
+		if err != nil || runList == nil {
+			klog.V(4).Infof("failed to list the runs of pipeline %s/%s: %v", pipeline.Namespace, pipeline.Name, err)
+			continue
+		}
+		multiBranch := pipeline.Spec.Type == devopsv1alpha3.MultiBranchPipelineType
+		deleted := deletedRuns(pipeline)
+
+		// the runs of a multi-branch pipeline are the runs of all its branches and pull requests
+		branches := map[string][]devops.PipelineRun{}
+		for _, run := range runList.Items {
+			if run.ID == "" {
+				continue
+			}
+			branch := ""
+			if multiBranch {
+				branch = branchName(run.Pipeline)
+			}
+			branches[branch] = append(branches[branch], run)
+		}
+
+		for branch, runs := range branches {
+			sort.Slice(runs, func(i, j int) bool {
+				return newerRunID(runs[i].ID, runs[j].ID)
+			})
+			rank := 0
+			for _, run := range runs {
+				finished := run.State == stateFinished
+				if finished {
+					start := c.now()
+					if startTime := parseTime(run.StartTime); startTime != nil {
+						start = startTime.Time
+					}
+					retained := c.retained(rank, start)
+					rank++
+					if !retained {
+						continue
+					}
+				}
+				if known.Has(runRef(pipeline.Namespace, pipeline.Name, branch, run.ID)) || deleted.Has(deletedRun(branch, run.ID)) {
+					continue
+				}
+				if err := c.createMirror(pipeline, branch, run.ID); err != nil {
+					klog.Errorf("failed to mirror run %s of pipeline %s/%s: %v", run.ID, pipeline.Namespace, pipeline.Name, err)
+				}
+			}
+		}
+
+		if expired := expiredDeletedRuns(deleted, branches); len(expired) > 0 {
+			err := c.updateDeletedRuns(pipeline.Namespace, pipeline.Name, func(deleted sets.String) {
+				deleted.Delete(expired...)
+			})
+			if err != nil {
+				klog.Errorf("failed to forget the deleted runs of pipeline %s/%s: %v", pipeline.Namespace, pipeline.Name, err)
+			}
+		}
+	}
+}
+
+func (c *Controller) createMirror(pipeline *devopsv1alpha3.Pipeline, branch, runID string) error {
+	pipelineRun := &devopsv1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            RunName(pipeline.Name, branch, runID),
+			Namespace:       pipeline.Namespace,
+			Labels:          map[string]string{devopsv1alpha3.PipelineNameLabelKey: pipeline.Name},
+			Annotations:     map[string]string{devopsv1alpha3.PipelineRunIDAnnoKey: runID},
+			OwnerReferences: ownerReferences(pipeline),
+			Finalizers:      []string{devopsv1alpha3.PipelineRunFinalizerName},
+		},
+		Spec: devopsv1alpha3.PipelineRunSpec{
+			Pipeline: pipeline.Name,
+			Branch:   branch,
+		},
+	}
+	_, err := c.ksclient.DevopsV1alpha3().PipelineRuns(pipeline.Namespace).Create(context.Background(),
+		pipelineRun, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// cleanup deletes the finished PipelineRuns out of retention. They are ranked by their run ids in every pipeline
+// or branch as the mirroring does, and the PipelineRuns which failed to be triggered are ranked by themselves.
+func (c *Controller) cleanup(pipelineRuns []*devopsv1alpha3.PipelineRun) {
+	if c.options.MaxRuns == 0 && c.options.MaxAge == 0 {
+		return
+	}
+
+	groups := map[string][]*devopsv1alpha3.PipelineRun{}
+	for _, pipelineRun := range pipelineRuns {
+		if !pipelineRun.IsFinished() || pipelineRun.DeletionTimestamp != nil {
+			continue
+		}
+		runID := pipelineRun.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey]
+		group := runRef(pipelineRun.Namespace, pipelineRun.Spec.Pipeline, pipelineRun.Spec.Branch, "")
+		if runID == "" {
+			group += "untriggered"
+		}
+		groups[group] = append(groups[group], pipelineRun)
+	}
+
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			a, b := group[i], group[j]
+			aID, bID := a.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey], b.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey]
+			if aID != bID {
+				return newerRunID(aID, bID)
+			}
+			if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+				return b.CreationTimestamp.Before(&a.CreationTimestamp)
+			}
+			return a.Name < b.Name
+		})
+		for i, pipelineRun := range group {
+			start := pipelineRun.CreationTimestamp.Time
+			if pipelineRun.Status.StartTime != nil {
+				start = pipelineRun.Status.StartTime.Time
+			}
+			if c.retained(i, start) {
+				continue
+			}
+			// the runs out of retention are never mirrored, so they don't need to be remembered
+			if err := c.removeFinalizer(pipelineRun); err != nil {
+				continue
+			}
+			err := c.ksclient.DevopsV1alpha3().PipelineRuns(pipelineRun.Namespace).Delete(context.Background(),
+				pipelineRun.Name, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				klog.Errorf("failed to delete pipeline run %s/%s: %v", pipelineRun.Namespace, pipelineRun.Name, err)
+			}
+		}
+	}
+}
+
+// retained checks if the finished run of the rank in its pipeline or branch is kept
+func (c *Controller) retained(rank int, start time.Time) bool {
+	if c.options.MaxRuns == 0 && c.options.MaxAge == 0 {
+		return true
+	}
+	if c.options.MaxRuns > 0 && rank < c.options.MaxRuns {
+		return true
+	}
+	return c.options.MaxAge > 0 && c.now().Sub(start) < c.options.MaxAge
+}
+
+// updateDeletedRuns changes the deleted runs recorded in the pipeline, the pipelines not found are ignored
+func (c *Controller) updateDeletedRuns(namespace, name string, mutate func(deleted sets.String)) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pipeline, err := c.ksclient.DevopsV1alpha3().Pipelines(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if pipeline.DeletionTimestamp != nil {
+			return nil
+		}
+		deleted := deletedRuns(pipeline)
+		value := strings.Join(deleted.List(), ",")
+		mutate(deleted)
+		if strings.Join(deleted.List(), ",") == value {
+			return nil
+		}
+
+		pipeline = pipeline.DeepCopy()
+		if pipeline.Annotations == nil {
+			pipeline.Annotations = map[string]string{}
+		}
+		if deleted.Len() == 0 {
+			delete(pipeline.Annotations, devopsv1alpha3.PipelineDeletedRunsAnnoKey)
+		} else {
+			pipeline.Annotations[devopsv1alpha3.PipelineDeletedRunsAnnoKey] = strings.Join(deleted.List(), ",")
+		}
+		_, err = c.ksclient.DevopsV1alpha3().Pipelines(namespace).Update(context.Background(), pipeline, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		klog.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Controller) triggeredRun(key string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.triggered[key]
+}
+
+// setTriggered records the run triggered for the PipelineRun, an empty id forgets it
+func (c *Controller) setTriggered(key, runID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if runID == "" {
+		delete(c.triggered, key)
+	} else {
+		c.triggered[key] = runID
+	}
+}
+
+// RunName returns the name of the PipelineRun mirroring a run, the branches are sanitized to DNS subdomains and
+// suffixed by their hash if they are changed
+func RunName(pipeline, branch, runID string) string {
+	if branch == "" {
+		return fmt.Sprintf("%s-%s", pipeline, runID)
+	}
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, branch)
+	sanitized = strings.Trim(sanitized, "-")
+	if sanitized != branch {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(branch))
+		sanitized = strings.TrimPrefix(fmt.Sprintf("%s-%08x", sanitized, hash.Sum32()), "-")
+	}
+	return fmt.Sprintf("%s-%s-%s", pipeline, sanitized, runID)
+}
+
+// phaseOf translates the state and the result of a run in Jenkins to the phase
+func phaseOf(state, result string) devopsv1alpha3.RunPhase {
+	switch state {
+	case stateQueued:
+		return devopsv1alpha3.RunPending
+	case stateFinished:
+		switch result {
+		case resultSuccess:
+			return devopsv1alpha3.RunSucceeded
+		case resultAborted:
+			return devopsv1alpha3.RunCancelled
+		}
+		return devopsv1alpha3.RunFailed
+	}
+	return devopsv1alpha3.RunRunning
+}
+
+func stageOf(id, name, state, result, startTime string, durationInMillis int) devopsv1alpha3.StageStatus {
+	return devopsv1alpha3.StageStatus{
+		ID:               id,
+		Name:             name,
+		State:            state,
+		Result:           result,
+		StartTime:        parseTime(startTime),
+		DurationInMillis: int64(durationInMillis),
+	}
+}
+
+// parseTime parses the time of Blue Ocean, it is truncated to seconds as metav1.Time is serialized
+func parseTime(value string) *metav1.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(jenkins.JenkinsBlueTimeLayout, value)
+	if err != nil {
+		return nil
+	}
+	result := metav1.NewTime(t.Truncate(time.Second).UTC())
+	return &result
+}
+
+// newerRunID compares the ids of two runs numerically, the empty ids are the oldest
+func newerRunID(a, b string) bool {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	if aErr == nil && bErr == nil {
+		return aNum > bNum
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+// deletedRun returns the entry of the run in the deleted runs of its pipeline
+func deletedRun(branch, runID string) string {
+	if branch == "" {
+		return runID
+	}
+	return url.PathEscape(branch) + "/" + runID
+}
+
+func deletedRuns(pipeline *devopsv1alpha3.Pipeline) sets.String {
+	deleted := sets.NewString()
+	for _, run := range strings.Split(pipeline.Annotations[devopsv1alpha3.PipelineDeletedRunsAnnoKey], ",") {
+		if run != "" {
+			deleted.Insert(run)
+		}
+	}
+	return deleted
+}
+
+// expiredDeletedRuns returns the deleted runs which are not listed but older than a listed run of their branch,
+// they are out of the runs checked by the mirroring, or removed from Jenkins, so they never come back
+func expiredDeletedRuns(deleted sets.String, branches map[string][]devops.PipelineRun) []string {
+	var expired []string
+	for _, entry := range deleted.List() {
+		branch, runID := "", entry
+		if i := strings.LastIndex(entry, "/"); i >= 0 {
+			branch, runID = branchName(entry[:i]), entry[i+1:]
+		}
+		listed, newer := false, false
+		for _, run := range branches[branch] {
+			listed = listed || run.ID == runID
+			newer = newer || newerRunID(run.ID, runID)
+		}
+		if !listed && newer {
+			expired = append(expired, entry)
+		}
+	}
+	return expired
+}
+
+func runRef(namespace, pipeline, branch, runID string) string {
+	return strings.Join([]string{namespace, pipeline, branch, runID}, "/")
+}
+
+func hasFinalizer(pipelineRun *devopsv1alpha3.PipelineRun) bool {
+	for _, finalizer := range pipelineRun.Finalizers {
+		if finalizer == devopsv1alpha3.PipelineRunFinalizerName {
+			return true
+		}
+	}
+	return false
+}
+
+func ownerReferences(pipeline *devopsv1alpha3.Pipeline) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		*metav1.NewControllerRef(pipeline, devopsv1alpha3.GroupVersion.WithKind(devopsv1alpha3.ResourceKindPipeline)),
+	}
+}
+
+// jobPath returns the path of the job of the branch in Blue Ocean, the names of the jobs are the escaped branches
+// which are escaped again in the paths
+func jobPath(branch string) string {
+	return url.PathEscape(url.PathEscape(branch))
+}
+
+// branchName returns the branch of the job of a multi-branch pipeline
+func branchName(job string) string {
+	if name, err := url.PathUnescape(job); err == nil {
+		return name
+	}
+	return job
+}
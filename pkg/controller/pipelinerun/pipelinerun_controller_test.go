@@ -0,0 +1,541 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+var testNow = time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeJenkins keeps the runs of the pipelines, they are keyed by pipeline/job/id where the job of the
+// regular pipelines is empty
+type fakeJenkins struct {
+	devops.Interface
+
+	runs    map[string]*devops.PipelineRun
+	nodes   map[string][]devops.PipelineRunNodes
+	bodies  []string
+	stopped []string
+	// err is returned by the runs and the stops
+	err error
+}
+
+func runKey(pipeline, job, runID string) string {
+	return fmt.Sprintf("%s/%s/%s", pipeline, job, runID)
+}
+
+func (j *fakeJenkins) run(pipeline, job string, httpParameters *devops.HttpParameters) (*devops.RunPipeline, error) {
+	if j.err != nil {
+		return nil, j.err
+	}
+	body, _ := ioutil.ReadAll(httpParameters.Body)
+	j.bodies = append(j.bodies, string(body))
+	runID := strconv.Itoa(len(j.bodies))
+	j.runs[runKey(pipeline, job, runID)] = &devops.PipelineRun{ID: runID, State: stateQueued, Result: "UNKNOWN"}
+	return &devops.RunPipeline{ID: runID}, nil
+}
+
+func (j *fakeJenkins) get(pipeline, job, runID string) (*devops.PipelineRun, error) {
+	run, ok := j.runs[runKey(pipeline, job, runID)]
+	if !ok {
+		return nil, errors.New("404")
+	}
+	return run, nil
+}
+
+func (j *fakeJenkins) stop(pipeline, job, runID string) (*devops.StopPipeline, error) {
+	if j.err != nil {
+		return nil, j.err
+	}
+	j.stopped = append(j.stopped, runKey(pipeline, job, runID))
+	return &devops.StopPipeline{}, nil
+}
+
+func (j *fakeJenkins) RunPipeline(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.RunPipeline, error) {
+	return j.run(pipelineName, "", httpParameters)
+}
+
+func (j *fakeJenkins) RunBranchPipeline(projectName, pipelineName, branchName string, httpParameters *devops.HttpParameters) (*devops.RunPipeline, error) {
+	return j.run(pipelineName, branchName, httpParameters)
+}
+
+func (j *fakeJenkins) GetPipelineRun(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (*devops.PipelineRun, error) {
+	return j.get(pipelineName, "", runId)
+}
+
+func (j *fakeJenkins) GetBranchPipelineRun(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (*devops.PipelineRun, error) {
+	return j.get(pipelineName, branchName, runId)
+}
+
+func (j *fakeJenkins) GetPipelineRunNodes(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) ([]devops.PipelineRunNodes, error) {
+	return j.nodes[runKey(pipelineName, "", runId)], nil
+}
+
+func (j *fakeJenkins) GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) ([]devops.BranchPipelineRunNodes, error) {
+	return nil, nil
+}
+
+func (j *fakeJenkins) StopPipeline(projectName, pipelineName, runId string, httpParameters *devops.HttpParameters) (*devops.StopPipeline, error) {
+	return j.stop(pipelineName, "", runId)
+}
+
+func (j *fakeJenkins) StopBranchPipeline(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) (*devops.StopPipeline, error) {
+	return j.stop(pipelineName, branchName, runId)
+}
+
+func (j *fakeJenkins) ListPipelineRuns(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.PipelineRunList, error) {
+	list := &devops.PipelineRunList{}
+	for key, run := range j.runs {
+		if len(key) > len(pipelineName) && key[:len(pipelineName)+1] == pipelineName+"/" {
+			list.Items = append(list.Items, *run)
+		}
+	}
+	list.Total = len(list.Items)
+	return list, nil
+}
+
+func newPipeline(name string, multiBranch bool) *devopsv1alpha3.Pipeline {
+	pipeline := &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "foo", UID: types.UID("uid-" + name)},
+		Spec:       devopsv1alpha3.PipelineSpec{Type: devopsv1alpha3.NoScmPipelineType},
+	}
+	if multiBranch {
+		pipeline.Spec.Type = devopsv1alpha3.MultiBranchPipelineType
+	}
+	return pipeline
+}
+
+func newPipelineRun(name, pipeline, branch string) *devopsv1alpha3.PipelineRun {
+	return &devopsv1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "foo", CreationTimestamp: metav1.NewTime(testNow)},
+		Spec:       devopsv1alpha3.PipelineRunSpec{Pipeline: pipeline, Branch: branch},
+	}
+}
+
+type testController struct {
+	*Controller
+	client    *fake.Clientset
+	jenkins   *fakeJenkins
+	indexer   cache.Indexer
+	pipelines cache.Indexer
+}
+
+func newTestController(options *Options, pipelines []*devopsv1alpha3.Pipeline, pipelineRuns ...*devopsv1alpha3.PipelineRun) *testController {
+	var objects []runtime.Object
+	for _, pipeline := range pipelines {
+		objects = append(objects, pipeline)
+	}
+	for _, pipelineRun := range pipelineRuns {
+		objects = append(objects, pipelineRun)
+	}
+	client := fake.NewSimpleClientset(objects...)
+	informerFactory := ksinformers.NewSharedInformerFactory(client, 0)
+	pipelineInformer := informerFactory.Devops().V1alpha3().Pipelines()
+	for _, pipeline := range pipelines {
+		pipelineInformer.Informer().GetIndexer().Add(pipeline)
+	}
+	pipelineRunInformer := informerFactory.Devops().V1alpha3().PipelineRuns()
+	for _, pipelineRun := range pipelineRuns {
+		pipelineRunInformer.Informer().GetIndexer().Add(pipelineRun)
+	}
+
+	jenkins := &fakeJenkins{
+		Interface: fakedevops.New("foo"),
+		runs:      map[string]*devops.PipelineRun{},
+		nodes:     map[string][]devops.PipelineRunNodes{},
+	}
+	c := NewController(jenkins, client, pipelineInformer, pipelineRunInformer, options)
+	c.now = func() time.Time {
+		return testNow
+	}
+	return &testController{Controller: c, client: client, jenkins: jenkins, indexer: pipelineRunInformer.Informer().GetIndexer(),
+		pipelines: pipelineInformer.Informer().GetIndexer()}
+}
+
+// sync refreshes the cache from the client and syncs the PipelineRun
+func (c *testController) sync(t *testing.T, name string) *devopsv1alpha3.PipelineRun {
+	if pipelineRun := c.get(name); pipelineRun != nil {
+		if err := c.indexer.Update(pipelineRun); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.syncHandler("foo/" + name); err != nil {
+		t.Fatal(err)
+	}
+	return c.get(name)
+}
+
+func (c *testController) get(name string) *devopsv1alpha3.PipelineRun {
+	pipelineRun, err := c.client.DevopsV1alpha3().PipelineRuns("foo").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	return pipelineRun
+}
+
+func TestSyncHandler(t *testing.T) {
+	pipelineRun := newPipelineRun("build", "p1", "")
+	pipelineRun.Spec.Parameters = []devopsv1alpha3.ParameterValue{{Name: "version", Value: "1.0"}}
+	c := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("p1", false)}, pipelineRun)
+
+	pipelineRun = c.sync(t, "build")
+	if len(c.jenkins.bodies) != 1 || c.jenkins.bodies[0] != `{"parameters":[{"name":"version","value":"1.0"}]}` {
+		t.Fatalf("unexpected runs %v", c.jenkins.bodies)
+	}
+	if pipelineRun.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey] != "1" || !hasFinalizer(pipelineRun) ||
+		pipelineRun.Annotations[devopsv1alpha3.PipelineRunTriggeringAnnoKey] != "" ||
+		pipelineRun.Labels[devopsv1alpha3.PipelineNameLabelKey] != "p1" ||
+		len(pipelineRun.OwnerReferences) != 1 || pipelineRun.OwnerReferences[0].UID != "uid-p1" {
+		t.Fatalf("expected the PipelineRun is bound to run 1 and pipeline p1, got %+v", pipelineRun.ObjectMeta)
+	}
+
+	pipelineRun = c.sync(t, "build")
+	if len(c.jenkins.bodies) != 1 {
+		t.Fatalf("expected the PipelineRun is triggered only once")
+	}
+	if pipelineRun.Status.Phase != devopsv1alpha3.RunPending || pipelineRun.Status.UpdateTime == nil {
+		t.Fatalf("unexpected status %+v", pipelineRun.Status)
+	}
+
+	c.jenkins.runs[runKey("p1", "", "1")] = &devops.PipelineRun{ID: "1", State: "RUNNING", Result: "UNKNOWN",
+		StartTime: "2020-09-30T23:50:00.123+0800"}
+	c.jenkins.nodes[runKey("p1", "", "1")] = []devops.PipelineRunNodes{
+		{ID: "6", DisplayName: "build", State: "FINISHED", Result: "SUCCESS", DurationInMillis: 1500},
+		{ID: "15", DisplayName: "test", State: "RUNNING", Result: "UNKNOWN"},
+	}
+	pipelineRun = c.sync(t, "build")
+	status := pipelineRun.Status
+	if status.Phase != devopsv1alpha3.RunRunning || len(status.Stages) != 2 || status.Stages[1].Name != "test" ||
+		status.Stages[0].DurationInMillis != 1500 || status.Result != "" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if !status.StartTime.Time.Equal(time.Date(2020, 9, 30, 15, 50, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start time %v", status.StartTime)
+	}
+
+	c.jenkins.runs[runKey("p1", "", "1")] = &devops.PipelineRun{ID: "1", State: stateFinished, Result: "UNSTABLE",
+		StartTime: "2020-09-30T23:50:00.123+0800", EndTime: "2020-09-30T23:55:00.456+0800"}
+	pipelineRun = c.sync(t, "build")
+	if pipelineRun.Status.Phase != devopsv1alpha3.RunFailed || pipelineRun.Status.Result != "UNSTABLE" ||
+		pipelineRun.Status.CompletionTime == nil {
+		t.Fatalf("unexpected status %+v", pipelineRun.Status)
+	}
+
+	pipelineRun = c.sync(t, "build")
+	if !hasFinalizer(pipelineRun) {
+		t.Errorf("expected the finalizer is kept to record the run once the PipelineRun is deleted")
+	}
+}
+
+func TestSyncHandlerRecordFailure(t *testing.T) {
+	c := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("p1", false)}, newPipelineRun("build", "p1", ""))
+	c.client.PrependReactor("update", "pipelineruns", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pipelineRun := action.(k8stesting.UpdateAction).GetObject().(*devopsv1alpha3.PipelineRun)
+		if pipelineRun.Annotations[devopsv1alpha3.PipelineRunIDAnnoKey] != "" {
+			return true, nil, errors.New("conflict")
+		}
+		return false, nil, nil
+	})
+	if err := c.syncHandler("foo/build"); err == nil {
+		t.Fatalf("expected recording the run fails")
+	}
+	if len(c.jenkins.bodies) != 1 {
+		t.Fatalf("unexpected runs %v", c.jenkins.bodies)
+	}
+
+	// the controller restarts before the run is recorded
+	pipelineRun := c.get("build")
+	if pipelineRun.Annotations[devopsv1alpha3.PipelineRunTriggeringAnnoKey] == "" || !hasFinalizer(pipelineRun) {
+		t.Fatalf("expected the trigger is persisted before the run, got %+v", pipelineRun.ObjectMeta)
+	}
+	restarted := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("p1", false)}, pipelineRun)
+	if pipelineRun = restarted.sync(t, "build"); pipelineRun.Status.Phase != "" {
+		t.Errorf("expected the PipelineRun being triggered is waited for, got %+v", pipelineRun.Status)
+	}
+	restarted.now = func() time.Time {
+		return testNow.Add(triggerTimeout)
+	}
+	pipelineRun = restarted.sync(t, "build")
+	if len(restarted.jenkins.bodies) != 0 {
+		t.Errorf("expected the PipelineRun is not triggered twice, got %v", restarted.jenkins.bodies)
+	}
+	if pipelineRun.Status.Phase != devopsv1alpha3.RunUnknown {
+		t.Errorf("unexpected status %+v", pipelineRun.Status)
+	}
+}
+
+func TestSyncHandlerFailures(t *testing.T) {
+	c := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("p1", false), newPipeline("mb", true)},
+		newPipelineRun("missing", "p2", ""),
+		newPipelineRun("no-branch", "mb", ""),
+		newPipelineRun("branch", "p1", "master"),
+		newPipelineRun("rejected", "p1", ""),
+		newPipelineRun("unavailable", "p1", ""),
+	)
+
+	for name, message := range map[string]string{
+		"missing":   "pipeline p2 not found",
+		"no-branch": "branch is required by the multi-branch pipeline",
+		"branch":    "only the multi-branch pipelines have branches",
+	} {
+		pipelineRun := c.sync(t, name)
+		if pipelineRun.Status.Phase != devopsv1alpha3.RunFailed || pipelineRun.Status.Message != message {
+			t.Errorf("unexpected status of %s: %+v", name, pipelineRun.Status)
+		}
+	}
+
+	c.jenkins.err = errors.New("400")
+	if pipelineRun := c.sync(t, "rejected"); pipelineRun.Status.Phase != devopsv1alpha3.RunFailed {
+		t.Errorf("expected the PipelineRun rejected by jenkins fails, got %+v", pipelineRun.Status)
+	}
+
+	c.jenkins.err = errors.New("503")
+	if err := c.syncHandler("foo/unavailable"); err == nil {
+		t.Errorf("expected the PipelineRun is retried if jenkins is unavailable")
+	}
+	if pipelineRun := c.get("unavailable"); pipelineRun.Status.Phase != "" ||
+		pipelineRun.Annotations[devopsv1alpha3.PipelineRunTriggeringAnnoKey] != "" {
+		t.Errorf("expected the PipelineRun refused by jenkins is triggered again, got %+v", pipelineRun)
+	}
+
+	c.jenkins.err = &url.Error{Op: "Post", URL: "http://jenkins", Err: errors.New("i/o timeout")}
+	if err := c.syncHandler("foo/unavailable"); err == nil {
+		t.Errorf("expected the PipelineRun is retried if jenkins doesn't respond")
+	}
+	if pipelineRun := c.get("unavailable"); pipelineRun.Annotations[devopsv1alpha3.PipelineRunTriggeringAnnoKey] == "" {
+		t.Errorf("expected the PipelineRun which might have been triggered is not triggered again, got %+v", pipelineRun)
+	}
+	if len(c.jenkins.bodies) != 0 {
+		t.Errorf("unexpected runs %v", c.jenkins.bodies)
+	}
+}
+
+func TestSyncHandlerNotFound(t *testing.T) {
+	pipelineRun := newPipelineRun("build", "p1", "")
+	pipelineRun.Annotations = map[string]string{devopsv1alpha3.PipelineRunIDAnnoKey: "3"}
+	pipelineRun.Finalizers = []string{devopsv1alpha3.PipelineRunFinalizerName}
+	c := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("p1", false)}, pipelineRun)
+
+	pipelineRun = c.sync(t, "build")
+	if pipelineRun.Status.Phase != devopsv1alpha3.RunUnknown || pipelineRun.Status.Message != "run 3 is not found in jenkins" {
+		t.Errorf("unexpected status %+v", pipelineRun.Status)
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	deletionTime := metav1.NewTime(testNow)
+	newDeleted := func(name, branch string) *devopsv1alpha3.PipelineRun {
+		pipelineRun := newPipelineRun(name, "mb", branch)
+		pipelineRun.Annotations = map[string]string{devopsv1alpha3.PipelineRunIDAnnoKey: "2"}
+		pipelineRun.Finalizers = []string{devopsv1alpha3.PipelineRunFinalizerName}
+		pipelineRun.DeletionTimestamp = &deletionTime
+		return pipelineRun
+	}
+	c := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("mb", true)},
+		newDeleted("feature", "feature/login"), newDeleted("unavailable", "master"))
+
+	if err := c.syncHandler("foo/feature"); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.jenkins.stopped) != 1 || c.jenkins.stopped[0] != "mb/feature%252Flogin/2" {
+		t.Errorf("unexpected stopped runs %v", c.jenkins.stopped)
+	}
+	if pipelineRun := c.get("feature"); pipelineRun != nil && hasFinalizer(pipelineRun) {
+		t.Errorf("expected the finalizer is removed after the run is stopped")
+	}
+
+	c.jenkins.err = errors.New("503")
+	if err := c.syncHandler("foo/unavailable"); err == nil {
+		t.Errorf("expected the finalizer is kept until the run is stopped")
+	}
+	c.jenkins.err = errors.New("404")
+	if err := c.syncHandler("foo/unavailable"); err != nil {
+		t.Errorf("expected the runs not found are not stopped, got %v", err)
+	}
+}
+
+func TestMirrorAndCleanup(t *testing.T) {
+	existing := newPipelineRun(RunName("mb", "master", "3"), "mb", "master")
+	existing.Annotations = map[string]string{devopsv1alpha3.PipelineRunIDAnnoKey: "3"}
+	existing.Status.Phase = devopsv1alpha3.RunSucceeded
+	var old []*devopsv1alpha3.PipelineRun
+	for i := 1; i <= 3; i++ {
+		pipelineRun := newPipelineRun(RunName("p1", "", strconv.Itoa(i)), "p1", "")
+		pipelineRun.Annotations = map[string]string{devopsv1alpha3.PipelineRunIDAnnoKey: strconv.Itoa(i)}
+		pipelineRun.Status.Phase = devopsv1alpha3.RunSucceeded
+		old = append(old, pipelineRun)
+	}
+	old[0].Status.Phase = devopsv1alpha3.RunRunning
+
+	options := NewOptions()
+	options.MaxRuns = 1
+	c := newTestController(options, []*devopsv1alpha3.Pipeline{newPipeline("mb", true), newPipeline("p1", false)},
+		append(old, existing)...)
+	for _, run := range []devops.PipelineRun{
+		{ID: "1", Pipeline: "master", State: stateFinished, Result: resultSuccess},
+		{ID: "2", Pipeline: "master", State: stateFinished, Result: resultSuccess},
+		{ID: "3", Pipeline: "master", State: stateFinished, Result: resultSuccess},
+		{ID: "4", Pipeline: "master", State: "RUNNING"},
+		{ID: "1", Pipeline: "feature%2Flogin", State: stateFinished, Result: resultSuccess},
+	} {
+		run := run
+		c.jenkins.runs[runKey("mb", run.Pipeline, run.ID)] = &run
+	}
+
+	c.resync()
+	c.mirrorRuns()
+
+	expected := map[string]bool{
+		"mb-master-3": true,
+		"mb-master-4": true,
+		// the run failing the retention is not mirrored
+		"mb-master-2":                       false,
+		RunName("mb", "feature/login", "1"): true,
+		// the running PipelineRun is kept, the older finished one is deleted
+		"p1-1": true,
+		"p1-2": false,
+		"p1-3": true,
+	}
+	for name, exists := range expected {
+		if pipelineRun := c.get(name); (pipelineRun != nil) != exists {
+			t.Errorf("expected PipelineRun %s exists: %v", name, exists)
+		}
+	}
+
+	mirrored := c.get("mb-master-4")
+	if mirrored.Spec.Branch != "master" || !hasFinalizer(mirrored) || mirrored.Labels[devopsv1alpha3.PipelineNameLabelKey] != "mb" {
+		t.Errorf("unexpected mirrored PipelineRun %+v", mirrored)
+	}
+	if feature := c.get(RunName("mb", "feature/login", "1")); feature == nil || feature.Spec.Branch != "feature/login" || !hasFinalizer(feature) {
+		t.Errorf("unexpected mirrored PipelineRun %+v", feature)
+	}
+}
+
+func TestMirrorSkipsTriggeringPipelines(t *testing.T) {
+	triggering := newPipelineRun("build", "p1", "")
+	triggering.Annotations = map[string]string{devopsv1alpha3.PipelineRunTriggeringAnnoKey: testNow.Format(time.RFC3339)}
+	c := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("p1", false)}, triggering)
+	c.jenkins.runs[runKey("p1", "", "1")] = &devops.PipelineRun{ID: "1", State: "RUNNING"}
+
+	c.mirrorRuns()
+	if c.get("p1-1") != nil {
+		t.Errorf("expected the run which might be triggered by PipelineRun build is not mirrored")
+	}
+}
+
+func TestDeleteAndResync(t *testing.T) {
+	c := newTestController(nil, []*devopsv1alpha3.Pipeline{newPipeline("p1", false), newPipeline("mb", true)})
+	c.jenkins.runs[runKey("p1", "", "1")] = &devops.PipelineRun{ID: "1", State: "RUNNING"}
+	c.jenkins.runs[runKey("mb", "feature%252Flogin", "1")] = &devops.PipelineRun{ID: "1", Pipeline: "feature%2Flogin",
+		State: stateFinished, Result: resultSuccess}
+	c.resync()
+	c.mirrorRuns()
+
+	deletionTime := metav1.NewTime(testNow)
+	for _, name := range []string{"p1-1", RunName("mb", "feature/login", "1")} {
+		if c.get(name) == nil {
+			t.Fatalf("expected run %s is mirrored", name)
+		}
+		pipelineRun := c.sync(t, name)
+		pipelineRun.DeletionTimestamp = &deletionTime
+		if err := c.indexer.Add(pipelineRun); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.syncHandler("foo/" + name); err != nil {
+			t.Fatal(err)
+		}
+		if pipelineRun = c.get(name); hasFinalizer(pipelineRun) {
+			t.Fatalf("expected the finalizer of %s is removed", name)
+		}
+		if err := c.client.DevopsV1alpha3().PipelineRuns("foo").Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.indexer.Delete(pipelineRun); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.jenkins.stopped) != 1 || c.jenkins.stopped[0] != "p1//1" {
+		t.Errorf("expected only the unfinished run is stopped, got %v", c.jenkins.stopped)
+	}
+
+	refresh := func() map[string]string {
+		deleted := map[string]string{}
+		for _, name := range []string{"p1", "mb"} {
+			pipeline, err := c.client.DevopsV1alpha3().Pipelines("foo").Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = c.pipelines.Update(pipeline); err != nil {
+				t.Fatal(err)
+			}
+			deleted[name] = pipeline.Annotations[devopsv1alpha3.PipelineDeletedRunsAnnoKey]
+		}
+		return deleted
+	}
+	if deleted := refresh(); deleted["p1"] != "1" || deleted["mb"] != "feature%2Flogin/1" {
+		t.Fatalf("unexpected deleted runs %v", deleted)
+	}
+
+	c.resync()
+	c.mirrorRuns()
+	for _, name := range []string{"p1-1", RunName("mb", "feature/login", "1")} {
+		if c.get(name) != nil {
+			t.Errorf("expected the deleted PipelineRun %s is not mirrored again", name)
+		}
+	}
+
+	// the deleted run is forgotten once it is removed from jenkins
+	delete(c.jenkins.runs, runKey("p1", "", "1"))
+	c.jenkins.runs[runKey("p1", "", "2")] = &devops.PipelineRun{ID: "2", State: "RUNNING"}
+	c.resync()
+	c.mirrorRuns()
+	if deleted := refresh(); deleted["p1"] != "" || deleted["mb"] != "feature%2Flogin/1" {
+		t.Errorf("unexpected deleted runs %v", deleted)
+	}
+}
+
+func TestRunName(t *testing.T) {
+	if name := RunName("p1", "", "3"); name != "p1-3" {
+		t.Errorf("unexpected name %s", name)
+	}
+	if name := RunName("mb", "PR-1", "3"); name == "mb-pr-1-3" || name == RunName("mb", "pr-1", "3") {
+		t.Errorf("expected the changed branches are suffixed by their hash, got %s", name)
+	}
+	if name := RunName("mb", "master", "3"); name != "mb-master-3" {
+		t.Errorf("unexpected name %s", name)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibinary
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	devopsinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/devops/v1alpha1"
+	devopslisters "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/client/storage"
+	"devops.kubesphere.io/plugin/pkg/models/devops"
+	"devops.kubesphere.io/plugin/pkg/utils/sliceutil"
+)
+
+const maxRetries = 15
+
+// Controller deletes the stored objects of the S2iBinaries being deleted, then removes their finalizers
+type Controller struct {
+	client  kubesphere.Interface
+	storage storage.Interface
+
+	s2iBinaryLister devopslisters.S2iBinaryLister
+	s2iBinarySynced cache.InformerSynced
+
+	workqueue workqueue.RateLimitingInterface
+}
+
+func NewController(client kubesphere.Interface, objectStorage storage.Interface,
+	s2iBinaryInformer devopsinformers.S2iBinaryInformer) *Controller {
+	c := &Controller{
+		client:          client,
+		storage:         objectStorage,
+		s2iBinaryLister: s2iBinaryInformer.Lister(),
+		s2iBinarySynced: s2iBinaryInformer.Informer().HasSynced,
+		workqueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "s2ibinary"),
+	}
+
+	s2iBinaryInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueS2iBinary,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueS2iBinary(newObj)
+		},
+	})
+	return c
+}
+
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Info("starting s2ibinary controller")
+	defer klog.Info("shutting down s2ibinary controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.s2iBinarySynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	return nil
+}
+
+// enqueueS2iBinary enqueues the S2iBinary if it is being deleted and the objects have not been deleted
+func (c *Controller) enqueueS2iBinary(obj interface{}) {
+	s2iBinary, ok := obj.(*devopsv1alpha1.S2iBinary)
+	if !ok || !isDeleting(s2iBinary) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
+
+func (c *Controller) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	key, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		return true
+	}
+
+	if err := c.syncHandler(key); err != nil {
+		if c.workqueue.NumRequeues(obj) < maxRetries {
+			klog.Warningf("failed to clean up s2ibinary %s, retrying: %v", key, err)
+			c.workqueue.AddRateLimited(obj)
+			return true
+		}
+		klog.Errorf("dropping s2ibinary %s out of the queue: %v", key, err)
+	}
+	c.workqueue.Forget(obj)
+	return true
+}
+
+func (c *Controller) syncHandler(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Error(err)
+		return nil
+	}
+
+	s2iBinary, err := c.s2iBinaryLister.S2iBinaries(namespace).Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !isDeleting(s2iBinary) {
+		return nil
+	}
+
+	objects, err := c.storage.List(devops.S2iBinaryObjectPrefix(namespace, name))
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	if err = c.storage.Delete(keys...); err != nil {
+		return err
+	}
+
+	s2iBinary = s2iBinary.DeepCopy()
+	s2iBinary.Finalizers = sliceutil.RemoveString(s2iBinary.Finalizers, func(item string) bool {
+		return item == devopsv1alpha1.S2iBinaryFinalizerName
+	})
+	_, err = c.client.DevopsV1alpha1().S2iBinaries(namespace).Update(context.Background(), s2iBinary, metav1.UpdateOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func isDeleting(s2iBinary *devopsv1alpha1.S2iBinary) bool {
+	return s2iBinary.DeletionTimestamp != nil && sliceutil.HasString(s2iBinary.Finalizers, devopsv1alpha1.S2iBinaryFinalizerName)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibinary
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	fakestorage "devops.kubesphere.io/plugin/pkg/client/storage/fake"
+	"devops.kubesphere.io/plugin/pkg/models/devops"
+)
+
+func TestSyncHandler(t *testing.T) {
+	now := metav1.Now()
+	deleting := &devopsv1alpha1.S2iBinary{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "deleting",
+			Namespace:         "bar",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{devopsv1alpha1.S2iBinaryFinalizerName, "other"},
+		},
+	}
+	alive := &devopsv1alpha1.S2iBinary{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "alive",
+			Namespace:  "bar",
+			Finalizers: []string{devopsv1alpha1.S2iBinaryFinalizerName},
+		},
+	}
+
+	client := fake.NewSimpleClientset(deleting, alive)
+	informerFactory := ksinformers.NewSharedInformerFactory(client, 0)
+	indexer := informerFactory.Devops().V1alpha1().S2iBinaries().Informer().GetIndexer()
+	indexer.Add(deleting)
+	indexer.Add(alive)
+
+	objectStorage := fakestorage.NewFakeStorage()
+	objectStorage.Objects[devops.S2iBinaryObjectPrefix("bar", "deleting")+"binary"] = []byte("deleting")
+	objectStorage.Objects[devops.S2iBinaryObjectPrefix("bar", "deleting")+"chunks/00000000000000000000"] = []byte("chunk")
+	objectStorage.Objects[devops.S2iBinaryObjectPrefix("bar", "alive")+"binary"] = []byte("alive")
+
+	c := NewController(client, objectStorage, informerFactory.Devops().V1alpha1().S2iBinaries())
+	for _, key := range []string{"bar/deleting", "bar/alive", "bar/missing"} {
+		if err := c.syncHandler(key); err != nil {
+			t.Fatalf("failed to sync %s: %v", key, err)
+		}
+	}
+
+	if objects, _ := objectStorage.List(devops.S2iBinaryObjectPrefix("bar", "deleting")); len(objects) != 0 {
+		t.Errorf("expected the objects of deleting S2iBinary are deleted, got %v", objects)
+	}
+	if objects, _ := objectStorage.List(devops.S2iBinaryObjectPrefix("bar", "alive")); len(objects) != 1 {
+		t.Errorf("expected the objects of alive S2iBinary are kept, got %v", objects)
+	}
+
+	updated, err := client.DevopsV1alpha1().S2iBinaries("bar").Get(context.Background(), "deleting", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Finalizers) != 1 || updated.Finalizers[0] != "other" {
+		t.Errorf("expected only the finalizer of S2iBinary is removed, got %v", updated.Finalizers)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultRetentionInterval is the interval of the full cleanup, the age of runs is checked in it
+const DefaultRetentionInterval = 10 * time.Minute
+
+// RetentionOptions is the global retention policy of S2iRuns, it can be overridden by the annotations
+// of namespaces. The finished runs are deleted unless they are among the newest MaxRuns runs of their
+// builder or younger than MaxAge, a zero value disables the criterion.
+type RetentionOptions struct {
+	// MaxRuns is the number of the newest runs kept for every builder
+	MaxRuns int `json:"maxRuns,omitempty" yaml:"maxRuns,omitempty" mapstructure:"maxRuns"`
+	// MaxAge is the duration which the runs are kept for after they are created
+	MaxAge time.Duration `json:"maxAge,omitempty" yaml:"maxAge,omitempty" mapstructure:"maxAge"`
+	// Interval is the interval of the full cleanup
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty" mapstructure:"interval"`
+}
+
+func NewRetentionOptions() *RetentionOptions {
+	return &RetentionOptions{
+		Interval: DefaultRetentionInterval,
+	}
+}
+
+// Validate check options
+func (o *RetentionOptions) Validate() []error {
+	errors := make([]error, 0)
+	if o == nil {
+		return errors
+	}
+
+	if o.MaxRuns < 0 {
+		errors = append(errors, fmt.Errorf("maxRuns of s2irun retention must not be negative"))
+	}
+	if o.MaxAge < 0 {
+		errors = append(errors, fmt.Errorf("maxAge of s2irun retention must not be negative"))
+	}
+	if o.Interval < 0 {
+		errors = append(errors, fmt.Errorf("interval of s2irun retention must not be negative"))
+	}
+	return errors
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1informer "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	devopsinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/devops/v1alpha1"
+	devopslisters "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+const maxRetries = 15
+
+// retentionPolicy is the effective policy of a namespace
+type retentionPolicy struct {
+	maxRuns int
+	maxAge  time.Duration
+}
+
+func (p retentionPolicy) enabled() bool {
+	return p.maxRuns > 0 || p.maxAge > 0
+}
+
+// RetentionController deletes the finished S2iRuns and their Jobs out of the retention policy. The latest
+// run and the run which built the current image of a builder are always kept.
+type RetentionController struct {
+	k8sclient kubernetes.Interface
+	ksclient  kubesphere.Interface
+	options   *RetentionOptions
+
+	namespaceLister corev1lister.NamespaceLister
+	namespaceSynced cache.InformerSynced
+
+	s2iBuilderLister devopslisters.S2iBuilderLister
+	s2iBuilderSynced cache.InformerSynced
+
+	s2iRunLister devopslisters.S2iRunLister
+	s2iRunSynced cache.InformerSynced
+
+	workqueue workqueue.RateLimitingInterface
+	now       func() time.Time
+}
+
+func NewRetentionController(k8sclient kubernetes.Interface, ksclient kubesphere.Interface, options *RetentionOptions,
+	namespaceInformer corev1informer.NamespaceInformer,
+	s2iBuilderInformer devopsinformers.S2iBuilderInformer,
+	s2iRunInformer devopsinformers.S2iRunInformer) *RetentionController {
+
+	if options == nil {
+		options = NewRetentionOptions()
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultRetentionInterval
+	}
+
+	c := &RetentionController{
+		k8sclient:        k8sclient,
+		ksclient:         ksclient,
+		options:          options,
+		namespaceLister:  namespaceInformer.Lister(),
+		namespaceSynced:  namespaceInformer.Informer().HasSynced,
+		s2iBuilderLister: s2iBuilderInformer.Lister(),
+		s2iBuilderSynced: s2iBuilderInformer.Informer().HasSynced,
+		s2iRunLister:     s2iRunInformer.Lister(),
+		s2iRunSynced:     s2iRunInformer.Informer().HasSynced,
+		workqueue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "s2irun-retention"),
+		now:              time.Now,
+	}
+
+	s2iRunInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueS2iRun,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueS2iRun(newObj)
+		},
+	})
+	namespaceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNamespace, newNamespace := oldObj.(*corev1.Namespace), newObj.(*corev1.Namespace)
+			if oldNamespace.Annotations[constants.S2iRunMaxRunsAnnotationKey] != newNamespace.Annotations[constants.S2iRunMaxRunsAnnotationKey] ||
+				oldNamespace.Annotations[constants.S2iRunMaxAgeAnnotationKey] != newNamespace.Annotations[constants.S2iRunMaxAgeAnnotationKey] {
+				c.workqueue.Add(newNamespace.Name)
+			}
+		},
+	})
+	return c
+}
+
+func (c *RetentionController) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Info("starting s2irun retention controller")
+	defer klog.Info("shutting down s2irun retention controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.namespaceSynced, c.s2iBuilderSynced, c.s2iRunSynced) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	// the runs are aged out without any events, so all of them are checked periodically
+	go wait.Until(c.resync, c.options.Interval, stopCh)
+
+	<-stopCh
+	return nil
+}
+
+// resync enqueues all the namespaces which have S2iRuns
+func (c *RetentionController) resync() {
+	s2iRuns, err := c.s2iRunLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	namespaces := sets.NewString()
+	for _, s2iRun := range s2iRuns {
+		namespaces.Insert(s2iRun.Namespace)
+	}
+	for _, namespace := range namespaces.List() {
+		c.workqueue.Add(namespace)
+	}
+}
+
+// enqueueS2iRun enqueues the namespace of the S2iRun once it finishes
+func (c *RetentionController) enqueueS2iRun(obj interface{}) {
+	s2iRun, ok := obj.(*devopsv1alpha1.S2iRun)
+	if !ok || !isFinished(s2iRun) {
+		return
+	}
+	c.workqueue.Add(s2iRun.Namespace)
+}
+
+func (c *RetentionController) worker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *RetentionController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	namespace, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		return true
+	}
+
+	if err := c.syncHandler(namespace); err != nil {
+		if c.workqueue.NumRequeues(obj) < maxRetries {
+			klog.Warningf("failed to clean up s2iruns of namespace %s, retrying: %v", namespace, err)
+			c.workqueue.AddRateLimited(obj)
+			return true
+		}
+		klog.Errorf("dropping namespace %s out of the queue: %v", namespace, err)
+	}
+	c.workqueue.Forget(obj)
+	return true
+}
+
+func (c *RetentionController) syncHandler(namespace string) error {
+	ns, err := c.namespaceLister.Get(namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	policy := c.policyOf(ns)
+	if !policy.enabled() {
+		return nil
+	}
+
+	protected, err := c.protectedS2iRuns(namespace)
+	if err != nil {
+		return err
+	}
+	s2iRuns, err := c.s2iRunLister.S2iRuns(namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	runsOfBuilder := make(map[string][]*devopsv1alpha1.S2iRun)
+	for _, s2iRun := range s2iRuns {
+		runsOfBuilder[s2iRun.Spec.BuilderName] = append(runsOfBuilder[s2iRun.Spec.BuilderName], s2iRun)
+	}
+
+	now := c.now()
+	for _, runs := range runsOfBuilder {
+		sortNewestFirst(runs)
+		for i, s2iRun := range runs {
+			if !isFinished(s2iRun) || s2iRun.DeletionTimestamp != nil || protected.Has(s2iRun.Name) {
+				continue
+			}
+			if policy.maxRuns > 0 && i < policy.maxRuns {
+				continue
+			}
+			if policy.maxAge > 0 && now.Sub(s2iRun.CreationTimestamp.Time) < policy.maxAge {
+				continue
+			}
+			if err = c.deleteS2iRun(s2iRun); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// policyOf returns the global policy overridden by the annotations of namespace
+func (c *RetentionController) policyOf(namespace *corev1.Namespace) retentionPolicy {
+	policy := retentionPolicy{maxRuns: c.options.MaxRuns, maxAge: c.options.MaxAge}
+
+	if value, ok := namespace.Annotations[constants.S2iRunMaxRunsAnnotationKey]; ok {
+		if maxRuns, err := strconv.Atoi(value); err != nil || maxRuns < 0 {
+			klog.Warningf("invalid annotation %s=%q of namespace %s, it must be a non-negative integer",
+				constants.S2iRunMaxRunsAnnotationKey, value, namespace.Name)
+		} else {
+			policy.maxRuns = maxRuns
+		}
+	}
+	if value, ok := namespace.Annotations[constants.S2iRunMaxAgeAnnotationKey]; ok {
+		if maxAge, err := time.ParseDuration(value); err != nil || maxAge < 0 {
+			klog.Warningf("invalid annotation %s=%q of namespace %s, it must be a non-negative duration like 168h",
+				constants.S2iRunMaxAgeAnnotationKey, value, namespace.Name)
+		} else {
+			policy.maxAge = maxAge
+		}
+	}
+	return policy
+}
+
+// protectedS2iRuns returns the latest run of every builder, and the newest successful run which built the
+// image the builder currently points to
+func (c *RetentionController) protectedS2iRuns(namespace string) (sets.String, error) {
+	builders, err := c.s2iBuilderLister.S2iBuilders(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	s2iRuns, err := c.s2iRunLister.S2iRuns(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sortNewestFirst(s2iRuns)
+
+	protected := sets.NewString()
+	for _, builder := range builders {
+		if builder.Status.LastRunName != nil {
+			protected.Insert(*builder.Status.LastRunName)
+		}
+		image := currentImageOf(builder)
+		if image == "" {
+			continue
+		}
+		for _, s2iRun := range s2iRuns {
+			if s2iRun.Spec.BuilderName == builder.Name && s2iRun.Status.RunState == devopsv1alpha1.Successful &&
+				hasBuiltImage(s2iRun, image) {
+				protected.Insert(s2iRun.Name)
+				break
+			}
+		}
+	}
+	return protected, nil
+}
+
+// deleteS2iRun deletes the Job of the S2iRun with its pods, then the S2iRun
+func (c *RetentionController) deleteS2iRun(s2iRun *devopsv1alpha1.S2iRun) error {
+	propagation := metav1.DeletePropagationBackground
+	if jobName := s2iRun.Status.KubernetesJobName; jobName != "" {
+		err := c.k8sclient.BatchV1().Jobs(s2iRun.Namespace).Delete(context.Background(), jobName,
+			metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	err := c.ksclient.DevopsV1alpha1().S2iRuns(s2iRun.Namespace).Delete(context.Background(), s2iRun.Name,
+		metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	klog.V(4).Infof("s2irun %s/%s is deleted by the retention policy", s2iRun.Namespace, s2iRun.Name)
+	return nil
+}
+
+// currentImageOf returns the image with tag which the builder pushes to, the tag defaults to latest
+func currentImageOf(builder *devopsv1alpha1.S2iBuilder) string {
+	if builder.Spec.Config == nil || builder.Spec.Config.ImageName == "" {
+		return ""
+	}
+	tag := builder.Spec.Config.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return builder.Spec.Config.ImageName + ":" + tag
+}
+
+func hasBuiltImage(s2iRun *devopsv1alpha1.S2iRun, image string) bool {
+	result := s2iRun.Status.S2iBuildResult
+	if result == nil {
+		return false
+	}
+	if result.ImageName == image {
+		return true
+	}
+	for _, tag := range result.ImageRepoTags {
+		if tag == image {
+			return true
+		}
+	}
+	return false
+}
+
+func isFinished(s2iRun *devopsv1alpha1.S2iRun) bool {
+	return s2iRun.Status.RunState == devopsv1alpha1.Successful || s2iRun.Status.RunState == devopsv1alpha1.Failed
+}
+
+func sortNewestFirst(s2iRuns []*devopsv1alpha1.S2iRun) {
+	sort.SliceStable(s2iRuns, func(i, j int) bool {
+		if s2iRuns[i].CreationTimestamp.Equal(&s2iRuns[j].CreationTimestamp) {
+			return s2iRuns[i].Name > s2iRuns[j].Name
+		}
+		return s2iRuns[j].CreationTimestamp.Before(&s2iRuns[i].CreationTimestamp)
+	})
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+var testNow = time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+func newS2iRun(name, builder string, age time.Duration, state devopsv1alpha1.RunState, image string) *devopsv1alpha1.S2iRun {
+	s2iRun := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "bar",
+			CreationTimestamp: metav1.NewTime(testNow.Add(-age)),
+		},
+		Spec: devopsv1alpha1.S2iRunSpec{BuilderName: builder},
+		Status: devopsv1alpha1.S2iRunStatus{
+			RunState:          state,
+			KubernetesJobName: name + "-job",
+		},
+	}
+	if image != "" {
+		s2iRun.Status.S2iBuildResult = &devopsv1alpha1.S2iBuildResult{ImageName: image}
+	}
+	return s2iRun
+}
+
+func TestRetentionController(t *testing.T) {
+	lastRunName := "foo-5"
+	builder := &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+		Spec: devopsv1alpha1.S2iBuilderSpec{
+			Config: &devopsv1alpha1.S2iConfig{ImageName: "foo/app", Tag: "v1"},
+		},
+		Status: devopsv1alpha1.S2iBuilderStatus{LastRunName: &lastRunName},
+	}
+	s2iRuns := []*devopsv1alpha1.S2iRun{
+		newS2iRun("foo-1", "foo", 50*time.Hour, devopsv1alpha1.Successful, "foo/app:v1"),
+		newS2iRun("foo-2", "foo", 40*time.Hour, devopsv1alpha1.Failed, ""),
+		newS2iRun("foo-3", "foo", 30*time.Hour, devopsv1alpha1.Running, ""),
+		newS2iRun("foo-4", "foo", 20*time.Hour, devopsv1alpha1.Failed, ""),
+		newS2iRun("foo-5", "foo", 10*time.Hour, devopsv1alpha1.Failed, ""),
+		newS2iRun("other-1", "other", 30*time.Hour, devopsv1alpha1.Successful, ""),
+		newS2iRun("other-2", "other", 1*time.Hour, devopsv1alpha1.Successful, ""),
+	}
+
+	tests := []struct {
+		description string
+		options     *RetentionOptions
+		annotations map[string]string
+		expected    []string
+	}{
+		{
+			description: "retention is disabled",
+			options:     NewRetentionOptions(),
+			expected:    []string{},
+		},
+		{
+			description: "keep the newest run",
+			options:     &RetentionOptions{MaxRuns: 1},
+			// foo-1 built the current image, foo-3 is running, foo-5 is the last run
+			expected: []string{"foo-2", "foo-4", "other-1"},
+		},
+		{
+			description: "keep the runs younger than a day",
+			options:     &RetentionOptions{MaxAge: 24 * time.Hour},
+			expected:    []string{"foo-2", "other-1"},
+		},
+		{
+			description: "keep either the newest runs or the young runs",
+			options:     &RetentionOptions{MaxRuns: 2, MaxAge: 35 * time.Hour},
+			expected:    []string{"foo-2"},
+		},
+		{
+			description: "annotations override the global policy",
+			options:     &RetentionOptions{MaxRuns: 1},
+			annotations: map[string]string{
+				constants.S2iRunMaxRunsAnnotationKey: "0",
+				constants.S2iRunMaxAgeAnnotationKey:  "45h",
+			},
+			expected: []string{},
+		},
+		{
+			description: "invalid annotations are ignored",
+			options:     &RetentionOptions{MaxAge: 24 * time.Hour},
+			annotations: map[string]string{constants.S2iRunMaxAgeAnnotationKey: "a day"},
+			expected:    []string{"foo-2", "other-1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bar", Annotations: test.annotations}}
+			k8sClient := k8sfake.NewSimpleClientset(namespace)
+			k8sInformerFactory := k8sinformers.NewSharedInformerFactory(k8sClient, 0)
+			k8sInformerFactory.Core().V1().Namespaces().Informer().GetIndexer().Add(namespace)
+
+			ksClient := fake.NewSimpleClientset(builder)
+			ksInformerFactory := ksinformers.NewSharedInformerFactory(ksClient, 0)
+			ksInformerFactory.Devops().V1alpha1().S2iBuilders().Informer().GetIndexer().Add(builder)
+			for _, s2iRun := range s2iRuns {
+				ksClient.Tracker().Add(s2iRun)
+				ksInformerFactory.Devops().V1alpha1().S2iRuns().Informer().GetIndexer().Add(s2iRun)
+			}
+
+			c := NewRetentionController(k8sClient, ksClient, test.options,
+				k8sInformerFactory.Core().V1().Namespaces(),
+				ksInformerFactory.Devops().V1alpha1().S2iBuilders(),
+				ksInformerFactory.Devops().V1alpha1().S2iRuns())
+			c.now = func() time.Time { return testNow }
+
+			if err := c.syncHandler("bar"); err != nil {
+				t.Fatal(err)
+			}
+
+			deletedRuns := deletedNames(ksClient.Actions(), "s2iruns")
+			if !reflect.DeepEqual(deletedRuns, test.expected) {
+				t.Errorf("expected deleted s2iruns %v, got %v", test.expected, deletedRuns)
+			}
+			expectedJobs := make([]string, 0, len(test.expected))
+			for _, name := range test.expected {
+				expectedJobs = append(expectedJobs, name+"-job")
+			}
+			if deletedJobs := deletedNames(k8sClient.Actions(), "jobs"); !reflect.DeepEqual(deletedJobs, expectedJobs) {
+				t.Errorf("expected deleted jobs %v, got %v", expectedJobs, deletedJobs)
+			}
+		})
+	}
+}
+
+func deletedNames(actions []k8stesting.Action, resource string) []string {
+	names := make([]string, 0)
+	for _, action := range actions {
+		if deleteAction, ok := action.(k8stesting.DeleteAction); ok && action.GetResource().Resource == resource {
+			names = append(names, deleteAction.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
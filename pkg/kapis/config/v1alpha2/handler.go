@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"fmt"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+	"devops.kubesphere.io/plugin/pkg/config"
+)
+
+// ConfigGetter returns the configuration in effect, which is implemented by the apiserver
+type ConfigGetter interface {
+	EffectiveConfig() *config.Config
+	ReloadStatus() config.ReloadStatus
+}
+
+// Configz is the configuration in effect with the secrets redacted
+type Configz struct {
+	// Enabled tells whether each section of the configuration is set
+	Enabled map[string]bool `json:"enabled" description:"whether each section of the configuration is set"`
+	// Config is the configuration in its json form
+	Config map[string]interface{} `json:"config" description:"configuration in effect, the passwords, secrets and tokens are redacted"`
+
+	config.ReloadStatus `json:",inline"`
+}
+
+type configHandler struct {
+	config     ConfigGetter
+	authorizer authorizer.Authorizer
+}
+
+func newConfigHandler(config ConfigGetter, authorizer authorizer.Authorizer) *configHandler {
+	return &configHandler{
+		config:     config,
+		authorizer: authorizer,
+	}
+}
+
+func (h *configHandler) GetConfigz(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok || requestUser.GetName() == user.Anonymous {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("the configuration is only shown to the authenticated users"))
+		return
+	}
+	decision, reason, err := h.authorizer.Authorize(authorizer.AttributesRecord{
+		User:            requestUser,
+		Verb:            "get",
+		APIGroup:        GroupVersion.Group,
+		APIVersion:      GroupVersion.Version,
+		Resource:        "configs",
+		Name:            "configz",
+		ResourceRequest: true,
+		ResourceScope:   request.ClusterScope,
+	})
+	if err != nil {
+		klog.Error(err)
+		api.HandleInternalError(resp, req, err)
+		return
+	}
+	if decision != authorizer.DecisionAllow {
+		api.HandleForbidden(resp, req, fmt.Errorf("user %s cannot get the configuration: %s", requestUser.GetName(), reason))
+		return
+	}
+
+	conf := h.config.EffectiveConfig()
+	redacted, err := conf.ToRedactedMap()
+	if err != nil {
+		api.HandleInternalError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(Configz{
+		Enabled:      conf.ToMap(),
+		Config:       redacted,
+		ReloadStatus: h.config.ReloadStatus(),
+	})
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	restfulspec "github.com/emicklei/go-restful-openapi"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/runtime"
+)
+
+const (
+	GroupName = "config.kubesphere.io"
+
+	tagConfiguration = "Configuration"
+)
+
+var GroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha2"}
+
+func AddToContainer(c *restful.Container, config ConfigGetter, authorizer authorizer.Authorizer) error {
+	ws := runtime.NewWebService(GroupVersion)
+	handler := newConfigHandler(config, authorizer)
+
+	ws.Route(ws.GET("/configs/configz").
+		To(handler.GetConfigz).
+		Doc("Get the configuration in effect with the passwords, secrets and tokens redacted, and the result of its last reload").
+		Returns(http.StatusOK, api.StatusOK, Configz{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{tagConfiguration}))
+
+	c.Add(ws)
+	return nil
+}
@@ -0,0 +1,379 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+	"devops.kubesphere.io/plugin/pkg/models/devops"
+)
+
+const (
+	// s2iBinaryFormField is the form field of the binary file
+	s2iBinaryFormField = "s2ibinary"
+	// multipartMemoryLimit is the size of the uploaded file kept in memory
+	multipartMemoryLimit = 32 << 20
+	// maxS2iBinarySize is the size limit of the binaries
+	maxS2iBinarySize = 2 << 30
+	// multipartOverhead is the size allowed for the boundaries and headers of the multipart form
+	multipartOverhead = 1 << 20
+)
+
+type s2iHandler struct {
+	s2iRun      devops.S2iRunOperator
+	s2iBinary   devops.S2iBinaryOperator
+	s2iTemplate devops.S2iBuilderTemplateOperator
+	authorizer  authorizer.Authorizer
+}
+
+func newS2iHandler(s2iRun devops.S2iRunOperator, s2iBinary devops.S2iBinaryOperator,
+	s2iTemplate devops.S2iBuilderTemplateOperator, authorizer authorizer.Authorizer) *s2iHandler {
+	return &s2iHandler{
+		s2iRun:      s2iRun,
+		s2iBinary:   s2iBinary,
+		s2iTemplate: s2iTemplate,
+		authorizer:  authorizer,
+	}
+}
+
+// authorizeS2iBinary checks if the request user can do verb on the S2iBinaries of the namespace
+func (h *s2iHandler) authorizeS2iBinary(req *restful.Request, verb string) error {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		return restful.NewError(http.StatusUnauthorized, "cannot obtain user info")
+	}
+	return devops.AuthorizeS2iBinaries(h.authorizer, requestUser, verb, req.PathParameter("namespace"))
+}
+
+func (h *s2iHandler) GetS2iRunLog(req *restful.Request, resp *restful.Response) {
+	namespace := req.PathParameter("namespace")
+	name := req.PathParameter("s2irun")
+
+	options, err := parsePodLogOptions(req)
+	if err != nil {
+		api.HandleBadRequest(resp, req, err)
+		return
+	}
+
+	stream, err := h.s2iRun.GetS2iRunLog(req.Request.Context(), namespace, name, options)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	defer stream.Close()
+
+	resp.Header().Set(restful.HEADER_ContentType, "text/plain; charset=utf-8")
+	resp.WriteHeader(http.StatusOK)
+	if err = copyAndFlush(resp, stream); err != nil {
+		klog.V(4).Infof("stop streaming the log of S2iRun %s/%s: %v", namespace, name, err)
+	}
+}
+
+func (h *s2iHandler) WatchS2iRun(req *restful.Request, resp *restful.Response) {
+	namespace := req.PathParameter("namespace")
+	name := req.PathParameter("s2irun")
+
+	events, err := h.s2iRun.WatchS2iRun(req.Request.Context(), namespace, name)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+
+	resp.Header().Set(restful.HEADER_ContentType, restful.MIME_JSON)
+	resp.WriteHeader(http.StatusOK)
+	resp.Flush()
+
+	encoder := json.NewEncoder(resp)
+	var lastState string
+	for event := range events {
+		// the transition may be sent twice if it happens while the watch begins
+		if event.Type == watch.Modified && string(event.RunState) == lastState {
+			continue
+		}
+		if err = encoder.Encode(event); err != nil {
+			klog.V(4).Infof("stop watching S2iRun %s/%s: %v", namespace, name, err)
+			return
+		}
+		resp.Flush()
+
+		if event.Finished() {
+			return
+		}
+		lastState = string(event.RunState)
+	}
+}
+
+func (h *s2iHandler) CreateS2iRun(req *restful.Request, resp *restful.Response) {
+	overrides := &devops.S2iRunOverrides{}
+	if req.Request.ContentLength != 0 {
+		if err := req.ReadEntity(overrides); err != nil {
+			api.HandleBadRequest(resp, req, err)
+			return
+		}
+	}
+
+	s2iRun, err := h.s2iRun.CreateS2iRun(req.PathParameter("namespace"), req.PathParameter("s2ibuilder"), overrides)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(s2iRun)
+}
+
+func (h *s2iHandler) RerunS2iRun(req *restful.Request, resp *restful.Response) {
+	s2iRun, err := h.s2iRun.RerunS2iRun(req.PathParameter("namespace"), req.PathParameter("s2irun"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(s2iRun)
+}
+
+func (h *s2iHandler) ListS2iRunHistory(req *restful.Request, resp *restful.Response) {
+	records, err := h.s2iRun.ListS2iRunHistory(req.PathParameter("namespace"), req.PathParameter("s2ibuilder"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(records)
+}
+
+func (h *s2iHandler) RenderS2iBuilderTemplate(req *restful.Request, resp *restful.Response) {
+	input := &devops.S2iTemplateInput{}
+	if err := req.ReadEntity(input); err != nil {
+		api.HandleBadRequest(resp, req, err)
+		return
+	}
+
+	rendering, err := h.s2iTemplate.RenderS2iBuilderTemplate(req.PathParameter("s2ibuildertemplate"), input)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(rendering)
+}
+
+func (h *s2iHandler) UploadS2iBinary(req *restful.Request, resp *restful.Response) {
+	namespace := req.PathParameter("namespace")
+	name := req.PathParameter("s2ibinary")
+	if err := h.authorizeS2iBinary(req, "update"); err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+
+	// the file is kept in memory up to the limit, the rest is spooled to a temporary file
+	req.Request.Body = http.MaxBytesReader(resp, req.Request.Body, maxS2iBinarySize+multipartOverhead)
+	if err := req.Request.ParseMultipartForm(multipartMemoryLimit); err != nil {
+		// MaxBytesReader returns an untyped error
+		if strings.Contains(err.Error(), "request body too large") {
+			api.HandleError(resp, req, restful.NewError(http.StatusRequestEntityTooLarge, err.Error()))
+			return
+		}
+		api.HandleBadRequest(resp, req, err)
+		return
+	}
+	defer req.Request.MultipartForm.RemoveAll()
+
+	file, header, err := req.Request.FormFile(s2iBinaryFormField)
+	if err != nil {
+		api.HandleBadRequest(resp, req, fmt.Errorf("failed to read the form file %s: %v", s2iBinaryFormField, err))
+		return
+	}
+	defer file.Close()
+	if header.Size > maxS2iBinarySize {
+		api.HandleError(resp, req, restful.NewError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("the binary is larger than %d bytes", int64(maxS2iBinarySize))))
+		return
+	}
+
+	s2iBinary, err := h.s2iBinary.UploadS2iBinary(namespace, name, &devops.S2iBinaryFile{
+		FileName: header.Filename,
+		MD5:      req.Request.FormValue("md5"),
+		Size:     header.Size,
+		Reader:   file,
+	})
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(s2iBinary)
+}
+
+func (h *s2iHandler) UploadS2iBinaryChunk(req *restful.Request, resp *restful.Response) {
+	namespace := req.PathParameter("namespace")
+	name := req.PathParameter("s2ibinary")
+	if err := h.authorizeS2iBinary(req, "update"); err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+
+	start, end, total, err := parseContentRange(req.HeaderParameter("Content-Range"))
+	if err != nil {
+		api.HandleBadRequest(resp, req, err)
+		return
+	}
+	if total > maxS2iBinarySize {
+		api.HandleError(resp, req, restful.NewError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("the binary is larger than %d bytes", int64(maxS2iBinarySize))))
+		return
+	}
+	size := end - start + 1
+	if req.Request.ContentLength >= 0 && req.Request.ContentLength != size {
+		api.HandleBadRequest(resp, req, fmt.Errorf("content length %d doesn't match the range of %d bytes",
+			req.Request.ContentLength, size))
+		return
+	}
+	fileName := req.QueryParameter("fileName")
+	if fileName == "" {
+		api.HandleBadRequest(resp, req, fmt.Errorf("fileName is required"))
+		return
+	}
+
+	status, err := h.s2iBinary.UploadS2iBinaryChunk(namespace, name, &devops.S2iBinaryChunk{
+		FileName: fileName,
+		MD5:      req.QueryParameter("md5"),
+		Start:    start,
+		Size:     size,
+		Total:    total,
+		// the chunk never exceeds its range, even if the body is sent without its length
+		Reader: http.MaxBytesReader(resp, req.Request.Body, size),
+	})
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(status)
+}
+
+func (h *s2iHandler) GetS2iBinaryUploadStatus(req *restful.Request, resp *restful.Response) {
+	if err := h.authorizeS2iBinary(req, "get"); err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	status, err := h.s2iBinary.GetS2iBinaryUploadStatus(req.PathParameter("namespace"), req.PathParameter("s2ibinary"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(status)
+}
+
+func (h *s2iHandler) DownloadS2iBinary(req *restful.Request, resp *restful.Response) {
+	namespace := req.PathParameter("namespace")
+	name := req.PathParameter("s2ibinary")
+	fileName := req.PathParameter("file")
+	// the S2I builders download the binary by the token in its download URL, the others have to be authorized
+	token := req.QueryParameter(devops.S2iBinaryDownloadTokenParameter)
+	if token == "" {
+		if err := h.authorizeS2iBinary(req, "get"); err != nil {
+			api.HandleError(resp, req, err)
+			return
+		}
+	}
+
+	reader, _, err := h.s2iBinary.DownloadS2iBinary(namespace, name, fileName, token)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	defer reader.Close()
+
+	resp.Header().Set(restful.HEADER_ContentType, "application/octet-stream")
+	resp.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	resp.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(resp, reader); err != nil {
+		klog.V(4).Infof("stop downloading S2iBinary %s/%s: %v", namespace, name, err)
+	}
+}
+
+// parseContentRange parses the header like bytes 0-1023/4096
+func parseContentRange(value string) (start, end, total int64, err error) {
+	if _, err = fmt.Sscanf(value, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q, it has to be like bytes 0-1023/4096", value)
+	}
+	if start < 0 || end < start || end >= total {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	return start, end, total, nil
+}
+
+func parsePodLogOptions(req *restful.Request) (*corev1.PodLogOptions, error) {
+	options := &corev1.PodLogOptions{
+		Container: req.QueryParameter("container"),
+	}
+
+	var err error
+	if value := req.QueryParameter("follow"); value != "" {
+		if options.Follow, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid follow %q: %v", value, err)
+		}
+	}
+	if value := req.QueryParameter("timestamps"); value != "" {
+		if options.Timestamps, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid timestamps %q: %v", value, err)
+		}
+	}
+	if value := req.QueryParameter("sinceSeconds"); value != "" {
+		sinceSeconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || sinceSeconds <= 0 {
+			return nil, fmt.Errorf("invalid sinceSeconds %q, it must be a positive integer", value)
+		}
+		options.SinceSeconds = &sinceSeconds
+	}
+	if value := req.QueryParameter("tailLines"); value != "" {
+		tailLines, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || tailLines < 0 {
+			return nil, fmt.Errorf("invalid tailLines %q, it must be a non-negative integer", value)
+		}
+		options.TailLines = &tailLines
+	}
+	return options, nil
+}
+
+// copyAndFlush copies the stream to the response, and flushes every chunk so the followed log is not buffered
+func copyAndFlush(resp *restful.Response, reader io.Reader) error {
+	buffer := make([]byte, 4096)
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			if _, writeErr := resp.Write(buffer[:n]); writeErr != nil {
+				return writeErr
+			}
+			resp.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
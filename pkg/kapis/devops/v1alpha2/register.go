@@ -0,0 +1,168 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	restfulspec "github.com/emicklei/go-restful-openapi"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/runtime"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	"devops.kubesphere.io/plugin/pkg/client/storage"
+	"devops.kubesphere.io/plugin/pkg/constants"
+	"devops.kubesphere.io/plugin/pkg/informers"
+	"devops.kubesphere.io/plugin/pkg/models/devops"
+)
+
+const (
+	GroupName = "devops.kubesphere.io"
+)
+
+var GroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha2"}
+
+// AddToContainer installs the S2I APIs, the APIs of S2iBinary files are installed only if objectStorage is not nil
+func AddToContainer(c *restful.Container, k8sclient kubernetes.Interface, ksclient kubesphere.Interface,
+	factory informers.InformerFactory, objectStorage storage.Interface, downloadServer string,
+	authorizer authorizer.Authorizer) error {
+	ws := runtime.NewWebService(GroupVersion)
+	ksInformers := factory.KubeSphereSharedInformerFactory().Devops().V1alpha1()
+
+	var s2iBinaryOperator devops.S2iBinaryOperator
+	if objectStorage != nil {
+		s2iBinaryOperator = devops.NewS2iBinaryOperator(ksclient, ksInformers.S2iBinaries(), objectStorage, downloadServer)
+	}
+	handler := newS2iHandler(devops.NewS2iRunOperator(k8sclient, ksclient, ksInformers.S2iBuilders(), ksInformers.S2iRuns(),
+		factory.KubernetesSharedInformerFactory().Core().V1().Pods()), s2iBinaryOperator,
+		devops.NewS2iBuilderTemplateOperator(ksInformers.S2iBuilderTemplates()), authorizer)
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2iruns/{s2irun}/log").
+		To(handler.GetS2iRunLog).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iRun")).
+		Param(ws.PathParameter("s2irun", "the name of the S2iRun")).
+		Param(ws.QueryParameter("container", "the container of the build pod, defaults to the only container").Required(false)).
+		Param(ws.QueryParameter("follow", "follow the log stream until the build finishes").DataType("boolean").Required(false).DefaultValue("false")).
+		Param(ws.QueryParameter("sinceSeconds", "only return logs newer than the relative duration in seconds").DataType("integer").Required(false)).
+		Param(ws.QueryParameter("tailLines", "the number of lines from the end of the log to show").DataType("integer").Required(false)).
+		Param(ws.QueryParameter("timestamps", "prefix each line with its timestamp").DataType("boolean").Required(false).DefaultValue("false")).
+		Doc("Get the log of the build pod of the S2iRun").
+		Produces("text/plain", restful.MIME_JSON).
+		Returns(http.StatusOK, api.StatusOK, nil).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2iruns/{s2irun}/watch").
+		To(handler.WatchS2iRun).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iRun")).
+		Param(ws.PathParameter("s2irun", "the name of the S2iRun")).
+		Doc("Watch the run state of the S2iRun, the current state and then every transition are sent as a stream of JSON objects until the build finishes").
+		Returns(http.StatusOK, api.StatusOK, devops.S2iRunEvent{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/s2ibuilders/{s2ibuilder}/s2iruns").
+		To(handler.CreateS2iRun).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iBuilder")).
+		Param(ws.PathParameter("s2ibuilder", "the name of the S2iBuilder")).
+		Reads(devops.S2iRunOverrides{}).
+		Doc("Start a build of the S2iBuilder, newRevisionId is only applicable to the git source and newSourceURL to the binary source").
+		Returns(http.StatusOK, api.StatusOK, devopsv1alpha1.S2iRun{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2ibuilders/{s2ibuilder}/s2iruns").
+		To(handler.ListS2iRunHistory).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iBuilder")).
+		Param(ws.PathParameter("s2ibuilder", "the name of the S2iBuilder")).
+		Doc("List the runs of the S2iBuilder with their build results, the newest first").
+		Returns(http.StatusOK, api.StatusOK, []devops.S2iRunRecord{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/s2iruns/{s2irun}/rerun").
+		To(handler.RerunS2iRun).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iRun")).
+		Param(ws.PathParameter("s2irun", "the name of the S2iRun")).
+		Doc("Start a new build with the same builder and the revision built by the S2iRun, the binary source is downloaded again from the URL built by the S2iRun").
+		Returns(http.StatusOK, api.StatusOK, devopsv1alpha1.S2iRun{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.POST("/s2ibuildertemplates/{s2ibuildertemplate}/render").
+		To(handler.RenderS2iBuilderTemplate).
+		Param(ws.PathParameter("s2ibuildertemplate", "the name of the S2iBuilderTemplate")).
+		Reads(devops.S2iTemplateInput{}).
+		Doc("Validate the parameter values and builder image against the S2iBuilderTemplate, then render the environment and images of S2iConfig").
+		Returns(http.StatusOK, api.StatusOK, devops.S2iTemplateRendering{}).
+		Returns(http.StatusUnprocessableEntity, "the input doesn't satisfy the template", nil).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	if s2iBinaryOperator != nil {
+		addS2iBinaryRoutes(ws, handler)
+	}
+
+	c.Add(ws)
+	return nil
+}
+
+func addS2iBinaryRoutes(ws *restful.WebService, handler *s2iHandler) {
+	ws.Route(ws.PUT("/namespaces/{namespace}/s2ibinaries/{s2ibinary}/file").
+		To(handler.UploadS2iBinary).
+		Consumes(runtime.MimeMultipartFormData).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iBinary")).
+		Param(ws.PathParameter("s2ibinary", "the name of the S2iBinary")).
+		Param(ws.FormParameter(s2iBinaryFormField, "the binary file").DataType("file").Required(true)).
+		Param(ws.FormParameter("md5", "the MD5 of the binary, the upload fails if it doesn't match").Required(false)).
+		Doc("Upload the binary of S2iBinary in a single request").
+		Returns(http.StatusOK, api.StatusOK, devopsv1alpha1.S2iBinary{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.PUT("/namespaces/{namespace}/s2ibinaries/{s2ibinary}/upload").
+		To(handler.UploadS2iBinaryChunk).
+		Consumes("application/octet-stream").
+		Param(ws.PathParameter("namespace", "the namespace of the S2iBinary")).
+		Param(ws.PathParameter("s2ibinary", "the name of the S2iBinary")).
+		Param(ws.HeaderParameter("Content-Range", "the range of the chunk, e.g. bytes 0-1023/4096").Required(true)).
+		Param(ws.QueryParameter("fileName", "the file name of the binary").Required(true)).
+		Param(ws.QueryParameter("md5", "the MD5 of the whole binary, it is verified when the last chunk is uploaded").Required(false)).
+		Doc("Upload a chunk of the binary of S2iBinary, the chunks have to be uploaded in order. The chunk starts from 0 restarts the upload, "+
+			"and the binary is stored when the last chunk is uploaded").
+		Returns(http.StatusOK, api.StatusOK, devops.S2iBinaryUploadStatus{}).
+		Returns(http.StatusConflict, "the chunk doesn't start from the uploaded bytes", nil).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2ibinaries/{s2ibinary}/upload").
+		To(handler.GetS2iBinaryUploadStatus).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iBinary")).
+		Param(ws.PathParameter("s2ibinary", "the name of the S2iBinary")).
+		Doc("Get the uploaded bytes of the chunked upload, the interrupted upload resumes from it").
+		Returns(http.StatusOK, api.StatusOK, devops.S2iBinaryUploadStatus{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/s2ibinaries/{s2ibinary}/file/{file}").
+		To(handler.DownloadS2iBinary).
+		Param(ws.PathParameter("namespace", "the namespace of the S2iBinary")).
+		Param(ws.PathParameter("s2ibinary", "the name of the S2iBinary")).
+		Param(ws.PathParameter("file", "the file name of the binary")).
+		Param(ws.QueryParameter(devops.S2iBinaryDownloadTokenParameter, "the token in the download URL of the S2iBinary, "+
+			"the requests without it need the permission to get the S2iBinary").Required(false)).
+		Doc("Download the binary of S2iBinary").
+		Produces("application/octet-stream", restful.MIME_JSON).
+		Returns(http.StatusOK, api.StatusOK, nil).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsS2iTag}))
+}
@@ -0,0 +1,420 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	"devops.kubesphere.io/plugin/pkg/client/devops/router"
+	"devops.kubesphere.io/plugin/pkg/models/devops"
+	"devops.kubesphere.io/plugin/pkg/models/devops/approval"
+	"devops.kubesphere.io/plugin/pkg/models/devops/commitstatus"
+	"devops.kubesphere.io/plugin/pkg/models/devops/notification"
+	"devops.kubesphere.io/plugin/pkg/models/devops/queue"
+	pipelinemodel "devops.kubesphere.io/plugin/pkg/models/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/models/devops/webhook"
+)
+
+// maxWebhookPayload limits the payload of a webhook, it's the limit of GitHub
+const maxWebhookPayload = 25 << 20
+
+type devopsHandler struct {
+	registry     *router.Registry
+	ksclient     kubesphere.Interface
+	buildGraph   devops.BuildGraphOperator
+	pipeline     pipelinemodel.PipelineOperator
+	webhook      webhook.Receiver
+	approval     approval.Operator
+	queue        queue.Operator
+	agent        devops.AgentOperator
+	notification notification.Operator
+	commitStatus commitstatus.Reporter
+	authorizer   authorizer.Authorizer
+}
+
+func newDevopsHandler(registry *router.Registry, ksclient kubesphere.Interface, buildGraph devops.BuildGraphOperator, pipeline pipelinemodel.PipelineOperator,
+	receiver webhook.Receiver, approvalOperator approval.Operator, queueOperator queue.Operator,
+	agentOperator devops.AgentOperator, notificationOperator notification.Operator,
+	commitStatusReporter commitstatus.Reporter, authorizer authorizer.Authorizer) *devopsHandler {
+	return &devopsHandler{
+		registry:     registry,
+		ksclient:     ksclient,
+		buildGraph:   buildGraph,
+		pipeline:     pipeline,
+		webhook:      receiver,
+		approval:     approvalOperator,
+		queue:        queueOperator,
+		agent:        agentOperator,
+		notification: notificationOperator,
+		commitStatus: commitStatusReporter,
+		authorizer:   authorizer,
+	}
+}
+
+func (h *devopsHandler) ListJenkinsBackends(req *restful.Request, resp *restful.Response) {
+	resp.WriteEntity(h.registry.Status())
+}
+
+func (h *devopsHandler) GetPipelineRunGraph(req *restful.Request, resp *restful.Response) {
+	graph, err := h.buildGraph.GetPipelineRunGraph(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("run"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(graph)
+}
+
+func (h *devopsHandler) GetBranchPipelineRunGraph(req *restful.Request, resp *restful.Response) {
+	graph, err := h.buildGraph.GetBranchPipelineRunGraph(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("branch"), req.PathParameter("run"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(graph)
+}
+
+func (h *devopsHandler) GetPipeline(req *restful.Request, resp *restful.Response) {
+	pipeline, err := h.pipeline.GetPipeline(req.PathParameter("devops"), req.PathParameter("pipeline"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(pipeline)
+}
+
+func (h *devopsHandler) GetPipelineRun(req *restful.Request, resp *restful.Response) {
+	run, err := h.pipeline.GetPipelineRun(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("run"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(run)
+}
+
+func (h *devopsHandler) GetPipelineRunNodes(req *restful.Request, resp *restful.Response) {
+	nodes, err := h.pipeline.GetPipelineRunNodes(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("run"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(nodes)
+}
+
+func (h *devopsHandler) GetNodeSteps(req *restful.Request, resp *restful.Response) {
+	steps, err := h.pipeline.GetNodeSteps(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("run"), req.PathParameter("node"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(steps)
+}
+
+func (h *devopsHandler) GetBranchPipeline(req *restful.Request, resp *restful.Response) {
+	branch, err := h.pipeline.GetBranchPipeline(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("branch"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(branch)
+}
+
+func (h *devopsHandler) GetBranchPipelineRun(req *restful.Request, resp *restful.Response) {
+	run, err := h.pipeline.GetBranchPipelineRun(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("branch"), req.PathParameter("run"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(run)
+}
+
+func (h *devopsHandler) GetBranchPipelineRunNodes(req *restful.Request, resp *restful.Response) {
+	nodes, err := h.pipeline.GetBranchPipelineRunNodes(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("branch"), req.PathParameter("run"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(nodes)
+}
+
+func (h *devopsHandler) GetBranchNodeSteps(req *restful.Request, resp *restful.Response) {
+	steps, err := h.pipeline.GetBranchNodeSteps(req.PathParameter("devops"), req.PathParameter("pipeline"),
+		req.PathParameter("branch"), req.PathParameter("run"), req.PathParameter("node"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(steps)
+}
+
+func (h *devopsHandler) ReceiveWebhook(req *restful.Request, resp *restful.Response) {
+	body, err := ioutil.ReadAll(io.LimitReader(req.Request.Body, maxWebhookPayload+1))
+	if err != nil {
+		api.HandleBadRequest(resp, req, err)
+		return
+	}
+	if len(body) > maxWebhookPayload {
+		api.HandleBadRequest(resp, req, fmt.Errorf("the payload exceeds %d bytes", maxWebhookPayload))
+		return
+	}
+	delivery, err := h.webhook.Receive(req.PathParameter("provider"), req.Request.Header, body)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(delivery.Receipt())
+}
+
+func (h *devopsHandler) ListWebhookDeliveries(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	namespace := req.PathParameter("devops")
+	if err := devops.AuthorizePipelines(h.authorizer, requestUser, "get", namespace); err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	limit := 0
+	if value := req.QueryParameter("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			api.HandleBadRequest(resp, req, fmt.Errorf("invalid limit %q, it must be a non-negative integer", value))
+			return
+		}
+	}
+	resp.WriteEntity(h.webhook.ListDeliveries(namespace, limit))
+}
+
+func (h *devopsHandler) ListPendingInputs(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	inputs, err := h.approval.ListPendingInputs(requestUser, req.QueryParameter("devops"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(inputs)
+}
+
+func (h *devopsHandler) SubmitInput(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	submission := &approval.Submission{}
+	if err := req.ReadEntity(submission); err != nil {
+		api.HandleBadRequest(resp, req, err)
+		return
+	}
+	ref := approval.InputRef{
+		Namespace: req.PathParameter("devops"),
+		Pipeline:  req.PathParameter("pipeline"),
+		Branch:    req.PathParameter("branch"),
+		Run:       req.PathParameter("run"),
+		Node:      req.PathParameter("node"),
+		Step:      req.PathParameter("step"),
+	}
+	input, err := h.approval.Submit(requestUser, ref, submission)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(input)
+}
+
+func (h *devopsHandler) ListInputRecords(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	limit := 0
+	if value := req.QueryParameter("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			api.HandleBadRequest(resp, req, fmt.Errorf("invalid limit %q, it must be a non-negative integer", value))
+			return
+		}
+	}
+	records, err := h.approval.ListRecords(requestUser, req.PathParameter("devops"), limit)
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(records)
+}
+
+func (h *devopsHandler) ListNotificationDeliveries(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	namespace := req.PathParameter("devops")
+	if err := devops.AuthorizePipelines(h.authorizer, requestUser, "get", namespace); err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	limit := 0
+	if value := req.QueryParameter("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			api.HandleBadRequest(resp, req, fmt.Errorf("invalid limit %q, it must be a non-negative integer", value))
+			return
+		}
+	}
+	// the notifications are disabled if the notification policies are not installed
+	if h.notification == nil {
+		resp.WriteEntity([]notification.Delivery{})
+		return
+	}
+	resp.WriteEntity(h.notification.ListDeliveries(namespace, limit))
+}
+
+func (h *devopsHandler) ListCommitStatusReports(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	namespace := req.PathParameter("devops")
+	if err := devops.AuthorizePipelines(h.authorizer, requestUser, "get", namespace); err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	limit := 0
+	if value := req.QueryParameter("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			api.HandleBadRequest(resp, req, fmt.Errorf("invalid limit %q, it must be a non-negative integer", value))
+			return
+		}
+	}
+	resp.WriteEntity(h.commitStatus.ListReports(namespace, limit))
+}
+
+func (h *devopsHandler) ListQueueItems(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	items, err := h.queue.ListItems(requestUser, req.QueryParameter("devops"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(items)
+}
+
+func (h *devopsHandler) CancelQueueItem(req *restful.Request, resp *restful.Response) {
+	requestUser, ok := request.UserFrom(req.Request.Context())
+	if !ok {
+		api.HandleUnauthorized(resp, req, fmt.Errorf("cannot obtain user info"))
+		return
+	}
+	id, err := strconv.ParseInt(req.PathParameter("item"), 10, 64)
+	if err != nil {
+		api.HandleBadRequest(resp, req, fmt.Errorf("invalid queue item %q", req.PathParameter("item")))
+		return
+	}
+	if err = h.queue.Cancel(requestUser, req.PathParameter("devops"), id); err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteHeader(http.StatusOK)
+}
+
+func (h *devopsHandler) ListAgents(req *restful.Request, resp *restful.Response) {
+	agents, err := h.agent.ListAgents(req.PathParameter("devops"), req.QueryParameter("label"))
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(agents)
+}
+
+func (h *devopsHandler) PreviewCron(req *restful.Request, resp *restful.Response) {
+	cronRequest := &devops.CronPreviewRequest{}
+	if err := req.ReadEntity(cronRequest); err != nil {
+		api.HandleBadRequest(resp, req, err)
+		return
+	}
+	preview, err := devops.PreviewCron(req.PathParameter("devops"), cronRequest, time.Now())
+	if err != nil {
+		api.HandleError(resp, req, err)
+		return
+	}
+	resp.WriteEntity(preview)
+}
+
+// ValidatePipeline is the validating admission webhook of the pipelines, it rejects the pipelines with invalid cron
+// of the timer trigger, or selecting the agents of other projects
+func (h *devopsHandler) ValidatePipeline(req *restful.Request, resp *restful.Response) {
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := req.ReadEntity(review); err != nil || review.Request == nil {
+		api.HandleBadRequest(resp, req, fmt.Errorf("invalid admission review"))
+		return
+	}
+
+	response := &admissionv1beta1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	// the object of other operations like deletion is empty, only the created and updated pipelines are validated
+	if operation := review.Request.Operation; operation == admissionv1beta1.Create || operation == admissionv1beta1.Update {
+		pipeline := &devopsv1alpha3.Pipeline{}
+		if err := json.Unmarshal(review.Request.Object.Raw, pipeline); err != nil {
+			response.Allowed = false
+			response.Result = &metav1.Status{Code: http.StatusBadRequest, Message: err.Error()}
+		} else if err = devops.ValidatePipelineCron(pipeline); err != nil {
+			response.Allowed = false
+			response.Result = &metav1.Status{Code: http.StatusUnprocessableEntity, Message: err.Error()}
+		} else if err = devops.ValidatePipelineAgents(h.ksclient, pipeline); err != nil {
+			response.Allowed = false
+			response.Result = &metav1.Status{Code: http.StatusForbidden, Message: err.Error()}
+		}
+	}
+	review.Response = response
+	review.Request = nil
+	resp.WriteEntity(review)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+)
+
+func TestValidatePipeline(t *testing.T) {
+	newPipeline := func(cron string) []byte {
+		data, _ := json.Marshal(&devopsv1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: "project"},
+			Spec: devopsv1alpha3.PipelineSpec{
+				Type: devopsv1alpha3.NoScmPipelineType,
+				Pipeline: &devopsv1alpha3.NoScmPipeline{
+					Name:         "pipeline",
+					TimerTrigger: &devopsv1alpha3.TimerTrigger{Cron: cron},
+				},
+			},
+		})
+		return data
+	}
+
+	tests := []struct {
+		description string
+		operation   admissionv1beta1.Operation
+		object      []byte
+		allowed     bool
+	}{
+		{description: "valid cron", operation: admissionv1beta1.Create, object: newPipeline("H H * * *"), allowed: true},
+		{description: "invalid cron", operation: admissionv1beta1.Update, object: newPipeline("H H * * 8"), allowed: false},
+		{description: "invalid object", operation: admissionv1beta1.Create, object: []byte("[]"), allowed: false},
+		{description: "deletion without object", operation: admissionv1beta1.Delete, allowed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			body, err := json.Marshal(&admissionv1beta1.AdmissionReview{Request: &admissionv1beta1.AdmissionRequest{
+				UID:       "uid",
+				Operation: test.operation,
+				Object:    runtime.RawExtension{Raw: test.object},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpReq := httptest.NewRequest(http.MethodPost, "/admission/pipelines", bytes.NewReader(body))
+			httpReq.Header.Set("Content-Type", restful.MIME_JSON)
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			(&devopsHandler{ksclient: fake.NewSimpleClientset()}).ValidatePipeline(restful.NewRequest(httpReq), resp)
+			if resp.StatusCode() != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", resp.StatusCode())
+			}
+
+			review := &admissionv1beta1.AdmissionReview{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), review); err != nil {
+				t.Fatal(err)
+			}
+			if review.Response == nil || review.Response.UID != "uid" || review.Response.Allowed != test.allowed {
+				t.Errorf("expected allowed %v, got %+v", test.allowed, review.Response)
+			}
+		})
+	}
+}
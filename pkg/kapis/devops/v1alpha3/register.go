@@ -0,0 +1,276 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	restfulspec "github.com/emicklei/go-restful-openapi"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/runtime"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/router"
+	"devops.kubesphere.io/plugin/pkg/constants"
+	"devops.kubesphere.io/plugin/pkg/informers"
+	devopsmodel "devops.kubesphere.io/plugin/pkg/models/devops"
+	"devops.kubesphere.io/plugin/pkg/models/devops/approval"
+	"devops.kubesphere.io/plugin/pkg/models/devops/commitstatus"
+	"devops.kubesphere.io/plugin/pkg/models/devops/notification"
+	"devops.kubesphere.io/plugin/pkg/models/devops/queue"
+	pipelinemodel "devops.kubesphere.io/plugin/pkg/models/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/models/devops/webhook"
+)
+
+const (
+	GroupName = "devops.kubesphere.io"
+)
+
+var GroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha3"}
+
+func AddToContainer(c *restful.Container, registry *router.Registry, devopsClient devops.Interface,
+	ksclient kubesphere.Interface, informerFactory informers.InformerFactory, approvalOperator approval.Operator, queueOperator queue.Operator,
+	notificationOperator notification.Operator, commitStatusReporter commitstatus.Reporter,
+	authorizer authorizer.Authorizer) error {
+	ws := runtime.NewWebService(GroupVersion)
+	receiver := webhook.NewReceiver(devopsClient,
+		informerFactory.KubeSphereSharedInformerFactory().Devops().V1alpha3().Pipelines().Lister(),
+		informerFactory.KubernetesSharedInformerFactory().Core().V1().Secrets().Lister(),
+		webhook.DefaultMaxDeliveries)
+	handler := newDevopsHandler(registry, ksclient, devopsmodel.NewBuildGraphOperator(devopsClient),
+		pipelinemodel.NewPipelineOperator(devopsClient), receiver, approvalOperator, queueOperator,
+		devopsmodel.NewAgentOperator(devopsClient), notificationOperator, commitStatusReporter, authorizer)
+
+	ws.Route(ws.GET("/jenkins/backends").
+		To(handler.ListJenkinsBackends).
+		Doc("List the Jenkins backends with their health and request metrics").
+		Returns(http.StatusOK, api.StatusOK, []router.BackendStatus{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsJenkinsTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/runs/{run}/graph").
+		To(handler.GetPipelineRunGraph).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the pipeline")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Doc("Get the stages, parallel branches and steps of the pipeline run as a graph, with the critical path and the pending inputs").
+		Returns(http.StatusOK, api.StatusOK, devopsmodel.BuildGraph{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/branches/{branch}/runs/{run}/graph").
+		To(handler.GetBranchPipelineRunGraph).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the multi-branch pipeline")).
+		Param(ws.PathParameter("branch", "the name of the branch")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Doc("Get the stages, parallel branches and steps of the branch pipeline run as a graph, with the critical path and the pending inputs").
+		Returns(http.StatusOK, api.StatusOK, devopsmodel.BuildGraph{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}").
+		To(handler.GetPipeline).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the pipeline")).
+		Doc("Get the typed pipeline").
+		Returns(http.StatusOK, api.StatusOK, pipelinemodel.Pipeline{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/runs/{run}").
+		To(handler.GetPipelineRun).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the pipeline")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Doc("Get the typed pipeline run, with its causes and change set").
+		Returns(http.StatusOK, api.StatusOK, pipelinemodel.PipelineRun{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/runs/{run}/nodes").
+		To(handler.GetPipelineRunNodes).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the pipeline")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Doc("Get the typed stages and parallel branches of the pipeline run").
+		Returns(http.StatusOK, api.StatusOK, []pipelinemodel.PipelineRunNode{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/runs/{run}/nodes/{node}/steps").
+		To(handler.GetNodeSteps).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the pipeline")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Param(ws.PathParameter("node", "the id of the node")).
+		Doc("Get the typed steps of the node of the pipeline run").
+		Returns(http.StatusOK, api.StatusOK, []pipelinemodel.NodeStep{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/branches/{branch}").
+		To(handler.GetBranchPipeline).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the multi-branch pipeline")).
+		Param(ws.PathParameter("branch", "the name of the branch")).
+		Doc("Get the typed branch of the multi-branch pipeline, with its latest run").
+		Returns(http.StatusOK, api.StatusOK, pipelinemodel.BranchPipeline{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/branches/{branch}/runs/{run}").
+		To(handler.GetBranchPipelineRun).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the multi-branch pipeline")).
+		Param(ws.PathParameter("branch", "the name of the branch")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Doc("Get the typed branch pipeline run, with its causes and change set").
+		Returns(http.StatusOK, api.StatusOK, pipelinemodel.PipelineRun{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/branches/{branch}/runs/{run}/nodes").
+		To(handler.GetBranchPipelineRunNodes).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the multi-branch pipeline")).
+		Param(ws.PathParameter("branch", "the name of the branch")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Doc("Get the typed stages and parallel branches of the branch pipeline run").
+		Returns(http.StatusOK, api.StatusOK, []pipelinemodel.PipelineRunNode{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/pipelines/{pipeline}/branches/{branch}/runs/{run}/nodes/{node}/steps").
+		To(handler.GetBranchNodeSteps).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the multi-branch pipeline")).
+		Param(ws.PathParameter("branch", "the name of the branch")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Param(ws.PathParameter("node", "the id of the node")).
+		Doc("Get the typed steps of the node of the branch pipeline run").
+		Returns(http.StatusOK, api.StatusOK, []pipelinemodel.NodeStep{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	// the webhooks are authenticated by the signatures against the secrets of the pipelines
+	ws.Route(ws.POST("/webhooks/{provider}").
+		To(handler.ReceiveWebhook).
+		Param(ws.PathParameter("provider", "the SCM provider sending the webhook, one of github, gitlab, bitbucket_server and gitea")).
+		Doc("Receive the push, tag and pull request webhooks of the SCM, then scan or run the multi-branch pipelines of the repository").
+		Returns(http.StatusOK, api.StatusOK, webhook.Receipt{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsWebhookTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/webhooks/deliveries").
+		To(handler.ListWebhookDeliveries).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.QueryParameter("limit", "the number of the latest deliveries to return, defaults to all the kept deliveries").DataType("integer").Required(false)).
+		Doc("List the latest webhook deliveries triggering the pipelines of the DevOps project with the actions taken on them, the newest first").
+		Returns(http.StatusOK, api.StatusOK, []webhook.Delivery{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsWebhookTag}))
+
+	ws.Route(ws.GET("/approvals").
+		To(handler.ListPendingInputs).
+		Param(ws.QueryParameter("devops", "the name of the DevOps project, defaults to all the DevOps projects").Required(false)).
+		Doc("List the pending inputs of the pipeline runs the current user can approve, the oldest first. The inputs are synced every minute, so the inputs paused since the last sync are not listed yet").
+		Returns(http.StatusOK, api.StatusOK, []approval.InputApproval{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.POST("/devops/{devops}/pipelines/{pipeline}/runs/{run}/nodes/{node}/steps/{step}/approval").
+		To(handler.SubmitInput).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the pipeline")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Param(ws.PathParameter("node", "the id of the node")).
+		Param(ws.PathParameter("step", "the id of the input step")).
+		Reads(approval.Submission{}).
+		Doc("Approve or abort the pending input as the current user, the input proceeds once the quorum is reached").
+		Returns(http.StatusOK, api.StatusOK, approval.InputApproval{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.POST("/devops/{devops}/pipelines/{pipeline}/branches/{branch}/runs/{run}/nodes/{node}/steps/{step}/approval").
+		To(handler.SubmitInput).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("pipeline", "the name of the multi-branch pipeline")).
+		Param(ws.PathParameter("branch", "the name of the branch")).
+		Param(ws.PathParameter("run", "the id of the pipeline run")).
+		Param(ws.PathParameter("node", "the id of the node")).
+		Param(ws.PathParameter("step", "the id of the input step")).
+		Reads(approval.Submission{}).
+		Doc("Approve or abort the pending input of the branch pipeline run as the current user, the input proceeds once the quorum is reached").
+		Returns(http.StatusOK, api.StatusOK, approval.InputApproval{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/approvals/records").
+		To(handler.ListInputRecords).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.QueryParameter("limit", "the number of the latest records to return, defaults to all the kept records").DataType("integer").Required(false)).
+		Doc("List the latest proceeded, aborted and expired inputs with their approvers and comments, the newest first").
+		Returns(http.StatusOK, api.StatusOK, []approval.InputApproval{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/notifications/deliveries").
+		To(handler.ListNotificationDeliveries).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.QueryParameter("limit", "the number of the latest deliveries to return, defaults to all the kept deliveries").DataType("integer").Required(false)).
+		Doc("List the latest deliveries of the notification policies with their attempts and errors, the newest first. The deliveries are kept in the memory of the replica holding the lease of the notifications only, they are lost on restarts and on the changes of the lease, and the other replicas return none").
+		Returns(http.StatusOK, api.StatusOK, []notification.Delivery{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/commitstatuses").
+		To(handler.ListCommitStatusReports).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.QueryParameter("limit", "the number of the latest reports to return, defaults to all the kept reports").DataType("integer").Required(false)).
+		Doc("List the latest commit statuses posted to the SCM providers for the runs of the branches and the pull requests, the newest first. The reports are kept in the memory of the replica holding the lease of the controllers only, they are lost on restarts and the other replicas return none").
+		Returns(http.StatusOK, api.StatusOK, []commitstatus.Report{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/queue").
+		To(handler.ListQueueItems).
+		Param(ws.QueryParameter("devops", "the name of the DevOps project, defaults to all the DevOps projects").Required(false)).
+		Doc("List the items waiting in the build queue of Jenkins with the reasons of the blockage, the item likely built first comes first. The queue is ordered by Jenkins and can't be reordered").
+		Returns(http.StatusOK, api.StatusOK, []queue.Item{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.DELETE("/devops/{devops}/queue/{item}").
+		To(handler.CancelQueueItem).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.PathParameter("item", "the id of the queued item").DataType("integer")).
+		Doc("Cancel the queued item, the pipeline run waiting for the item is aborted").
+		Returns(http.StatusOK, api.StatusOK, nil).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.GET("/devops/{devops}/agents").
+		To(handler.ListAgents).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Param(ws.QueryParameter("label", "only the agents having the label, e.g. maven").Required(false)).
+		Doc("List the agents of Jenkins and their executors with the busy or idle state, only the builds of the DevOps project are shown").
+		Returns(http.StatusOK, api.StatusOK, devopsmodel.AgentList{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsJenkinsTag}))
+
+	ws.Route(ws.POST("/devops/{devops}/cron/preview").
+		To(handler.PreviewCron).
+		Param(ws.PathParameter("devops", "the name of the DevOps project")).
+		Reads(devopsmodel.CronPreviewRequest{}).
+		Doc("Validate the Jenkins cron spec of the timer trigger and preview its last and next trigger times in the timezone").
+		Returns(http.StatusOK, api.StatusOK, devopsmodel.CronPreview{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	ws.Route(ws.POST("/admission/pipelines").
+		To(handler.ValidatePipeline).
+		Reads(admissionv1beta1.AdmissionReview{}).
+		Doc("The validating admission webhook of the pipelines, it rejects the pipelines with invalid cron of the timer trigger, or selecting the agents of other projects").
+		Returns(http.StatusOK, api.StatusOK, admissionv1beta1.AdmissionReview{}).
+		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsPipelineTag}))
+
+	c.Add(ws)
+	return nil
+}
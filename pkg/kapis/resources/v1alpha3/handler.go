@@ -47,6 +47,15 @@ func (h *Handler) handleListResources(request *restful.Request, response *restfu
 	resourceType := request.PathParameter("resources")
 	namespace := request.PathParameter("namespace")
 
+	if _, err := query.ContinueToken(); err != nil {
+		api.HandleBadRequest(response, request, err)
+		return
+	}
+	if _, err := query.Matcher(); err != nil {
+		api.HandleBadRequest(response, request, err)
+		return
+	}
+
 	result, err := h.resourceGetterV1alpha3.List(resourceType, namespace, query)
 	if err == nil {
 		response.WriteEntity(result)
@@ -55,7 +64,7 @@ func (h *Handler) handleListResources(request *restful.Request, response *restfu
 
 	if err != resourcev1alpha3.ErrResourceNotSupported {
 		klog.Error(err, resourceType)
-		api.HandleInternalError(response, request, err)
+		api.HandleError(response, request, err)
 		return
 	}
 
@@ -120,6 +129,19 @@ func (h *Handler) fallback(resourceType string, namespace string, q *query.Query
 		}
 	}
 
+	for _, requirement := range q.Requirements {
+		if requirement.Operator == query.OperatorIn {
+			switch requirement.Field {
+			case query.FieldNames:
+				requirement.Field = v1alpha2.Name
+			case query.FieldOwnerReference:
+				requirement.Field = v1alpha2.Owner
+			}
+		}
+		conditions.Requirements = append(conditions.Requirements, requirement)
+	}
+	conditions.FieldSelector = q.FieldSelector
+
 	result, err := h.resourcesGetterV1alpha2.ListResources(namespace, resourceType, conditions, orderBy, reverse, limit, offset)
 	if err != nil {
 		klog.Error(err)
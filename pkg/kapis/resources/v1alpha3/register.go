@@ -63,6 +63,7 @@ func AddToContainer(c *restful.Container, informerFactory informers.InformerFact
 		Param(webservice.QueryParameter(query.ParameterLimit, "limit").Required(false)).
 		Param(webservice.QueryParameter(query.ParameterAscending, "sort parameters, e.g. reverse=true").Required(false).DefaultValue("ascending=false")).
 		Param(webservice.QueryParameter(query.ParameterOrderBy, "sort parameters, e.g. orderBy=createTime")).
+		Param(webservice.QueryParameter(query.ParameterContinue, "the continue token returned with the previous page, the next page starts after the last item of it").Required(false)).
 		Returns(http.StatusOK, ok, api.ListResult{}))
 
 	c.Add(webservice)
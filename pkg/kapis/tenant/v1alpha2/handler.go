@@ -18,6 +18,7 @@ package v1alpha2
 
 import (
 	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/dispatch"
 	"devops.kubesphere.io/plugin/pkg/informers"
 	"fmt"
 	"k8s.io/client-go/kubernetes"
@@ -36,18 +37,30 @@ import (
 
 type tenantHandler struct {
 	tenant tenant.Interface
+	// aggregator lists the devops projects of the member clusters, it is nil in a single-cluster environment
+	aggregator dispatch.Aggregator
 }
 
 func newTenantHandler(factory informers.InformerFactory, k8sclient kubernetes.Interface,
-	ksclient kubesphere.Interface, authorizer authorizer.Authorizer, resourceGetter *resourcev1alpha3.ResourceGetter) *tenantHandler {
+	ksclient kubesphere.Interface, authorizer authorizer.Authorizer, resourceGetter *resourcev1alpha3.ResourceGetter,
+	aggregator dispatch.Aggregator) *tenantHandler {
 	return &tenantHandler{
-		tenant: tenant.New(factory, k8sclient, ksclient, authorizer, resourceGetter),
+		tenant:     tenant.New(factory, k8sclient, ksclient, authorizer, resourceGetter),
+		aggregator: aggregator,
 	}
 }
 
 func (h *tenantHandler) ListDevOpsProjects(req *restful.Request, resp *restful.Response) {
 	workspace := req.PathParameter("workspace")
 	queryParam := query.ParseQueryParameter(req)
+	if _, err := queryParam.ContinueToken(); err != nil {
+		api.HandleBadRequest(resp, nil, err)
+		return
+	}
+	if _, err := queryParam.Matcher(); err != nil {
+		api.HandleBadRequest(resp, nil, err)
+		return
+	}
 
 	var workspaceMember user.Info
 	if username := req.PathParameter("workspacemember"); username != "" {
@@ -66,11 +79,24 @@ func (h *tenantHandler) ListDevOpsProjects(req *restful.Request, resp *restful.R
 	}
 
 	fmt.Println(workspaceMember, workspace, queryParam)
-	result, err := h.tenant.ListDevOpsProjects(workspaceMember, workspace, queryParam)
+	// the host cluster is listed in full, the aggregator paginates it with the lists of the member clusters
+	hostQuery := queryParam
+	if h.aggregator != nil {
+		unpaginated := *queryParam
+		unpaginated.Pagination, unpaginated.Continue = query.NoPagination, ""
+		hostQuery = &unpaginated
+	}
+	result, err := h.tenant.ListDevOpsProjects(workspaceMember, workspace, hostQuery)
 	if err != nil {
-		api.HandleInternalError(resp, nil, err)
+		api.HandleError(resp, nil, err)
 		return
 	}
+	if h.aggregator != nil {
+		if err = h.aggregator.AggregateList(req.Request, queryParam, result); err != nil {
+			api.HandleError(resp, nil, err)
+			return
+		}
+	}
 
 	resp.WriteEntity(result)
 }
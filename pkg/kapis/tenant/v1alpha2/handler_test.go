@@ -0,0 +1,107 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+)
+
+type fakeTenant struct {
+	query *query.Query
+}
+
+func (t *fakeTenant) ListDevOpsProjects(user user.Info, workspace string, query *query.Query) (*api.ListResult, error) {
+	t.query = query
+	return &api.ListResult{Items: []interface{}{}}, nil
+}
+
+type fakeAggregator struct {
+	aggregated int
+	query      *query.Query
+}
+
+func (a *fakeAggregator) AggregateList(req *http.Request, q *query.Query, result *api.ListResult) error {
+	a.aggregated++
+	a.query = q
+	return nil
+}
+
+func TestListDevOpsProjectsAggregation(t *testing.T) {
+	token := query.NewContinueToken(&query.Query{SortBy: query.FieldCreationTimeStamp},
+		&metav1.ObjectMeta{Name: "project"}).Encode()
+	tests := []struct {
+		description string
+		queryString string
+		limit       int
+	}{
+		{description: "not paginated", queryString: "", limit: -1},
+		{description: "first page", queryString: "page=1&limit=10", limit: 10},
+		{description: "second page", queryString: "page=2&limit=10", limit: 10},
+		{description: "continued", queryString: "limit=10&continue=" + token, limit: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			tenant := &fakeTenant{}
+			aggregator := &fakeAggregator{}
+			h := &tenantHandler{tenant: tenant, aggregator: aggregator}
+
+			httpReq := httptest.NewRequest(http.MethodGet, "/workspaces/ws/devops?"+test.queryString, nil)
+			httpReq = httpReq.WithContext(request.WithUser(httpReq.Context(), &user.DefaultInfo{Name: "admin"}))
+			resp := restful.NewResponse(httptest.NewRecorder())
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.ListDevOpsProjects(restful.NewRequest(httpReq), resp)
+			if resp.StatusCode() != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", resp.StatusCode())
+			}
+
+			// every page is cut from the merged list of all clusters
+			if aggregator.aggregated != 1 || aggregator.query.Pagination.Limit != test.limit {
+				t.Errorf("expected the list is aggregated once and paginated by %d, got %d times by %v",
+					test.limit, aggregator.aggregated, aggregator.query.Pagination)
+			}
+			if tenant.query.Pagination.Limit != query.NoPagination.Limit || tenant.query.Continue != "" {
+				t.Errorf("expected the host cluster is listed in full, got %v", tenant.query.Pagination)
+			}
+		})
+	}
+}
+
+func TestListDevOpsProjectsInvalidFilter(t *testing.T) {
+	for _, queryString := range []string{"name=regex(%28foo)", "fieldSelector=spec.type"} {
+		h := &tenantHandler{tenant: &fakeTenant{}, aggregator: &fakeAggregator{}}
+
+		httpReq := httptest.NewRequest(http.MethodGet, "/workspaces/ws/devops?"+queryString, nil)
+		httpReq = httpReq.WithContext(request.WithUser(httpReq.Context(), &user.DefaultInfo{Name: "admin"}))
+		resp := restful.NewResponse(httptest.NewRecorder())
+		resp.SetRequestAccepts(restful.MIME_JSON)
+		h.ListDevOpsProjects(restful.NewRequest(httpReq), resp)
+		if resp.StatusCode() != http.StatusBadRequest {
+			t.Errorf("expected status 400 of %s, got %d", queryString, resp.StatusCode())
+		}
+	}
+}
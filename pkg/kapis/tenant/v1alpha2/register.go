@@ -29,6 +29,8 @@ import (
 
 	"devops.kubesphere.io/plugin/pkg/api"
 	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/dispatch"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
 	"devops.kubesphere.io/plugin/pkg/apiserver/runtime"
 	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
 	"devops.kubesphere.io/plugin/pkg/constants"
@@ -48,15 +50,18 @@ func Resource(resource string) schema.GroupResource {
 
 func AddToContainer(c *restful.Container, factory informers.InformerFactory, k8sclient kubernetes.Interface,
 	ksclient kubesphere.Interface, authorizer authorizer.Authorizer,
-	cache cache.Cache) error {
+	cache cache.Cache, aggregator dispatch.Aggregator) error {
 
 	ws := runtime.NewWebService(GroupVersion)
-	handler := newTenantHandler(factory, k8sclient, ksclient, authorizer, resourcev1alpha3.NewResourceGetter(factory, cache))
+	handler := newTenantHandler(factory, k8sclient, ksclient, authorizer, resourcev1alpha3.NewResourceGetter(factory, cache),
+		aggregator)
 
 	ws.Route(ws.GET("/workspaces/{workspace}/devops").
 		To(handler.ListDevOpsProjects).
 		Param(ws.PathParameter("workspace", "workspace name")).
-		Doc("List the devops projects of the specified workspace for the current user").
+		Param(ws.QueryParameter(query.ParameterContinue, "the continue token returned with the previous page").Required(false)).
+		Doc("List the devops projects of the specified workspace for the current user, the projects of the member "+
+			"clusters are included in a multi-cluster environment").
 		Returns(http.StatusOK, api.StatusOK, api.ListResult{}).
 		Metadata(restfulspec.KeyOpenAPITags, []string{constants.DevOpsProjectTag}))
 
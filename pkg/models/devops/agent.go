@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+// AgentList is the agents of Jenkins with the usage of their executors
+type AgentList struct {
+	Items []devops.Agent `json:"items"`
+	// TotalExecutors and BusyExecutors count the executors of the online agents
+	TotalExecutors int `json:"totalExecutors"`
+	BusyExecutors  int `json:"busyExecutors"`
+}
+
+type AgentOperator interface {
+	// ListAgents returns the agents of the Jenkins serving the project, only the agents having the label are
+	// returned if it is not empty
+	ListAgents(projectName, label string) (*AgentList, error)
+}
+
+type agentOperator struct {
+	devopsClient devops.Interface
+}
+
+func NewAgentOperator(devopsClient devops.Interface) AgentOperator {
+	return &agentOperator{devopsClient: devopsClient}
+}
+
+func (o *agentOperator) ListAgents(projectName, label string) (*AgentList, error) {
+	agents, err := o.devopsClient.ListAgents(projectName)
+	if err != nil {
+		klog.Error(err)
+		return nil, restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+	}
+
+	list := &AgentList{Items: make([]devops.Agent, 0, len(agents))}
+	for _, agent := range agents {
+		if label != "" && !hasLabel(agent, label) {
+			continue
+		}
+		list.Items = append(list.Items, agent)
+		if agent.Offline {
+			continue
+		}
+		for _, executor := range agent.Executors {
+			list.TotalExecutors++
+			if !executor.Idle {
+				list.BusyExecutors++
+			}
+		}
+	}
+	return list, nil
+}
+
+func hasLabel(agent devops.Agent, label string) bool {
+	if agent.Name == label {
+		return true
+	}
+	for _, l := range agent.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// agentLabelPattern finds the label expressions of the agent directives of the declarative pipelines and the node
+// steps of the scripted pipelines
+var agentLabelPattern = regexp.MustCompile(`\b(?:label|node)\s*\(?\s*['"]([^'"]+)['"]`)
+
+// ValidatePipelineAgents rejects the Jenkinsfile of the pipeline selecting the agents of the templates of other
+// projects. Only the Jenkinsfile saved in the pipeline is checked, the Jenkinsfiles in SCM are not available
+// to the plugin.
+func ValidatePipelineAgents(ksclient kubesphere.Interface, pipeline *devopsv1alpha3.Pipeline) error {
+	if pipeline.Spec.Pipeline == nil || pipeline.Spec.Pipeline.Jenkinsfile == "" {
+		return nil
+	}
+
+	// the labels of agent templates are prefixed with their namespace
+	labels := map[string]sets.String{}
+	for _, match := range agentLabelPattern.FindAllStringSubmatch(pipeline.Spec.Pipeline.Jenkinsfile, -1) {
+		atoms := strings.FieldsFunc(match[1], func(r rune) bool {
+			return strings.ContainsRune(" \t\n&|!()<>=\"", r)
+		})
+		for _, atom := range atoms {
+			namespace := strings.SplitN(atom, ".", 2)[0]
+			if namespace == atom || namespace == pipeline.Namespace {
+				continue
+			}
+			if labels[namespace] == nil {
+				labels[namespace] = sets.NewString()
+			}
+			labels[namespace].Insert(atom)
+		}
+	}
+
+	for _, namespace := range sets.StringKeySet(labels).List() {
+		agentTemplates, err := ksclient.DevopsV1alpha3().AgentTemplates(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			klog.Error(err)
+			return err
+		}
+		for _, agentTemplate := range agentTemplates.Items {
+			for _, label := range agentTemplate.Spec.Labels {
+				if agentLabel := devopsv1alpha3.AgentLabel(namespace, label); labels[namespace].Has(agentLabel) {
+					return fmt.Errorf("agent label '%s' belongs to another DevOps project", agentLabel)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+)
+
+func TestListAgents(t *testing.T) {
+	client := fakedevops.New("project")
+	client.Agents = []devops.Agent{
+		{Name: "master", Idle: true, NumExecutors: 1, Executors: []devops.Executor{{Number: 0, Idle: true}}},
+		{Name: "maven-x1b2c", Labels: []string{"maven"}, NumExecutors: 2, Executors: []devops.Executor{
+			{Number: 0, Pipeline: "pipeline", RunID: "3"},
+			{Number: 1, Idle: true},
+		}},
+		{Name: "maven-f9k3m", Labels: []string{"maven"}, Offline: true, NumExecutors: 1, Executors: []devops.Executor{
+			{Number: 0},
+		}},
+	}
+	operator := NewAgentOperator(client)
+
+	tests := []struct {
+		label string
+		names []string
+		total int
+		busy  int
+	}{
+		{label: "", names: []string{"master", "maven-x1b2c", "maven-f9k3m"}, total: 3, busy: 1},
+		{label: "maven", names: []string{"maven-x1b2c", "maven-f9k3m"}, total: 2, busy: 1},
+		{label: "master", names: []string{"master"}, total: 1, busy: 0},
+		{label: "go", names: []string{}, total: 0, busy: 0},
+	}
+	for _, test := range tests {
+		list, err := operator.ListAgents("project", test.label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, agent := range list.Items {
+			names = append(names, agent.Name)
+		}
+		if len(names) != len(test.names) || list.TotalExecutors != test.total || list.BusyExecutors != test.busy {
+			t.Errorf("label %q: expected %v with %d/%d busy executors, got %v with %d/%d", test.label,
+				test.names, test.busy, test.total, names, list.BusyExecutors, list.TotalExecutors)
+			continue
+		}
+		for i := range names {
+			if names[i] != test.names[i] {
+				t.Errorf("label %q: expected %v, got %v", test.label, test.names, names)
+			}
+		}
+	}
+}
+
+func TestValidatePipelineAgents(t *testing.T) {
+	ksclient := fake.NewSimpleClientset(&devopsv1alpha3.AgentTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "maven", Namespace: "other"},
+		Spec:       devopsv1alpha3.AgentTemplateSpec{Labels: []string{"mvn"}},
+	})
+	newPipeline := func(jenkinsfile string) *devopsv1alpha3.Pipeline {
+		return &devopsv1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: "project"},
+			Spec: devopsv1alpha3.PipelineSpec{
+				Type:     devopsv1alpha3.NoScmPipelineType,
+				Pipeline: &devopsv1alpha3.NoScmPipeline{Name: "pipeline", Jenkinsfile: jenkinsfile},
+			},
+		}
+	}
+
+	tests := []struct {
+		jenkinsfile string
+		valid       bool
+	}{
+		{jenkinsfile: "pipeline { agent { label 'project.mvn' } }", valid: true},
+		{jenkinsfile: "pipeline { agent { label 'maven && ubuntu-20.04' } }", valid: true},
+		{jenkinsfile: "pipeline { agent { label 'other.gradle' } }", valid: true},
+		{jenkinsfile: "pipeline { agent { label 'other.mvn' } }", valid: false},
+		{jenkinsfile: "node(\"base || other.mvn\") { sh 'make' }", valid: false},
+	}
+	for _, test := range tests {
+		if err := ValidatePipelineAgents(ksclient, newPipeline(test.jenkinsfile)); (err == nil) != test.valid {
+			t.Errorf("expected valid %v of %s, got %v", test.valid, test.jenkinsfile, err)
+		}
+	}
+}
@@ -0,0 +1,723 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins"
+	devopslister "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha3"
+	devopsmodel "devops.kubesphere.io/plugin/pkg/models/devops"
+)
+
+const (
+	// DefaultMaxRecords is the number of the latest decided inputs kept in the annotation of each pipeline
+	DefaultMaxRecords = 20
+	// DefaultSyncPeriod is the period to look for the new and the expired pending inputs
+	DefaultSyncPeriod = time.Minute
+
+	// maxConcurrentPipelines bounds the pipelines whose runs are fetched from Jenkins at the same time
+	maxConcurrentPipelines = 10
+)
+
+// The states of an input
+const (
+	StatePending   = "pending"
+	StateProceeded = "proceeded"
+	StateAborted   = "aborted"
+	StateExpired   = "expired"
+)
+
+// The reasons of the events recorded on the pipelines
+const (
+	EventReasonInputPending   = "InputPending"
+	EventReasonInputApproved  = "InputApproved"
+	EventReasonInputProceeded = "InputProceeded"
+	EventReasonInputAborted   = "InputAborted"
+	EventReasonInputExpired   = "InputExpired"
+)
+
+// InputRef locates the input step of a pipeline run, the branch is empty for the regular pipelines
+type InputRef struct {
+	Namespace string `json:"namespace"`
+	Pipeline  string `json:"pipeline"`
+	Branch    string `json:"branch,omitempty"`
+	Run       string `json:"run"`
+	Node      string `json:"node"`
+	Step      string `json:"step"`
+}
+
+func (r InputRef) String() string {
+	if r.Branch != "" {
+		return fmt.Sprintf("%s/%s/%s#%s", r.Namespace, r.Pipeline, r.Branch, r.Run)
+	}
+	return fmt.Sprintf("%s/%s#%s", r.Namespace, r.Pipeline, r.Run)
+}
+
+type Approval struct {
+	Approver string    `json:"approver"`
+	Comment  string    `json:"comment,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// InputApproval is an input step with the approvals it got
+type InputApproval struct {
+	InputRef
+	InputID    string        `json:"inputId"`
+	Message    string        `json:"message,omitempty"`
+	Submitters []string      `json:"submitters,omitempty"`
+	Parameters []interface{} `json:"parameters,omitempty"`
+	// Quorum is the number of the distinct approvers required to proceed the input
+	Quorum    int        `json:"quorum"`
+	Approvals []Approval `json:"approvals"`
+	StartTime *time.Time `json:"startTime,omitempty"`
+	// ExpiresAt is the time the input is aborted if it is still pending
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	State     string     `json:"state"`
+	// DecidedBy is the user who proceeded the input with the last approval, or aborted it
+	DecidedBy string     `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// pendingApprovals is the approvals of a pending input kept in the annotation of its pipeline
+type pendingApprovals struct {
+	InputRef
+	Approvals []Approval `json:"approvals"`
+}
+
+type InputParameter struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// Submission is the decision of a user on a pending input
+type Submission struct {
+	// Abort aborts the input, otherwise the input is approved
+	Abort   bool   `json:"abort,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	// Parameters are the values of the parameters of the input, they are sent to Jenkins with the approval reaching
+	// the quorum
+	Parameters []InputParameter `json:"parameters,omitempty"`
+}
+
+type Operator interface {
+	// ListPendingInputs returns the pending inputs the user can approve, in all the DevOps projects or in the given one.
+	// The inputs are listed from the last sync, so the inputs paused since then are not listed yet.
+	ListPendingInputs(user user.Info, namespace string) ([]InputApproval, error)
+
+	// Submit approves or aborts the pending input as the user. Jenkins proceeds the input once the quorum is reached.
+	Submit(user user.Info, ref InputRef, submission *Submission) (*InputApproval, error)
+
+	// ListRecords returns the latest decided inputs of the DevOps project, the newest first
+	ListRecords(user user.Info, namespace string, limit int) ([]InputApproval, error)
+
+	// Run syncs the pending inputs periodically until stopCh is closed
+	Run(period time.Duration, stopCh <-chan struct{})
+
+	// Lead notifies the new pending inputs and aborts the expired ones on the syncs until stopCh is closed. It is only
+	// called on the replica holding the lease of the controllers, so every input is notified and aborted once.
+	Lead(stopCh <-chan struct{})
+}
+
+// operator keeps the approvals and the decided inputs in the annotations of the pipelines, the updates of the
+// annotations are serialized by the resource version, so an input reaching the quorum is proceeded only once
+type operator struct {
+	devopsClient   devops.Interface
+	ksClient       kubesphere.Interface
+	buildGraph     devopsmodel.BuildGraphOperator
+	pipelineLister devopslister.PipelineLister
+	authorizer     authorizer.Authorizer
+	recorder       record.EventRecorder
+	now            func() time.Time
+	maxRecords     int
+
+	// leading is set while this replica holds the lease of the controllers
+	leading int32
+
+	lock sync.Mutex
+	// the pending inputs seen by this apiserver, they are only notified by the leader
+	notified map[InputRef]bool
+	// pending is the snapshot of the pending inputs taken by the last sync, the oldest first
+	pending []InputApproval
+}
+
+func NewOperator(devopsClient devops.Interface, ksClient kubesphere.Interface, pipelineLister devopslister.PipelineLister,
+	authorizer authorizer.Authorizer, recorder record.EventRecorder, maxRecords int) Operator {
+	return &operator{
+		devopsClient:   devopsClient,
+		ksClient:       ksClient,
+		buildGraph:     devopsmodel.NewBuildGraphOperator(devopsClient),
+		pipelineLister: pipelineLister,
+		authorizer:     authorizer,
+		recorder:       recorder,
+		now:            time.Now,
+		maxRecords:     maxRecords,
+		notified:       make(map[InputRef]bool),
+	}
+}
+
+func (o *operator) ListPendingInputs(user user.Info, namespace string) ([]InputApproval, error) {
+	o.lock.Lock()
+	pending := o.pending
+	o.lock.Unlock()
+
+	// the authorization is decided by the DevOps project
+	allowed := make(map[string]bool)
+	approvable := make([]InputApproval, 0)
+	for _, input := range pending {
+		if namespace != "" && input.Namespace != namespace {
+			continue
+		}
+		ok, checked := allowed[input.Namespace]
+		if !checked {
+			ok = devopsmodel.AuthorizePipelines(o.authorizer, user, "update", input.Namespace) == nil
+			allowed[input.Namespace] = ok
+		}
+		if !ok {
+			continue
+		}
+		// the approvals submitted since the sync are read from the pipeline
+		pipeline, err := o.pipelineLister.Pipelines(input.Namespace).Get(input.Pipeline)
+		if err != nil {
+			continue
+		}
+		input.Approvals = append(make([]Approval, 0), approvalsOf(pipeline)[input.InputRef]...)
+		if isSubmitter(user, input.Submitters) && !hasApproved(user, input.Approvals) {
+			approvable = append(approvable, input)
+		}
+	}
+	return approvable, nil
+}
+
+func (o *operator) Submit(user user.Info, ref InputRef, submission *Submission) (*InputApproval, error) {
+	if err := devopsmodel.AuthorizePipelines(o.authorizer, user, "update", ref.Namespace); err != nil {
+		return nil, err
+	}
+	pipeline, err := o.pipelineLister.Pipelines(ref.Namespace).Get(ref.Pipeline)
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	input, err := o.findPendingInput(pipeline, ref)
+	if err != nil {
+		return nil, err
+	}
+	if !isSubmitter(user, input.Submitters) {
+		return nil, restful.NewError(http.StatusForbidden,
+			fmt.Sprintf("user %s is not one of the submitters of the input", user.GetName()))
+	}
+
+	now := o.now()
+	if submission.Abort {
+		if err := o.submitToJenkins(ref, map[string]interface{}{"id": input.InputID, "abort": true}); err != nil {
+			return nil, err
+		}
+		if err := o.decide(input, StateAborted, user.GetName(), submission.Comment, now); err != nil {
+			return nil, err
+		}
+		o.recorder.Eventf(pipeline, corev1.EventTypeNormal, EventReasonInputAborted, "The input of %s is aborted by %s",
+			ref, user.GetName())
+		return input, nil
+	}
+
+	// the approval is persisted before Jenkins is called, so only the approval reaching the quorum proceeds the input
+	err = o.updateInputs(ref.Namespace, ref.Pipeline, func(approvals map[InputRef][]Approval, records *[]InputApproval) error {
+		current := approvals[ref]
+		if hasApproved(user, current) {
+			return restful.NewError(http.StatusConflict,
+				fmt.Sprintf("user %s has approved the input", user.GetName()))
+		}
+		if len(current) >= input.Quorum {
+			return restful.NewError(http.StatusConflict, "the input has reached the quorum")
+		}
+		input.Approvals = append(append(make([]Approval, 0), current...),
+			Approval{Approver: user.GetName(), Comment: submission.Comment, Time: now})
+		approvals[ref] = input.Approvals
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Approvals) < input.Quorum {
+		o.recorder.Eventf(pipeline, corev1.EventTypeNormal, EventReasonInputApproved, "The input of %s is approved by %s, %d/%d",
+			ref, user.GetName(), len(input.Approvals), input.Quorum)
+		return input, nil
+	}
+
+	parameters := submission.Parameters
+	if parameters == nil {
+		parameters = make([]InputParameter, 0)
+	}
+	if err := o.submitToJenkins(ref, map[string]interface{}{"id": input.InputID, "parameters": parameters}); err != nil {
+		// the approval is withdrawn, so it can be submitted again
+		o.updateInputs(ref.Namespace, ref.Pipeline, func(approvals map[InputRef][]Approval, records *[]InputApproval) error {
+			approvals[ref] = withoutApprover(approvals[ref], user.GetName())
+			return nil
+		})
+		return nil, err
+	}
+	if err := o.decide(input, StateProceeded, user.GetName(), "", now); err != nil {
+		return nil, err
+	}
+	o.recorder.Eventf(pipeline, corev1.EventTypeNormal, EventReasonInputProceeded, "The input of %s is proceeded by %s",
+		ref, user.GetName())
+	return input, nil
+}
+
+func (o *operator) ListRecords(user user.Info, namespace string, limit int) ([]InputApproval, error) {
+	if err := devopsmodel.AuthorizePipelines(o.authorizer, user, "get", namespace); err != nil {
+		return nil, err
+	}
+	pipelines, err := o.pipelineLister.Pipelines(namespace).List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	records := make([]InputApproval, 0)
+	for _, pipeline := range pipelines {
+		records = append(records, recordsOf(pipeline)...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].DecidedAt.After(*records[j].DecidedAt)
+	})
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+func (o *operator) Run(period time.Duration, stopCh <-chan struct{}) {
+	klog.Info("starting the input approvals")
+	wait.Until(o.sync, period, stopCh)
+	klog.Info("shutting down the input approvals")
+}
+
+func (o *operator) Lead(stopCh <-chan struct{}) {
+	atomic.StoreInt32(&o.leading, 1)
+	defer atomic.StoreInt32(&o.leading, 0)
+	<-stopCh
+}
+
+// sync takes the snapshot of the pending inputs. The leader also notifies the inputs pending since the last sync,
+// aborts the expired ones, and forgets the approvals of the inputs decided in Jenkins directly.
+func (o *operator) sync() {
+	leading := atomic.LoadInt32(&o.leading) == 1
+	pipelines, err := o.pipelineLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	results := make([][]InputApproval, len(pipelines))
+	errs := make([]error, len(pipelines))
+	workqueue.ParallelizeUntil(context.Background(), maxConcurrentPipelines, len(pipelines), func(i int) {
+		results[i], errs[i] = o.pendingInputs(pipelines[i])
+	})
+
+	pending := make(map[InputRef]bool)
+	failed := make(map[string]bool)
+	snapshot := make([]InputApproval, 0)
+	for i, pipeline := range pipelines {
+		if errs[i] != nil {
+			klog.Error(errs[i])
+			failed[pipeline.Namespace+"/"+pipeline.Name] = true
+			continue
+		}
+		for j := range results[i] {
+			input := &results[i][j]
+			pending[input.InputRef] = true
+			if input.ExpiresAt != nil && o.now().After(*input.ExpiresAt) {
+				if leading {
+					o.expire(pipeline, input)
+				}
+				continue
+			}
+			snapshot = append(snapshot, *input)
+			o.lock.Lock()
+			notified := o.notified[input.InputRef]
+			o.notified[input.InputRef] = true
+			o.lock.Unlock()
+			if !notified && leading {
+				o.recorder.Eventf(pipeline, corev1.EventTypeNormal, EventReasonInputPending,
+					"The input of %s is waiting for the approval of %v: %s", input.InputRef, input.Submitters, input.Message)
+			}
+		}
+
+		if !leading {
+			continue
+		}
+		stale := false
+		for ref := range approvalsOf(pipeline) {
+			stale = stale || !pending[ref]
+		}
+		if stale {
+			o.updateInputs(pipeline.Namespace, pipeline.Name, func(approvals map[InputRef][]Approval, records *[]InputApproval) error {
+				for ref := range approvals {
+					if !pending[ref] {
+						delete(approvals, ref)
+					}
+				}
+				return nil
+			})
+		}
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for ref := range o.notified {
+		if !pending[ref] && !failed[ref.Namespace+"/"+ref.Pipeline] {
+			delete(o.notified, ref)
+		}
+	}
+	// the inputs of the pipelines failing in Jenkins are kept until the next sync
+	for _, input := range o.pending {
+		if failed[input.Namespace+"/"+input.Pipeline] {
+			snapshot = append(snapshot, input)
+		}
+	}
+	sort.SliceStable(snapshot, func(i, j int) bool {
+		if snapshot[i].StartTime == nil || snapshot[j].StartTime == nil {
+			return snapshot[i].StartTime != nil
+		}
+		return snapshot[i].StartTime.Before(*snapshot[j].StartTime)
+	})
+	o.pending = snapshot
+}
+
+func (o *operator) expire(pipeline *devopsv1alpha3.Pipeline, input *InputApproval) {
+	if err := o.submitToJenkins(input.InputRef, map[string]interface{}{"id": input.InputID, "abort": true}); err != nil {
+		klog.Error(err)
+		return
+	}
+	timeout := input.ExpiresAt.Sub(*input.StartTime)
+	if err := o.decide(input, StateExpired, "", fmt.Sprintf("the input is not approved in %s", timeout), o.now()); err != nil {
+		return
+	}
+	o.recorder.Eventf(pipeline, corev1.EventTypeWarning, EventReasonInputExpired,
+		"The input of %s is aborted, it is not approved in %s", input.InputRef, timeout)
+}
+
+// decide records the decided input in its pipeline and forgets its approvals
+func (o *operator) decide(input *InputApproval, state, decidedBy, reason string, now time.Time) error {
+	input.State = state
+	input.DecidedBy = decidedBy
+	input.DecidedAt = &now
+	input.Reason = reason
+	o.lock.Lock()
+	delete(o.notified, input.InputRef)
+	// the snapshot is shared with the listings, so it is copied
+	pending := make([]InputApproval, 0, len(o.pending))
+	for _, item := range o.pending {
+		if item.InputRef != input.InputRef {
+			pending = append(pending, item)
+		}
+	}
+	o.pending = pending
+	o.lock.Unlock()
+
+	record := *input
+	// the parameters of the input are not needed to audit the decision
+	record.Parameters = nil
+	return o.updateInputs(input.Namespace, input.Pipeline, func(approvals map[InputRef][]Approval, records *[]InputApproval) error {
+		delete(approvals, input.InputRef)
+		*records = append(*records, record)
+		if len(*records) > o.maxRecords {
+			*records = (*records)[len(*records)-o.maxRecords:]
+		}
+		return nil
+	})
+}
+
+// updateInputs updates the approvals and the records in the annotations of the pipeline, mutate is called again
+// with the latest pipeline on conflicts, and its errors are returned as is
+func (o *operator) updateInputs(namespace, name string, mutate func(approvals map[InputRef][]Approval, records *[]InputApproval) error) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pipeline, err := o.ksClient.DevopsV1alpha3().Pipelines(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		approvals, records := approvalsOf(pipeline), recordsOf(pipeline)
+		if err = mutate(approvals, &records); err != nil {
+			return err
+		}
+
+		pipeline = pipeline.DeepCopy()
+		if pipeline.Annotations == nil {
+			pipeline.Annotations = map[string]string{}
+		}
+		pending := encodeApprovals(approvals)
+		if err = setAnnotation(pipeline, devopsv1alpha3.PipelineInputApprovalsAnnoKey, pending, len(pending) == 0); err != nil {
+			return err
+		}
+		if err = setAnnotation(pipeline, devopsv1alpha3.PipelineInputRecordsAnnoKey, records, len(records) == 0); err != nil {
+			return err
+		}
+		_, err = o.ksClient.DevopsV1alpha3().Pipelines(namespace).Update(context.Background(), pipeline, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		klog.Error(err)
+		if _, ok := err.(restful.ServiceError); ok {
+			return err
+		}
+		return restful.NewError(http.StatusInternalServerError, err.Error())
+	}
+	return nil
+}
+
+// pendingInputs returns the inputs waiting in the paused runs of the pipeline
+func (o *operator) pendingInputs(pipeline *devopsv1alpha3.Pipeline) ([]InputApproval, error) {
+	httpParameters := devops.NewHttpParameters(http.MethodGet, nil)
+	httpParameters.Url.RawQuery = "start=0&limit=100"
+	runs, err := o.devopsClient.ListPipelineRuns(pipeline.Namespace, pipeline.Name, httpParameters)
+	if err != nil {
+		return nil, restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+	}
+
+	inputs := make([]InputApproval, 0)
+	for _, run := range runs.Items {
+		if run.State != devops.StatePaused {
+			continue
+		}
+		ref := InputRef{Namespace: pipeline.Namespace, Pipeline: pipeline.Name, Run: run.ID}
+		// the runs of a multi-branch pipeline are the runs of all its branches
+		if pipeline.Spec.Type == devopsv1alpha3.MultiBranchPipelineType {
+			ref.Branch = run.Pipeline
+		}
+		graph, err := o.getGraph(ref)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, o.inputsOfGraph(pipeline, ref, graph)...)
+	}
+	return inputs, nil
+}
+
+func (o *operator) findPendingInput(pipeline *devopsv1alpha3.Pipeline, ref InputRef) (*InputApproval, error) {
+	graph, err := o.getGraph(ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, input := range o.inputsOfGraph(pipeline, ref, graph) {
+		if input.InputRef == ref {
+			return &input, nil
+		}
+	}
+	return nil, restful.NewError(http.StatusNotFound, fmt.Sprintf("no pending input found in step %s of node %s", ref.Step, ref.Node))
+}
+
+func (o *operator) getGraph(ref InputRef) (*devopsmodel.BuildGraph, error) {
+	if ref.Branch != "" {
+		return o.buildGraph.GetBranchPipelineRunGraph(ref.Namespace, ref.Pipeline, ref.Branch, ref.Run)
+	}
+	return o.buildGraph.GetPipelineRunGraph(ref.Namespace, ref.Pipeline, ref.Run)
+}
+
+func (o *operator) inputsOfGraph(pipeline *devopsv1alpha3.Pipeline, ref InputRef, graph *devopsmodel.BuildGraph) []InputApproval {
+	quorum, timeout := inputPolicy(pipeline)
+	approvals := approvalsOf(pipeline)
+	inputs := make([]InputApproval, 0)
+	for _, node := range graph.Nodes {
+		for _, step := range node.Steps {
+			if !step.InputPending {
+				continue
+			}
+			input := InputApproval{
+				InputRef:   ref,
+				InputID:    step.Input.ID,
+				Message:    step.Input.Message,
+				Submitters: submitters(step.Input),
+				Parameters: step.Input.Parameters,
+				Quorum:     quorum,
+				State:      StatePending,
+			}
+			input.Node, input.Step = node.ID, step.ID
+			if startTime, err := jenkins.ParseJenkinsBlueTime(step.StartTime); err == nil {
+				start := time.Time(startTime)
+				input.StartTime = &start
+				if timeout > 0 {
+					expiresAt := start.Add(timeout)
+					input.ExpiresAt = &expiresAt
+				}
+			}
+			input.Approvals = append(make([]Approval, 0), approvals[input.InputRef]...)
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+func (o *operator) submitToJenkins(ref InputRef, body map[string]interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	httpParameters := devops.NewHttpParameters(http.MethodPost, data)
+	if ref.Branch != "" {
+		_, err = o.devopsClient.SubmitBranchInputStep(ref.Namespace, ref.Pipeline, ref.Branch, ref.Run, ref.Node, ref.Step, httpParameters)
+	} else {
+		_, err = o.devopsClient.SubmitInputStep(ref.Namespace, ref.Pipeline, ref.Run, ref.Node, ref.Step, httpParameters)
+	}
+	if err != nil {
+		klog.Error(err)
+		return restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+	}
+	return nil
+}
+
+// approvalsOf returns the approvals of the pending inputs of the pipeline, the invalid annotation is ignored
+func approvalsOf(pipeline *devopsv1alpha3.Pipeline) map[InputRef][]Approval {
+	approvals := make(map[InputRef][]Approval)
+	value, ok := pipeline.Annotations[devopsv1alpha3.PipelineInputApprovalsAnnoKey]
+	if !ok {
+		return approvals
+	}
+	var pending []pendingApprovals
+	if err := json.Unmarshal([]byte(value), &pending); err != nil {
+		klog.Warningf("invalid input approvals of pipeline %s/%s: %v", pipeline.Namespace, pipeline.Name, err)
+		return approvals
+	}
+	for _, item := range pending {
+		approvals[item.InputRef] = item.Approvals
+	}
+	return approvals
+}
+
+// recordsOf returns the decided inputs of the pipeline, the oldest first, the invalid annotation is ignored
+func recordsOf(pipeline *devopsv1alpha3.Pipeline) []InputApproval {
+	records := make([]InputApproval, 0)
+	value, ok := pipeline.Annotations[devopsv1alpha3.PipelineInputRecordsAnnoKey]
+	if !ok {
+		return records
+	}
+	if err := json.Unmarshal([]byte(value), &records); err != nil {
+		klog.Warningf("invalid input records of pipeline %s/%s: %v", pipeline.Namespace, pipeline.Name, err)
+		return make([]InputApproval, 0)
+	}
+	return records
+}
+
+func encodeApprovals(approvals map[InputRef][]Approval) []pendingApprovals {
+	pending := make([]pendingApprovals, 0, len(approvals))
+	for ref, items := range approvals {
+		if len(items) > 0 {
+			pending = append(pending, pendingApprovals{InputRef: ref, Approvals: items})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Approvals[0].Time.Before(pending[j].Approvals[0].Time)
+	})
+	return pending
+}
+
+// setAnnotation sets the annotation to the value in JSON, the annotation of an empty value is removed
+func setAnnotation(pipeline *devopsv1alpha3.Pipeline, key string, value interface{}, empty bool) error {
+	if empty {
+		delete(pipeline.Annotations, key)
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	pipeline.Annotations[key] = string(data)
+	return nil
+}
+
+// inputPolicy returns the quorum and the timeout of the inputs of the pipeline, the invalid annotations are ignored
+func inputPolicy(pipeline *devopsv1alpha3.Pipeline) (quorum int, timeout time.Duration) {
+	quorum = 1
+	if value, ok := pipeline.Annotations[devopsv1alpha3.PipelineInputQuorumAnnoKey]; ok {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			quorum = n
+		} else {
+			klog.Warningf("invalid input quorum %q of pipeline %s/%s", value, pipeline.Namespace, pipeline.Name)
+		}
+	}
+	if value, ok := pipeline.Annotations[devopsv1alpha3.PipelineInputTimeoutAnnoKey]; ok {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			timeout = d
+		} else {
+			klog.Warningf("invalid input timeout %q of pipeline %s/%s", value, pipeline.Namespace, pipeline.Name)
+		}
+	}
+	return
+}
+
+func submitters(input *devops.Input) []string {
+	result := make([]string, 0)
+	for _, submitter := range input.GetSubmitters() {
+		if submitter != "" {
+			result = append(result, submitter)
+		}
+	}
+	return result
+}
+
+// isSubmitter checks if the user can approve the input, anyone allowed to update the pipelines can approve the inputs
+// without submitters
+func isSubmitter(user user.Info, submitters []string) bool {
+	if len(submitters) == 0 {
+		return true
+	}
+	input := &devops.Input{Submitter: strings.Join(submitters, ",")}
+	return input.ApprovableBy(user.GetName(), user.GetGroups())
+}
+
+func withoutApprover(approvals []Approval, approver string) []Approval {
+	result := make([]Approval, 0, len(approvals))
+	for _, approval := range approvals {
+		if approval.Approver != approver {
+			result = append(result, approval)
+		}
+	}
+	return result
+}
+
+func hasApproved(user user.Info, approvals []Approval) bool {
+	for _, approval := range approvals {
+		if approval.Approver == user.GetName() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package approval
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+// inputDevops has a paused run 1 of pipeline deploy in each project, waiting for the input in step 15 of node 5
+// until the input is submitted
+type inputDevops struct {
+	*fakedevops.Devops
+	submitted   map[string]bool
+	submissions []string
+}
+
+func (d *inputDevops) ListPipelineRuns(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.PipelineRunList, error) {
+	run := devops.PipelineRun{ID: "1", Pipeline: pipelineName, State: devops.StatePaused}
+	if d.submitted[projectName] {
+		run.State = "FINISHED"
+	}
+	return &devops.PipelineRunList{Items: []devops.PipelineRun{run, {ID: "2", Pipeline: pipelineName, State: "FINISHED"}}}, nil
+}
+
+func (d *inputDevops) SubmitInputStep(projectName, pipelineName, runId, nodeId, stepId string, httpParameters *devops.HttpParameters) ([]byte, error) {
+	body, _ := ioutil.ReadAll(httpParameters.Body)
+	d.submitted[projectName] = true
+	d.submissions = append(d.submissions, strings.Join([]string{projectName, pipelineName, runId, nodeId, stepId, string(body)}, " "))
+	return nil, nil
+}
+
+func newInputDevops(startTime string, projects ...string) *inputDevops {
+	client := &inputDevops{Devops: fakedevops.New(projects...), submitted: map[string]bool{}}
+	client.Data = map[string]interface{}{}
+	for _, project := range projects {
+		input := &devops.Input{ID: "approve", Message: "Deploy to production?", Submitter: "admin, release-managers"}
+		client.Data[project+"-deploy-1"] = []devops.PipelineRunNodes{{ID: "5", DisplayName: "Deploy", Type: "STAGE", State: devops.StatePaused}}
+		client.Data[project+"-deploy-1-5"] = []devops.NodeSteps{{ID: "15", State: devops.StatePaused, StartTime: startTime, Input: input}}
+	}
+	return client
+}
+
+func newTestOperator(client devops.Interface, deniedProjects []string, pipelines ...*devopsv1alpha3.Pipeline) (*operator, *record.FakeRecorder) {
+	objects := make([]runtime.Object, 0, len(pipelines))
+	for _, pipeline := range pipelines {
+		objects = append(objects, pipeline)
+	}
+	return newTestOperatorWithClient(client, fake.NewSimpleClientset(objects...), deniedProjects)
+}
+
+// newTestOperatorWithClient lists the pipelines of ksClient, the updated pipelines are listed at once
+func newTestOperatorWithClient(client devops.Interface, ksClient *fake.Clientset, deniedProjects []string) (*operator, *record.FakeRecorder) {
+	informerFactory := ksinformers.NewSharedInformerFactory(ksClient, 0)
+	indexer := informerFactory.Devops().V1alpha3().Pipelines().Informer().GetIndexer()
+	pipelines, _ := ksClient.DevopsV1alpha3().Pipelines("").List(context.Background(), metav1.ListOptions{})
+	for i := range pipelines.Items {
+		indexer.Add(&pipelines.Items[i])
+	}
+	ksClient.PrependReactor("update", "pipelines", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		indexer.Update(action.(k8stesting.UpdateAction).GetObject())
+		return false, nil, nil
+	})
+	authorize := authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+		for _, project := range deniedProjects {
+			if a.GetDevOps() == project {
+				return authorizer.DecisionDeny, "not a member", nil
+			}
+		}
+		return authorizer.DecisionAllow, "", nil
+	})
+	recorder := record.NewFakeRecorder(10)
+	o := NewOperator(client, ksClient, informerFactory.Devops().V1alpha3().Pipelines().Lister(), authorize, recorder, DefaultMaxRecords)
+	return o.(*operator), recorder
+}
+
+func newPipeline(namespace string, annotations map[string]string) *devopsv1alpha3.Pipeline {
+	return &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: namespace, Annotations: annotations},
+		Spec:       devopsv1alpha3.PipelineSpec{Type: devopsv1alpha3.NoScmPipelineType},
+	}
+}
+
+func expectStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	if serviceErr, ok := err.(restful.ServiceError); !ok || serviceErr.Code != status {
+		t.Fatalf("expected status %d, got %v", status, err)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	client := newInputDevops("2020-06-01T10:00:00.000+0000", "project")
+	o, recorder := newTestOperator(client, nil,
+		newPipeline("project", map[string]string{devopsv1alpha3.PipelineInputQuorumAnnoKey: "2"}))
+	ref := InputRef{Namespace: "project", Pipeline: "deploy", Run: "1", Node: "5", Step: "15"}
+	rick := &user.DefaultInfo{Name: "rick", Groups: []string{"release-managers"}}
+
+	input, err := o.Submit(rick, ref, &Submission{Comment: "LGTM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.State != StatePending || len(input.Approvals) != 1 || input.Approvals[0].Comment != "LGTM" || len(client.submissions) != 0 {
+		t.Fatalf("expected the input is pending with one approval, got %+v", input)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, EventReasonInputApproved) {
+		t.Errorf("expected event %s, got %s", EventReasonInputApproved, event)
+	}
+
+	_, err = o.Submit(rick, ref, &Submission{})
+	expectStatus(t, err, http.StatusConflict)
+	_, err = o.Submit(&user.DefaultInfo{Name: "morty", Groups: []string{"developers"}}, ref, &Submission{})
+	expectStatus(t, err, http.StatusForbidden)
+	_, err = o.Submit(&user.DefaultInfo{Name: "admin"}, InputRef{Namespace: "project", Pipeline: "deploy", Run: "1", Node: "5", Step: "16"}, &Submission{})
+	expectStatus(t, err, http.StatusNotFound)
+
+	input, err = o.Submit(&user.DefaultInfo{Name: "admin"}, ref, &Submission{
+		Parameters: []InputParameter{{Name: "version", Value: "v1.0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.State != StateProceeded || input.DecidedBy != "admin" || len(input.Approvals) != 2 {
+		t.Errorf("expected the input is proceeded by admin, got %+v", input)
+	}
+	expected := `project deploy 1 5 15 {"id":"approve","parameters":[{"name":"version","value":"v1.0"}]}`
+	if len(client.submissions) != 1 || client.submissions[0] != expected {
+		t.Errorf("expected the submission %s, got %v", expected, client.submissions)
+	}
+
+	records, err := o.ListRecords(rick, "project", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].State != StateProceeded || len(records[0].Approvals) != 2 {
+		t.Errorf("expected the proceeded input is recorded, got %+v", records)
+	}
+	pipeline, _ := o.pipelineLister.Pipelines("project").Get("deploy")
+	if _, ok := pipeline.Annotations[devopsv1alpha3.PipelineInputApprovalsAnnoKey]; ok {
+		t.Errorf("expected the approvals of the proceeded input are removed, got %v", pipeline.Annotations)
+	}
+}
+
+func TestSubmitAfterRestart(t *testing.T) {
+	client := newInputDevops("2020-06-01T10:00:00.000+0000", "project")
+	o, _ := newTestOperator(client, nil,
+		newPipeline("project", map[string]string{devopsv1alpha3.PipelineInputQuorumAnnoKey: "2"}))
+	ref := InputRef{Namespace: "project", Pipeline: "deploy", Run: "1", Node: "5", Step: "15"}
+	if _, err := o.Submit(&user.DefaultInfo{Name: "rick", Groups: []string{"release-managers"}}, ref, &Submission{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the approvals and the records are kept in the pipeline, not in the apiserver
+	restarted, _ := newTestOperatorWithClient(client, o.ksClient.(*fake.Clientset), nil)
+	input, err := restarted.Submit(&user.DefaultInfo{Name: "admin"}, ref, &Submission{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.State != StateProceeded || len(input.Approvals) != 2 || input.Approvals[0].Approver != "rick" {
+		t.Errorf("expected the input is proceeded with the approval of rick, got %+v", input)
+	}
+	restarted, _ = newTestOperatorWithClient(client, o.ksClient.(*fake.Clientset), nil)
+	records, err := restarted.ListRecords(&user.DefaultInfo{Name: "admin"}, "project", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].DecidedBy != "admin" || records[0].Parameters != nil {
+		t.Errorf("expected the proceeded input is recorded, got %+v", records)
+	}
+}
+
+func TestSubmitAbort(t *testing.T) {
+	client := newInputDevops("2020-06-01T10:00:00.000+0000", "project", "other")
+	o, _ := newTestOperator(client, []string{"other"}, newPipeline("project", nil), newPipeline("other", nil))
+	admin := &user.DefaultInfo{Name: "admin"}
+
+	_, err := o.Submit(admin, InputRef{Namespace: "other", Pipeline: "deploy", Run: "1", Node: "5", Step: "15"}, &Submission{Abort: true})
+	expectStatus(t, err, http.StatusForbidden)
+
+	input, err := o.Submit(admin, InputRef{Namespace: "project", Pipeline: "deploy", Run: "1", Node: "5", Step: "15"},
+		&Submission{Abort: true, Comment: "not today"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.State != StateAborted || input.DecidedBy != "admin" || input.Reason != "not today" {
+		t.Errorf("expected the input is aborted by admin, got %+v", input)
+	}
+	expected := `project deploy 1 5 15 {"abort":true,"id":"approve"}`
+	if len(client.submissions) != 1 || client.submissions[0] != expected {
+		t.Errorf("expected the submission %s, got %v", expected, client.submissions)
+	}
+}
+
+func TestListPendingInputs(t *testing.T) {
+	client := newInputDevops("2020-06-01T10:00:00.000+0000", "project", "other")
+	o, _ := newTestOperator(client, []string{"other"},
+		newPipeline("project", map[string]string{devopsv1alpha3.PipelineInputQuorumAnnoKey: "2"}), newPipeline("other", nil))
+
+	// the inputs are listed from the snapshot of the sync
+	inputs, err := o.ListPendingInputs(&user.DefaultInfo{Name: "rick", Groups: []string{"release-managers"}}, "")
+	if err != nil || len(inputs) != 0 {
+		t.Fatalf("expected no input before the sync, got %+v, %v", inputs, err)
+	}
+	o.sync()
+	inputs, err = o.ListPendingInputs(&user.DefaultInfo{Name: "rick", Groups: []string{"release-managers"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 || inputs[0].Namespace != "project" || inputs[0].Quorum != 2 ||
+		inputs[0].StartTime == nil || inputs[0].ExpiresAt != nil {
+		t.Fatalf("expected the pending input of project, got %+v", inputs)
+	}
+
+	inputs, err = o.ListPendingInputs(&user.DefaultInfo{Name: "morty"}, "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 0 {
+		t.Errorf("expected no input approvable by morty, got %+v", inputs)
+	}
+
+	// the inputs already approved by the user are not pending for the user
+	ref := InputRef{Namespace: "project", Pipeline: "deploy", Run: "1", Node: "5", Step: "15"}
+	if _, err = o.Submit(&user.DefaultInfo{Name: "admin"}, ref, &Submission{}); err != nil {
+		t.Fatal(err)
+	}
+	inputs, err = o.ListPendingInputs(&user.DefaultInfo{Name: "admin"}, "project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 0 {
+		t.Errorf("expected no input pending for admin, got %+v", inputs)
+	}
+	inputs, _ = o.ListPendingInputs(&user.DefaultInfo{Name: "summer", Groups: []string{"release-managers"}}, "project")
+	if len(inputs) != 1 || len(inputs[0].Approvals) != 1 {
+		t.Errorf("expected the approval since the sync is listed, got %+v", inputs)
+	}
+
+	// the proceeded input is removed from the snapshot at once
+	if _, err = o.Submit(&user.DefaultInfo{Name: "rick", Groups: []string{"release-managers"}}, ref, &Submission{}); err != nil {
+		t.Fatal(err)
+	}
+	inputs, _ = o.ListPendingInputs(&user.DefaultInfo{Name: "summer", Groups: []string{"release-managers"}}, "project")
+	if len(inputs) != 0 {
+		t.Errorf("expected no input pending after it is proceeded, got %+v", inputs)
+	}
+}
+
+func TestSync(t *testing.T) {
+	client := newInputDevops("2020-06-01T10:00:00.000+0000", "project", "other")
+	o, recorder := newTestOperator(client, nil,
+		newPipeline("project", map[string]string{devopsv1alpha3.PipelineInputTimeoutAnnoKey: "1h"}),
+		newPipeline("other", map[string]string{devopsv1alpha3.PipelineInputTimeoutAnnoKey: "24h"}))
+	o.now = func() time.Time {
+		return time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+	o.leading = 1
+
+	o.sync()
+	expected := `project deploy 1 5 15 {"abort":true,"id":"approve"}`
+	if len(client.submissions) != 1 || client.submissions[0] != expected {
+		t.Errorf("expected the expired input is aborted, got %v", client.submissions)
+	}
+	records, _ := o.ListRecords(&user.DefaultInfo{Name: "admin"}, "project", 0)
+	if len(records) != 1 || records[0].State != StateExpired {
+		t.Errorf("expected the expired input is recorded, got %+v", records)
+	}
+
+	// the pending input is notified once, the aborted one is forgotten
+	o.sync()
+	events := map[string]int{}
+	for len(recorder.Events) > 0 {
+		event := <-recorder.Events
+		events[strings.Fields(event)[1]]++
+	}
+	if events[EventReasonInputPending] != 1 || events[EventReasonInputExpired] != 1 || len(client.submissions) != 1 {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestSyncNotLeading(t *testing.T) {
+	client := newInputDevops("2020-06-01T10:00:00.000+0000", "project", "other")
+	o, recorder := newTestOperator(client, nil,
+		newPipeline("project", map[string]string{devopsv1alpha3.PipelineInputTimeoutAnnoKey: "1h"}),
+		newPipeline("other", map[string]string{devopsv1alpha3.PipelineInputTimeoutAnnoKey: "24h"}))
+	o.now = func() time.Time {
+		return time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+
+	// only the leader aborts and notifies the inputs, the others take the snapshot only
+	o.sync()
+	if len(client.submissions) != 0 || len(recorder.Events) != 0 {
+		t.Errorf("expected no submission and no event, got %v and %d events", client.submissions, len(recorder.Events))
+	}
+	inputs, _ := o.ListPendingInputs(&user.DefaultInfo{Name: "admin"}, "")
+	if len(inputs) != 1 || inputs[0].Namespace != "other" {
+		t.Errorf("expected the pending input of other only, got %+v", inputs)
+	}
+
+	// the inputs seen before leading are not notified again
+	stopCh := make(chan struct{})
+	go o.Lead(stopCh)
+	defer close(stopCh)
+	if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return atomic.LoadInt32(&o.leading) == 1, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	o.sync()
+	if len(client.submissions) != 1 || len(recorder.Events) != 1 {
+		t.Errorf("expected the expired input is aborted only, got %v and %d events", client.submissions, len(recorder.Events))
+	}
+}
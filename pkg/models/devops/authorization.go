@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/apiserver/request"
+)
+
+// AuthorizePipelines checks if the user can do verb on the pipelines of the DevOps project, the denial is a
+// forbidden error
+func AuthorizePipelines(a authorizer.Authorizer, user user.Info, verb, namespace string) error {
+	return authorize(a, authorizer.AttributesRecord{
+		User:            user,
+		Verb:            verb,
+		Namespace:       namespace,
+		DevOps:          namespace,
+		APIGroup:        devopsv1alpha3.GroupVersion.Group,
+		APIVersion:      devopsv1alpha3.GroupVersion.Version,
+		Resource:        devopsv1alpha3.ResourcePluralPipeline,
+		ResourceRequest: true,
+		ResourceScope:   request.DevOpsScope,
+	})
+}
+
+// AuthorizeS2iBinaries checks if the user can do verb on the S2iBinaries of the namespace, the denial is a
+// forbidden error
+func AuthorizeS2iBinaries(a authorizer.Authorizer, user user.Info, verb, namespace string) error {
+	return authorize(a, authorizer.AttributesRecord{
+		User:            user,
+		Verb:            verb,
+		Namespace:       namespace,
+		APIGroup:        devopsv1alpha1.SchemeGroupVersion.Group,
+		APIVersion:      devopsv1alpha1.SchemeGroupVersion.Version,
+		Resource:        devopsv1alpha1.ResourcePluralS2iBinary,
+		ResourceRequest: true,
+		ResourceScope:   request.NamespaceScope,
+	})
+}
+
+func authorize(a authorizer.Authorizer, attributes authorizer.AttributesRecord) error {
+	decision, reason, err := a.Authorize(attributes)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	if decision != authorizer.DecisionAllow {
+		return restful.NewError(http.StatusForbidden, fmt.Sprintf("user %s cannot %s the %s of %s: %s",
+			attributes.User.GetName(), attributes.Verb, attributes.Resource, attributes.Namespace, reason))
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+// The types of nodes in the BlueOcean graph
+const (
+	NodeTypeStage    = "STAGE"
+	NodeTypeParallel = "PARALLEL"
+)
+
+// maxConcurrentNodes is the maximum number of the requests getting the steps of nodes at a time
+const maxConcurrentNodes = 10
+
+// BuildGraph is the DAG of a pipeline run, the edges point from a node to the nodes run after it
+type BuildGraph struct {
+	Nodes []BuildNode `json:"nodes"`
+	Edges []BuildEdge `json:"edges"`
+	// CriticalPath is the IDs of the nodes on the longest path of the graph, from the first to the last
+	CriticalPath []string `json:"criticalPath"`
+	// DurationInMillis is the duration of the critical path
+	DurationInMillis int64 `json:"durationInMillis"`
+	// PendingInputs is the IDs of the nodes waiting for inputs
+	PendingInputs []string `json:"pendingInputs"`
+}
+
+type BuildEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BuildNode is a stage or a parallel branch
+type BuildNode struct {
+	ID               string `json:"id"`
+	DisplayName      string `json:"displayName"`
+	Type             string `json:"type"`
+	State            string `json:"state,omitempty"`
+	Result           string `json:"result,omitempty"`
+	StartTime        string `json:"startTime,omitempty"`
+	DurationInMillis int64  `json:"durationInMillis"`
+	// FirstParent is the ID of the stage the node belongs to or follows
+	FirstParent string `json:"firstParent,omitempty"`
+	// Branches is the IDs of the parallel branches of the stage
+	Branches     []string      `json:"branches,omitempty"`
+	InputPending bool          `json:"inputPending"`
+	Input        *devops.Input `json:"input,omitempty"`
+	Steps        []BuildStep   `json:"steps"`
+}
+
+type BuildStep struct {
+	ID                 string        `json:"id"`
+	DisplayName        string        `json:"displayName"`
+	DisplayDescription string        `json:"displayDescription,omitempty"`
+	State              string        `json:"state,omitempty"`
+	Result             string        `json:"result,omitempty"`
+	StartTime          string        `json:"startTime,omitempty"`
+	DurationInMillis   int64         `json:"durationInMillis"`
+	InputPending       bool          `json:"inputPending"`
+	Input              *devops.Input `json:"input,omitempty"`
+}
+
+type BuildGraphOperator interface {
+	// GetPipelineRunGraph returns the graph of a run of the regular pipeline
+	GetPipelineRunGraph(projectName, pipelineName, runId string) (*BuildGraph, error)
+
+	// GetBranchPipelineRunGraph returns the graph of a run of the branch of multi-branch pipeline
+	GetBranchPipelineRunGraph(projectName, pipelineName, branchName, runId string) (*BuildGraph, error)
+}
+
+type buildGraphOperator struct {
+	devopsClient devops.Interface
+}
+
+func NewBuildGraphOperator(devopsClient devops.Interface) BuildGraphOperator {
+	return &buildGraphOperator{devopsClient: devopsClient}
+}
+
+func (o *buildGraphOperator) GetPipelineRunGraph(projectName, pipelineName, runId string) (*BuildGraph, error) {
+	runNodes, err := o.devopsClient.GetPipelineRunNodes(projectName, pipelineName, runId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		klog.Error(err)
+		return nil, restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+	}
+
+	return o.buildGraph(runNodes, func(nodeId string) ([]devops.NodeSteps, error) {
+		return o.devopsClient.GetNodeSteps(projectName, pipelineName, runId, nodeId, devops.NewHttpParameters(http.MethodGet, nil))
+	})
+}
+
+func (o *buildGraphOperator) GetBranchPipelineRunGraph(projectName, pipelineName, branchName, runId string) (*BuildGraph, error) {
+	branchNodes, err := o.devopsClient.GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		klog.Error(err)
+		return nil, restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+	}
+
+	// the nodes of branches only differ from the ones of regular pipelines in the type of edges
+	runNodes := make([]devops.PipelineRunNodes, len(branchNodes))
+	for i, branchNode := range branchNodes {
+		runNodes[i] = devops.PipelineRunNodes{
+			ID:               branchNode.ID,
+			DisplayName:      branchNode.DisplayName,
+			Type:             branchNode.Type,
+			State:            branchNode.State,
+			Result:           branchNode.Result,
+			StartTime:        branchNode.StartTime,
+			DurationInMillis: branchNode.DurationInMillis,
+			FirstParent:      branchNode.FirstParent,
+			Input:            branchNode.Input,
+		}
+		for _, edge := range branchNode.Edges {
+			runNodes[i].Edges = append(runNodes[i].Edges, map[string]interface{}{"id": edge.ID})
+		}
+	}
+
+	return o.buildGraph(runNodes, func(nodeId string) ([]devops.NodeSteps, error) {
+		return o.devopsClient.GetBranchNodeSteps(projectName, pipelineName, branchName, runId, nodeId, devops.NewHttpParameters(http.MethodGet, nil))
+	})
+}
+
+// buildGraph maps the nodes of BlueOcean with their steps to the graph
+func (o *buildGraphOperator) buildGraph(runNodes []devops.PipelineRunNodes, getSteps func(nodeId string) ([]devops.NodeSteps, error)) (*BuildGraph, error) {
+	nodes := make([]BuildNode, 0, len(runNodes))
+	edges := make([]BuildEdge, 0)
+	for _, runNode := range runNodes {
+		nodes = append(nodes, BuildNode{
+			ID:               runNode.ID,
+			DisplayName:      runNode.DisplayName,
+			Type:             runNode.Type,
+			State:            runNode.State,
+			Result:           runNode.Result,
+			StartTime:        runNode.StartTime,
+			DurationInMillis: int64(runNode.DurationInMillis),
+			FirstParent:      idOf(runNode.FirstParent),
+			Input:            runNode.Input,
+		})
+		for _, edge := range runNode.Edges {
+			if edgeMap, ok := edge.(map[string]interface{}); ok {
+				if to := idOf(edgeMap["id"]); to != "" {
+					edges = append(edges, BuildEdge{From: runNode.ID, To: to})
+				}
+			}
+		}
+	}
+
+	if err := fillSteps(nodes, getSteps); err != nil {
+		return nil, err
+	}
+	return NewBuildGraph(nodes, edges), nil
+}
+
+// fillSteps gets the steps of the nodes concurrently, at most maxConcurrentNodes requests at a time
+func fillSteps(nodes []BuildNode, getSteps func(nodeId string) ([]devops.NodeSteps, error)) error {
+	errs := make([]error, len(nodes))
+	workqueue.ParallelizeUntil(context.Background(), maxConcurrentNodes, len(nodes), func(i int) {
+		node := &nodes[i]
+		nodeSteps, err := getSteps(node.ID)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		node.Steps = make([]BuildStep, 0, len(nodeSteps))
+		for _, step := range nodeSteps {
+			node.Steps = append(node.Steps, BuildStep{
+				ID:                 step.ID,
+				DisplayName:        step.DisplayName,
+				DisplayDescription: step.DisplayDescription,
+				State:              step.State,
+				Result:             step.Result,
+				StartTime:          step.StartTime,
+				DurationInMillis:   int64(step.DurationInMillis),
+				InputPending:       step.Input != nil && step.State == devops.StatePaused,
+				Input:              step.Input,
+			})
+		}
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			klog.Error(err)
+			return restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+		}
+	}
+	return nil
+}
+
+// NewBuildGraph links the parallel branches to their stages, marks the nodes waiting for inputs, and
+// calculates the critical path. The stage of parallel branches weighs nothing on the critical path,
+// its duration is covered by the branches.
+func NewBuildGraph(nodes []BuildNode, edges []BuildEdge) *BuildGraph {
+	graph := &BuildGraph{
+		Nodes:         nodes,
+		Edges:         edges,
+		CriticalPath:  make([]string, 0),
+		PendingInputs: make([]string, 0),
+	}
+
+	index := make(map[string]int, len(nodes))
+	for i := range nodes {
+		index[nodes[i].ID] = i
+		if nodes[i].Steps == nil {
+			nodes[i].Steps = make([]BuildStep, 0)
+		}
+	}
+
+	successors := make(map[string][]string, len(nodes))
+	inDegree := make(map[string]int, len(nodes))
+	for _, edge := range edges {
+		to, ok := index[edge.To]
+		if _, fromOk := index[edge.From]; !ok || !fromOk {
+			continue
+		}
+		successors[edge.From] = append(successors[edge.From], edge.To)
+		inDegree[edge.To]++
+		if nodes[to].Type == NodeTypeParallel {
+			from := &nodes[index[edge.From]]
+			from.Branches = append(from.Branches, edge.To)
+		}
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		node.InputPending = node.State == devops.StatePaused && node.Input != nil
+		for _, step := range node.Steps {
+			node.InputPending = node.InputPending || step.InputPending
+		}
+		if node.InputPending {
+			graph.PendingInputs = append(graph.PendingInputs, node.ID)
+		}
+	}
+
+	// longest path in topological order, the nodes of cycles, if any, are left out
+	queue := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if inDegree[node.ID] == 0 {
+			queue = append(queue, node.ID)
+		}
+	}
+	distance := make(map[string]int64, len(nodes))
+	previous := make(map[string]string, len(nodes))
+	var last string
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		node := &nodes[index[id]]
+		if len(node.Branches) == 0 {
+			distance[id] += node.DurationInMillis
+		}
+		// the later node wins the tie, so the trailing nodes which take no time are on the path
+		if last == "" || distance[id] >= distance[last] {
+			last = id
+		}
+
+		for _, next := range successors[id] {
+			if _, ok := previous[next]; !ok || distance[id] > distance[next] {
+				distance[next] = distance[id]
+				previous[next] = id
+			}
+			inDegree[next]--
+			if inDegree[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	if last != "" {
+		graph.DurationInMillis = distance[last]
+		for id := last; id != ""; id = previous[id] {
+			graph.CriticalPath = append([]string{id}, graph.CriticalPath...)
+		}
+	}
+	return graph
+}
+
+// idOf returns the ID in the untyped fields of BlueOcean, the IDs are strings but numbers are tolerated
+func idOf(value interface{}) string {
+	switch id := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	case float64:
+		return fmt.Sprintf("%d", int64(id))
+	default:
+		return fmt.Sprintf("%v", id)
+	}
+}
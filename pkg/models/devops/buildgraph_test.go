@@ -0,0 +1,177 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/fake"
+)
+
+func newRunNode(id, name, nodeType string, duration int, firstParent interface{}, edges ...string) devops.PipelineRunNodes {
+	node := devops.PipelineRunNodes{
+		ID:               id,
+		DisplayName:      name,
+		Type:             nodeType,
+		State:            "FINISHED",
+		Result:           "SUCCESS",
+		DurationInMillis: duration,
+		FirstParent:      firstParent,
+	}
+	for _, edge := range edges {
+		node.Edges = append(node.Edges, map[string]interface{}{"id": edge, "type": NodeTypeStage})
+	}
+	return node
+}
+
+func TestGetPipelineRunGraph(t *testing.T) {
+	deploy := newRunNode("5", "Deploy", NodeTypeStage, 2000, "2")
+	deploy.State, deploy.Result = devops.StatePaused, "UNKNOWN"
+	deploy.Input = &devops.Input{ID: "approve", Message: "Deploy to production?"}
+
+	test := newRunNode("2", "Test", NodeTypeStage, 5000, "1")
+	test.Edges = []interface{}{
+		map[string]interface{}{"id": "3", "type": NodeTypeParallel},
+		map[string]interface{}{"id": "4", "type": NodeTypeParallel},
+	}
+
+	client := fake.New("project")
+	client.Data = map[string]interface{}{
+		"project-pipeline-1": []devops.PipelineRunNodes{
+			newRunNode("1", "Checkout", NodeTypeStage, 1000, nil, "2"),
+			test,
+			newRunNode("3", "unit", NodeTypeParallel, 4000, "2", "5"),
+			newRunNode("4", "e2e", NodeTypeParallel, 4500, "2", "5"),
+			deploy,
+		},
+		"project-pipeline-1-1": []devops.NodeSteps{{ID: "11", DisplayName: "git", State: "FINISHED", DurationInMillis: 1000}},
+		"project-pipeline-1-2": []devops.NodeSteps{},
+		"project-pipeline-1-3": []devops.NodeSteps{{ID: "13", DisplayName: "go test", State: "FINISHED", DurationInMillis: 4000}},
+		"project-pipeline-1-4": []devops.NodeSteps{{ID: "14", DisplayName: "e2e", State: "FINISHED", DurationInMillis: 4500}},
+		"project-pipeline-1-5": []devops.NodeSteps{{ID: "15", DisplayName: "input", State: devops.StatePaused, Input: deploy.Input}},
+	}
+
+	graph, err := NewBuildGraphOperator(client).GetPipelineRunGraph("project", "pipeline", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(graph.Nodes) != 5 || len(graph.Edges) != 5 {
+		t.Fatalf("expected 5 nodes and 5 edges, got %d nodes and %d edges", len(graph.Nodes), len(graph.Edges))
+	}
+	if expected := []string{"1", "2", "4", "5"}; !reflect.DeepEqual(graph.CriticalPath, expected) {
+		t.Errorf("expected critical path %v, got %v", expected, graph.CriticalPath)
+	}
+	if graph.DurationInMillis != 7500 {
+		t.Errorf("expected the duration 7500, got %d", graph.DurationInMillis)
+	}
+	if expected := []string{"5"}; !reflect.DeepEqual(graph.PendingInputs, expected) {
+		t.Errorf("expected pending inputs %v, got %v", expected, graph.PendingInputs)
+	}
+	if expected := []string{"3", "4"}; !reflect.DeepEqual(graph.Nodes[1].Branches, expected) {
+		t.Errorf("expected the branches %v of stage Test, got %v", expected, graph.Nodes[1].Branches)
+	}
+	if graph.Nodes[1].FirstParent != "1" || len(graph.Nodes[2].Steps) != 1 || !graph.Nodes[4].Steps[0].InputPending {
+		t.Errorf("unexpected nodes %+v", graph.Nodes)
+	}
+}
+
+func TestGetBranchPipelineRunGraph(t *testing.T) {
+	var runNodes []devops.BranchPipelineRunNodes
+	err := json.Unmarshal([]byte(`[
+		{"id": "1", "displayName": "Build", "type": "STAGE", "durationInMillis": 3000, "edges": [{"id": "2", "type": "STAGE"}]},
+		{"id": "2", "displayName": "Push", "type": "STAGE", "durationInMillis": 1000, "firstParent": "1"}
+	]`), &runNodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.New("project")
+	client.Data = map[string]interface{}{
+		"project-pipeline-master-1":   runNodes,
+		"project-pipeline-master-1-1": []devops.NodeSteps{},
+		"project-pipeline-master-1-2": []devops.NodeSteps{},
+	}
+
+	graph, err := NewBuildGraphOperator(client).GetBranchPipelineRunGraph("project", "pipeline", "master", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"1", "2"}; !reflect.DeepEqual(graph.CriticalPath, expected) {
+		t.Errorf("expected critical path %v, got %v", expected, graph.CriticalPath)
+	}
+	if graph.DurationInMillis != 4000 || len(graph.PendingInputs) != 0 {
+		t.Errorf("unexpected graph %+v", graph)
+	}
+}
+
+func TestNewBuildGraphWithCycle(t *testing.T) {
+	graph := NewBuildGraph([]BuildNode{
+		{ID: "1", DurationInMillis: 1000},
+		{ID: "2", DurationInMillis: 1000},
+		{ID: "3", DurationInMillis: 1000},
+	}, []BuildEdge{{From: "1", To: "2"}, {From: "2", To: "3"}, {From: "3", To: "2"}, {From: "3", To: "missing"}})
+
+	if expected := []string{"1"}; !reflect.DeepEqual(graph.CriticalPath, expected) {
+		t.Errorf("expected the nodes of the cycle are left out, got %v", graph.CriticalPath)
+	}
+	for _, node := range graph.Nodes {
+		if node.Steps == nil {
+			t.Errorf("expected the steps of node %s are not nil", node.ID)
+		}
+	}
+}
+
+func TestFillStepsConcurrently(t *testing.T) {
+	nodes := make([]BuildNode, 3*maxConcurrentNodes)
+	for i := range nodes {
+		nodes[i].ID = fmt.Sprintf("%d", i)
+	}
+
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	err := fillSteps(nodes, func(nodeId string) ([]devops.NodeSteps, error) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		running--
+		lock.Unlock()
+		return []devops.NodeSteps{{ID: nodeId}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > maxConcurrentNodes {
+		t.Errorf("expected at most %d requests at a time, got %d", maxConcurrentNodes, maxRunning)
+	}
+	for _, node := range nodes {
+		if len(node.Steps) != 1 || node.Steps[0].ID != node.ID {
+			t.Errorf("expected the steps of node %s, got %+v", node.ID, node.Steps)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commitstatus
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultSyncPeriod is the period to look for the state changes of the pipeline runs
+	DefaultSyncPeriod = 30 * time.Second
+	// DefaultMaxAttempts is the number of the attempts to post a status before giving up
+	DefaultMaxAttempts = 3
+	// DefaultMaxReports is the number of the latest reports kept
+	DefaultMaxReports = 200
+	// DefaultContext is the prefix of the contexts of the statuses, the name of the pipeline follows it
+	DefaultContext = "kubesphere"
+	// DefaultCluster is the cluster in the links to the console
+	DefaultCluster = "default"
+)
+
+// Options configures the commit statuses reported for the multi-branch pipelines
+type Options struct {
+	SyncPeriod time.Duration `json:"syncPeriod,omitempty" yaml:"syncPeriod,omitempty" mapstructure:"syncPeriod"`
+	MaxReports int           `json:"maxReports,omitempty" yaml:"maxReports,omitempty" mapstructure:"maxReports"`
+	// Context is the prefix of the contexts of the statuses, e.g. kubesphere/my-pipeline
+	Context string `json:"context,omitempty" yaml:"context,omitempty" mapstructure:"context"`
+	// ConsoleURL is the address of the KubeSphere console the statuses link to, the statuses have no links if
+	// it is empty. Bitbucket Server requires the links.
+	ConsoleURL string `json:"consoleURL,omitempty" yaml:"consoleURL,omitempty" mapstructure:"consoleURL"`
+	// Cluster is the name of the cluster in the links
+	Cluster string `json:"cluster,omitempty" yaml:"cluster,omitempty" mapstructure:"cluster"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		SyncPeriod: DefaultSyncPeriod,
+		MaxReports: DefaultMaxReports,
+		Context:    DefaultContext,
+		Cluster:    DefaultCluster,
+	}
+}
+
+// Validate check options
+func (o *Options) Validate() []error {
+	errors := make([]error, 0)
+	if o == nil {
+		return errors
+	}
+
+	if o.SyncPeriod < 0 {
+		errors = append(errors, fmt.Errorf("syncPeriod of commit status must not be negative"))
+	}
+	if o.MaxReports < 0 {
+		errors = append(errors, fmt.Errorf("maxReports of commit status must not be negative"))
+	}
+	if o.ConsoleURL != "" {
+		if u, err := url.Parse(o.ConsoleURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, fmt.Errorf("consoleURL %q of commit status is not a http or https url", o.ConsoleURL))
+		}
+	}
+	return errors
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commitstatus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/models/devops/webhook"
+)
+
+// The states of the commit statuses, they are translated to the states of the providers
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StateError   = "error"
+)
+
+// maxDescription is the max length of the descriptions of GitHub
+const maxDescription = 140
+
+// Status is the commit status of a pipeline run
+type Status struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"targetURL,omitempty"`
+}
+
+// repository is where the statuses of a multi-branch pipeline are posted
+type repository struct {
+	provider string
+	// apiURL is the root of the REST API for GitHub, and the address of the server for the others
+	apiURL       string
+	owner, repo  string
+	credentialID string
+}
+
+// credential authenticates the requests, token is empty for the basic auth
+type credential struct {
+	username, password, token string
+}
+
+// resolveRepository finds the repository of the multi-branch pipeline having the commit status annotation
+func resolveRepository(pipeline *devopsv1alpha3.Pipeline) (*repository, error) {
+	multiBranch := pipeline.Spec.MultiBranchPipeline
+	if pipeline.Spec.Type != devopsv1alpha3.MultiBranchPipelineType || multiBranch == nil {
+		return nil, fmt.Errorf("only the multi-branch pipelines report commit statuses")
+	}
+	value := pipeline.Annotations[devopsv1alpha3.PipelineCommitStatusAnnoKey]
+
+	switch multiBranch.SourceType {
+	case devopsv1alpha3.SourceTypeGithub:
+		source := multiBranch.GitHubSource
+		if source == nil {
+			return nil, fmt.Errorf("the pipeline has no github source")
+		}
+		apiURL := source.ApiUri
+		if apiURL == "" {
+			apiURL = "https://api.github.com"
+		}
+		return &repository{provider: webhook.ProviderGithub, apiURL: apiURL, owner: source.Owner, repo: source.Repo,
+			credentialID: source.CredentialId}, nil
+	case devopsv1alpha3.SourceTypeGitlab:
+		source := multiBranch.GitlabSource
+		if source == nil {
+			return nil, fmt.Errorf("the pipeline has no gitlab source")
+		}
+		apiURL := source.ApiUri
+		if apiURL == "" {
+			apiURL = "https://gitlab.com"
+		}
+		return &repository{provider: webhook.ProviderGitlab, apiURL: apiURL, owner: source.Owner, repo: source.Repo,
+			credentialID: source.CredentialId}, nil
+	case devopsv1alpha3.SourceTypeBitbucket:
+		source := multiBranch.BitbucketServerSource
+		if source == nil {
+			return nil, fmt.Errorf("the pipeline has no bitbucket server source")
+		}
+		if source.ApiUri == "" {
+			return nil, fmt.Errorf("the api uri of the bitbucket server source is required")
+		}
+		return &repository{provider: webhook.ProviderBitbucketServer, apiURL: source.ApiUri, owner: source.Owner,
+			repo: source.Repo, credentialID: source.CredentialId}, nil
+	case devopsv1alpha3.SourceTypeGit:
+		if multiBranch.GitSource == nil {
+			return nil, fmt.Errorf("the pipeline has no git source")
+		}
+		return parseGitURL(value, multiBranch.GitSource.Url, multiBranch.GitSource.CredentialId)
+	}
+	return nil, fmt.Errorf("the source type %s does not support commit statuses", multiBranch.SourceType)
+}
+
+// parseGitURL finds the repository from the clone url of a git source, the provider is named by the annotation as
+// it can't be told from the url. The ssh urls are expected to be served by https too.
+func parseGitURL(provider, cloneURL, credentialID string) (*repository, error) {
+	switch provider {
+	case webhook.ProviderGithub, webhook.ProviderGitlab, webhook.ProviderBitbucketServer, webhook.ProviderGitea:
+	default:
+		return nil, fmt.Errorf("the git source must name its provider in annotation %s, one of github, gitlab, bitbucket_server and gitea",
+			devopsv1alpha3.PipelineCommitStatusAnnoKey)
+	}
+
+	var scheme, host, path string
+	if u, err := url.Parse(cloneURL); err == nil && u.Host != "" {
+		scheme, host, path = u.Scheme, u.Host, u.Path
+		if scheme != "http" {
+			// ssh://git@host:port/owner/repo.git
+			scheme, host = "https", u.Hostname()
+		}
+	} else if at := strings.Index(cloneURL, "@"); at >= 0 && strings.Contains(cloneURL[at:], ":") {
+		// git@host:owner/repo.git
+		rest := cloneURL[at+1:]
+		colon := strings.Index(rest, ":")
+		scheme, host, path = "https", rest[:colon], rest[colon+1:]
+	} else {
+		return nil, fmt.Errorf("invalid url %q of the git source", cloneURL)
+	}
+
+	segments := strings.Split(strings.Trim(strings.TrimSuffix(path, ".git"), "/"), "/")
+	// the http urls of Bitbucket Server are /scm/project/repo.git
+	if provider == webhook.ProviderBitbucketServer && len(segments) == 3 && segments[0] == "scm" {
+		segments = segments[1:]
+	}
+	if len(segments) < 2 || segments[0] == "" {
+		return nil, fmt.Errorf("the url %q of the git source has no owner and repository", cloneURL)
+	}
+
+	r := &repository{
+		provider:     provider,
+		apiURL:       scheme + "://" + host,
+		owner:        strings.Join(segments[:len(segments)-1], "/"),
+		repo:         segments[len(segments)-1],
+		credentialID: credentialID,
+	}
+	if provider == webhook.ProviderGithub {
+		if host == "github.com" {
+			r.apiURL = "https://api.github.com"
+		} else {
+			r.apiURL += "/api/v3"
+		}
+	}
+	return r, nil
+}
+
+// newRequest builds the request posting the status of the commit
+func (r *repository) newRequest(commit string, status *Status, cred *credential) (*http.Request, error) {
+	apiURL := strings.TrimSuffix(r.apiURL, "/")
+	description := status.Description
+	if len(description) > maxDescription {
+		description = description[:maxDescription-3] + "..."
+	}
+
+	var address string
+	var body interface{}
+	switch r.provider {
+	case webhook.ProviderGithub, webhook.ProviderGitea:
+		address = fmt.Sprintf("%s/repos/%s/%s/statuses/%s", apiURL, r.owner, r.repo, commit)
+		if r.provider == webhook.ProviderGitea {
+			address = fmt.Sprintf("%s/api/v1/repos/%s/%s/statuses/%s", apiURL, r.owner, r.repo, commit)
+		}
+		body = map[string]string{
+			"state":       status.State,
+			"context":     status.Context,
+			"description": description,
+			"target_url":  status.TargetURL,
+		}
+	case webhook.ProviderGitlab:
+		project := url.PathEscape(r.owner + "/" + r.repo)
+		address = fmt.Sprintf("%s/api/v4/projects/%s/statuses/%s", apiURL, project, commit)
+		body = map[string]string{
+			"state":       gitlabState(status.State),
+			"name":        status.Context,
+			"description": description,
+			"target_url":  status.TargetURL,
+		}
+	case webhook.ProviderBitbucketServer:
+		if status.TargetURL == "" {
+			return nil, fmt.Errorf("bitbucket server requires the link of the status, the console url is not configured")
+		}
+		address = fmt.Sprintf("%s/rest/build-status/1.0/commits/%s", apiURL, commit)
+		body = map[string]string{
+			"state":       bitbucketState(status.State),
+			"key":         status.Context,
+			"name":        status.Context,
+			"description": description,
+			"url":         status.TargetURL,
+		}
+	default:
+		return nil, fmt.Errorf("unknown provider %s", r.provider)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, address, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	r.authorize(req, cred)
+	return req, nil
+}
+
+func (r *repository) authorize(req *http.Request, cred *credential) {
+	switch {
+	case r.provider == webhook.ProviderGitlab:
+		token := cred.token
+		if token == "" {
+			token = cred.password
+		}
+		req.Header.Set("PRIVATE-TOKEN", token)
+	case cred.token == "":
+		req.SetBasicAuth(cred.username, cred.password)
+	case r.provider == webhook.ProviderBitbucketServer:
+		req.Header.Set("Authorization", "Bearer "+cred.token)
+	default:
+		req.Header.Set("Authorization", "token "+cred.token)
+	}
+}
+
+// checkResponse returns the error of the response of a status
+func (r *repository) checkResponse(resp *http.Response) error {
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+	// GitLab refuses to post a state twice, e.g. to update the description of a running pipeline
+	if r.provider == webhook.ProviderGitlab && resp.StatusCode == http.StatusBadRequest &&
+		strings.Contains(string(data), "Cannot transition status") {
+		return nil
+	}
+	message := string(data)
+	if len(message) > 200 {
+		message = message[:200] + "..."
+	}
+	return fmt.Errorf("%s responded %d: %s", r.provider, resp.StatusCode, message)
+}
+
+func gitlabState(state string) string {
+	switch state {
+	case StatePending:
+		return "running"
+	case StateSuccess:
+		return "success"
+	case StateFailure:
+		return "failed"
+	}
+	return "canceled"
+}
+
+func bitbucketState(state string) string {
+	switch state {
+	case StatePending:
+		return "INPROGRESS"
+	case StateSuccess:
+		return "SUCCESSFUL"
+	}
+	return "FAILED"
+}
@@ -0,0 +1,501 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commitstatus
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	devopslister "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+// The states and the results of the pipeline runs and the nodes in BlueOcean
+const (
+	stateQueued   = "QUEUED"
+	stateRunning  = "RUNNING"
+	stateFinished = "FINISHED"
+
+	resultSuccess  = "SUCCESS"
+	resultFailure  = "FAILURE"
+	resultUnstable = "UNSTABLE"
+	resultAborted  = "ABORTED"
+)
+
+// runLimit is the number of the latest runs of a pipeline watched
+const runLimit = 20
+
+// Report is the record of a status posted for a run of a branch or a pull request
+type Report struct {
+	Namespace string `json:"namespace"`
+	Pipeline  string `json:"pipeline"`
+	Branch    string `json:"branch"`
+	Run       string `json:"run"`
+	Commit    string `json:"commit"`
+	Provider  string `json:"provider"`
+	Status    Status `json:"status"`
+	// Attempts is the number of the attempts to post the status
+	Attempts  int       `json:"attempts"`
+	Succeeded bool      `json:"succeeded"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+type Reporter interface {
+	// ListReports returns the latest reports of the DevOps project posted by this replica, the newest first.
+	// The reports are kept in memory only, they are lost on restart and are empty on the replicas not leading.
+	ListReports(namespace string, limit int) []Report
+
+	// Run reports the runs of the multi-branch pipelines having the commit status annotation until stopCh is closed.
+	// It is only called on the replica holding the lease of the controllers, so every status is posted once.
+	Run(stopCh <-chan struct{})
+}
+
+// runKey locates a run of a branch or a pull request
+type runKey struct {
+	namespace, pipeline, branch, run string
+}
+
+// runStatus is the last status of a run
+type runStatus struct {
+	status   Status
+	finished bool
+	// attempts is the number of the attempts to post the status, it gives up after DefaultMaxAttempts
+	attempts int
+	posted   bool
+}
+
+func (s *runStatus) done() bool {
+	return s.posted || s.attempts >= DefaultMaxAttempts
+}
+
+type reporter struct {
+	devopsClient    devops.Interface
+	pipelineLister  devopslister.PipelineLister
+	secretLister    corev1lister.SecretLister
+	namespaceLister corev1lister.NamespaceLister
+	options         *Options
+	client          *http.Client
+	now             func() time.Time
+
+	lock     sync.Mutex
+	statuses map[runKey]*runStatus
+	// primed is the pipelines synced once, the runs finished before the first sync are not reported
+	primed sets.String
+	// errors is the last error of every pipeline, it is logged once
+	errors  map[string]string
+	reports []Report
+}
+
+func NewReporter(devopsClient devops.Interface, pipelineLister devopslister.PipelineLister,
+	secretLister corev1lister.SecretLister, namespaceLister corev1lister.NamespaceLister, options *Options) Reporter {
+	if options == nil {
+		options = NewOptions()
+	}
+	return &reporter{
+		devopsClient:    devopsClient,
+		pipelineLister:  pipelineLister,
+		secretLister:    secretLister,
+		namespaceLister: namespaceLister,
+		options:         options,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		now:             time.Now,
+		statuses:        make(map[runKey]*runStatus),
+		primed:          sets.NewString(),
+		errors:          make(map[string]string),
+	}
+}
+
+func (r *reporter) ListReports(namespace string, limit int) []Report {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	reports := make([]Report, 0)
+	for i := len(r.reports) - 1; i >= 0 && (limit <= 0 || len(reports) < limit); i-- {
+		if r.reports[i].Namespace == namespace {
+			reports = append(reports, r.reports[i])
+		}
+	}
+	return reports
+}
+
+func (r *reporter) Run(stopCh <-chan struct{}) {
+	period := r.options.SyncPeriod
+	if period <= 0 {
+		period = DefaultSyncPeriod
+	}
+	klog.Info("starting the commit status reporter")
+	wait.Until(r.sync, period, stopCh)
+	klog.Info("shutting down the commit status reporter")
+}
+
+// sync posts the statuses of the runs changed since the last sync
+func (r *reporter) sync() {
+	pipelines, err := r.pipelineLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+
+	watched := sets.NewString()
+	for _, pipeline := range pipelines {
+		value := pipeline.Annotations[devopsv1alpha3.PipelineCommitStatusAnnoKey]
+		if value == "" || value == "false" || pipeline.Spec.Type != devopsv1alpha3.MultiBranchPipelineType {
+			continue
+		}
+		id := pipeline.Namespace + "/" + pipeline.Name
+		watched.Insert(id)
+		repo, err := resolveRepository(pipeline)
+		if err == nil {
+			err = r.syncPipeline(pipeline, repo, r.primed.Has(id))
+		}
+		r.logError(id, err)
+		if err == nil {
+			r.primed.Insert(id)
+		}
+	}
+
+	// forget the pipelines deleted or not reported any more
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for key := range r.statuses {
+		if !watched.Has(key.namespace + "/" + key.pipeline) {
+			delete(r.statuses, key)
+		}
+	}
+	for _, id := range r.primed.List() {
+		if !watched.Has(id) {
+			r.primed.Delete(id)
+		}
+	}
+	for id := range r.errors {
+		if !watched.Has(id) {
+			delete(r.errors, id)
+		}
+	}
+}
+
+// logError logs the error of the pipeline once, the misconfigured pipelines fail in every sync
+func (r *reporter) logError(id string, err error) {
+	if err == nil {
+		delete(r.errors, id)
+		return
+	}
+	if r.errors[id] != err.Error() {
+		klog.Warningf("failed to report the commit statuses of pipeline %s: %v", id, err)
+		r.errors[id] = err.Error()
+	}
+}
+
+func (r *reporter) syncPipeline(pipeline *devopsv1alpha3.Pipeline, repo *repository, primed bool) error {
+	httpParameters := devops.NewHttpParameters(http.MethodGet, nil)
+	httpParameters.Url.RawQuery = fmt.Sprintf("start=0&limit=%d", runLimit)
+	runs, err := r.devopsClient.ListPipelineRuns(pipeline.Namespace, pipeline.Name, httpParameters)
+	if err != nil {
+		return err
+	}
+	if runs == nil {
+		runs = &devops.PipelineRunList{}
+	}
+
+	listed := make(map[runKey]bool)
+	for i := len(runs.Items) - 1; i >= 0; i-- {
+		run := &runs.Items[i]
+		// the runs of a multi-branch pipeline are the runs of all its branches and pull requests
+		key := runKey{namespace: pipeline.Namespace, pipeline: pipeline.Name, branch: run.Pipeline, run: run.ID}
+		listed[key] = true
+		r.observe(repo, key, run, primed)
+	}
+
+	// the unfinished runs pushed out of the list by the newer runs are fetched one by one
+	r.lock.Lock()
+	var missing []runKey
+	for key, status := range r.statuses {
+		if key.namespace == pipeline.Namespace && key.pipeline == pipeline.Name && !listed[key] {
+			if status.finished {
+				delete(r.statuses, key)
+			} else {
+				missing = append(missing, key)
+			}
+		}
+	}
+	r.lock.Unlock()
+	for _, key := range missing {
+		run, err := r.devopsClient.GetBranchPipelineRun(key.namespace, key.pipeline, key.branch, key.run, devops.NewHttpParameters(http.MethodGet, nil))
+		if err != nil || run == nil {
+			if devops.GetDevOpsStatusCode(err) == http.StatusNotFound {
+				r.lock.Lock()
+				delete(r.statuses, key)
+				r.lock.Unlock()
+			}
+			continue
+		}
+		r.observe(repo, key, run, primed)
+	}
+	return nil
+}
+
+// observe posts the status of the run if it changed, the failed posts are retried in the next syncs
+func (r *reporter) observe(repo *repository, key runKey, run *devops.PipelineRun, primed bool) {
+	commit := commitOf(run)
+	if commit == "" {
+		return
+	}
+	finished := run.State == stateFinished
+
+	r.lock.Lock()
+	last := r.statuses[key]
+	if last == nil && finished && !primed {
+		r.statuses[key] = &runStatus{finished: true, posted: true}
+	}
+	r.lock.Unlock()
+	if (last == nil && finished && !primed) || (last != nil && last.finished && last.done()) {
+		return
+	}
+
+	status := Status{
+		State:       stateOf(run),
+		Context:     r.contextOf(key),
+		Description: r.describe(key, run),
+		TargetURL:   r.targetURL(key),
+	}
+	current := &runStatus{status: status}
+	if last != nil && last.status == status {
+		if last.done() {
+			return
+		}
+		current.attempts = last.attempts
+	}
+	current.finished = finished
+	current.attempts++
+
+	report := Report{
+		Namespace: key.namespace,
+		Pipeline:  key.pipeline,
+		Branch:    branchName(key.branch),
+		Run:       key.run,
+		Commit:    commit,
+		Provider:  repo.provider,
+		Status:    status,
+		Attempts:  current.attempts,
+	}
+	if err := r.post(repo, key.namespace, commit, &status); err != nil {
+		report.Error = err.Error()
+		klog.Warningf("failed to post the status of %s/%s/%s #%s to commit %s, attempt %d: %v",
+			key.namespace, key.pipeline, report.Branch, key.run, commit, current.attempts, err)
+	} else {
+		current.posted = true
+		report.Succeeded = true
+	}
+	report.Time = r.now()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.statuses[key] = current
+	r.record(&report)
+}
+
+func (r *reporter) post(repo *repository, namespace, commit string, status *Status) error {
+	cred, err := r.credentialOf(namespace, repo.credentialID)
+	if err != nil {
+		return err
+	}
+	req, err := repo.newRequest(commit, status, cred)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return repo.checkResponse(resp)
+}
+
+// credentialOf reads the credential of the SCM source, it is a basic auth or a secret text credential
+func (r *reporter) credentialOf(namespace, credentialID string) (*credential, error) {
+	if credentialID == "" {
+		return nil, fmt.Errorf("the source of the pipeline has no credential")
+	}
+	secret, err := r.secretLister.Secrets(namespace).Get(credentialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the credential %s: %v", credentialID, err)
+	}
+	switch secret.Type {
+	case devopsv1alpha3.SecretTypeBasicAuth:
+		return &credential{
+			username: string(secret.Data[devopsv1alpha3.BasicAuthUsernameKey]),
+			password: string(secret.Data[devopsv1alpha3.BasicAuthPasswordKey]),
+		}, nil
+	case devopsv1alpha3.SecretTypeSecretText:
+		return &credential{token: string(secret.Data[devopsv1alpha3.SecretTextSecretKey])}, nil
+	}
+	return nil, fmt.Errorf("the credential %s of type %s can't post commit statuses", credentialID, secret.Type)
+}
+
+func (r *reporter) contextOf(key runKey) string {
+	context := r.options.Context
+	if context == "" {
+		context = DefaultContext
+	}
+	return context + "/" + key.pipeline
+}
+
+// describe summarizes the stages of the run
+func (r *reporter) describe(key runKey, run *devops.PipelineRun) string {
+	var nodes []devops.BranchPipelineRunNodes
+	if run.State != stateQueued {
+		var err error
+		nodes, err = r.devopsClient.GetBranchPipelineRunNodes(key.namespace, key.pipeline, key.branch, key.run, devops.NewHttpParameters(http.MethodGet, nil))
+		if err != nil {
+			klog.V(4).Infof("failed to get the nodes of %s/%s/%s #%s: %v", key.namespace, key.pipeline, key.branch, key.run, err)
+		}
+	}
+	return summarize(run, nodes)
+}
+
+// targetURL links the run in the console, the workspace of the DevOps project is a label of its namespace
+func (r *reporter) targetURL(key runKey) string {
+	if r.options.ConsoleURL == "" {
+		return ""
+	}
+	namespace, err := r.namespaceLister.Get(key.namespace)
+	if err != nil {
+		klog.V(4).Infof("failed to get namespace %s: %v", key.namespace, err)
+		return ""
+	}
+	cluster := r.options.Cluster
+	if cluster == "" {
+		cluster = DefaultCluster
+	}
+	return fmt.Sprintf("%s/%s/clusters/%s/devops/%s/pipelines/%s/branch/%s/run/%s/task-status",
+		strings.TrimSuffix(r.options.ConsoleURL, "/"), namespace.Labels[constants.WorkspaceLabelKey], cluster,
+		key.namespace, key.pipeline, key.branch, key.run)
+}
+
+func (r *reporter) record(report *Report) {
+	maxReports := r.options.MaxReports
+	if maxReports <= 0 {
+		maxReports = DefaultMaxReports
+	}
+	r.reports = append(r.reports, *report)
+	if len(r.reports) > maxReports {
+		r.reports = r.reports[len(r.reports)-maxReports:]
+	}
+}
+
+// stateOf translates the state and the result of the run to the state of the status
+func stateOf(run *devops.PipelineRun) string {
+	if run.State != stateFinished {
+		return StatePending
+	}
+	switch run.Result {
+	case resultSuccess:
+		return StateSuccess
+	case resultFailure, resultUnstable:
+		return StateFailure
+	}
+	return StateError
+}
+
+// summarize describes the run with its stages, e.g. "Failed in stage Test, 2/4 stages passed"
+func summarize(run *devops.PipelineRun, nodes []devops.BranchPipelineRunNodes) string {
+	passed := 0
+	var current, failed string
+	for _, node := range nodes {
+		switch {
+		case node.Result == resultSuccess:
+			passed++
+		case node.Result == resultFailure || node.Result == resultUnstable:
+			if failed == "" {
+				failed = node.DisplayName
+			}
+		case node.State == stateRunning || node.State == devops.StatePaused:
+			if current == "" {
+				current = node.DisplayName
+			}
+		}
+	}
+	progress := ""
+	if len(nodes) > 0 {
+		progress = fmt.Sprintf(", %d/%d stages passed", passed, len(nodes))
+	}
+
+	switch {
+	case run.State == stateQueued || run.State == "":
+		return "Queued"
+	case run.State == devops.StatePaused && current != "":
+		return fmt.Sprintf("Waiting for input in stage %s%s", current, progress)
+	case run.State != stateFinished && current != "":
+		return fmt.Sprintf("Running stage %s%s", current, progress)
+	case run.State != stateFinished:
+		return "Running" + progress
+	case run.Result == resultSuccess:
+		return "Succeeded" + progress
+	case (run.Result == resultFailure || run.Result == resultUnstable) && failed != "":
+		return fmt.Sprintf("%s in stage %s%s", resultText(run.Result), failed, progress)
+	}
+	return resultText(run.Result) + progress
+}
+
+func resultText(result string) string {
+	switch result {
+	case resultFailure:
+		return "Failed"
+	case resultUnstable:
+		return "Unstable"
+	case resultAborted:
+		return "Aborted"
+	case "":
+		return "Finished"
+	}
+	text := strings.ToLower(strings.Replace(result, "_", " ", -1))
+	return strings.ToUpper(text[:1]) + text[1:]
+}
+
+// commitOf returns the commit of the run. The runs of the pull requests merged with their targets may have the
+// revisions like "head+base (merge)", whose head commit is reported.
+func commitOf(run *devops.PipelineRun) string {
+	commit, _ := run.CommitID.(string)
+	end := 0
+	for end < len(commit) && strings.ContainsRune("0123456789abcdefABCDEF", rune(commit[end])) {
+		end++
+	}
+	if end < 7 {
+		return ""
+	}
+	return commit[:end]
+}
+
+// branchName decodes the name of the branch job, e.g. feature%2Fdemo
+func branchName(job string) string {
+	if name, err := url.PathUnescape(job); err == nil {
+		return name
+	}
+	return job
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commitstatus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/constants"
+	"devops.kubesphere.io/plugin/pkg/models/devops/webhook"
+)
+
+// runsDevops lists the runs and the nodes set by the tests
+type runsDevops struct {
+	*fakedevops.Devops
+	runs  map[string][]devops.PipelineRun
+	nodes map[string][]devops.BranchPipelineRunNodes
+}
+
+func (d *runsDevops) ListPipelineRuns(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.PipelineRunList, error) {
+	return &devops.PipelineRunList{Items: d.runs[projectName+"/"+pipelineName]}, nil
+}
+
+func (d *runsDevops) GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId string, httpParameters *devops.HttpParameters) ([]devops.BranchPipelineRunNodes, error) {
+	return d.nodes[strings.Join([]string{projectName, pipelineName, branchName, runId}, "/")], nil
+}
+
+// provider is a stand-in of the APIs of the SCM providers, it records the statuses posted
+type provider struct {
+	*httptest.Server
+	lock     sync.Mutex
+	failures int
+	requests []string
+}
+
+func newProvider() *provider {
+	p := &provider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if p.failures > 0 {
+			p.failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		status := map[string]string{}
+		json.Unmarshal(body, &status)
+		auth := r.Header.Get("Authorization")
+		if token := r.Header.Get("PRIVATE-TOKEN"); token != "" {
+			auth = "PRIVATE-TOKEN " + token
+		}
+		p.requests = append(p.requests, fmt.Sprintf("%s %s %s %s", r.URL.EscapedPath(), auth,
+			status["state"], status["description"]))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return p
+}
+
+func (p *provider) takeRequests() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	requests := p.requests
+	p.requests = nil
+	return requests
+}
+
+func newPipeline(name string, source devopsv1alpha3.MultiBranchPipeline, annotation string) *devopsv1alpha3.Pipeline {
+	return &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project",
+			Annotations: map[string]string{devopsv1alpha3.PipelineCommitStatusAnnoKey: annotation}},
+		Spec: devopsv1alpha3.PipelineSpec{Type: devopsv1alpha3.MultiBranchPipelineType, MultiBranchPipeline: &source},
+	}
+}
+
+func newTestReporter(client devops.Interface, options *Options, pipelines ...*devopsv1alpha3.Pipeline) *reporter {
+	informerFactory := ksinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	for _, pipeline := range pipelines {
+		informerFactory.Devops().V1alpha3().Pipelines().Informer().GetIndexer().Add(pipeline)
+	}
+	k8sInformerFactory := k8sinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	k8sInformerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "scm", Namespace: "project"},
+		Type:       devopsv1alpha3.SecretTypeBasicAuth,
+		Data:       map[string][]byte{"username": []byte("bot"), "password": []byte("secret")},
+	})
+	k8sInformerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "project"},
+		Type:       devopsv1alpha3.SecretTypeSecretText,
+		Data:       map[string][]byte{"secret": []byte("t0ken")},
+	})
+	k8sInformerFactory.Core().V1().Namespaces().Informer().GetIndexer().Add(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "project", Labels: map[string]string{constants.WorkspaceLabelKey: "team"}},
+	})
+
+	r := NewReporter(client, informerFactory.Devops().V1alpha3().Pipelines().Lister(),
+		k8sInformerFactory.Core().V1().Secrets().Lister(), k8sInformerFactory.Core().V1().Namespaces().Lister(),
+		options).(*reporter)
+	r.now = func() time.Time {
+		return time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	}
+	return r
+}
+
+func TestProviders(t *testing.T) {
+	p := newProvider()
+	defer p.Close()
+
+	pipelines := []*devopsv1alpha3.Pipeline{
+		newPipeline("github", devopsv1alpha3.MultiBranchPipeline{SourceType: devopsv1alpha3.SourceTypeGithub,
+			GitHubSource: &devopsv1alpha3.GithubSource{Owner: "team", Repo: "app", ApiUri: p.URL, CredentialId: "token"}}, "true"),
+		newPipeline("gitlab", devopsv1alpha3.MultiBranchPipeline{SourceType: devopsv1alpha3.SourceTypeGitlab,
+			GitlabSource: &devopsv1alpha3.GitlabSource{Owner: "team", Repo: "app", ApiUri: p.URL, CredentialId: "scm"}}, "true"),
+		newPipeline("bitbucket", devopsv1alpha3.MultiBranchPipeline{SourceType: devopsv1alpha3.SourceTypeBitbucket,
+			BitbucketServerSource: &devopsv1alpha3.BitbucketServerSource{Owner: "TEAM", Repo: "app", ApiUri: p.URL, CredentialId: "scm"}}, "true"),
+		newPipeline("gitea", devopsv1alpha3.MultiBranchPipeline{SourceType: devopsv1alpha3.SourceTypeGit,
+			GitSource: &devopsv1alpha3.GitSource{Url: p.URL + "/team/app.git", CredentialId: "scm"}}, webhook.ProviderGitea),
+		// the provider of the git source is unknown
+		newPipeline("git", devopsv1alpha3.MultiBranchPipeline{SourceType: devopsv1alpha3.SourceTypeGit,
+			GitSource: &devopsv1alpha3.GitSource{Url: p.URL + "/team/app.git", CredentialId: "scm"}}, "true"),
+	}
+	client := &runsDevops{Devops: fakedevops.New("project"), runs: map[string][]devops.PipelineRun{},
+		nodes: map[string][]devops.BranchPipelineRunNodes{}}
+	for _, pipeline := range pipelines {
+		client.runs["project/"+pipeline.Name] = []devops.PipelineRun{{ID: "1", Pipeline: "PR-1", State: stateRunning,
+			CommitID: "0123456789abcdef0123456789abcdef01234567+fedcba9876543210fedcba9876543210fedcba98 (1111111)"}}
+		client.nodes["project/"+pipeline.Name+"/PR-1/1"] = []devops.BranchPipelineRunNodes{
+			{DisplayName: "Build", State: stateFinished, Result: resultSuccess},
+			{DisplayName: "Test", State: stateRunning},
+		}
+	}
+	options := NewOptions()
+	options.ConsoleURL = "http://console"
+	r := newTestReporter(client, options, pipelines...)
+	r.sync()
+
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	description := "Running stage Test, 1/2 stages passed"
+	expected := []string{
+		"/repos/team/app/statuses/" + commit + " token t0ken pending " + description,
+		"/api/v4/projects/team%2Fapp/statuses/" + commit + " PRIVATE-TOKEN secret running " + description,
+		"/rest/build-status/1.0/commits/" + commit + " Basic Ym90OnNlY3JldA== INPROGRESS " + description,
+		"/api/v1/repos/team/app/statuses/" + commit + " Basic Ym90OnNlY3JldA== pending " + description,
+	}
+	requests := p.takeRequests()
+	for _, request := range expected {
+		found := false
+		for _, got := range requests {
+			found = found || got == request
+		}
+		if !found {
+			t.Errorf("expected request %s, got %v", request, requests)
+		}
+	}
+	if len(requests) != len(expected) {
+		t.Errorf("expected %d requests, got %v", len(expected), requests)
+	}
+
+	reports := r.ListReports("project", 1)
+	if len(reports) != 1 || reports[0].Status.TargetURL != "http://console/team/clusters/default/devops/project/pipelines/"+
+		reports[0].Pipeline+"/branch/PR-1/run/1/task-status" || reports[0].Status.Context != "kubesphere/"+reports[0].Pipeline {
+		t.Errorf("unexpected reports %+v", reports)
+	}
+	if _, ok := r.errors["project/git"]; !ok {
+		t.Errorf("expected the git source without its provider fails")
+	}
+}
+
+func TestSync(t *testing.T) {
+	p := newProvider()
+	defer p.Close()
+
+	pipeline := newPipeline("app", devopsv1alpha3.MultiBranchPipeline{SourceType: devopsv1alpha3.SourceTypeGithub,
+		GitHubSource: &devopsv1alpha3.GithubSource{Owner: "team", Repo: "app", ApiUri: p.URL, CredentialId: "token"}}, "true")
+	client := &runsDevops{Devops: fakedevops.New("project"), nodes: map[string][]devops.BranchPipelineRunNodes{},
+		runs: map[string][]devops.PipelineRun{"project/app": {
+			{ID: "1", Pipeline: "master", State: stateFinished, Result: resultSuccess, CommitID: "aaaaaaaaaaaa"},
+		}},
+	}
+	r := newTestReporter(client, nil, pipeline)
+
+	// the runs finished before the first sync are not reported
+	r.sync()
+	if requests := p.takeRequests(); len(requests) != 0 {
+		t.Fatalf("expected nothing is reported in the first sync, got %v", requests)
+	}
+
+	steps := []struct {
+		runs     []devops.PipelineRun
+		nodes    []devops.BranchPipelineRunNodes
+		failures int
+		expected []string
+	}{
+		{
+			runs:     []devops.PipelineRun{{ID: "1", Pipeline: "feature%2Fdemo", State: stateQueued, CommitID: "bbbbbbbbbbbb"}},
+			expected: []string{"/repos/team/app/statuses/bbbbbbbbbbbb token t0ken pending Queued"},
+		},
+		{
+			runs:     []devops.PipelineRun{{ID: "1", Pipeline: "feature%2Fdemo", State: stateQueued, CommitID: "bbbbbbbbbbbb"}},
+			expected: nil,
+		},
+		{
+			// the failed posts are retried in the next syncs
+			runs: []devops.PipelineRun{{ID: "1", Pipeline: "feature%2Fdemo", State: stateFinished, Result: resultFailure,
+				CommitID: "bbbbbbbbbbbb"}},
+			nodes: []devops.BranchPipelineRunNodes{
+				{DisplayName: "Build", State: stateFinished, Result: resultSuccess},
+				{DisplayName: "Test", State: stateFinished, Result: resultFailure},
+				{DisplayName: "Deploy", State: "NOT_BUILT"},
+			},
+			failures: 1,
+			expected: nil,
+		},
+		{
+			runs: []devops.PipelineRun{{ID: "1", Pipeline: "feature%2Fdemo", State: stateFinished, Result: resultFailure,
+				CommitID: "bbbbbbbbbbbb"}},
+			expected: []string{"/repos/team/app/statuses/bbbbbbbbbbbb token t0ken failure Failed in stage Test, 1/3 stages passed"},
+		},
+		{
+			runs: []devops.PipelineRun{{ID: "1", Pipeline: "feature%2Fdemo", State: stateFinished, Result: resultFailure,
+				CommitID: "bbbbbbbbbbbb"}},
+			expected: nil,
+		},
+	}
+	for i, step := range steps {
+		p.failures = step.failures
+		client.runs["project/app"] = append(step.runs, devops.PipelineRun{ID: "1", Pipeline: "master",
+			State: stateFinished, Result: resultSuccess, CommitID: "aaaaaaaaaaaa"})
+		if step.nodes != nil {
+			client.nodes["project/app/feature%2Fdemo/1"] = step.nodes
+		}
+		r.sync()
+		if requests := p.takeRequests(); strings.Join(requests, "\n") != strings.Join(step.expected, "\n") {
+			t.Errorf("step %d: expected %v, got %v", i, step.expected, requests)
+		}
+	}
+
+	reports := r.ListReports("project", 0)
+	if len(reports) != 3 || reports[0].Attempts != 2 || !reports[0].Succeeded || reports[1].Succeeded ||
+		reports[1].Error == "" || reports[2].Branch != "feature/demo" || reports[2].Status.TargetURL != "" {
+		t.Errorf("unexpected reports %+v", reports)
+	}
+}
+
+func TestParseGitURL(t *testing.T) {
+	tests := []struct {
+		provider string
+		url      string
+		expected repository
+	}{
+		{
+			provider: webhook.ProviderGitea,
+			url:      "https://gitea.example.com/team/app.git",
+			expected: repository{provider: webhook.ProviderGitea, apiURL: "https://gitea.example.com", owner: "team", repo: "app"},
+		},
+		{
+			provider: webhook.ProviderGithub,
+			url:      "git@github.com:team/app.git",
+			expected: repository{provider: webhook.ProviderGithub, apiURL: "https://api.github.com", owner: "team", repo: "app"},
+		},
+		{
+			provider: webhook.ProviderGithub,
+			url:      "ssh://git@github.example.com:2222/team/app",
+			expected: repository{provider: webhook.ProviderGithub, apiURL: "https://github.example.com/api/v3", owner: "team", repo: "app"},
+		},
+		{
+			provider: webhook.ProviderGitlab,
+			url:      "http://gitlab.example.com/group/subgroup/app.git",
+			expected: repository{provider: webhook.ProviderGitlab, apiURL: "http://gitlab.example.com", owner: "group/subgroup", repo: "app"},
+		},
+		{
+			provider: webhook.ProviderBitbucketServer,
+			url:      "https://bitbucket.example.com/scm/team/app.git",
+			expected: repository{provider: webhook.ProviderBitbucketServer, apiURL: "https://bitbucket.example.com", owner: "team", repo: "app"},
+		},
+	}
+	for _, test := range tests {
+		repo, err := parseGitURL(test.provider, test.url, "")
+		if err != nil {
+			t.Errorf("url %s: unexpected error %v", test.url, err)
+			continue
+		}
+		if *repo != test.expected {
+			t.Errorf("url %s: expected %+v, got %+v", test.url, test.expected, *repo)
+		}
+	}
+
+	for _, invalid := range [][2]string{{"true", "https://gitea.example.com/team/app.git"}, {webhook.ProviderGitea, "https://gitea.example.com/app"}} {
+		if _, err := parseGitURL(invalid[0], invalid[1], ""); err == nil {
+			t.Errorf("expected %s of provider %s is invalid", invalid[1], invalid[0])
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	nodes := []devops.BranchPipelineRunNodes{
+		{DisplayName: "Build", State: stateFinished, Result: resultSuccess},
+		{DisplayName: "Approve", State: devops.StatePaused},
+	}
+	tests := []struct {
+		run      devops.PipelineRun
+		nodes    []devops.BranchPipelineRunNodes
+		expected string
+	}{
+		{run: devops.PipelineRun{State: stateQueued}, expected: "Queued"},
+		{run: devops.PipelineRun{State: devops.StatePaused}, nodes: nodes, expected: "Waiting for input in stage Approve, 1/2 stages passed"},
+		{run: devops.PipelineRun{State: stateRunning}, expected: "Running"},
+		{run: devops.PipelineRun{State: stateFinished, Result: resultSuccess}, nodes: nodes[:1], expected: "Succeeded, 1/1 stages passed"},
+		{run: devops.PipelineRun{State: stateFinished, Result: resultAborted}, nodes: nodes, expected: "Aborted, 1/2 stages passed"},
+		{run: devops.PipelineRun{State: stateFinished, Result: "NOT_BUILT"}, expected: "Not built"},
+	}
+	for _, test := range tests {
+		if got := summarize(&test.run, test.nodes); got != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, got)
+		}
+	}
+}
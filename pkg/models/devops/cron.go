@@ -0,0 +1,102 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emicklei/go-restful"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins/cron"
+)
+
+const (
+	DefaultCronPreviewCount = 5
+	MaxCronPreviewCount     = 100
+)
+
+type CronPreviewRequest struct {
+	Cron string `json:"cron"`
+	// PipelineName is the name of the pipeline hashing the H symbols, the H symbols are hashed by the project only
+	// if it is empty
+	PipelineName string `json:"pipelineName,omitempty"`
+	// Timezone is the IANA name of the location evaluating the lines without TZ, it's the local timezone by default
+	Timezone string `json:"timezone,omitempty"`
+	// Count is the number of the next trigger times, it's 5 by default and 100 at most
+	Count int `json:"count,omitempty"`
+}
+
+type CronPreview struct {
+	// LastTime is nil if the spec did not trigger in the last 5 years
+	LastTime  *time.Time  `json:"lastTime,omitempty"`
+	NextTimes []time.Time `json:"nextTimes"`
+}
+
+// PreviewCron gives the last and the next trigger times of the cron spec around now
+func PreviewCron(projectName string, request *CronPreviewRequest, now time.Time) (*CronPreview, error) {
+	location := time.Local
+	if request.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(request.Timezone); err != nil {
+			return nil, restful.NewError(http.StatusBadRequest, fmt.Sprintf("invalid timezone %s", request.Timezone))
+		}
+	}
+	count := request.Count
+	if count <= 0 {
+		count = DefaultCronPreviewCount
+	} else if count > MaxCronPreviewCount {
+		count = MaxCronPreviewCount
+	}
+
+	seed := projectName
+	if request.PipelineName != "" {
+		seed = projectName + "/" + request.PipelineName
+	}
+	spec, err := cron.Parse(request.Cron, seed)
+	if err != nil {
+		return nil, restful.NewError(http.StatusBadRequest, err.Error())
+	}
+
+	now = now.In(location)
+	preview := &CronPreview{NextTimes: spec.NextN(now, count)}
+	if last, ok := spec.Prev(now); ok {
+		preview.LastTime = &last
+	}
+	return preview, nil
+}
+
+// ValidatePipelineCron checks the cron spec of the timer trigger of the pipeline
+func ValidatePipelineCron(pipeline *devopsv1alpha3.Pipeline) error {
+	var trigger *devopsv1alpha3.TimerTrigger
+	switch {
+	case pipeline.Spec.Pipeline != nil:
+		trigger = pipeline.Spec.Pipeline.TimerTrigger
+	case pipeline.Spec.MultiBranchPipeline != nil:
+		trigger = pipeline.Spec.MultiBranchPipeline.TimerTrigger
+	}
+	if trigger == nil || trigger.Cron == "" {
+		return nil
+	}
+
+	if _, err := cron.Parse(trigger.Cron, pipeline.Namespace+"/"+pipeline.Name); err != nil {
+		return fmt.Errorf("invalid cron of the timer trigger: %v", err)
+	}
+	return nil
+}
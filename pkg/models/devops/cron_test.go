@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+)
+
+func TestPreviewCron(t *testing.T) {
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	preview, err := PreviewCron("project", &CronPreviewRequest{Cron: "30 8 * * 1-5", Timezone: "Asia/Shanghai", Count: 3}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"2020-06-02T08:30:00+08:00", "2020-06-03T08:30:00+08:00", "2020-06-04T08:30:00+08:00"}
+	if len(preview.NextTimes) != len(expected) {
+		t.Fatalf("expected %d trigger times, got %v", len(expected), preview.NextTimes)
+	}
+	for i := range expected {
+		if got := preview.NextTimes[i].Format(time.RFC3339); got != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], got)
+		}
+	}
+	if preview.LastTime == nil || preview.LastTime.Format(time.RFC3339) != "2020-06-01T08:30:00+08:00" {
+		t.Errorf("unexpected last time %v", preview.LastTime)
+	}
+
+	preview, err = PreviewCron("project", &CronPreviewRequest{Cron: "@hourly", PipelineName: "pipeline", Timezone: "UTC"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.NextTimes) != DefaultCronPreviewCount || preview.NextTimes[1].Sub(preview.NextTimes[0]) != time.Hour {
+		t.Errorf("expected %d hourly trigger times, got %v", DefaultCronPreviewCount, preview.NextTimes)
+	}
+
+	for _, request := range []*CronPreviewRequest{
+		{Cron: "0 25 * * *"},
+		{Cron: "@daily", Timezone: "Mars/Olympus_Mons"},
+	} {
+		_, err = PreviewCron("project", request, now)
+		if serviceErr, ok := err.(restful.ServiceError); !ok || serviceErr.Code != http.StatusBadRequest {
+			t.Errorf("expected bad request of %+v, got %v", request, err)
+		}
+	}
+}
+
+func TestValidatePipelineCron(t *testing.T) {
+	pipeline := &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: "project"},
+		Spec: devopsv1alpha3.PipelineSpec{
+			Type:     devopsv1alpha3.NoScmPipelineType,
+			Pipeline: &devopsv1alpha3.NoScmPipeline{Name: "pipeline"},
+		},
+	}
+	if err := ValidatePipelineCron(pipeline); err != nil {
+		t.Errorf("expected the pipeline without timer trigger is valid, got %v", err)
+	}
+
+	pipeline.Spec.Pipeline.TimerTrigger = &devopsv1alpha3.TimerTrigger{Cron: "TZ=Europe/London\nH H(0-7) * * *"}
+	if err := ValidatePipelineCron(pipeline); err != nil {
+		t.Errorf("expected the cron is valid, got %v", err)
+	}
+
+	pipeline.Spec.Pipeline.TimerTrigger.Cron = "H H * * 8"
+	if err := ValidatePipelineCron(pipeline); err == nil {
+		t.Error("expected the day of week 8 is invalid")
+	}
+}
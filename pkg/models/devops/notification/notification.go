@@ -0,0 +1,515 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	devopslister "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha3"
+)
+
+// The states and the results of the pipeline runs in BlueOcean
+const (
+	stateQueued   = "QUEUED"
+	stateRunning  = "RUNNING"
+	stateFinished = "FINISHED"
+
+	resultSuccess  = "SUCCESS"
+	resultFailure  = "FAILURE"
+	resultUnstable = "UNSTABLE"
+)
+
+// runLimit is the number of the latest runs of a pipeline watched
+const runLimit = 20
+
+// leaseName is the name of the lease electing the replica sending the notifications
+const leaseName = "devops-notifications"
+
+// Event is a state change of a pipeline run, the branch is empty for the regular pipelines
+type Event struct {
+	Type             string    `json:"type"`
+	Namespace        string    `json:"namespace"`
+	Pipeline         string    `json:"pipeline"`
+	Branch           string    `json:"branch,omitempty"`
+	Run              string    `json:"run"`
+	State            string    `json:"state"`
+	Result           string    `json:"result,omitempty"`
+	StartTime        string    `json:"startTime,omitempty"`
+	DurationInMillis int       `json:"durationInMillis,omitempty"`
+	Causes           []string  `json:"causes,omitempty"`
+	Time             time.Time `json:"time"`
+}
+
+// Delivery is the record of an event sent to a sink of a policy
+type Delivery struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Policy    string `json:"policy"`
+	Sink      string `json:"sink"`
+	SinkType  string `json:"sinkType"`
+	Event     Event  `json:"event"`
+	Attempts  int    `json:"attempts"`
+	Succeeded bool   `json:"succeeded"`
+	// Error is the error of the last attempt
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+type Operator interface {
+	// ListDeliveries returns the latest deliveries of the DevOps project sent by this replica, the newest first.
+	// The deliveries are kept in memory only, they are lost on restart and are empty on the replicas not elected.
+	ListDeliveries(namespace string, limit int) []Delivery
+
+	// Run watches the runs of the pipelines having notification policies while this replica holds the lease,
+	// until stopCh is closed
+	Run(stopCh <-chan struct{})
+}
+
+// runKey locates a pipeline run
+type runKey struct {
+	namespace, pipeline, branch, run string
+}
+
+// runState is the last observed state of a pipeline run
+type runState struct {
+	state, result string
+}
+
+type operator struct {
+	devopsClient   devops.Interface
+	kubeClient     kubernetes.Interface
+	pipelineLister devopslister.PipelineLister
+	policyLister   devopslister.NotificationPolicyLister
+	secretLister   corev1lister.SecretLister
+	options        *Options
+	sender         sender
+	retryInterval  time.Duration
+	now            func() time.Time
+
+	// inflight is the deliveries being sent, tests wait for them
+	inflight sync.WaitGroup
+	// watching serializes the watchers of the successive leaderships
+	watching sync.Mutex
+
+	lock sync.Mutex
+	runs map[runKey]runState
+	// lastResults is the result of the last finished run of every pipeline or branch
+	lastResults map[runKey]string
+	// primed is the pipelines synced once, the runs found in the first sync are not notified
+	primed     sets.String
+	deliveries []Delivery
+}
+
+func NewOperator(devopsClient devops.Interface, kubeClient kubernetes.Interface, pipelineLister devopslister.PipelineLister,
+	policyLister devopslister.NotificationPolicyLister, secretLister corev1lister.SecretLister, options *Options) Operator {
+	if options == nil {
+		options = NewOptions()
+	}
+	return &operator{
+		devopsClient:   devopsClient,
+		kubeClient:     kubeClient,
+		pipelineLister: pipelineLister,
+		policyLister:   policyLister,
+		secretLister:   secretLister,
+		options:        options,
+		sender:         &defaultSender{client: newHTTPClient(10*time.Second, options.allowedNetworks()), smtp: options.SMTP},
+		retryInterval:  5 * time.Second,
+		now:            time.Now,
+		runs:           make(map[runKey]runState),
+		lastResults:    make(map[runKey]string),
+		primed:         sets.NewString(),
+	}
+}
+
+func (o *operator) ListDeliveries(namespace string, limit int) []Delivery {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	deliveries := make([]Delivery, 0)
+	for i := len(o.deliveries) - 1; i >= 0 && (limit <= 0 || len(deliveries) < limit); i-- {
+		if o.deliveries[i].Namespace == namespace {
+			deliveries = append(deliveries, o.deliveries[i])
+		}
+	}
+	return deliveries
+}
+
+func (o *operator) Run(stopCh <-chan struct{}) {
+	hostname, _ := os.Hostname()
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: o.options.LeaseNamespace, Name: leaseName},
+		Client:     o.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: hostname + "_" + string(uuid.NewUUID())},
+	}
+	leaseDuration := o.options.LeaseDuration
+	if leaseDuration <= 0 {
+		leaseDuration = DefaultLeaseDuration
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	// the replica losing the lease stands for the election again
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   leaseDuration,
+			RenewDeadline:   leaseDuration * 2 / 3,
+			RetryPeriod:     leaseDuration / 7,
+			ReleaseOnCancel: true,
+			Name:            leaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					o.watch(ctx.Done())
+				},
+				OnStoppedLeading: func() {
+					klog.Info("stopped leading the pipeline run notifications")
+				},
+			},
+		})
+	}
+}
+
+// watch syncs the runs periodically until stopCh is closed. The runs are observed from scratch, so the runs changed
+// while another replica was leading are not notified again.
+func (o *operator) watch(stopCh <-chan struct{}) {
+	o.watching.Lock()
+	defer o.watching.Unlock()
+	period := o.options.SyncPeriod
+	if period <= 0 {
+		period = DefaultSyncPeriod
+	}
+	o.lock.Lock()
+	o.runs = make(map[runKey]runState)
+	o.lastResults = make(map[runKey]string)
+	o.primed = sets.NewString()
+	o.lock.Unlock()
+
+	klog.Info("starting the pipeline run notifications")
+	wait.Until(func() {
+		o.sync(stopCh)
+	}, period, stopCh)
+	klog.Info("shutting down the pipeline run notifications")
+}
+
+// sync compares the runs of the pipelines having policies with the last sync, and notifies the state changes
+func (o *operator) sync(stopCh <-chan struct{}) {
+	policies, err := o.policyLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	namespaces := sets.NewString()
+	for _, policy := range policies {
+		namespaces.Insert(policy.Namespace)
+	}
+
+	watched := sets.NewString()
+	for _, namespace := range namespaces.List() {
+		pipelines, err := o.pipelineLister.Pipelines(namespace).List(labels.Everything())
+		if err != nil {
+			klog.Error(err)
+			continue
+		}
+		for _, pipeline := range pipelines {
+			id := pipeline.Namespace + "/" + pipeline.Name
+			watched.Insert(id)
+			events, err := o.syncPipeline(pipeline)
+			if err != nil {
+				klog.Error(err)
+				continue
+			}
+			if o.primed.Has(id) {
+				for i := range events {
+					o.dispatch(&events[i], stopCh)
+				}
+			}
+			o.primed.Insert(id)
+		}
+	}
+
+	// forget the pipelines deleted or not watched any more
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for key := range o.runs {
+		if !watched.Has(key.namespace + "/" + key.pipeline) {
+			delete(o.runs, key)
+		}
+	}
+	for key := range o.lastResults {
+		if !watched.Has(key.namespace + "/" + key.pipeline) {
+			delete(o.lastResults, key)
+		}
+	}
+	for _, id := range o.primed.List() {
+		if !watched.Has(id) {
+			o.primed.Delete(id)
+		}
+	}
+}
+
+// syncPipeline returns the events of the runs of the pipeline since the last sync
+func (o *operator) syncPipeline(pipeline *devopsv1alpha3.Pipeline) ([]Event, error) {
+	httpParameters := &devops.HttpParameters{
+		Method: http.MethodGet,
+		Header: http.Header{},
+		Url:    &url.URL{RawQuery: fmt.Sprintf("start=0&limit=%d", runLimit)},
+	}
+	runs, err := o.devopsClient.ListPipelineRuns(pipeline.Namespace, pipeline.Name, httpParameters)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = &devops.PipelineRunList{}
+	}
+
+	listed := make(map[runKey]bool)
+	events := make([]Event, 0)
+	// the runs are listed the newest first, the older runs decide the last results first
+	for i := len(runs.Items) - 1; i >= 0; i-- {
+		run := &runs.Items[i]
+		key := runKey{namespace: pipeline.Namespace, pipeline: pipeline.Name, run: run.ID}
+		// the runs of a multi-branch pipeline are the runs of all its branches
+		if pipeline.Spec.Type == devopsv1alpha3.MultiBranchPipelineType {
+			key.branch = run.Pipeline
+		}
+		listed[key] = true
+		events = append(events, o.observe(key, run)...)
+	}
+
+	// the unfinished runs pushed out of the list by the newer runs are fetched one by one
+	o.lock.Lock()
+	var missing []runKey
+	for key, state := range o.runs {
+		if key.namespace == pipeline.Namespace && key.pipeline == pipeline.Name && !listed[key] {
+			if key.branch == "" && state.state != stateFinished {
+				missing = append(missing, key)
+			} else {
+				delete(o.runs, key)
+			}
+		}
+	}
+	o.lock.Unlock()
+	for _, key := range missing {
+		run, err := o.devopsClient.GetPipelineRun(key.namespace, key.pipeline, key.run, httpParameters)
+		if err != nil || run == nil {
+			if devops.GetDevOpsStatusCode(err) == http.StatusNotFound {
+				o.lock.Lock()
+				delete(o.runs, key)
+				o.lock.Unlock()
+			}
+			continue
+		}
+		events = append(events, o.observe(key, run)...)
+	}
+	return events, nil
+}
+
+// observe records the state of the run, and returns the events of its change
+func (o *operator) observe(key runKey, run *devops.PipelineRun) []Event {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	previous, seen := o.runs[key]
+	current := runState{state: run.State, result: run.Result}
+	o.runs[key] = current
+	if seen && previous == current {
+		return nil
+	}
+
+	var types []string
+	started := seen && previous.state != stateQueued && previous.state != ""
+	if !started && (current.state == stateRunning || current.state == devops.StatePaused) {
+		types = append(types, devopsv1alpha3.NotificationEventStarted)
+	}
+	if current.state == devops.StatePaused && previous.state != devops.StatePaused {
+		types = append(types, devopsv1alpha3.NotificationEventWaitingForInput)
+	}
+	if current.state == stateFinished && previous.state != stateFinished {
+		job := runKey{namespace: key.namespace, pipeline: key.pipeline, branch: key.branch}
+		lastResult := o.lastResults[job]
+		o.lastResults[job] = current.result
+		switch current.result {
+		case resultSuccess:
+			if lastResult == resultFailure || lastResult == resultUnstable {
+				types = append(types, devopsv1alpha3.NotificationEventFixed)
+			} else {
+				types = append(types, devopsv1alpha3.NotificationEventSucceeded)
+			}
+		case resultFailure, resultUnstable:
+			types = append(types, devopsv1alpha3.NotificationEventFailed)
+		}
+	} else if current.state == stateFinished {
+		o.lastResults[runKey{namespace: key.namespace, pipeline: key.pipeline, branch: key.branch}] = current.result
+	}
+
+	events := make([]Event, 0, len(types))
+	for _, eventType := range types {
+		event := Event{
+			Type:             eventType,
+			Namespace:        key.namespace,
+			Pipeline:         key.pipeline,
+			Branch:           key.branch,
+			Run:              key.run,
+			State:            run.State,
+			Result:           run.Result,
+			StartTime:        run.StartTime,
+			DurationInMillis: run.DurationInMillis,
+			Time:             o.now(),
+		}
+		for _, cause := range run.Causes {
+			event.Causes = append(event.Causes, cause.ShortDescription)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// dispatch sends the event to the sinks of the policies matching it
+func (o *operator) dispatch(event *Event, stopCh <-chan struct{}) {
+	policies, err := o.policyLister.NotificationPolicies(event.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return
+	}
+	for _, policy := range policies {
+		if !matches(policy, event) {
+			continue
+		}
+		for _, spec := range policy.Spec.Sinks {
+			delivery := Delivery{
+				ID:        rand.String(16),
+				Namespace: policy.Namespace,
+				Policy:    policy.Name,
+				Sink:      spec.Name,
+				SinkType:  spec.Type,
+				Event:     *event,
+			}
+			o.inflight.Add(1)
+			go func(spec devopsv1alpha3.NotificationSink) {
+				defer o.inflight.Done()
+				o.deliver(&delivery, spec, stopCh)
+			}(spec)
+		}
+	}
+}
+
+// deliver sends the event to the sink, and retries with a growing interval until it reaches the max attempts or
+// stopCh is closed
+func (o *operator) deliver(delivery *Delivery, spec devopsv1alpha3.NotificationSink, stopCh <-chan struct{}) {
+	defer o.record(delivery)
+
+	sink, err := o.newSink(delivery.Namespace, spec)
+	if err != nil {
+		delivery.Error = err.Error()
+		delivery.Time = o.now()
+		return
+	}
+
+	maxAttempts := o.options.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	interval := o.retryInterval
+	for delivery.Attempts < maxAttempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-stopCh:
+				delivery.Error = fmt.Sprintf("stopped before the retry: %s", delivery.Error)
+				return
+			case <-time.After(interval):
+			}
+			interval *= 2
+		}
+		delivery.Attempts++
+		err = sink.send(o.sender, &delivery.Event)
+		delivery.Time = o.now()
+		if err == nil {
+			delivery.Succeeded = true
+			delivery.Error = ""
+			return
+		}
+		delivery.Error = err.Error()
+		klog.Warningf("failed to send the notification of %s/%s to sink %s of policy %s, attempt %d: %v",
+			delivery.Event.Namespace, delivery.Event.Pipeline, spec.Name, delivery.Policy, delivery.Attempts, err)
+	}
+}
+
+// newSink resolves the url of the sink from its secret
+func (o *operator) newSink(namespace string, spec devopsv1alpha3.NotificationSink) (*sink, error) {
+	address := spec.URL
+	if spec.URLFrom != nil {
+		secret, err := o.secretLister.Secrets(namespace).Get(spec.URLFrom.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the url of sink %s: %v", spec.Name, err)
+		}
+		value, ok := secret.Data[spec.URLFrom.Key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret %s", spec.URLFrom.Key, spec.URLFrom.Name)
+		}
+		address = string(value)
+	}
+	return newSink(spec, address)
+}
+
+// record keeps the delivery in memory, the oldest ones are dropped after MaxDeliveries
+func (o *operator) record(delivery *Delivery) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	maxDeliveries := o.options.MaxDeliveries
+	if maxDeliveries <= 0 {
+		maxDeliveries = DefaultMaxDeliveries
+	}
+	o.deliveries = append(o.deliveries, *delivery)
+	if len(o.deliveries) > maxDeliveries {
+		o.deliveries = o.deliveries[len(o.deliveries)-maxDeliveries:]
+	}
+}
+
+// matches checks if the policy notifies the event, the policies notifying the succeeded runs notify the fixed ones
+func matches(policy *devopsv1alpha3.NotificationPolicy, event *Event) bool {
+	if len(policy.Spec.Pipelines) > 0 && !sets.NewString(policy.Spec.Pipelines...).Has(event.Pipeline) {
+		return false
+	}
+	events := sets.NewString(policy.Spec.Events...)
+	if events.Has(event.Type) {
+		return true
+	}
+	return event.Type == devopsv1alpha3.NotificationEventFixed && events.Has(devopsv1alpha3.NotificationEventSucceeded)
+}
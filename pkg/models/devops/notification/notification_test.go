@@ -0,0 +1,383 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+var now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// runsDevops lists the runs set by the tests
+type runsDevops struct {
+	*fakedevops.Devops
+	runs map[string][]devops.PipelineRun
+}
+
+func (d *runsDevops) ListPipelineRuns(projectName, pipelineName string, httpParameters *devops.HttpParameters) (*devops.PipelineRunList, error) {
+	return &devops.PipelineRunList{Items: d.runs[projectName+"/"+pipelineName]}, nil
+}
+
+// fakeSender records the notifications, the first failures requests fail
+type fakeSender struct {
+	lock     sync.Mutex
+	failures int
+	posts    []string
+	mails    []string
+}
+
+func (s *fakeSender) post(url string, header http.Header, body []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("the webhook responded 502")
+	}
+	s.posts = append(s.posts, url+" "+string(body))
+	return nil
+}
+
+func (s *fakeSender) sendMail(to []string, subject, body string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mails = append(s.mails, strings.Join(to, ",")+" "+subject+" "+body)
+	return nil
+}
+
+func newPolicy(name string, pipelines, events []string, sinks ...devopsv1alpha3.NotificationSink) *devopsv1alpha3.NotificationPolicy {
+	return &devopsv1alpha3.NotificationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project"},
+		Spec:       devopsv1alpha3.NotificationPolicySpec{Pipelines: pipelines, Events: events, Sinks: sinks},
+	}
+}
+
+func newTestOperator(client devops.Interface, policies ...*devopsv1alpha3.NotificationPolicy) (*operator, *fakeSender) {
+	informerFactory := ksinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	for _, name := range []string{"build", "deploy"} {
+		informerFactory.Devops().V1alpha3().Pipelines().Informer().GetIndexer().Add(&devopsv1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project"},
+			Spec:       devopsv1alpha3.PipelineSpec{Type: devopsv1alpha3.NoScmPipelineType},
+		})
+	}
+	for _, policy := range policies {
+		informerFactory.Devops().V1alpha3().NotificationPolicies().Informer().GetIndexer().Add(policy)
+	}
+	k8sInformerFactory := k8sinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	k8sInformerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "project"},
+		Data:       map[string][]byte{"url": []byte("https://hooks.slack.com/services/token")},
+	})
+
+	o := NewOperator(client, k8sfake.NewSimpleClientset(), informerFactory.Devops().V1alpha3().Pipelines().Lister(),
+		informerFactory.Devops().V1alpha3().NotificationPolicies().Lister(),
+		k8sInformerFactory.Core().V1().Secrets().Lister(), nil).(*operator)
+	sender := &fakeSender{}
+	o.sender = sender
+	o.retryInterval = 0
+	o.now = func() time.Time {
+		return now
+	}
+	return o, sender
+}
+
+func (o *operator) syncAndWait() {
+	o.sync(make(chan struct{}))
+	o.inflight.Wait()
+}
+
+func TestSync(t *testing.T) {
+	client := &runsDevops{Devops: fakedevops.New("project"), runs: map[string][]devops.PipelineRun{
+		"project/build": {{ID: "1", State: stateFinished, Result: resultFailure}},
+	}}
+	o, sender := newTestOperator(client,
+		newPolicy("team", nil,
+			[]string{devopsv1alpha3.NotificationEventStarted, devopsv1alpha3.NotificationEventSucceeded},
+			devopsv1alpha3.NotificationSink{Name: "hook", Type: devopsv1alpha3.NotificationSinkWebhook, URL: "http://hook",
+				Template: `{{.Pipeline}}#{{.Run}} {{.Type}}`},
+		),
+		newPolicy("deploy", []string{"deploy"}, []string{devopsv1alpha3.NotificationEventFailed},
+			devopsv1alpha3.NotificationSink{Name: "slack", Type: devopsv1alpha3.NotificationSinkSlack,
+				URLFrom: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "slack"}, Key: "url"}},
+		),
+	)
+
+	// the runs found in the first sync are not notified
+	o.syncAndWait()
+	if len(sender.posts) != 0 {
+		t.Fatalf("expected nothing is notified in the first sync, got %v", sender.posts)
+	}
+
+	steps := []struct {
+		runs     []devops.PipelineRun
+		expected []string
+	}{
+		{
+			runs:     []devops.PipelineRun{{ID: "2", State: stateQueued}},
+			expected: []string{},
+		},
+		{
+			runs:     []devops.PipelineRun{{ID: "2", State: stateRunning}},
+			expected: []string{"http://hook build#2 started"},
+		},
+		{
+			runs:     []devops.PipelineRun{{ID: "2", State: devops.StatePaused}},
+			expected: []string{},
+		},
+		{
+			// the policy notifying the succeeded runs notifies the fixed ones
+			runs:     []devops.PipelineRun{{ID: "2", State: stateFinished, Result: resultSuccess}},
+			expected: []string{"http://hook build#2 fixed"},
+		},
+		{
+			runs:     []devops.PipelineRun{{ID: "3", State: stateFinished, Result: resultSuccess}, {ID: "2", State: stateFinished, Result: resultSuccess}},
+			expected: []string{"http://hook build#3 succeeded"},
+		},
+	}
+	for i, step := range steps {
+		sender.posts = nil
+		client.runs["project/build"] = append(step.runs, devops.PipelineRun{ID: "1", State: stateFinished, Result: resultFailure})
+		o.syncAndWait()
+		if strings.Join(sender.posts, "\n") != strings.Join(step.expected, "\n") {
+			t.Errorf("step %d: expected %v, got %v", i, step.expected, sender.posts)
+		}
+	}
+
+	sender.posts = nil
+	failed := devops.PipelineRun{}
+	json.Unmarshal([]byte(`{"id": "1", "state": "FINISHED", "result": "FAILURE",
+		"causes": [{"shortDescription": "Started by user admin", "userId": "admin"}]}`), &failed)
+	client.runs["project/deploy"] = []devops.PipelineRun{failed}
+	o.syncAndWait()
+	if len(sender.posts) != 1 || sender.posts[0] != `https://hooks.slack.com/services/token {"text":"Pipeline project/deploy #1 failed, result: FAILURE, cause: Started by user admin"}` {
+		t.Errorf("expected the failure is notified to slack, got %v", sender.posts)
+	}
+
+	deliveries := o.ListDeliveries("project", 2)
+	if len(deliveries) != 2 || deliveries[0].Policy != "deploy" || deliveries[1].Event.Type != devopsv1alpha3.NotificationEventSucceeded ||
+		!deliveries[0].Succeeded || deliveries[0].Attempts != 1 {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	o, sender := newTestOperator(fakedevops.New("project"))
+	event := Event{Type: devopsv1alpha3.NotificationEventFailed, Namespace: "project", Pipeline: "build", Run: "1"}
+	spec := devopsv1alpha3.NotificationSink{Name: "hook", Type: devopsv1alpha3.NotificationSinkWebhook, URL: "http://hook"}
+
+	sender.failures = 2
+	delivery := &Delivery{Namespace: "project", Policy: "team", Sink: "hook", Event: event}
+	o.deliver(delivery, spec, make(chan struct{}))
+	if !delivery.Succeeded || delivery.Attempts != 3 || len(sender.posts) != 1 {
+		t.Errorf("expected the delivery succeeds in the third attempt, got %+v", delivery)
+	}
+
+	sender.failures = 3
+	delivery = &Delivery{Namespace: "project", Policy: "team", Sink: "hook", Event: event}
+	o.deliver(delivery, spec, make(chan struct{}))
+	if delivery.Succeeded || delivery.Attempts != 3 || delivery.Error != "the webhook responded 502" {
+		t.Errorf("expected the delivery fails after 3 attempts, got %+v", delivery)
+	}
+
+	delivery = &Delivery{Namespace: "project", Policy: "team", Sink: "chat", Event: event}
+	o.deliver(delivery, devopsv1alpha3.NotificationSink{Name: "chat", Type: devopsv1alpha3.NotificationSinkSlack,
+		URLFrom: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "url"}},
+		make(chan struct{}))
+	if delivery.Succeeded || delivery.Attempts != 0 || delivery.Error == "" {
+		t.Errorf("expected the delivery fails without the secret, got %+v", delivery)
+	}
+	if records := o.ListDeliveries("project", 0); len(records) != 3 {
+		t.Errorf("expected 3 deliveries recorded, got %d", len(records))
+	}
+
+	// the retries stop with the operator
+	o.retryInterval = time.Hour
+	stopCh := make(chan struct{})
+	close(stopCh)
+	sender.failures = 3
+	delivery = &Delivery{Namespace: "project", Policy: "team", Sink: "hook", Event: event}
+	o.deliver(delivery, spec, stopCh)
+	if delivery.Succeeded || delivery.Attempts != 1 || !strings.HasPrefix(delivery.Error, "stopped before the retry") {
+		t.Errorf("expected the delivery stops after the first attempt, got %+v", delivery)
+	}
+}
+
+func TestRunWithLease(t *testing.T) {
+	client := &runsDevops{Devops: fakedevops.New("project"), runs: map[string][]devops.PipelineRun{}}
+	o, _ := newTestOperator(client)
+	kubeClient := k8sfake.NewSimpleClientset()
+	o.kubeClient = kubeClient
+	o.options.LeaseDuration = time.Second
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		o.Run(stopCh)
+		close(done)
+	}()
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		lease, err := kubeClient.CoordinationV1().Leases(o.options.LeaseNamespace).Get(context.Background(), leaseName, metav1.GetOptions{})
+		return err == nil && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "", nil
+	})
+	if err != nil {
+		t.Fatalf("expected the lease is acquired: %v", err)
+	}
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the operator stops with stopCh")
+	}
+}
+
+func TestSinks(t *testing.T) {
+	event := &Event{Type: devopsv1alpha3.NotificationEventWaitingForInput, Namespace: "project", Pipeline: "build",
+		Branch: "master", Run: "7", State: devops.StatePaused}
+	tests := []struct {
+		spec     devopsv1alpha3.NotificationSink
+		expected string
+	}{
+		{
+			spec: devopsv1alpha3.NotificationSink{Name: "hook", Type: devopsv1alpha3.NotificationSinkWebhook, URL: "http://hook"},
+			expected: `http://hook {"type":"waiting-for-input","namespace":"project","pipeline":"build","branch":"master",` +
+				`"run":"7","state":"PAUSED","time":"0001-01-01T00:00:00Z"}`,
+		},
+		{
+			spec:     devopsv1alpha3.NotificationSink{Name: "ding", Type: devopsv1alpha3.NotificationSinkDingTalk, URL: "http://ding"},
+			expected: `http://ding {"msgtype":"text","text":{"content":"Pipeline project/build/master #7 waiting-for-input"}}`,
+		},
+		{
+			spec: devopsv1alpha3.NotificationSink{Name: "wecom", Type: devopsv1alpha3.NotificationSinkWeCom, URL: "http://wecom",
+				Template: `{{.Pipeline}} is waiting`},
+			expected: `http://wecom {"msgtype":"text","text":{"content":"build is waiting"}}`,
+		},
+		{
+			spec: devopsv1alpha3.NotificationSink{Name: "mail", Type: devopsv1alpha3.NotificationSinkEmail,
+				To: []string{"dev@example.com", "ops@example.com"}},
+			expected: `dev@example.com,ops@example.com [project] build/master #7 waiting-for-input ` +
+				`Pipeline project/build/master #7 waiting-for-input`,
+		},
+	}
+	for _, test := range tests {
+		s, err := newSink(test.spec, test.spec.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sender := &fakeSender{}
+		if err := s.send(sender, event); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(append(sender.posts, sender.mails...), "\n"); got != test.expected {
+			t.Errorf("sink %s: expected %s, got %s", test.spec.Name, test.expected, got)
+		}
+	}
+
+	invalid := []devopsv1alpha3.NotificationSink{
+		{Name: "no-url", Type: devopsv1alpha3.NotificationSinkSlack},
+		{Name: "no-recipient", Type: devopsv1alpha3.NotificationSinkEmail},
+		{Name: "unknown", Type: "sms", URL: "http://sms"},
+		{Name: "template", Type: devopsv1alpha3.NotificationSinkSlack, URL: "http://slack", Template: "{{.Pipeline"},
+	}
+	for _, spec := range invalid {
+		if _, err := newSink(spec, spec.URL); err == nil {
+			t.Errorf("expected sink %s is invalid", spec.Name)
+		}
+	}
+}
+
+func TestDefaultSenderPost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := map[string]interface{}{}
+		json.Unmarshal(body, &payload)
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		case "/token":
+			w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("internal secret"))
+		}
+	}))
+	defer server.Close()
+
+	sender := &defaultSender{client: newHTTPClient(time.Second, parseNetworks("127.0.0.0/8"))}
+	if err := sender.post(server.URL+"/ok", http.Header{}, []byte(`{}`)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := sender.post(server.URL+"/token", http.Header{}, []byte(`{}`)); err == nil ||
+		err.Error() != "the webhook responded error 310000" {
+		t.Errorf("expected the error code is an error, got %v", err)
+	}
+	if err := sender.post(server.URL+"/missing", http.Header{}, []byte(`{}`)); err == nil ||
+		err.Error() != "the webhook responded 404" {
+		t.Errorf("expected 404 is an error without the response, got %v", err)
+	}
+	if err := sender.post("file:///etc/passwd", http.Header{}, []byte(`{}`)); err == nil {
+		t.Errorf("expected the url which is not http is rejected")
+	}
+
+	// the loopback and private addresses are blocked by default, even if a name resolves to them
+	blocked := &defaultSender{client: newHTTPClient(time.Second, nil)}
+	for _, url := range []string{server.URL + "/ok", strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/ok",
+		"http://169.254.169.254/latest/meta-data/"} {
+		if err := blocked.post(url, http.Header{}, []byte(`{}`)); err != errDestinationNotAllowed {
+			t.Errorf("expected %s is not allowed, got %v", url, err)
+		}
+	}
+}
+
+func TestDefaultSenderSendMail(t *testing.T) {
+	sender := &defaultSender{}
+	if err := sender.sendMail([]string{"dev@example.com"}, "subject", "body"); err == nil {
+		t.Errorf("expected the email fails without the smtp server")
+	}
+
+	sender.smtp = SMTPOptions{Host: "127.0.0.1", Port: 1, From: "devops@example.com", AllowedDomains: []string{"example.com"}}
+	if err := sender.sendMail([]string{"dev@Example.com", "someone@attacker.com"}, "subject", "body"); err == nil ||
+		err.Error() != "recipient someone@attacker.com is not in the allowed domains of the smtp server" {
+		t.Errorf("expected the recipient out of the allowed domains is rejected, got %v", err)
+	}
+}
+
+func TestMailMessage(t *testing.T) {
+	message := string(mailMessage("devops@example.com", []string{"dev@example.com"}, "build\r\nBcc: evil@example.com", "body"))
+	if !strings.Contains(message, "Subject: build  Bcc: evil@example.com\r\n") || !strings.HasSuffix(message, "\r\n\r\nbody") {
+		t.Errorf("unexpected message %q", message)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"devops.kubesphere.io/plugin/pkg/constants"
+)
+
+const (
+	// DefaultSyncPeriod is the period to look for the state changes of the pipeline runs
+	DefaultSyncPeriod = 30 * time.Second
+	// DefaultMaxAttempts is the number of the attempts of a delivery before it fails
+	DefaultMaxAttempts = 3
+	// DefaultMaxDeliveries is the number of the latest deliveries kept in memory
+	DefaultMaxDeliveries = 200
+	// DefaultLeaseDuration is the duration the other replicas wait before they take over the lease of the
+	// notifications
+	DefaultLeaseDuration = 15 * time.Second
+)
+
+// Options configures the notifications of the pipeline runs, the SMTP server is shared by all the email sinks.
+// Only the replica holding the lease in LeaseNamespace watches the pipeline runs, so every notification is sent once.
+// The sinks never post to the loopback, link-local and private addresses except the AllowedNetworks.
+type Options struct {
+	SyncPeriod    time.Duration `json:"syncPeriod,omitempty" yaml:"syncPeriod,omitempty" mapstructure:"syncPeriod"`
+	MaxAttempts   int           `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty" mapstructure:"maxAttempts"`
+	MaxDeliveries int           `json:"maxDeliveries,omitempty" yaml:"maxDeliveries,omitempty" mapstructure:"maxDeliveries"`
+	SMTP          SMTPOptions   `json:"smtp,omitempty" yaml:"smtp,omitempty" mapstructure:"smtp"`
+	// AllowedNetworks are the CIDRs of the private networks which the sinks may post to, e.g. a chat server
+	// in the intranet
+	AllowedNetworks []string `json:"allowedNetworks,omitempty" yaml:"allowedNetworks,omitempty" mapstructure:"allowedNetworks"`
+	// LeaseNamespace is the namespace of the lease electing the replica sending the notifications
+	LeaseNamespace string        `json:"leaseNamespace,omitempty" yaml:"leaseNamespace,omitempty" mapstructure:"leaseNamespace"`
+	LeaseDuration  time.Duration `json:"leaseDuration,omitempty" yaml:"leaseDuration,omitempty" mapstructure:"leaseDuration"`
+}
+
+// SMTPOptions is the SMTP server sending the emails, the email sinks fail if Host is empty. The emails are sent
+// with the shared credentials, so they are only sent to the recipients in AllowedDomains.
+type SMTPOptions struct {
+	Host     string `json:"host,omitempty" yaml:"host,omitempty" mapstructure:"host"`
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty" mapstructure:"port"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty" mapstructure:"username"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty" mapstructure:"password"`
+	From     string `json:"from,omitempty" yaml:"from,omitempty" mapstructure:"from"`
+	// AllowedDomains are the domains of the recipients, e.g. the domain of the company
+	AllowedDomains []string `json:"allowedDomains,omitempty" yaml:"allowedDomains,omitempty" mapstructure:"allowedDomains"`
+}
+
+func (o SMTPOptions) allowsRecipient(recipient string) bool {
+	at := strings.LastIndex(recipient, "@")
+	if at < 0 {
+		return false
+	}
+	domain := recipient[at+1:]
+	for _, allowed := range o.AllowedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedNetworks parses AllowedNetworks, the invalid CIDRs are reported by Validate
+func (o *Options) allowedNetworks() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range o.AllowedNetworks {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+func NewOptions() *Options {
+	return &Options{
+		SyncPeriod:     DefaultSyncPeriod,
+		MaxAttempts:    DefaultMaxAttempts,
+		MaxDeliveries:  DefaultMaxDeliveries,
+		SMTP:           SMTPOptions{Port: 25},
+		LeaseNamespace: constants.KubesphereDevOpsNamespace,
+		LeaseDuration:  DefaultLeaseDuration,
+	}
+}
+
+// Validate check options
+func (o *Options) Validate() []error {
+	errors := make([]error, 0)
+	if o == nil {
+		return errors
+	}
+
+	if o.SyncPeriod < 0 {
+		errors = append(errors, fmt.Errorf("syncPeriod of notification must not be negative"))
+	}
+	if o.MaxAttempts < 0 {
+		errors = append(errors, fmt.Errorf("maxAttempts of notification must not be negative"))
+	}
+	if o.MaxDeliveries < 0 {
+		errors = append(errors, fmt.Errorf("maxDeliveries of notification must not be negative"))
+	}
+	if o.LeaseDuration < 0 || (o.LeaseDuration > 0 && o.LeaseDuration < time.Second) {
+		errors = append(errors, fmt.Errorf("leaseDuration of notification must be at least 1s"))
+	}
+	if o.SMTP.Host != "" && (o.SMTP.Port <= 0 || o.SMTP.Port > 65535) {
+		errors = append(errors, fmt.Errorf("port of smtp server %d is invalid", o.SMTP.Port))
+	}
+	if o.SMTP.Host != "" && o.SMTP.From == "" {
+		errors = append(errors, fmt.Errorf("from of smtp server is required"))
+	}
+	for _, cidr := range o.AllowedNetworks {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errors = append(errors, fmt.Errorf("allowed network %s of notification is invalid: %v", cidr, err))
+		}
+	}
+	return errors
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+)
+
+// The default templates of the messages, they are used if the sink has no template
+const (
+	defaultMessageTemplate = `Pipeline {{.Namespace}}/{{.Pipeline}}{{if .Branch}}/{{.Branch}}{{end}} #{{.Run}} {{.Type}}` +
+		`{{if .Result}}, result: {{.Result}}{{end}}{{if .Causes}}, cause: {{index .Causes 0}}{{end}}`
+	defaultSubjectTemplate = `[{{.Namespace}}] {{.Pipeline}}{{if .Branch}}/{{.Branch}}{{end}} #{{.Run}} {{.Type}}`
+)
+
+// sender sends the rendered notifications, it is replaced in tests
+type sender interface {
+	post(url string, header http.Header, body []byte) error
+	sendMail(to []string, subject, body string) error
+}
+
+// sink renders the events and sends them to its destination
+type sink struct {
+	spec     devopsv1alpha3.NotificationSink
+	url      string
+	template *template.Template
+	subject  *template.Template
+}
+
+func newSink(spec devopsv1alpha3.NotificationSink, url string) (*sink, error) {
+	s := &sink{spec: spec, url: url}
+	switch spec.Type {
+	case devopsv1alpha3.NotificationSinkWebhook, devopsv1alpha3.NotificationSinkSlack,
+		devopsv1alpha3.NotificationSinkDingTalk, devopsv1alpha3.NotificationSinkWeCom:
+		if url == "" {
+			return nil, fmt.Errorf("the url of sink %s is required", spec.Name)
+		}
+	case devopsv1alpha3.NotificationSinkEmail:
+		if len(spec.To) == 0 {
+			return nil, fmt.Errorf("the recipients of sink %s are required", spec.Name)
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %q of sink %s", spec.Type, spec.Name)
+	}
+
+	text := spec.Template
+	if text == "" && spec.Type != devopsv1alpha3.NotificationSinkWebhook {
+		text = defaultMessageTemplate
+	}
+	var err error
+	if text != "" {
+		if s.template, err = template.New(spec.Name).Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid template of sink %s: %v", spec.Name, err)
+		}
+	}
+	if spec.Type == devopsv1alpha3.NotificationSinkEmail {
+		text = spec.Subject
+		if text == "" {
+			text = defaultSubjectTemplate
+		}
+		if s.subject, err = template.New(spec.Name).Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid subject of sink %s: %v", spec.Name, err)
+		}
+	}
+	return s, nil
+}
+
+// send renders the event in the format of the sink type and sends it
+func (s *sink) send(sender sender, event *Event) error {
+	var message string
+	if s.template != nil {
+		buf := &bytes.Buffer{}
+		if err := s.template.Execute(buf, event); err != nil {
+			return fmt.Errorf("failed to render the template: %v", err)
+		}
+		message = buf.String()
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	var body interface{}
+	switch s.spec.Type {
+	case devopsv1alpha3.NotificationSinkWebhook:
+		for key, value := range s.spec.Headers {
+			header.Set(key, value)
+		}
+		if s.template != nil {
+			return sender.post(s.url, header, []byte(message))
+		}
+		body = event
+	case devopsv1alpha3.NotificationSinkSlack:
+		body = map[string]interface{}{"text": message}
+	case devopsv1alpha3.NotificationSinkDingTalk, devopsv1alpha3.NotificationSinkWeCom:
+		body = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": message},
+		}
+	case devopsv1alpha3.NotificationSinkEmail:
+		buf := &bytes.Buffer{}
+		if err := s.subject.Execute(buf, event); err != nil {
+			return fmt.Errorf("failed to render the subject: %v", err)
+		}
+		return sender.sendMail(s.spec.To, buf.String(), message)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return sender.post(s.url, header, data)
+}
+
+// maxResponseSize limits the response of a sink read for its error code
+const maxResponseSize = 64 << 10
+
+var errDestinationNotAllowed = errors.New("the destination of the sink is not allowed")
+
+// blockedNetworks are the loopback, link-local, private and other special purpose networks, the sinks are
+// configured by the tenants, so they must not reach the services in the cluster or the metadata of the cloud
+var blockedNetworks = parseNetworks(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// newHTTPClient returns the client posting to the sinks. The addresses are checked after the names are resolved,
+// including the redirects, so a public name can't be resolved to a blocked address. No proxy is used, because the
+// proxy would connect to the destination instead.
+func newHTTPClient(timeout time.Duration, allowedNetworks []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || (containsIP(blockedNetworks, ip) && !containsIP(allowedNetworks, ip)) {
+				return errDestinationNotAllowed
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
+
+// defaultSender sends the notifications with the HTTP client and the SMTP server
+type defaultSender struct {
+	client *http.Client
+	smtp   SMTPOptions
+}
+
+// post sends the body to the sink, the response is never in the error, because the error is shown to the tenants
+func (s *defaultSender) post(rawURL string, header http.Header, body []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("the url of the sink must be http or https")
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := s.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errDestinationNotAllowed) {
+			return errDestinationNotAllowed
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("the webhook responded %d", resp.StatusCode)
+	}
+
+	// DingTalk and WeCom respond 200 with an error code
+	result := struct {
+		ErrCode *int `json:"errcode"`
+	}{}
+	if json.Unmarshal(data, &result) == nil && result.ErrCode != nil && *result.ErrCode != 0 {
+		return fmt.Errorf("the webhook responded error %d", *result.ErrCode)
+	}
+	return nil
+}
+
+func (s *defaultSender) sendMail(to []string, subject, body string) error {
+	if s.smtp.Host == "" {
+		return fmt.Errorf("the smtp server is not configured")
+	}
+	for _, recipient := range to {
+		if !s.smtp.allowsRecipient(recipient) {
+			return fmt.Errorf("recipient %s is not in the allowed domains of the smtp server", recipient)
+		}
+	}
+	var auth smtp.Auth
+	if s.smtp.Username != "" {
+		auth = smtp.PlainAuth("", s.smtp.Username, s.smtp.Password, s.smtp.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(s.smtp.Host, strconv.Itoa(s.smtp.Port)), auth, s.smtp.From, to,
+		mailMessage(s.smtp.From, to, subject, body))
+}
+
+func mailMessage(from string, to []string, subject, body string) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(to, ", "))
+	// the subject is a single line, the rendered template must not inject headers
+	fmt.Fprintf(buf, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(subject))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buf.WriteString(body)
+	return buf.Bytes()
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	devopslister "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha3"
+	devopsmodel "devops.kubesphere.io/plugin/pkg/models/devops"
+)
+
+// The states of a queued item
+const (
+	// StateWaiting is the item in its quiet period
+	StateWaiting = "waiting"
+	// StateBlocked is the item blocked by another build, such as the running build of a pipeline disallowing
+	// concurrent builds
+	StateBlocked = "blocked"
+	// StateBuildable is the item waiting for an executor
+	StateBuildable = "buildable"
+)
+
+// Item is a pipeline, or a node block of a running pipeline, waiting in the build queue of Jenkins
+type Item struct {
+	ID        int64  `json:"id"`
+	Namespace string `json:"namespace"`
+	Pipeline  string `json:"pipeline"`
+	Branch    string `json:"branch,omitempty"`
+	// Run is the pipeline run waiting for an executor of its node block, it is empty if the pipeline is not started
+	Run   string `json:"run,omitempty"`
+	State string `json:"state"`
+	// Stuck is true if the item is buildable but waits for an executor too long
+	Stuck bool `json:"stuck"`
+	// Reason is the reason of the blockage given by Jenkins
+	Reason string `json:"reason,omitempty"`
+	// Labels is the requested labels of the agents
+	Labels               []string  `json:"labels,omitempty"`
+	InQueueSince         time.Time `json:"inQueueSince"`
+	WaitDurationInMillis int64     `json:"waitDurationInMillis"`
+	// Position is the place in the queue of Jenkins, the item at position 1 is likely built first
+	Position int `json:"position"`
+}
+
+// Operator lists and cancels the queued items, the queue can't be reordered, see devops.QueueOperator
+type Operator interface {
+	// ListItems returns the queued items the user can see, in all the DevOps projects or in the given one,
+	// the item likely built first comes first
+	ListItems(user user.Info, namespace string) ([]Item, error)
+
+	// Cancel removes the item from the queue
+	Cancel(user user.Info, namespace string, id int64) error
+}
+
+type operator struct {
+	devopsClient   devops.Interface
+	pipelineLister devopslister.PipelineLister
+	authorizer     authorizer.Authorizer
+	now            func() time.Time
+}
+
+func NewOperator(devopsClient devops.Interface, pipelineLister devopslister.PipelineLister,
+	authorizer authorizer.Authorizer) Operator {
+	return &operator{
+		devopsClient:   devopsClient,
+		pipelineLister: pipelineLister,
+		authorizer:     authorizer,
+		now:            time.Now,
+	}
+}
+
+func (o *operator) ListItems(user user.Info, namespace string) ([]Item, error) {
+	var namespaces []string
+	if namespace != "" {
+		if err := devopsmodel.AuthorizePipelines(o.authorizer, user, "get", namespace); err != nil {
+			return nil, err
+		}
+		namespaces = []string{namespace}
+	} else {
+		pipelines, err := o.pipelineLister.List(labels.Everything())
+		if err != nil {
+			klog.Error(err)
+			return nil, err
+		}
+		// the projects without pipelines have nothing in the queue
+		checked := make(map[string]bool)
+		for _, pipeline := range pipelines {
+			if checked[pipeline.Namespace] {
+				continue
+			}
+			checked[pipeline.Namespace] = true
+			if devopsmodel.AuthorizePipelines(o.authorizer, user, "get", pipeline.Namespace) == nil {
+				namespaces = append(namespaces, pipeline.Namespace)
+			}
+		}
+	}
+
+	items := make([]Item, 0)
+	if len(namespaces) == 0 {
+		return items, nil
+	}
+	queued, err := o.devopsClient.ListQueueItems(namespaces...)
+	if err != nil {
+		klog.Error(err)
+		if namespace != "" {
+			return nil, restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+		}
+		// the Jenkins failing do not hide the queued items of the others
+	}
+	now := o.now()
+	for _, item := range queued {
+		items = append(items, newItem(item, now))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Position != items[j].Position {
+			return items[i].Position < items[j].Position
+		}
+		return items[i].InQueueSince.Before(items[j].InQueueSince)
+	})
+	return items, nil
+}
+
+func (o *operator) Cancel(user user.Info, namespace string, id int64) error {
+	if err := devopsmodel.AuthorizePipelines(o.authorizer, user, "update", namespace); err != nil {
+		return err
+	}
+	if err := o.devopsClient.CancelQueueItem(namespace, id); err != nil {
+		return queueError(err, namespace, id)
+	}
+	return nil
+}
+
+func newItem(queued devops.QueueItem, now time.Time) Item {
+	item := Item{
+		ID:        queued.ID,
+		Namespace: queued.Project,
+		Pipeline:  queued.Pipeline,
+		Branch:    queued.Branch,
+		Run:       queued.RunID,
+		State:     StateWaiting,
+		Stuck:     queued.Stuck,
+		Reason:    queued.Why,
+		Labels:    queued.Labels,
+		Position:  queued.Position,
+	}
+	switch {
+	case queued.Blocked:
+		item.State = StateBlocked
+	case queued.Buildable:
+		item.State = StateBuildable
+	}
+	if queued.InQueueSince > 0 {
+		item.InQueueSince = time.Unix(0, queued.InQueueSince*int64(time.Millisecond))
+		if wait := now.Sub(item.InQueueSince); wait > 0 {
+			item.WaitDurationInMillis = int64(wait / time.Millisecond)
+		}
+	}
+	return item
+}
+
+func queueError(err error, namespace string, id int64) error {
+	klog.Error(err)
+	code := devops.GetDevOpsStatusCode(err)
+	if code == http.StatusNotFound {
+		return restful.NewError(code, fmt.Sprintf("item %d is not in the queue of %s", id, namespace))
+	}
+	return restful.NewError(code, err.Error())
+}
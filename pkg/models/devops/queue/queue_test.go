@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queue
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/apiserver/authorization/authorizer"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+var now = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestOperator(client devops.Interface, deniedProjects []string, projects ...string) *operator {
+	informerFactory := ksinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	for _, project := range projects {
+		informerFactory.Devops().V1alpha3().Pipelines().Informer().GetIndexer().Add(&devopsv1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: project},
+		})
+	}
+	authorize := authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+		for _, project := range deniedProjects {
+			if a.GetDevOps() == project {
+				return authorizer.DecisionDeny, "not a member", nil
+			}
+		}
+		return authorizer.DecisionAllow, "", nil
+	})
+	o := NewOperator(client, informerFactory.Devops().V1alpha3().Pipelines().Lister(), authorize).(*operator)
+	o.now = func() time.Time {
+		return now
+	}
+	return o
+}
+
+func newFakeDevops() *fakedevops.Devops {
+	client := fakedevops.New("project", "other")
+	client.Queue = map[string][]devops.QueueItem{
+		"project": {
+			{ID: 12, Pipeline: "pipeline", RunID: "3", Buildable: true, Stuck: true, Position: 2,
+				Why: "Waiting for next available executor on ‘maven’", Labels: []string{"maven"},
+				InQueueSince: now.Add(-time.Hour).UnixNano() / int64(time.Millisecond)},
+			{ID: 15, Pipeline: "pipeline", Blocked: true, Position: 3,
+				Why: "Build #3 is already in progress", InQueueSince: now.Add(-time.Minute).UnixNano() / int64(time.Millisecond)},
+		},
+		"other": {
+			{ID: 13, Pipeline: "pipeline", Branch: "master", Buildable: true, Position: 1},
+		},
+	}
+	return client
+}
+
+func expectStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	if serviceErr, ok := err.(restful.ServiceError); !ok || serviceErr.Code != status {
+		t.Fatalf("expected status %d, got %v", status, err)
+	}
+}
+
+func TestListItems(t *testing.T) {
+	o := newTestOperator(newFakeDevops(), []string{"other"}, "project", "other")
+	rick := &user.DefaultInfo{Name: "rick"}
+
+	items, err := o.ListItems(rick, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != 12 || items[1].ID != 15 {
+		t.Fatalf("expected the items of project in the queue order, got %+v", items)
+	}
+	if items[0].State != StateBuildable || !items[0].Stuck || items[0].Run != "3" ||
+		items[0].WaitDurationInMillis != int64(time.Hour/time.Millisecond) || len(items[0].Labels) != 1 {
+		t.Errorf("unexpected item %+v", items[0])
+	}
+	if items[1].State != StateBlocked || items[1].Reason != "Build #3 is already in progress" {
+		t.Errorf("unexpected item %+v", items[1])
+	}
+
+	_, err = o.ListItems(rick, "other")
+	expectStatus(t, err, http.StatusForbidden)
+}
+
+func TestCancel(t *testing.T) {
+	client := newFakeDevops()
+	o := newTestOperator(client, []string{"other"}, "project", "other")
+	rick := &user.DefaultInfo{Name: "rick"}
+
+	expectStatus(t, o.Cancel(rick, "other", 13), http.StatusForbidden)
+	expectStatus(t, o.Cancel(rick, "project", 13), http.StatusNotFound)
+	if err := o.Cancel(rick, "project", 12); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Queue["project"]) != 1 || client.Queue["project"][0].ID != 15 {
+		t.Errorf("expected the item 12 is cancelled, got %+v", client.Queue["project"])
+	}
+}
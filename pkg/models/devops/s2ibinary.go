@@ -0,0 +1,406 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	devopsinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/devops/v1alpha1"
+	devopslisters "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/client/storage"
+	"devops.kubesphere.io/plugin/pkg/utils/sliceutil"
+)
+
+// S2iBinaryDownloadPath is the path of API which downloads the binary
+const S2iBinaryDownloadPath = "/kapis/devops.kubesphere.io/v1alpha2/namespaces/%s/s2ibinaries/%s/file/%s"
+
+// S2iBinaryDownloadTokenParameter is the query parameter of the download URL which lets the S2I builders
+// download the binary without credentials, the token is known to the users who can get the S2iBinary only
+const S2iBinaryDownloadTokenParameter = "token"
+
+// S2iBinaryObjectPrefix returns the prefix of all the objects stored for the S2iBinary
+func S2iBinaryObjectPrefix(namespace, name string) string {
+	return fmt.Sprintf("s2ibinary/%s/%s/", namespace, name)
+}
+
+func s2iBinaryKey(namespace, name string) string {
+	return S2iBinaryObjectPrefix(namespace, name) + "binary"
+}
+
+// s2iBinaryChunkPrefix is the prefix of the chunks uploaded but not concatenated yet
+func s2iBinaryChunkPrefix(namespace, name string) string {
+	return S2iBinaryObjectPrefix(namespace, name) + "chunks/"
+}
+
+// S2iBinaryFile is the whole binary uploaded by a single request
+type S2iBinaryFile struct {
+	FileName string
+	// MD5 is verified if it is not empty
+	MD5    string
+	Size   int64
+	Reader io.Reader
+}
+
+// S2iBinaryChunk is a part of the binary, the chunks are uploaded in order, and the binary is stored
+// once the last one is uploaded. An upload which is interrupted resumes from the uploaded bytes,
+// and the chunk starts from 0 restarts the upload.
+type S2iBinaryChunk struct {
+	FileName string
+	// MD5 of the whole binary, it is verified when the last chunk is uploaded
+	MD5 string
+	// Start is the offset of chunk, Size is the size of chunk, Total is the size of the whole binary
+	Start  int64
+	Size   int64
+	Total  int64
+	Reader io.Reader
+}
+
+type S2iBinaryUploadStatus struct {
+	// UploadedBytes is the size of uploaded chunks, the next chunk starts from it
+	UploadedBytes int64  `json:"uploadedBytes"`
+	Phase         string `json:"phase,omitempty"`
+	// S2iBinary is returned when the last chunk is uploaded
+	S2iBinary *devopsv1alpha1.S2iBinary `json:"s2ibinary,omitempty"`
+}
+
+type S2iBinaryOperator interface {
+	// UploadS2iBinary stores the binary, then updates the spec and phase of the S2iBinary
+	UploadS2iBinary(namespace, name string, file *S2iBinaryFile) (*devopsv1alpha1.S2iBinary, error)
+
+	// UploadS2iBinaryChunk stores the chunk, the S2iBinary is updated when the last chunk is uploaded
+	UploadS2iBinaryChunk(namespace, name string, chunk *S2iBinaryChunk) (*S2iBinaryUploadStatus, error)
+
+	// GetS2iBinaryUploadStatus returns the progress of the chunked upload
+	GetS2iBinaryUploadStatus(namespace, name string) (*S2iBinaryUploadStatus, error)
+
+	// DownloadS2iBinary returns the content of binary, fileName has to be the one of the ready S2iBinary. The token
+	// of the download URL is verified if it is not empty, the download without it has to be authorized by caller.
+	DownloadS2iBinary(namespace, name, fileName, token string) (io.ReadCloser, *devopsv1alpha1.S2iBinary, error)
+}
+
+type s2iBinaryOperator struct {
+	ksclient        kubesphere.Interface
+	s2iBinaryLister devopslisters.S2iBinaryLister
+	storage         storage.Interface
+	downloadServer  string
+
+	mutex sync.Mutex
+	// uploads are the locks of the S2iBinaries being uploaded, the uploads to the same S2iBinary through this
+	// replica are serialized
+	uploads map[string]*uploadLock
+}
+
+type uploadLock struct {
+	sync.Mutex
+	// waiters is the number of the uploads holding or waiting for the lock
+	waiters int
+}
+
+func NewS2iBinaryOperator(ksclient kubesphere.Interface, s2iBinaryInformer devopsinformers.S2iBinaryInformer,
+	objectStorage storage.Interface, downloadServer string) S2iBinaryOperator {
+	return &s2iBinaryOperator{
+		ksclient:        ksclient,
+		s2iBinaryLister: s2iBinaryInformer.Lister(),
+		storage:         objectStorage,
+		downloadServer:  strings.TrimSuffix(downloadServer, "/"),
+		uploads:         map[string]*uploadLock{},
+	}
+}
+
+// lockUpload waits until the other uploads to the S2iBinary finish, it returns the function which unlocks it
+func (o *s2iBinaryOperator) lockUpload(namespace, name string) func() {
+	key := namespace + "/" + name
+	o.mutex.Lock()
+	lock, ok := o.uploads[key]
+	if !ok {
+		lock = &uploadLock{}
+		o.uploads[key] = lock
+	}
+	lock.waiters++
+	o.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		o.mutex.Lock()
+		if lock.waiters--; lock.waiters == 0 {
+			delete(o.uploads, key)
+		}
+		o.mutex.Unlock()
+	}
+}
+
+func (o *s2iBinaryOperator) UploadS2iBinary(namespace, name string, file *S2iBinaryFile) (*devopsv1alpha1.S2iBinary, error) {
+	defer o.lockUpload(namespace, name)()
+
+	if err := o.startUpload(namespace, name); err != nil {
+		return nil, err
+	}
+
+	hash := md5.New()
+	if err := o.storage.Upload(s2iBinaryKey(namespace, name), io.TeeReader(file.Reader, hash), file.Size); err != nil {
+		klog.Error(err)
+		o.failUpload(namespace, name)
+		return nil, err
+	}
+	return o.completeUpload(namespace, name, file.FileName, file.MD5, hex.EncodeToString(hash.Sum(nil)), file.Size)
+}
+
+func (o *s2iBinaryOperator) UploadS2iBinaryChunk(namespace, name string, chunk *S2iBinaryChunk) (*S2iBinaryUploadStatus, error) {
+	if chunk.Start < 0 || chunk.Size <= 0 || chunk.Start+chunk.Size > chunk.Total {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid chunk of bytes %d-%d/%d",
+			chunk.Start, chunk.Start+chunk.Size-1, chunk.Total))
+	}
+	// the chunks are checked against the uploaded bytes, so they are uploaded one at a time
+	defer o.lockUpload(namespace, name)()
+
+	prefix := s2iBinaryChunkPrefix(namespace, name)
+	if chunk.Start == 0 {
+		// the first chunk restarts the upload, the stale chunks are dropped
+		if err := o.deleteObjects(prefix); err != nil {
+			return nil, err
+		}
+		if err := o.startUpload(namespace, name); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := o.s2iBinaryLister.S2iBinaries(namespace).Get(name); err != nil {
+			return nil, err
+		}
+		_, uploaded, err := o.listChunks(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if uploaded != chunk.Start {
+			return nil, errors.NewConflict(devopsv1alpha1.Resource(devopsv1alpha1.ResourcePluralS2iBinary), name,
+				fmt.Errorf("the next chunk has to start from %d", uploaded))
+		}
+	}
+
+	if err := o.storage.Upload(fmt.Sprintf("%s%020d", prefix, chunk.Start), chunk.Reader, chunk.Size); err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	if chunk.Start+chunk.Size < chunk.Total {
+		return &S2iBinaryUploadStatus{UploadedBytes: chunk.Start + chunk.Size, Phase: devopsv1alpha1.StatusUploading}, nil
+	}
+
+	// the last chunk is uploaded, concatenate all of them into the binary
+	chunks, uploaded, err := o.listChunks(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if uploaded != chunk.Total {
+		return nil, errors.NewConflict(devopsv1alpha1.Resource(devopsv1alpha1.ResourcePluralS2iBinary), name,
+			fmt.Errorf("%d bytes are uploaded, but the total size is %d", uploaded, chunk.Total))
+	}
+
+	hash := md5.New()
+	reader := &chunksReader{storage: o.storage, chunks: chunks}
+	err = o.storage.Upload(s2iBinaryKey(namespace, name), io.TeeReader(reader, hash), chunk.Total)
+	reader.Close()
+	if err != nil {
+		klog.Error(err)
+		o.failUpload(namespace, name)
+		return nil, err
+	}
+	if err = o.deleteObjects(prefix); err != nil {
+		klog.Warningf("failed to delete the chunks of S2iBinary %s/%s: %v", namespace, name, err)
+	}
+
+	s2iBinary, err := o.completeUpload(namespace, name, chunk.FileName, chunk.MD5, hex.EncodeToString(hash.Sum(nil)), chunk.Total)
+	if err != nil {
+		return nil, err
+	}
+	return &S2iBinaryUploadStatus{UploadedBytes: chunk.Total, Phase: s2iBinary.Status.Phase, S2iBinary: s2iBinary}, nil
+}
+
+func (o *s2iBinaryOperator) GetS2iBinaryUploadStatus(namespace, name string) (*S2iBinaryUploadStatus, error) {
+	s2iBinary, err := o.s2iBinaryLister.S2iBinaries(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	_, uploaded, err := o.listChunks(s2iBinaryChunkPrefix(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+	return &S2iBinaryUploadStatus{UploadedBytes: uploaded, Phase: s2iBinary.Status.Phase}, nil
+}
+
+func (o *s2iBinaryOperator) DownloadS2iBinary(namespace, name, fileName, token string) (io.ReadCloser, *devopsv1alpha1.S2iBinary, error) {
+	s2iBinary, err := o.s2iBinaryLister.S2iBinaries(namespace).Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(downloadToken(s2iBinary))) != 1 {
+		return nil, nil, errors.NewForbidden(devopsv1alpha1.Resource(devopsv1alpha1.ResourcePluralS2iBinary), name,
+			fmt.Errorf("invalid download token"))
+	}
+	notFound := errors.NewNotFound(devopsv1alpha1.Resource(devopsv1alpha1.ResourcePluralS2iBinary), name+"/"+fileName)
+	if s2iBinary.Status.Phase != devopsv1alpha1.StatusReady || s2iBinary.Spec.FileName != fileName {
+		return nil, nil, notFound
+	}
+
+	reader, err := o.storage.Read(s2iBinaryKey(namespace, name))
+	if err == storage.ErrNotFound {
+		return nil, nil, notFound
+	}
+	return reader, s2iBinary, err
+}
+
+// startUpload sets the phase to uploading, and adds the finalizer which deletes the stored objects
+func (o *s2iBinaryOperator) startUpload(namespace, name string) error {
+	_, err := o.updateS2iBinary(namespace, name, func(s2iBinary *devopsv1alpha1.S2iBinary) {
+		s2iBinary.Status.Phase = devopsv1alpha1.StatusUploading
+		if !sliceutil.HasString(s2iBinary.Finalizers, devopsv1alpha1.S2iBinaryFinalizerName) {
+			s2iBinary.Finalizers = append(s2iBinary.Finalizers, devopsv1alpha1.S2iBinaryFinalizerName)
+		}
+	})
+	return err
+}
+
+func (o *s2iBinaryOperator) failUpload(namespace, name string) {
+	_, err := o.updateS2iBinary(namespace, name, func(s2iBinary *devopsv1alpha1.S2iBinary) {
+		s2iBinary.Status.Phase = devopsv1alpha1.StatusUploadFailed
+	})
+	if err != nil {
+		klog.Error(err)
+	}
+}
+
+// completeUpload verifies the MD5 of binary, then updates the spec of the binary
+func (o *s2iBinaryOperator) completeUpload(namespace, name, fileName, expectedMD5, actualMD5 string, size int64) (*devopsv1alpha1.S2iBinary, error) {
+	if expectedMD5 != "" && !strings.EqualFold(expectedMD5, actualMD5) {
+		if err := o.storage.Delete(s2iBinaryKey(namespace, name)); err != nil {
+			klog.Error(err)
+		}
+		o.failUpload(namespace, name)
+		return nil, errors.NewBadRequest(fmt.Sprintf("MD5 of the uploaded binary is %s, but %s is expected", actualMD5, expectedMD5))
+	}
+
+	return o.updateS2iBinary(namespace, name, func(s2iBinary *devopsv1alpha1.S2iBinary) {
+		now := metav1.Now()
+		s2iBinary.Spec.FileName = fileName
+		s2iBinary.Spec.MD5 = actualMD5
+		s2iBinary.Spec.Size = resource.NewQuantity(size, resource.BinarySI).String()
+		s2iBinary.Spec.DownloadURL = o.downloadServer + fmt.Sprintf(S2iBinaryDownloadPath, namespace, name, url.PathEscape(fileName)) +
+			"?" + url.Values{S2iBinaryDownloadTokenParameter: {downloadToken(s2iBinary)}}.Encode()
+		s2iBinary.Spec.UploadTimeStamp = &now
+		s2iBinary.Status.Phase = devopsv1alpha1.StatusReady
+	})
+}
+
+// downloadToken is the UID of the S2iBinary, it is random and readable to the users who can get the S2iBinary,
+// which could download the binary anyway
+func downloadToken(s2iBinary *devopsv1alpha1.S2iBinary) string {
+	return string(s2iBinary.UID)
+}
+
+func (o *s2iBinaryOperator) updateS2iBinary(namespace, name string, mutate func(*devopsv1alpha1.S2iBinary)) (*devopsv1alpha1.S2iBinary, error) {
+	var updated *devopsv1alpha1.S2iBinary
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s2iBinary, err := o.ksclient.DevopsV1alpha1().S2iBinaries(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		mutate(s2iBinary)
+		updated, err = o.ksclient.DevopsV1alpha1().S2iBinaries(namespace).Update(context.Background(), s2iBinary, metav1.UpdateOptions{})
+		return err
+	})
+	return updated, err
+}
+
+// listChunks returns the chunks in order and their total size
+func (o *s2iBinaryOperator) listChunks(prefix string) ([]storage.Object, int64, error) {
+	chunks, err := o.storage.List(prefix)
+	if err != nil {
+		return nil, 0, err
+	}
+	var size int64
+	for _, chunk := range chunks {
+		size += chunk.Size
+	}
+	return chunks, size, nil
+}
+
+func (o *s2iBinaryOperator) deleteObjects(prefix string) error {
+	objects, err := o.storage.List(prefix)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	return o.storage.Delete(keys...)
+}
+
+// chunksReader reads the chunks one by one, a chunk is opened when the previous one is drained
+type chunksReader struct {
+	storage storage.Interface
+	chunks  []storage.Object
+	current io.ReadCloser
+}
+
+func (r *chunksReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			current, err := r.storage.Read(r.chunks[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.chunks = current, r.chunks[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunksReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
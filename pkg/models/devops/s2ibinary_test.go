@@ -0,0 +1,243 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	fakestorage "devops.kubesphere.io/plugin/pkg/client/storage/fake"
+	"devops.kubesphere.io/plugin/pkg/utils/sliceutil"
+)
+
+const testBinary = "hello, s2i binary"
+
+func TestUploadS2iBinary(t *testing.T) {
+	operator, objectStorage := prepareS2iBinary(newS2iBinary("foo"))
+
+	if _, err := operator.UploadS2iBinary("bar", "foo", &S2iBinaryFile{
+		FileName: "app.jar",
+		MD5:      "d41d8cd98f00b204e9800998ecf8427e",
+		Size:     int64(len(testBinary)),
+		Reader:   strings.NewReader(testBinary),
+	}); !errors.IsBadRequest(err) {
+		t.Fatalf("expected bad request error of MD5 mismatch, got %v", err)
+	}
+	if _, ok := objectStorage.Objects[s2iBinaryKey("bar", "foo")]; ok {
+		t.Errorf("expected the binary of mismatched MD5 is deleted")
+	}
+
+	s2iBinary, err := operator.UploadS2iBinary("bar", "foo", &S2iBinaryFile{
+		FileName: "app.jar",
+		MD5:      md5Hex(testBinary),
+		Size:     int64(len(testBinary)),
+		Reader:   strings.NewReader(testBinary),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReadyS2iBinary(t, s2iBinary)
+	if string(objectStorage.Objects[s2iBinaryKey("bar", "foo")]) != testBinary {
+		t.Errorf("unexpected stored binary %q", objectStorage.Objects[s2iBinaryKey("bar", "foo")])
+	}
+}
+
+func TestUploadS2iBinaryChunk(t *testing.T) {
+	operator, objectStorage := prepareS2iBinary(newS2iBinary("foo"))
+	total := int64(len(testBinary))
+
+	upload := func(start, end int64) (*S2iBinaryUploadStatus, error) {
+		return operator.UploadS2iBinaryChunk("bar", "foo", &S2iBinaryChunk{
+			FileName: "app.jar",
+			MD5:      md5Hex(testBinary),
+			Start:    start,
+			Size:     end - start,
+			Total:    total,
+			Reader:   strings.NewReader(testBinary[start:end]),
+		})
+	}
+
+	if _, err := upload(5, 10); !errors.IsConflict(err) {
+		t.Fatalf("expected conflict error of the chunk out of order, got %v", err)
+	}
+	status, err := upload(0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.UploadedBytes != 5 || status.Phase != devopsv1alpha1.StatusUploading {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if _, err = upload(0, 5); err != nil {
+		t.Fatalf("expected the first chunk restarts the upload, got %v", err)
+	}
+	if _, err = upload(5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err = operator.GetS2iBinaryUploadStatus("bar", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.UploadedBytes != 10 {
+		t.Errorf("expected 10 bytes are uploaded, got %d", status.UploadedBytes)
+	}
+
+	status, err = upload(10, total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.UploadedBytes != total || status.S2iBinary == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	expectReadyS2iBinary(t, status.S2iBinary)
+	if string(objectStorage.Objects[s2iBinaryKey("bar", "foo")]) != testBinary {
+		t.Errorf("unexpected stored binary %q", objectStorage.Objects[s2iBinaryKey("bar", "foo")])
+	}
+	if chunks, _ := objectStorage.List(s2iBinaryChunkPrefix("bar", "foo")); len(chunks) != 0 {
+		t.Errorf("expected the chunks are deleted, got %v", chunks)
+	}
+}
+
+func TestDownloadS2iBinary(t *testing.T) {
+	ready := newS2iBinary("ready")
+	ready.Spec.FileName = "app.jar"
+	ready.Status.Phase = devopsv1alpha1.StatusReady
+	uploading := newS2iBinary("uploading")
+	uploading.Spec.FileName = "app.jar"
+	uploading.Status.Phase = devopsv1alpha1.StatusUploading
+
+	operator, objectStorage := prepareS2iBinary(ready, uploading)
+	objectStorage.Objects[s2iBinaryKey("bar", "ready")] = []byte(testBinary)
+	objectStorage.Objects[s2iBinaryKey("bar", "uploading")] = []byte(testBinary)
+
+	tests := []struct {
+		description string
+		name        string
+		fileName    string
+		token       string
+		expectErr   func(error) bool
+	}{
+		{"S2iBinary not found", "missing", "app.jar", "", errors.IsNotFound},
+		{"file name mismatched", "ready", "other.jar", "", errors.IsNotFound},
+		{"S2iBinary not ready", "uploading", "app.jar", "", errors.IsNotFound},
+		{"invalid download token", "ready", "app.jar", "uploading-uid", errors.IsForbidden},
+		{"download the ready binary", "ready", "app.jar", "", nil},
+		{"download the ready binary by the token", "ready", "app.jar", "ready-uid", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			reader, _, err := operator.DownloadS2iBinary("bar", test.name, test.fileName, test.token)
+			if test.expectErr != nil {
+				if !test.expectErr(err) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			data, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != testBinary {
+				t.Errorf("unexpected binary %q", data)
+			}
+		})
+	}
+}
+
+func TestLockUpload(t *testing.T) {
+	operator, _ := prepareS2iBinary()
+
+	unlock := operator.lockUpload("bar", "foo")
+	locked, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		unlock := operator.lockUpload("bar", "foo")
+		close(locked)
+		unlock()
+		close(done)
+	}()
+	// the uploads of other S2iBinaries are not blocked
+	operator.lockUpload("bar", "other")()
+
+	select {
+	case <-locked:
+		t.Fatalf("expected the upload waits for the other upload to the same S2iBinary")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	<-done
+
+	if len(operator.uploads) != 0 {
+		t.Errorf("expected the locks are released, got %v", operator.uploads)
+	}
+}
+
+func expectReadyS2iBinary(t *testing.T, s2iBinary *devopsv1alpha1.S2iBinary) {
+	t.Helper()
+	if s2iBinary.Status.Phase != devopsv1alpha1.StatusReady {
+		t.Errorf("expected phase %s, got %s", devopsv1alpha1.StatusReady, s2iBinary.Status.Phase)
+	}
+	if s2iBinary.Spec.FileName != "app.jar" || s2iBinary.Spec.MD5 != md5Hex(testBinary) {
+		t.Errorf("unexpected spec %+v", s2iBinary.Spec)
+	}
+	if expected := "http://ks-devops/kapis/devops.kubesphere.io/v1alpha2/namespaces/bar/s2ibinaries/foo/file/app.jar?token=foo-uid"; s2iBinary.Spec.DownloadURL != expected {
+		t.Errorf("expected download URL %s, got %s", expected, s2iBinary.Spec.DownloadURL)
+	}
+	if !sliceutil.HasString(s2iBinary.Finalizers, devopsv1alpha1.S2iBinaryFinalizerName) {
+		t.Errorf("expected the finalizer is added, got %v", s2iBinary.Finalizers)
+	}
+}
+
+func md5Hex(value string) string {
+	hash := md5.Sum([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+func newS2iBinary(name string) *devopsv1alpha1.S2iBinary {
+	return &devopsv1alpha1.S2iBinary{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "bar", UID: types.UID(name + "-uid")},
+	}
+}
+
+func prepareS2iBinary(s2iBinaries ...*devopsv1alpha1.S2iBinary) (*s2iBinaryOperator, *fakestorage.Storage) {
+	ksClient := fake.NewSimpleClientset()
+	ksInformerFactory := ksinformers.NewSharedInformerFactory(ksClient, 0)
+	for _, s2iBinary := range s2iBinaries {
+		ksClient.Tracker().Add(s2iBinary)
+		ksInformerFactory.Devops().V1alpha1().S2iBinaries().Informer().GetIndexer().Add(s2iBinary)
+	}
+
+	objectStorage := fakestorage.NewFakeStorage()
+	operator := NewS2iBinaryOperator(ksClient, ksInformerFactory.Devops().V1alpha1().S2iBinaries(),
+		objectStorage, "http://ks-devops/").(*s2iBinaryOperator)
+	return operator, objectStorage
+}
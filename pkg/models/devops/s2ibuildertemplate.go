@@ -0,0 +1,230 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	devopsinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/devops/v1alpha1"
+	devopslisters "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha1"
+)
+
+// The types of template parameters which are checked, the other types are treated as strings
+const (
+	ParameterTypeString  = "string"
+	ParameterTypeBoolean = "boolean"
+	ParameterTypeInteger = "integer"
+	ParameterTypeNumber  = "number"
+)
+
+// S2iTemplateInput is the choices of user to build a S2iBuilder from the template
+type S2iTemplateInput struct {
+	// BuilderImage is one of the builder images of template, defaults to the default base image
+	BuilderImage string `json:"builderImage,omitempty"`
+	// Parameters are the values of template parameters by their keys
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// S2iTemplateRendering is the S2iConfig fields rendered from the template and the input
+type S2iTemplateRendering struct {
+	Template      string                       `json:"template"`
+	CodeFramework devopsv1alpha1.CodeFramework `json:"codeFramework,omitempty"`
+	// BuilderImages are all the builder images can be chosen from
+	BuilderImages    []string                         `json:"builderImages"`
+	BuilderImage     string                           `json:"builderImage"`
+	RuntimeImage     string                           `json:"runtimeImage,omitempty"`
+	RuntimeArtifacts []devopsv1alpha1.VolumeSpec      `json:"runtimeArtifacts,omitempty"`
+	BuildVolumes     []string                         `json:"buildVolumes,omitempty"`
+	Environment      []devopsv1alpha1.EnvironmentSpec `json:"environment"`
+	// Parameters are the template parameters with the values filled
+	Parameters []devopsv1alpha1.Parameter `json:"parameters"`
+}
+
+type S2iBuilderTemplateOperator interface {
+	// RenderS2iBuilderTemplate validates the input against the template, then renders the environment and images
+	RenderS2iBuilderTemplate(name string, input *S2iTemplateInput) (*S2iTemplateRendering, error)
+}
+
+type s2iBuilderTemplateOperator struct {
+	templateLister devopslisters.S2iBuilderTemplateLister
+}
+
+func NewS2iBuilderTemplateOperator(templateInformer devopsinformers.S2iBuilderTemplateInformer) S2iBuilderTemplateOperator {
+	return &s2iBuilderTemplateOperator{
+		templateLister: templateInformer.Lister(),
+	}
+}
+
+func (o *s2iBuilderTemplateOperator) RenderS2iBuilderTemplate(name string, input *S2iTemplateInput) (*S2iTemplateRendering, error) {
+	template, err := o.templateLister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if input == nil {
+		input = &S2iTemplateInput{}
+	}
+
+	rendering, allErrs := RenderS2iBuilderTemplate(template, input)
+	if len(allErrs) > 0 {
+		return nil, errors.NewInvalid(devopsv1alpha1.SchemeGroupVersion.WithKind(devopsv1alpha1.ResourceKindS2iBuilderTemplate).GroupKind(),
+			name, allErrs)
+	}
+	return rendering, nil
+}
+
+// RenderS2iBuilderTemplate checks the parameters are known, the required ones are set, the values have the right
+// types and are among the options, then renders the environment in the order of template parameters
+func RenderS2iBuilderTemplate(template *devopsv1alpha1.S2iBuilderTemplate, input *S2iTemplateInput) (*S2iTemplateRendering, field.ErrorList) {
+	allErrs := field.ErrorList{}
+	rendering := &S2iTemplateRendering{
+		Template:      template.Name,
+		CodeFramework: template.Spec.CodeFramework,
+		BuilderImages: builderImagesOf(template),
+		Environment:   make([]devopsv1alpha1.EnvironmentSpec, 0),
+		Parameters:    make([]devopsv1alpha1.Parameter, 0, len(template.Spec.Parameters)),
+	}
+
+	imagePath := field.NewPath("builderImage")
+	rendering.BuilderImage = input.BuilderImage
+	if rendering.BuilderImage == "" {
+		rendering.BuilderImage = template.Spec.DefaultBaseImage
+	}
+	if rendering.BuilderImage == "" && len(rendering.BuilderImages) == 1 {
+		rendering.BuilderImage = rendering.BuilderImages[0]
+	}
+	switch {
+	case rendering.BuilderImage == "":
+		allErrs = append(allErrs, field.Required(imagePath, "the template has no default builder image"))
+	case len(template.Spec.ContainerInfo) > 0:
+		info := containerInfoOf(template, rendering.BuilderImage)
+		if info == nil {
+			allErrs = append(allErrs, field.NotSupported(imagePath, rendering.BuilderImage, rendering.BuilderImages))
+			break
+		}
+		rendering.RuntimeImage = info.RuntimeImage
+		rendering.RuntimeArtifacts = info.RuntimeArtifacts
+		rendering.BuildVolumes = info.BuildVolumes
+	}
+
+	parametersPath := field.NewPath("parameters")
+	known := make(map[string]bool, len(template.Spec.Parameters))
+	for _, parameter := range template.Spec.Parameters {
+		known[parameter.Key] = true
+		path := parametersPath.Key(parameter.Key)
+		if value, ok := input.Parameters[parameter.Key]; ok {
+			parameter.Value = value
+		}
+
+		value := parameter.Value
+		if value == "" {
+			value = parameter.DefaultValue
+		}
+		if value == "" {
+			if parameter.Required {
+				allErrs = append(allErrs, field.Required(path, parameter.Description))
+			}
+		} else {
+			allErrs = append(allErrs, validateParameterValue(path, &parameter, value)...)
+		}
+
+		rendering.Parameters = append(rendering.Parameters, parameter)
+		if env := parameter.ToEnvonment(); env != nil {
+			rendering.Environment = append(rendering.Environment, *env)
+		}
+	}
+
+	unknown := make([]string, 0)
+	for key := range input.Parameters {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		allErrs = append(allErrs, field.NotFound(parametersPath.Key(key), key))
+	}
+
+	if len(allErrs) > 0 {
+		return nil, allErrs
+	}
+	return rendering, nil
+}
+
+func validateParameterValue(path *field.Path, parameter *devopsv1alpha1.Parameter, value string) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	var err error
+	switch strings.ToLower(parameter.Type) {
+	case ParameterTypeBoolean, "bool":
+		_, err = strconv.ParseBool(value)
+	case ParameterTypeInteger, "int":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case ParameterTypeNumber, "float":
+		_, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(path, value, "must be a value of type "+parameter.Type))
+	}
+
+	if len(parameter.OptValues) > 0 {
+		found := false
+		for _, option := range parameter.OptValues {
+			if option == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allErrs = append(allErrs, field.NotSupported(path, value, parameter.OptValues))
+		}
+	}
+	return allErrs
+}
+
+// builderImagesOf returns the builder images of template without duplicates, the default one first
+func builderImagesOf(template *devopsv1alpha1.S2iBuilderTemplate) []string {
+	images := make([]string, 0, len(template.Spec.ContainerInfo)+1)
+	seen := make(map[string]bool)
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	if len(template.Spec.ContainerInfo) == 0 || containerInfoOf(template, template.Spec.DefaultBaseImage) != nil {
+		add(template.Spec.DefaultBaseImage)
+	}
+	for _, info := range template.Spec.ContainerInfo {
+		add(info.BuilderImage)
+	}
+	return images
+}
+
+func containerInfoOf(template *devopsv1alpha1.S2iBuilderTemplate, builderImage string) *devopsv1alpha1.ContainerInfo {
+	for i := range template.Spec.ContainerInfo {
+		if template.Spec.ContainerInfo[i].BuilderImage == builderImage {
+			return &template.Spec.ContainerInfo[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+func newS2iBuilderTemplate() *devopsv1alpha1.S2iBuilderTemplate {
+	return &devopsv1alpha1.S2iBuilderTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "java"},
+		Spec: devopsv1alpha1.S2iBuilderTemplateSpec{
+			DefaultBaseImage: "kubesphere/java-8-centos7:v2.1.0",
+			CodeFramework:    devopsv1alpha1.Java,
+			ContainerInfo: []devopsv1alpha1.ContainerInfo{
+				{BuilderImage: "kubesphere/java-8-centos7:v2.1.0", RuntimeImage: "kubesphere/java-8-runtime:v2.1.0"},
+				{BuilderImage: "kubesphere/java-11-centos7:v2.1.0", RuntimeImage: "kubesphere/java-11-runtime:v2.1.0"},
+			},
+			Parameters: []devopsv1alpha1.Parameter{
+				{Key: "MAVEN_ARGS", Type: "string", DefaultValue: "-DskipTests"},
+				{Key: "MAVEN_CLEAR_REPO", Type: "boolean", DefaultValue: "false"},
+				{Key: "JAVA_OPTIONS", Type: "string", Required: true},
+				{Key: "BUILD_THREADS", Type: "integer"},
+				{Key: "PROFILE", Type: "string", OptValues: []string{"dev", "prod"}},
+			},
+		},
+	}
+}
+
+func TestRenderS2iBuilderTemplate(t *testing.T) {
+	tests := []struct {
+		description string
+		input       *S2iTemplateInput
+		expectErrs  []field.ErrorType
+		expectImage string
+		expectEnv   []devopsv1alpha1.EnvironmentSpec
+	}{
+		{
+			description: "required parameter is missing",
+			input:       &S2iTemplateInput{},
+			expectErrs:  []field.ErrorType{field.ErrorTypeRequired},
+		},
+		{
+			description: "values of wrong types and options",
+			input: &S2iTemplateInput{Parameters: map[string]string{
+				"JAVA_OPTIONS":     "-Xmx1g",
+				"MAVEN_CLEAR_REPO": "maybe",
+				"BUILD_THREADS":    "four",
+				"PROFILE":          "test",
+			}},
+			expectErrs: []field.ErrorType{field.ErrorTypeInvalid, field.ErrorTypeInvalid, field.ErrorTypeNotSupported},
+		},
+		{
+			description: "unknown parameter and builder image",
+			input: &S2iTemplateInput{
+				BuilderImage: "kubesphere/java-7-centos7:v2.1.0",
+				Parameters:   map[string]string{"JAVA_OPTIONS": "-Xmx1g", "UNKNOWN": "value"},
+			},
+			expectErrs: []field.ErrorType{field.ErrorTypeNotSupported, field.ErrorTypeNotFound},
+		},
+		{
+			description: "default builder image and values",
+			input:       &S2iTemplateInput{Parameters: map[string]string{"JAVA_OPTIONS": "-Xmx1g"}},
+			expectImage: "kubesphere/java-8-centos7:v2.1.0",
+			expectEnv: []devopsv1alpha1.EnvironmentSpec{
+				{Name: "MAVEN_ARGS", Value: "-DskipTests"},
+				{Name: "MAVEN_CLEAR_REPO", Value: "false"},
+				{Name: "JAVA_OPTIONS", Value: "-Xmx1g"},
+			},
+		},
+		{
+			description: "chosen builder image and values",
+			input: &S2iTemplateInput{
+				BuilderImage: "kubesphere/java-11-centos7:v2.1.0",
+				Parameters: map[string]string{
+					"JAVA_OPTIONS":     "-Xmx1g",
+					"MAVEN_CLEAR_REPO": "true",
+					"BUILD_THREADS":    "4",
+					"PROFILE":          "prod",
+				},
+			},
+			expectImage: "kubesphere/java-11-centos7:v2.1.0",
+			expectEnv: []devopsv1alpha1.EnvironmentSpec{
+				{Name: "MAVEN_ARGS", Value: "-DskipTests"},
+				{Name: "MAVEN_CLEAR_REPO", Value: "true"},
+				{Name: "JAVA_OPTIONS", Value: "-Xmx1g"},
+				{Name: "BUILD_THREADS", Value: "4"},
+				{Name: "PROFILE", Value: "prod"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			rendering, allErrs := RenderS2iBuilderTemplate(newS2iBuilderTemplate(), test.input)
+			if len(test.expectErrs) > 0 {
+				errTypes := make([]field.ErrorType, 0, len(allErrs))
+				for _, err := range allErrs {
+					errTypes = append(errTypes, err.Type)
+				}
+				if !reflect.DeepEqual(errTypes, test.expectErrs) {
+					t.Fatalf("expected errors %v, got %v", test.expectErrs, allErrs)
+				}
+				return
+			}
+			if len(allErrs) > 0 {
+				t.Fatal(allErrs.ToAggregate())
+			}
+			if rendering.BuilderImage != test.expectImage {
+				t.Errorf("expected builder image %s, got %s", test.expectImage, rendering.BuilderImage)
+			}
+			if !reflect.DeepEqual(rendering.Environment, test.expectEnv) {
+				t.Errorf("expected environment %v, got %v", test.expectEnv, rendering.Environment)
+			}
+			if len(rendering.BuilderImages) != 2 {
+				t.Errorf("expected 2 builder images, got %v", rendering.BuilderImages)
+			}
+		})
+	}
+}
+
+func TestS2iBuilderTemplateOperator(t *testing.T) {
+	ksInformerFactory := ksinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	ksInformerFactory.Devops().V1alpha1().S2iBuilderTemplates().Informer().GetIndexer().Add(newS2iBuilderTemplate())
+	operator := NewS2iBuilderTemplateOperator(ksInformerFactory.Devops().V1alpha1().S2iBuilderTemplates())
+
+	if _, err := operator.RenderS2iBuilderTemplate("missing", nil); !errors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if _, err := operator.RenderS2iBuilderTemplate("java", nil); !errors.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got %v", err)
+	}
+	rendering, err := operator.RenderS2iBuilderTemplate("java", &S2iTemplateInput{Parameters: map[string]string{"JAVA_OPTIONS": "-Xmx1g"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendering.RuntimeImage != "kubesphere/java-8-runtime:v2.1.0" {
+		t.Errorf("expected the runtime image of the default builder image, got %s", rendering.RuntimeImage)
+	}
+}
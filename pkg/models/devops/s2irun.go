@@ -0,0 +1,213 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	kubesphere "devops.kubesphere.io/plugin/pkg/client/clientset/versioned"
+	devopsinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions/devops/v1alpha1"
+	devopslisters "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha1"
+)
+
+// jobNameLabel is the label which the Job controller adds to its pods
+const jobNameLabel = "job-name"
+
+// s2iRunEventBuffer is the number of events buffered for each watcher, a watcher which can not keep up
+// with the transitions is closed and has to watch again
+const s2iRunEventBuffer = 16
+
+// S2iRunEvent is a transition of the run state of a S2iRun
+type S2iRunEvent struct {
+	// Type is ADDED for the current state when the watch begins, MODIFIED for the transitions, or DELETED
+	Type     watch.EventType         `json:"type"`
+	RunState devopsv1alpha1.RunState `json:"runState"`
+	Object   *devopsv1alpha1.S2iRun  `json:"object"`
+}
+
+// Finished returns true if no more events will be sent after this one
+func (e S2iRunEvent) Finished() bool {
+	return e.Type == watch.Deleted || e.RunState == devopsv1alpha1.Successful || e.RunState == devopsv1alpha1.Failed
+}
+
+type S2iRunOperator interface {
+	// GetS2iRunLog streams the container log of the newest pod of the Job created by the S2iRun
+	GetS2iRunLog(ctx context.Context, namespace, name string, options *corev1.PodLogOptions) (io.ReadCloser, error)
+
+	// WatchS2iRun sends the current run state of the S2iRun, then the transitions of it. The channel is
+	// closed when ctx is done or the watcher falls behind.
+	WatchS2iRun(ctx context.Context, namespace, name string) (<-chan S2iRunEvent, error)
+
+	// CreateS2iRun starts a build of the S2iBuilder, the overrides are validated against the S2iConfig of it
+	CreateS2iRun(namespace, builderName string, overrides *S2iRunOverrides) (*devopsv1alpha1.S2iRun, error)
+
+	// RerunS2iRun starts a new build with the same builder and source as the S2iRun
+	RerunS2iRun(namespace, name string) (*devopsv1alpha1.S2iRun, error)
+
+	// ListS2iRunHistory returns the runs of the S2iBuilder, the newest first
+	ListS2iRunHistory(namespace, builderName string) ([]S2iRunRecord, error)
+}
+
+type s2iRunOperator struct {
+	k8sclient        kubernetes.Interface
+	ksclient         kubesphere.Interface
+	s2iBuilderLister devopslisters.S2iBuilderLister
+	s2iRunLister     devopslisters.S2iRunLister
+	podLister        corelisters.PodLister
+
+	mutex    sync.Mutex
+	watchers map[string]map[chan S2iRunEvent]struct{}
+}
+
+// NewS2iRunOperator creates the operator, it has to be called before the informers start
+func NewS2iRunOperator(k8sclient kubernetes.Interface, ksclient kubesphere.Interface,
+	s2iBuilderInformer devopsinformers.S2iBuilderInformer, s2iRunInformer devopsinformers.S2iRunInformer,
+	podInformer coreinformers.PodInformer) S2iRunOperator {
+	o := &s2iRunOperator{
+		k8sclient:        k8sclient,
+		ksclient:         ksclient,
+		s2iBuilderLister: s2iBuilderInformer.Lister(),
+		s2iRunLister:     s2iRunInformer.Lister(),
+		podLister:        podInformer.Lister(),
+		watchers:         make(map[string]map[chan S2iRunEvent]struct{}),
+	}
+
+	s2iRunInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldS2iRun, newS2iRun := oldObj.(*devopsv1alpha1.S2iRun), newObj.(*devopsv1alpha1.S2iRun)
+			if oldS2iRun.Status.RunState != newS2iRun.Status.RunState {
+				o.dispatch(S2iRunEvent{Type: watch.Modified, RunState: newS2iRun.Status.RunState, Object: newS2iRun})
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if s2iRun, ok := obj.(*devopsv1alpha1.S2iRun); ok {
+				o.dispatch(S2iRunEvent{Type: watch.Deleted, RunState: s2iRun.Status.RunState, Object: s2iRun})
+			}
+		},
+	})
+	return o
+}
+
+func (o *s2iRunOperator) GetS2iRunLog(ctx context.Context, namespace, name string, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+	s2iRun, err := o.s2iRunLister.S2iRuns(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if s2iRun.Status.KubernetesJobName == "" {
+		return nil, errors.NewConflict(devopsv1alpha1.Resource(devopsv1alpha1.ResourcePluralS2iRun), name,
+			fmt.Errorf("the job has not been created, run state is %q", s2iRun.Status.RunState))
+	}
+
+	pod, err := o.newestPodOf(namespace, s2iRun.Status.KubernetesJobName)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.k8sclient.CoreV1().Pods(namespace).GetLogs(pod.Name, options).Stream(ctx)
+}
+
+// newestPodOf returns the newest pod of the job, the job creates new pods if the previous ones failed
+func (o *s2iRunOperator) newestPodOf(namespace, jobName string) (*corev1.Pod, error) {
+	pods, err := o.podLister.Pods(namespace).List(labels.SelectorFromSet(labels.Set{jobNameLabel: jobName}))
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) == 0 {
+		return nil, errors.NewNotFound(corev1.Resource("pods"), jobName)
+	}
+
+	newest := pods[0]
+	for _, pod := range pods[1:] {
+		if newest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			newest = pod
+		}
+	}
+	return newest, nil
+}
+
+func (o *s2iRunOperator) WatchS2iRun(ctx context.Context, namespace, name string) (<-chan S2iRunEvent, error) {
+	key := namespace + "/" + name
+
+	// read the current state and subscribe atomically, so no transition is missed
+	o.mutex.Lock()
+	s2iRun, err := o.s2iRunLister.S2iRuns(namespace).Get(name)
+	if err != nil {
+		o.mutex.Unlock()
+		return nil, err
+	}
+	events := make(chan S2iRunEvent, s2iRunEventBuffer)
+	events <- S2iRunEvent{Type: watch.Added, RunState: s2iRun.Status.RunState, Object: s2iRun}
+	if o.watchers[key] == nil {
+		o.watchers[key] = make(map[chan S2iRunEvent]struct{})
+	}
+	o.watchers[key][events] = struct{}{}
+	o.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		o.unsubscribe(key, events)
+	}()
+	return events, nil
+}
+
+func (o *s2iRunOperator) dispatch(event S2iRunEvent) {
+	key := event.Object.Namespace + "/" + event.Object.Name
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for events := range o.watchers[key] {
+		select {
+		case events <- event:
+		default:
+			klog.Warningf("close the watcher of S2iRun %s which falls behind", key)
+			o.unsubscribe(key, events)
+		}
+	}
+}
+
+// unsubscribe closes the channel if it is still subscribed, the caller must hold the lock
+func (o *s2iRunOperator) unsubscribe(key string, events chan S2iRunEvent) {
+	if _, ok := o.watchers[key][events]; !ok {
+		return
+	}
+	delete(o.watchers[key], events)
+	if len(o.watchers[key]) == 0 {
+		delete(o.watchers, key)
+	}
+	close(events)
+}
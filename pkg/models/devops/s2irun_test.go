@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	k8sinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+func TestGetS2iRunLog(t *testing.T) {
+	operator := prepare(
+		newS2iRun("created", "", devopsv1alpha1.NotRunning),
+		newS2iRun("running", "job-1", devopsv1alpha1.Running),
+		newS2iRun("lost", "job-2", devopsv1alpha1.Running),
+	)
+
+	tests := []struct {
+		description string
+		name        string
+		checkErr    func(error) bool
+	}{
+		{"S2iRun not found", "missing", errors.IsNotFound},
+		{"job not created", "created", errors.IsConflict},
+		{"pod not found", "lost", errors.IsNotFound},
+		{"log of the newest pod", "running", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			stream, err := operator.GetS2iRunLog(context.Background(), "bar", test.name, &corev1.PodLogOptions{})
+			if test.checkErr != nil {
+				if !test.checkErr(err) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			if _, err = ioutil.ReadAll(stream); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	pod, err := operator.newestPodOf("bar", "job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod.Name != "job-1-retry" {
+		t.Errorf("expected the newest pod job-1-retry, got %s", pod.Name)
+	}
+}
+
+func TestWatchS2iRun(t *testing.T) {
+	s2iRun := newS2iRun("running", "job-1", devopsv1alpha1.Running)
+	operator := prepare(s2iRun)
+
+	if _, err := operator.WatchS2iRun(context.Background(), "bar", "missing"); !errors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := operator.WatchS2iRun(ctx, "bar", "running")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEvent(t, events, watch.Added, devopsv1alpha1.Running)
+
+	successful := s2iRun.DeepCopy()
+	successful.Status.RunState = devopsv1alpha1.Successful
+	operator.dispatch(S2iRunEvent{Type: watch.Modified, RunState: successful.Status.RunState, Object: successful})
+	event := expectEvent(t, events, watch.Modified, devopsv1alpha1.Successful)
+	if !event.Finished() {
+		t.Errorf("expected the successful event finishes the watch")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatalf("expected the channel is closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the channel is not closed after the context is done")
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan S2iRunEvent, eventType watch.EventType, state devopsv1alpha1.RunState) S2iRunEvent {
+	select {
+	case event := <-events:
+		if event.Type != eventType || event.RunState != state {
+			t.Fatalf("expected %s event of %s, got %s event of %s", eventType, state, event.Type, event.RunState)
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("no event received")
+	}
+	return S2iRunEvent{}
+}
+
+func newS2iRun(name, jobName string, state devopsv1alpha1.RunState) *devopsv1alpha1.S2iRun {
+	return &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "bar"},
+		Status: devopsv1alpha1.S2iRunStatus{
+			RunState:          state,
+			KubernetesJobName: jobName,
+		},
+	}
+}
+
+func newPod(name, jobName string, created time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "bar",
+			Labels:            map[string]string{jobNameLabel: jobName},
+			CreationTimestamp: metav1.NewTime(created),
+		},
+	}
+}
+
+func prepare(s2iRuns ...*devopsv1alpha1.S2iRun) *s2iRunOperator {
+	return prepareWithBuilders(nil, s2iRuns...)
+}
+
+func prepareWithBuilders(builders []*devopsv1alpha1.S2iBuilder, s2iRuns ...*devopsv1alpha1.S2iRun) *s2iRunOperator {
+	now := time.Now()
+	pods := []*corev1.Pod{
+		newPod("job-1-first", "job-1", now.Add(-time.Minute)),
+		newPod("job-1-retry", "job-1", now),
+		newPod("other", "job-3", now),
+	}
+
+	k8sClient := k8sfake.NewSimpleClientset()
+	k8sInformerFactory := k8sinformers.NewSharedInformerFactory(k8sClient, 0)
+	for _, pod := range pods {
+		k8sClient.Tracker().Add(pod)
+		k8sInformerFactory.Core().V1().Pods().Informer().GetIndexer().Add(pod)
+	}
+
+	ksClient := fake.NewSimpleClientset()
+	ksInformerFactory := ksinformers.NewSharedInformerFactory(ksClient, 0)
+	for _, builder := range builders {
+		ksInformerFactory.Devops().V1alpha1().S2iBuilders().Informer().GetIndexer().Add(builder)
+	}
+	for _, s2iRun := range s2iRuns {
+		ksInformerFactory.Devops().V1alpha1().S2iRuns().Informer().GetIndexer().Add(s2iRun)
+	}
+
+	return NewS2iRunOperator(k8sClient, ksClient, ksInformerFactory.Devops().V1alpha1().S2iBuilders(),
+		ksInformerFactory.Devops().V1alpha1().S2iRuns(), k8sInformerFactory.Core().V1().Pods()).(*s2iRunOperator)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/klog"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+)
+
+// S2iRunRerunOfAnnotation records the S2iRun which a re-run is created from
+const S2iRunRerunOfAnnotation = "devops.kubesphere.io/rerun-of"
+
+// imageTagPattern is the grammar of docker image tags
+var imageTagPattern = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+// S2iRunOverrides overrides the S2iConfig of the builder for a single run
+type S2iRunOverrides struct {
+	// NewTag overrides the tag of the output image
+	NewTag string `json:"newTag,omitempty"`
+	// NewRevisionId overrides the branch or commit of the git source
+	NewRevisionId string `json:"newRevisionId,omitempty"`
+	// NewSourceURL overrides the URL of the binary source
+	NewSourceURL string `json:"newSourceURL,omitempty"`
+}
+
+// S2iRunRecord is a run of the S2iBuilder in the history
+type S2iRunRecord struct {
+	Name              string                         `json:"name"`
+	RunState          devopsv1alpha1.RunState        `json:"runState,omitempty"`
+	CreationTimestamp metav1.Time                    `json:"creationTimestamp"`
+	StartTime         *metav1.Time                   `json:"startTime,omitempty"`
+	CompletionTime    *metav1.Time                   `json:"completionTime,omitempty"`
+	Overrides         S2iRunOverrides                `json:"overrides"`
+	Result            *devopsv1alpha1.S2iBuildResult `json:"result,omitempty"`
+	Source            *devopsv1alpha1.S2iBuildSource `json:"source,omitempty"`
+}
+
+func (o *s2iRunOperator) CreateS2iRun(namespace, builderName string, overrides *S2iRunOverrides) (*devopsv1alpha1.S2iRun, error) {
+	builder, err := o.s2iBuilderLister.S2iBuilders(namespace).Get(builderName)
+	if err != nil {
+		return nil, err
+	}
+	if overrides == nil {
+		overrides = &S2iRunOverrides{}
+	}
+	if err = validateS2iRunOverrides(builder, overrides); err != nil {
+		return nil, err
+	}
+	return o.createS2iRun(builder, overrides, nil)
+}
+
+func (o *s2iRunOperator) RerunS2iRun(namespace, name string) (*devopsv1alpha1.S2iRun, error) {
+	previous, err := o.s2iRunLister.S2iRuns(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	builder, err := o.s2iBuilderLister.S2iBuilders(namespace).Get(previous.Spec.BuilderName)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := &S2iRunOverrides{
+		NewTag:        previous.Spec.NewTag,
+		NewRevisionId: previous.Spec.NewRevisionId,
+		NewSourceURL:  previous.Spec.NewSourceURL,
+	}
+	annotations := map[string]string{S2iRunRerunOfAnnotation: previous.Name}
+	// pin the revision which was actually built, the branch of the builder may have moved since then. The binary
+	// source is downloaded from the URL which was built, but the binary behind it is not pinned, it is the file
+	// uploaded to the S2iBinary last.
+	if source := previous.Status.S2iBuildSource; source != nil && builder.Spec.Config != nil {
+		if builder.Spec.Config.IsBinaryURL {
+			if source.SourceUrl != "" {
+				overrides.NewSourceURL = source.SourceUrl
+			}
+		} else if source.RevisionId != "" {
+			overrides.NewRevisionId = source.RevisionId
+		}
+	}
+	if err = validateS2iRunOverrides(builder, overrides); err != nil {
+		return nil, err
+	}
+	return o.createS2iRun(builder, overrides, annotations)
+}
+
+func (o *s2iRunOperator) ListS2iRunHistory(namespace, builderName string) ([]S2iRunRecord, error) {
+	if _, err := o.s2iBuilderLister.S2iBuilders(namespace).Get(builderName); err != nil {
+		return nil, err
+	}
+	s2iRuns, err := o.s2iRunLister.S2iRuns(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]S2iRunRecord, 0)
+	for _, s2iRun := range s2iRuns {
+		if s2iRun.Spec.BuilderName != builderName {
+			continue
+		}
+		records = append(records, S2iRunRecord{
+			Name:              s2iRun.Name,
+			RunState:          s2iRun.Status.RunState,
+			CreationTimestamp: s2iRun.CreationTimestamp,
+			StartTime:         s2iRun.Status.StartTime,
+			CompletionTime:    s2iRun.Status.CompletionTime,
+			Overrides: S2iRunOverrides{
+				NewTag:        s2iRun.Spec.NewTag,
+				NewRevisionId: s2iRun.Spec.NewRevisionId,
+				NewSourceURL:  s2iRun.Spec.NewSourceURL,
+			},
+			Result: s2iRun.Status.S2iBuildResult,
+			Source: s2iRun.Status.S2iBuildSource,
+		})
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].CreationTimestamp.Equal(&records[j].CreationTimestamp) {
+			return records[i].Name > records[j].Name
+		}
+		return records[j].CreationTimestamp.Before(&records[i].CreationTimestamp)
+	})
+	return records, nil
+}
+
+func (o *s2iRunOperator) createS2iRun(builder *devopsv1alpha1.S2iBuilder, overrides *S2iRunOverrides,
+	annotations map[string]string) (*devopsv1alpha1.S2iRun, error) {
+	s2iRun := &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%s", builder.Name, rand.String(5)),
+			Namespace:   builder.Namespace,
+			Annotations: annotations,
+		},
+		Spec: devopsv1alpha1.S2iRunSpec{
+			BuilderName:   builder.Name,
+			NewTag:        overrides.NewTag,
+			NewRevisionId: overrides.NewRevisionId,
+			NewSourceURL:  overrides.NewSourceURL,
+		},
+	}
+
+	created, err := o.ksclient.DevopsV1alpha1().S2iRuns(builder.Namespace).Create(context.Background(), s2iRun, metav1.CreateOptions{})
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	return created, nil
+}
+
+// validateS2iRunOverrides checks the overrides are applicable to the source type of the builder
+func validateS2iRunOverrides(builder *devopsv1alpha1.S2iBuilder, overrides *S2iRunOverrides) error {
+	config := builder.Spec.Config
+	if config == nil {
+		return errors.NewConflict(devopsv1alpha1.Resource(devopsv1alpha1.ResourcePluralS2iBuilder), builder.Name,
+			fmt.Errorf("the S2iBuilder has no S2iConfig yet"))
+	}
+
+	invalid := make([]string, 0)
+	if overrides.NewTag != "" && !imageTagPattern.MatchString(overrides.NewTag) {
+		invalid = append(invalid, fmt.Sprintf("newTag %q is not a valid image tag", overrides.NewTag))
+	}
+	if overrides.NewRevisionId != "" {
+		if config.IsBinaryURL {
+			invalid = append(invalid, "newRevisionId is not supported by the S2iBuilder of binary source")
+		} else if strings.ContainsAny(overrides.NewRevisionId, " \t\r\n") {
+			invalid = append(invalid, fmt.Sprintf("newRevisionId %q must not contain whitespaces", overrides.NewRevisionId))
+		}
+	}
+	if overrides.NewSourceURL != "" {
+		if !config.IsBinaryURL {
+			invalid = append(invalid, "newSourceURL is only supported by the S2iBuilder of binary source")
+		} else if u, err := url.Parse(overrides.NewSourceURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid = append(invalid, fmt.Sprintf("newSourceURL %q is not a valid http(s) URL", overrides.NewSourceURL))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return errors.NewBadRequest(strings.Join(invalid, "; "))
+	}
+	return nil
+}
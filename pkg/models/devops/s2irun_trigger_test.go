@@ -0,0 +1,166 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devops
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+)
+
+func TestCreateS2iRun(t *testing.T) {
+	operator := prepareWithBuilders([]*devopsv1alpha1.S2iBuilder{
+		newS2iBuilder("git", false),
+		newS2iBuilder("binary", true),
+		{ObjectMeta: metav1.ObjectMeta{Name: "template", Namespace: "bar"}},
+	})
+
+	tests := []struct {
+		description string
+		builder     string
+		overrides   *S2iRunOverrides
+		checkErr    func(error) bool
+	}{
+		{"builder not found", "missing", nil, errors.IsNotFound},
+		{"builder without config", "template", nil, errors.IsConflict},
+		{"invalid tag", "git", &S2iRunOverrides{NewTag: "v1:latest"}, errors.IsBadRequest},
+		{"revision of binary source", "binary", &S2iRunOverrides{NewRevisionId: "master"}, errors.IsBadRequest},
+		{"source URL of git source", "git", &S2iRunOverrides{NewSourceURL: "http://example.com/app.jar"}, errors.IsBadRequest},
+		{"invalid source URL", "binary", &S2iRunOverrides{NewSourceURL: "ftp://example.com/app.jar"}, errors.IsBadRequest},
+		{"no overrides", "git", nil, nil},
+		{"git overrides", "git", &S2iRunOverrides{NewTag: "v1.0", NewRevisionId: "release-1.0"}, nil},
+		{"binary overrides", "binary", &S2iRunOverrides{NewTag: "v1.0", NewSourceURL: "https://example.com/app.jar"}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s2iRun, err := operator.CreateS2iRun("bar", test.builder, test.overrides)
+			if test.checkErr != nil {
+				if !test.checkErr(err) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s2iRun.Spec.BuilderName != test.builder || !strings.HasPrefix(s2iRun.Name, test.builder+"-") {
+				t.Errorf("unexpected S2iRun %s of builder %s", s2iRun.Name, s2iRun.Spec.BuilderName)
+			}
+			if test.overrides != nil && (s2iRun.Spec.NewTag != test.overrides.NewTag ||
+				s2iRun.Spec.NewRevisionId != test.overrides.NewRevisionId || s2iRun.Spec.NewSourceURL != test.overrides.NewSourceURL) {
+				t.Errorf("expected the overrides %+v, got %+v", test.overrides, s2iRun.Spec)
+			}
+		})
+	}
+}
+
+func TestRerunS2iRun(t *testing.T) {
+	previous := newS2iRun("git-built", "job-1", devopsv1alpha1.Successful)
+	previous.Spec = devopsv1alpha1.S2iRunSpec{BuilderName: "git", NewTag: "v1.0", NewRevisionId: "master"}
+	previous.Status.S2iBuildSource = &devopsv1alpha1.S2iBuildSource{RevisionId: "0123abcd"}
+
+	operator := prepareWithBuilders([]*devopsv1alpha1.S2iBuilder{newS2iBuilder("git", false)}, previous)
+
+	if _, err := operator.RerunS2iRun("bar", "missing"); !errors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	s2iRun, err := operator.RerunS2iRun("bar", "git-built")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2iRun.Spec.BuilderName != "git" || s2iRun.Spec.NewTag != "v1.0" {
+		t.Errorf("unexpected spec %+v", s2iRun.Spec)
+	}
+	if s2iRun.Spec.NewRevisionId != "0123abcd" {
+		t.Errorf("expected the built revision 0123abcd is pinned, got %s", s2iRun.Spec.NewRevisionId)
+	}
+	if s2iRun.Annotations[S2iRunRerunOfAnnotation] != "git-built" {
+		t.Errorf("expected the annotation of the previous run, got %v", s2iRun.Annotations)
+	}
+}
+
+func TestRerunBinaryS2iRun(t *testing.T) {
+	previous := newS2iRun("binary-built", "job-1", devopsv1alpha1.Successful)
+	previous.Spec = devopsv1alpha1.S2iRunSpec{BuilderName: "binary", NewTag: "v1.0"}
+	previous.Status.S2iBuildSource = &devopsv1alpha1.S2iBuildSource{
+		SourceUrl:  "https://ks.example.com/s2ibinaries/app/file/app-1.0.jar",
+		BinaryName: "app-1.0.jar",
+	}
+
+	operator := prepareWithBuilders([]*devopsv1alpha1.S2iBuilder{newS2iBuilder("binary", true)}, previous)
+
+	s2iRun, err := operator.RerunS2iRun("bar", "binary-built")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2iRun.Spec.NewSourceURL != previous.Status.S2iBuildSource.SourceUrl || s2iRun.Spec.NewRevisionId != "" {
+		t.Errorf("expected the built source URL %s is reused, got %+v", previous.Status.S2iBuildSource.SourceUrl, s2iRun.Spec)
+	}
+	if s2iRun.Annotations[S2iRunRerunOfAnnotation] != "binary-built" {
+		t.Errorf("expected the annotation of the previous run, got %v", s2iRun.Annotations)
+	}
+}
+
+func TestListS2iRunHistory(t *testing.T) {
+	now := time.Now()
+	older := newS2iRun("git-older", "job-1", devopsv1alpha1.Failed)
+	older.Spec.BuilderName = "git"
+	older.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+	newer := newS2iRun("git-newer", "job-2", devopsv1alpha1.Successful)
+	newer.Spec.BuilderName = "git"
+	newer.CreationTimestamp = metav1.NewTime(now)
+	newer.Status.S2iBuildResult = &devopsv1alpha1.S2iBuildResult{ImageName: "foo/bar:v1.0"}
+	other := newS2iRun("binary-run", "job-3", devopsv1alpha1.Successful)
+	other.Spec.BuilderName = "binary"
+
+	operator := prepareWithBuilders([]*devopsv1alpha1.S2iBuilder{newS2iBuilder("git", false)}, older, newer, other)
+
+	if _, err := operator.ListS2iRunHistory("bar", "missing"); !errors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	records, err := operator.ListS2iRunHistory("bar", "git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Name != "git-newer" || records[1].Name != "git-older" {
+		t.Fatalf("expected the runs of builder git, the newest first, got %+v", records)
+	}
+	if records[0].Result == nil || records[0].Result.ImageName != "foo/bar:v1.0" {
+		t.Errorf("expected the build result of the newest run, got %+v", records[0].Result)
+	}
+}
+
+func newS2iBuilder(name string, binary bool) *devopsv1alpha1.S2iBuilder {
+	return &devopsv1alpha1.S2iBuilder{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "bar"},
+		Spec: devopsv1alpha1.S2iBuilderSpec{
+			Config: &devopsv1alpha1.S2iConfig{
+				ImageName:   "foo/" + name,
+				SourceURL:   "https://github.com/foo/bar.git",
+				IsBinaryURL: binary,
+			},
+		},
+	}
+}
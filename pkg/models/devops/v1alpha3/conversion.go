@@ -0,0 +1,479 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins"
+)
+
+// The conversions marshal the untyped responses of the devops client back into the BlueOcean JSON, then read
+// it with the loose types below, so the fields which are strings, numbers or null in different versions of
+// BlueOcean are tolerated.
+
+// ConvertPipeline converts a pipeline of the devops client
+func ConvertPipeline(pipeline *devops.Pipeline) (*Pipeline, error) {
+	var raw bluePipeline
+	if err := reread(pipeline, &raw); err != nil {
+		return nil, err
+	}
+
+	result := &Pipeline{
+		Name:                           raw.Name,
+		DisplayName:                    raw.DisplayName,
+		FullName:                       raw.FullName,
+		FullDisplayName:                raw.FullDisplayName,
+		Organization:                   raw.Organization,
+		Annotations:                    raw.Annotations,
+		Disabled:                       raw.Disabled,
+		Parameters:                     convertParameters(raw.Parameters),
+		Permissions:                    raw.Permissions,
+		EstimatedDurationInMillis:      raw.EstimatedDurationInMillis,
+		WeatherScore:                   raw.WeatherScore,
+		BranchNames:                    raw.BranchNames,
+		NumberOfFailingBranches:        raw.NumberOfFailingBranches,
+		NumberOfFailingPullRequests:    raw.NumberOfFailingPullRequests,
+		NumberOfSuccessfulBranches:     raw.NumberOfSuccessfulBranches,
+		NumberOfSuccessfulPullRequests: raw.NumberOfSuccessfulPullRequests,
+		TotalNumberOfBranches:          raw.TotalNumberOfBranches,
+		TotalNumberOfPullRequests:      raw.TotalNumberOfPullRequests,
+	}
+	if raw.ScmSource != nil && raw.ScmSource.ID != "" {
+		result.ScmSource = &ScmSource{ID: raw.ScmSource.ID, APIURL: string(raw.ScmSource.APIURL)}
+	}
+	return result, nil
+}
+
+// ConvertPipelineRun converts a run of the regular pipeline or the branch of multi-branch pipeline
+func ConvertPipelineRun(run *devops.PipelineRun) (*PipelineRun, error) {
+	var raw blueRun
+	if err := reread(run, &raw); err != nil {
+		return nil, err
+	}
+	return convertRun(&raw)
+}
+
+// ConvertBranchPipeline converts a branch of multi-branch pipeline, the latest run is nil if the branch never ran
+func ConvertBranchPipeline(branch *devops.BranchPipeline) (*BranchPipeline, error) {
+	var raw blueBranchPipeline
+	if err := reread(branch, &raw); err != nil {
+		return nil, err
+	}
+
+	result := &BranchPipeline{
+		Name:                      raw.Name,
+		DisplayName:               raw.DisplayName,
+		FullName:                  raw.FullName,
+		FullDisplayName:           raw.FullDisplayName,
+		Organization:              raw.Organization,
+		Disabled:                  raw.Disabled,
+		Parameters:                convertParameters(raw.Parameters),
+		Permissions:               raw.Permissions,
+		EstimatedDurationInMillis: raw.EstimatedDurationInMillis,
+		WeatherScore:              raw.WeatherScore,
+		Branch:                    convertBranch(raw.Branch),
+	}
+	if raw.LatestRun != nil && raw.LatestRun.ID != "" {
+		latestRun, err := convertRun(raw.LatestRun)
+		if err != nil {
+			return nil, err
+		}
+		result.LatestRun = latestRun
+	}
+	return result, nil
+}
+
+// ConvertPipelineRunNodes converts the nodes of a run of the regular pipeline
+func ConvertPipelineRunNodes(nodes []devops.PipelineRunNodes) ([]PipelineRunNode, error) {
+	var raw []blueNode
+	if err := reread(nodes, &raw); err != nil {
+		return nil, err
+	}
+	return convertNodes(raw)
+}
+
+// ConvertBranchPipelineRunNodes converts the nodes of a run of the branch of multi-branch pipeline
+func ConvertBranchPipelineRunNodes(nodes []devops.BranchPipelineRunNodes) ([]PipelineRunNode, error) {
+	var raw []blueNode
+	if err := reread(nodes, &raw); err != nil {
+		return nil, err
+	}
+	return convertNodes(raw)
+}
+
+// ConvertNodeSteps converts the steps of a node
+func ConvertNodeSteps(steps []devops.NodeSteps) ([]NodeStep, error) {
+	var raw []blueStep
+	if err := reread(steps, &raw); err != nil {
+		return nil, err
+	}
+
+	result := make([]NodeStep, 0, len(raw))
+	for _, step := range raw {
+		startTime, err := parseTime(step.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, NodeStep{
+			ID:                 step.ID,
+			DisplayName:        step.DisplayName,
+			DisplayDescription: string(step.DisplayDescription),
+			Type:               step.Type,
+			State:              step.State,
+			Result:             step.Result,
+			StartTime:          startTime,
+			DurationInMillis:   step.DurationInMillis,
+			Input:              convertInput(step.Input),
+			Approvable:         step.Approvable,
+		})
+	}
+	return result, nil
+}
+
+func convertRun(raw *blueRun) (*PipelineRun, error) {
+	result := &PipelineRun{
+		ID:                        raw.ID,
+		Name:                      string(raw.Name),
+		Pipeline:                  raw.Pipeline,
+		Organization:              raw.Organization,
+		Description:               string(raw.Description),
+		Type:                      raw.Type,
+		State:                     raw.State,
+		Result:                    raw.Result,
+		DurationInMillis:          raw.DurationInMillis,
+		EstimatedDurationInMillis: raw.EstimatedDurationInMillis,
+		RunSummary:                raw.RunSummary,
+		Replayable:                raw.Replayable,
+		CauseOfBlockage:           string(raw.CauseOfBlockage),
+		ArtifactsZipFile:          string(raw.ArtifactsZipFile),
+		Causes:                    make([]Cause, 0, len(raw.Causes)),
+		ChangeSet:                 make([]ChangeSetEntry, 0, len(raw.ChangeSet)),
+		Branch:                    convertBranch(raw.Branch),
+		CommitID:                  string(raw.CommitID),
+		CommitURL:                 string(raw.CommitURL),
+	}
+
+	var err error
+	if result.EnQueueTime, err = parseTime(raw.EnQueueTime); err != nil {
+		return nil, err
+	}
+	if result.StartTime, err = parseTime(raw.StartTime); err != nil {
+		return nil, err
+	}
+	if result.EndTime, err = parseTime(raw.EndTime); err != nil {
+		return nil, err
+	}
+
+	for _, cause := range raw.Causes {
+		result.Causes = append(result.Causes, Cause{
+			ShortDescription: cause.ShortDescription,
+			UserID:           cause.UserID,
+			UserName:         cause.UserName,
+		})
+	}
+	for _, change := range raw.ChangeSet {
+		entry := ChangeSetEntry{
+			CommitID:      change.CommitID,
+			Message:       change.Msg,
+			URL:           string(change.URL),
+			AffectedPaths: change.AffectedPaths,
+		}
+		if entry.AffectedPaths == nil {
+			entry.AffectedPaths = make([]string, 0)
+		}
+		if change.Author != nil {
+			entry.AuthorID = change.Author.ID
+			entry.AuthorName = string(change.Author.FullName)
+		}
+		if entry.Timestamp, err = parseTime(change.Timestamp); err != nil {
+			return nil, err
+		}
+		result.ChangeSet = append(result.ChangeSet, entry)
+	}
+	if raw.PullRequest != nil && raw.PullRequest.ID != "" {
+		result.PullRequest = &PullRequest{
+			ID:     string(raw.PullRequest.ID),
+			Title:  string(raw.PullRequest.Title),
+			Author: string(raw.PullRequest.Author),
+			URL:    string(raw.PullRequest.URL),
+		}
+	}
+	return result, nil
+}
+
+func convertNodes(raw []blueNode) ([]PipelineRunNode, error) {
+	result := make([]PipelineRunNode, 0, len(raw))
+	for _, node := range raw {
+		startTime, err := parseTime(node.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		edges := make([]NodeEdge, 0, len(node.Edges))
+		for _, edge := range node.Edges {
+			edges = append(edges, NodeEdge{ID: string(edge.ID), Type: edge.Type})
+		}
+		result = append(result, PipelineRunNode{
+			ID:                 string(node.ID),
+			DisplayName:        node.DisplayName,
+			DisplayDescription: string(node.DisplayDescription),
+			Type:               node.Type,
+			State:              node.State,
+			Result:             node.Result,
+			StartTime:          startTime,
+			DurationInMillis:   node.DurationInMillis,
+			CauseOfBlockage:    string(node.CauseOfBlockage),
+			FirstParent:        string(node.FirstParent),
+			Edges:              edges,
+			Restartable:        node.Restartable,
+			Input:              convertInput(node.Input),
+		})
+	}
+	return result, nil
+}
+
+func convertParameters(raw []blueParameter) []ParameterDefinition {
+	result := make([]ParameterDefinition, 0, len(raw))
+	for _, parameter := range raw {
+		definition := ParameterDefinition{
+			Name:        parameter.Name,
+			Type:        parameter.Type,
+			Description: parameter.Description,
+		}
+		if parameter.DefaultParameterValue != nil {
+			definition.DefaultValue = string(parameter.DefaultParameterValue.Value)
+		}
+		for _, choice := range parameter.Choices {
+			definition.Choices = append(definition.Choices, string(choice))
+		}
+		result = append(result, definition)
+	}
+	return result
+}
+
+func convertInput(raw *blueInput) *Input {
+	if raw == nil {
+		return nil
+	}
+	input := &Input{
+		ID:         raw.ID,
+		Message:    raw.Message,
+		Ok:         raw.Ok,
+		Parameters: convertParameters(raw.Parameters),
+		Submitters: make([]string, 0),
+	}
+	for _, submitter := range strings.Split(string(raw.Submitter), ",") {
+		if submitter = strings.TrimSpace(submitter); submitter != "" {
+			input.Submitters = append(input.Submitters, submitter)
+		}
+	}
+	return input
+}
+
+func convertBranch(raw *blueBranch) *Branch {
+	if raw == nil {
+		return nil
+	}
+	return &Branch{IsPrimary: raw.IsPrimary, URL: string(raw.URL)}
+}
+
+func parseTime(value string) (*jenkins.JenkinsBlueTime, error) {
+	if value == "" {
+		return nil, nil
+	}
+	blueTime, err := jenkins.ParseJenkinsBlueTime(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %q: %v", value, err)
+	}
+	return &blueTime, nil
+}
+
+// reread marshals the response of the devops client and reads it into the loose type
+func reread(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// looseString reads strings, numbers and booleans as strings, and null as the empty string
+type looseString string
+
+func (s *looseString) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = looseString(v)
+	case float64:
+		*s = looseString(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		*s = looseString(strconv.FormatBool(v))
+	default:
+		return fmt.Errorf("expected a string, a number or a boolean, got %s", string(data))
+	}
+	return nil
+}
+
+type blueParameter struct {
+	Name                  string `json:"name"`
+	Type                  string `json:"type"`
+	Description           string `json:"description"`
+	DefaultParameterValue *struct {
+		Value looseString `json:"value"`
+	} `json:"defaultParameterValue"`
+	Choices []looseString `json:"choices"`
+}
+
+type bluePipeline struct {
+	Name                           string            `json:"name"`
+	DisplayName                    string            `json:"displayName"`
+	FullName                       string            `json:"fullName"`
+	FullDisplayName                string            `json:"fullDisplayName"`
+	Organization                   string            `json:"organization"`
+	Annotations                    map[string]string `json:"annotations"`
+	Disabled                       bool              `json:"disabled"`
+	Parameters                     []blueParameter   `json:"parameters"`
+	Permissions                    Permissions       `json:"permissions"`
+	EstimatedDurationInMillis      int64             `json:"estimatedDurationInMillis"`
+	WeatherScore                   int               `json:"weatherScore"`
+	BranchNames                    []string          `json:"branchNames"`
+	NumberOfFailingBranches        int               `json:"numberOfFailingBranches"`
+	NumberOfFailingPullRequests    int               `json:"numberOfFailingPullRequests"`
+	NumberOfSuccessfulBranches     int               `json:"numberOfSuccessfulBranches"`
+	NumberOfSuccessfulPullRequests int               `json:"numberOfSuccessfulPullRequests"`
+	TotalNumberOfBranches          int               `json:"totalNumberOfBranches"`
+	TotalNumberOfPullRequests      int               `json:"totalNumberOfPullRequests"`
+	ScmSource                      *struct {
+		ID     string      `json:"id"`
+		APIURL looseString `json:"apiUrl"`
+	} `json:"scmSource"`
+}
+
+type blueRun struct {
+	ID                        string      `json:"id"`
+	Name                      looseString `json:"name"`
+	Pipeline                  string      `json:"pipeline"`
+	Organization              string      `json:"organization"`
+	Description               looseString `json:"description"`
+	Type                      string      `json:"type"`
+	State                     string      `json:"state"`
+	Result                    string      `json:"result"`
+	EnQueueTime               string      `json:"enQueueTime"`
+	StartTime                 string      `json:"startTime"`
+	EndTime                   string      `json:"endTime"`
+	DurationInMillis          int64       `json:"durationInMillis"`
+	EstimatedDurationInMillis int64       `json:"estimatedDurationInMillis"`
+	RunSummary                string      `json:"runSummary"`
+	Replayable                bool        `json:"replayable"`
+	CauseOfBlockage           looseString `json:"causeOfBlockage"`
+	ArtifactsZipFile          looseString `json:"artifactsZipFile"`
+	Causes                    []struct {
+		ShortDescription string `json:"shortDescription"`
+		UserID           string `json:"userId"`
+		UserName         string `json:"userName"`
+	} `json:"causes"`
+	ChangeSet []struct {
+		CommitID string `json:"commitId"`
+		Msg      string `json:"msg"`
+		Author   *struct {
+			ID       string      `json:"id"`
+			FullName looseString `json:"fullName"`
+		} `json:"author"`
+		Timestamp     string      `json:"timestamp"`
+		URL           looseString `json:"url"`
+		AffectedPaths []string    `json:"affectedPaths"`
+	} `json:"changeSet"`
+	Branch      *blueBranch `json:"branch"`
+	PullRequest *struct {
+		ID     looseString `json:"id"`
+		Title  looseString `json:"title"`
+		Author looseString `json:"author"`
+		URL    looseString `json:"url"`
+	} `json:"pullRequest"`
+	CommitID  looseString `json:"commitId"`
+	CommitURL looseString `json:"commitUrl"`
+}
+
+type blueBranch struct {
+	IsPrimary bool        `json:"isPrimary"`
+	URL       looseString `json:"url"`
+}
+
+type blueBranchPipeline struct {
+	Name                      string          `json:"name"`
+	DisplayName               string          `json:"displayName"`
+	FullName                  string          `json:"fullName"`
+	FullDisplayName           string          `json:"fullDisplayName"`
+	Organization              string          `json:"organization"`
+	Disabled                  bool            `json:"disabled"`
+	Parameters                []blueParameter `json:"parameters"`
+	Permissions               Permissions     `json:"permissions"`
+	EstimatedDurationInMillis int64           `json:"estimatedDurationInMillis"`
+	WeatherScore              int             `json:"weatherScore"`
+	Branch                    *blueBranch     `json:"branch"`
+	LatestRun                 *blueRun        `json:"latestRun"`
+}
+
+type blueInput struct {
+	ID         string          `json:"id"`
+	Message    string          `json:"message"`
+	Ok         string          `json:"ok"`
+	Parameters []blueParameter `json:"parameters"`
+	Submitter  looseString     `json:"submitter"`
+}
+
+type blueNode struct {
+	ID                 looseString `json:"id"`
+	DisplayName        string      `json:"displayName"`
+	DisplayDescription looseString `json:"displayDescription"`
+	Type               string      `json:"type"`
+	State              string      `json:"state"`
+	Result             string      `json:"result"`
+	StartTime          string      `json:"startTime"`
+	DurationInMillis   int64       `json:"durationInMillis"`
+	CauseOfBlockage    looseString `json:"causeOfBlockage"`
+	FirstParent        looseString `json:"firstParent"`
+	Edges              []struct {
+		ID   looseString `json:"id"`
+		Type string      `json:"type"`
+	} `json:"edges"`
+	Restartable bool       `json:"restartable"`
+	Input       *blueInput `json:"input"`
+}
+
+type blueStep struct {
+	ID                 string      `json:"id"`
+	DisplayName        string      `json:"displayName"`
+	DisplayDescription looseString `json:"displayDescription"`
+	Type               string      `json:"type"`
+	State              string      `json:"state"`
+	Result             string      `json:"result"`
+	StartTime          string      `json:"startTime"`
+	DurationInMillis   int64       `json:"durationInMillis"`
+	Input              *blueInput  `json:"input"`
+	Approvable         bool        `json:"aprovable"`
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestConvert reads the responses recorded from Jenkins into the types of the devops client, converts them and
+// compares the typed responses with the golden files
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		convert func(data []byte) (interface{}, error)
+	}{
+		{
+			name: "pipeline",
+			convert: func(data []byte) (interface{}, error) {
+				var pipeline devops.Pipeline
+				if err := json.Unmarshal(data, &pipeline); err != nil {
+					return nil, err
+				}
+				return ConvertPipeline(&pipeline)
+			},
+		},
+		{
+			name: "pipeline_run",
+			convert: func(data []byte) (interface{}, error) {
+				var run devops.PipelineRun
+				if err := json.Unmarshal(data, &run); err != nil {
+					return nil, err
+				}
+				return ConvertPipelineRun(&run)
+			},
+		},
+		{
+			name: "branch_pipeline",
+			convert: func(data []byte) (interface{}, error) {
+				var branch devops.BranchPipeline
+				if err := json.Unmarshal(data, &branch); err != nil {
+					return nil, err
+				}
+				return ConvertBranchPipeline(&branch)
+			},
+		},
+		{
+			name: "pipeline_run_nodes",
+			convert: func(data []byte) (interface{}, error) {
+				var nodes []devops.PipelineRunNodes
+				if err := json.Unmarshal(data, &nodes); err != nil {
+					return nil, err
+				}
+				return ConvertPipelineRunNodes(nodes)
+			},
+		},
+		{
+			name: "branch_pipeline_run_nodes",
+			convert: func(data []byte) (interface{}, error) {
+				var nodes []devops.BranchPipelineRunNodes
+				if err := json.Unmarshal(data, &nodes); err != nil {
+					return nil, err
+				}
+				return ConvertBranchPipelineRunNodes(nodes)
+			},
+		},
+		{
+			name: "node_steps",
+			convert: func(data []byte) (interface{}, error) {
+				var steps []devops.NodeSteps
+				if err := json.Unmarshal(data, &steps); err != nil {
+					return nil, err
+				}
+				return ConvertNodeSteps(steps)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := test.name
+			// the nodes of the branch pipeline runs have the same shape as the regular ones
+			if input == "branch_pipeline_run_nodes" {
+				input = "pipeline_run_nodes"
+			}
+			data, err := ioutil.ReadFile(filepath.Join("testdata", input+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			typed, err := test.convert(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := json.MarshalIndent(typed, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, append(actual, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(bytes.TrimSpace(expected), actual) {
+				t.Errorf("the converted %s does not match %s, run the test with -update if the change is expected:\n%s",
+					test.name, golden, actual)
+			}
+		})
+	}
+}
+
+func TestConvertInvalidTimestamp(t *testing.T) {
+	_, err := ConvertPipelineRun(&devops.PipelineRun{ID: "1", StartTime: "yesterday"})
+	if err == nil {
+		t.Fatal("expected an error for the invalid timestamp")
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/klog"
+
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+)
+
+type PipelineOperator interface {
+	GetPipeline(projectName, pipelineName string) (*Pipeline, error)
+	GetPipelineRun(projectName, pipelineName, runId string) (*PipelineRun, error)
+	GetPipelineRunNodes(projectName, pipelineName, runId string) ([]PipelineRunNode, error)
+	GetNodeSteps(projectName, pipelineName, runId, nodeId string) ([]NodeStep, error)
+
+	GetBranchPipeline(projectName, pipelineName, branchName string) (*BranchPipeline, error)
+	GetBranchPipelineRun(projectName, pipelineName, branchName, runId string) (*PipelineRun, error)
+	GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId string) ([]PipelineRunNode, error)
+	GetBranchNodeSteps(projectName, pipelineName, branchName, runId, nodeId string) ([]NodeStep, error)
+}
+
+type pipelineOperator struct {
+	devopsClient devops.Interface
+}
+
+func NewPipelineOperator(devopsClient devops.Interface) PipelineOperator {
+	return &pipelineOperator{devopsClient: devopsClient}
+}
+
+func (o *pipelineOperator) GetPipeline(projectName, pipelineName string) (*Pipeline, error) {
+	pipeline, err := o.devopsClient.GetPipeline(projectName, pipelineName, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertPipeline(pipeline)
+}
+
+func (o *pipelineOperator) GetPipelineRun(projectName, pipelineName, runId string) (*PipelineRun, error) {
+	run, err := o.devopsClient.GetPipelineRun(projectName, pipelineName, runId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertPipelineRun(run)
+}
+
+func (o *pipelineOperator) GetPipelineRunNodes(projectName, pipelineName, runId string) ([]PipelineRunNode, error) {
+	nodes, err := o.devopsClient.GetPipelineRunNodes(projectName, pipelineName, runId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertPipelineRunNodes(nodes)
+}
+
+func (o *pipelineOperator) GetNodeSteps(projectName, pipelineName, runId, nodeId string) ([]NodeStep, error) {
+	steps, err := o.devopsClient.GetNodeSteps(projectName, pipelineName, runId, nodeId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertNodeSteps(steps)
+}
+
+func (o *pipelineOperator) GetBranchPipeline(projectName, pipelineName, branchName string) (*BranchPipeline, error) {
+	branch, err := o.devopsClient.GetBranchPipeline(projectName, pipelineName, branchName, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertBranchPipeline(branch)
+}
+
+func (o *pipelineOperator) GetBranchPipelineRun(projectName, pipelineName, branchName, runId string) (*PipelineRun, error) {
+	run, err := o.devopsClient.GetBranchPipelineRun(projectName, pipelineName, branchName, runId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertPipelineRun(run)
+}
+
+func (o *pipelineOperator) GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId string) ([]PipelineRunNode, error) {
+	nodes, err := o.devopsClient.GetBranchPipelineRunNodes(projectName, pipelineName, branchName, runId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertBranchPipelineRunNodes(nodes)
+}
+
+func (o *pipelineOperator) GetBranchNodeSteps(projectName, pipelineName, branchName, runId, nodeId string) ([]NodeStep, error) {
+	steps, err := o.devopsClient.GetBranchNodeSteps(projectName, pipelineName, branchName, runId, nodeId, devops.NewHttpParameters(http.MethodGet, nil))
+	if err != nil {
+		return nil, clientError(err)
+	}
+	return ConvertNodeSteps(steps)
+}
+
+func clientError(err error) error {
+	klog.Error(err)
+	return restful.NewError(devops.GetDevOpsStatusCode(err), err.Error())
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha3 contains the typed responses of the pipelines, the untyped fields of BlueOcean are
+// converted into strings, int64 durations and timestamps
+package v1alpha3
+
+import (
+	"devops.kubesphere.io/plugin/pkg/client/devops/jenkins"
+)
+
+type Pipeline struct {
+	Name                           string                `json:"name" description:"name"`
+	DisplayName                    string                `json:"displayName,omitempty" description:"display name"`
+	FullName                       string                `json:"fullName,omitempty" description:"full name"`
+	FullDisplayName                string                `json:"fullDisplayName,omitempty" description:"full display name"`
+	Organization                   string                `json:"organization,omitempty" description:"the name of organization"`
+	Annotations                    map[string]string     `json:"annotations,omitempty" description:"annotations from crd"`
+	Disabled                       bool                  `json:"disabled" description:"disable or not, if disabled, can not do any action"`
+	Parameters                     []ParameterDefinition `json:"parameters" description:"the parameters the pipeline expects"`
+	Permissions                    Permissions           `json:"permissions" description:"permissions"`
+	EstimatedDurationInMillis      int64                 `json:"estimatedDurationInMillis" description:"estimated duration time in millis, -1 if unknown"`
+	WeatherScore                   int                   `json:"weatherScore" description:"the score to description the result of pipeline activity"`
+	BranchNames                    []string              `json:"branchNames,omitempty" description:"branch names"`
+	NumberOfFailingBranches        int                   `json:"numberOfFailingBranches" description:"number of failing branches"`
+	NumberOfFailingPullRequests    int                   `json:"numberOfFailingPullRequests" description:"number of failing pull requests"`
+	NumberOfSuccessfulBranches     int                   `json:"numberOfSuccessfulBranches" description:"number of successful branches"`
+	NumberOfSuccessfulPullRequests int                   `json:"numberOfSuccessfulPullRequests" description:"number of successful pull requests"`
+	TotalNumberOfBranches          int                   `json:"totalNumberOfBranches" description:"total number of branches"`
+	TotalNumberOfPullRequests      int                   `json:"totalNumberOfPullRequests" description:"total number of pull requests"`
+	ScmSource                      *ScmSource            `json:"scmSource,omitempty" description:"the source code management of multi-branch pipeline"`
+}
+
+type Permissions struct {
+	Create    bool `json:"create" description:"create action"`
+	Configure bool `json:"configure" description:"configure action"`
+	Read      bool `json:"read" description:"read action"`
+	Start     bool `json:"start" description:"start action"`
+	Stop      bool `json:"stop" description:"stop action"`
+}
+
+type ScmSource struct {
+	ID     string `json:"id" description:"the id of the source code management, e.g. github"`
+	APIURL string `json:"apiUrl,omitempty" description:"api url"`
+}
+
+// ParameterDefinition is a parameter of a pipeline or an input, the default value of boolean parameters is
+// "true" or "false"
+type ParameterDefinition struct {
+	Name         string   `json:"name" description:"name"`
+	Type         string   `json:"type" description:"type, e.g. StringParameterDefinition"`
+	Description  string   `json:"description,omitempty" description:"description"`
+	DefaultValue string   `json:"defaultValue,omitempty" description:"default value"`
+	Choices      []string `json:"choices,omitempty" description:"choices of ChoiceParameterDefinition"`
+}
+
+type PipelineRun struct {
+	ID                        string                   `json:"id" description:"id"`
+	Name                      string                   `json:"name,omitempty" description:"name"`
+	Pipeline                  string                   `json:"pipeline" description:"the name of pipeline"`
+	Organization              string                   `json:"organization,omitempty" description:"the name of organization"`
+	Description               string                   `json:"description,omitempty" description:"description"`
+	Type                      string                   `json:"type,omitempty" description:"type"`
+	State                     string                   `json:"state" description:"run state. e.g. RUNNING"`
+	Result                    string                   `json:"result" description:"the result of pipeline run. e.g. SUCCESS"`
+	EnQueueTime               *jenkins.JenkinsBlueTime `json:"enQueueTime,omitempty" description:"the time of enter the queue"`
+	StartTime                 *jenkins.JenkinsBlueTime `json:"startTime,omitempty" description:"the time of start"`
+	EndTime                   *jenkins.JenkinsBlueTime `json:"endTime,omitempty" description:"the time of end"`
+	DurationInMillis          int64                    `json:"durationInMillis" description:"duration time in millis"`
+	EstimatedDurationInMillis int64                    `json:"estimatedDurationInMillis" description:"estimated duration time in millis, -1 if unknown"`
+	RunSummary                string                   `json:"runSummary,omitempty" description:"pipeline run summary"`
+	Replayable                bool                     `json:"replayable" description:"replayable or not"`
+	CauseOfBlockage           string                   `json:"causeOfBlockage,omitempty" description:"the cause of blockage"`
+	ArtifactsZipFile          string                   `json:"artifactsZipFile,omitempty" description:"the artifacts zip file"`
+	Causes                    []Cause                  `json:"causes" description:"the causes of the run"`
+	ChangeSet                 []ChangeSetEntry         `json:"changeSet" description:"the commits built by the run"`
+	Branch                    *Branch                  `json:"branch,omitempty" description:"the branch of multi-branch pipeline"`
+	PullRequest               *PullRequest             `json:"pullRequest,omitempty" description:"the pull request of multi-branch pipeline"`
+	CommitID                  string                   `json:"commitId,omitempty" description:"commit id"`
+	CommitURL                 string                   `json:"commitUrl,omitempty" description:"commit url"`
+}
+
+type Cause struct {
+	ShortDescription string `json:"shortDescription" description:"short description"`
+	UserID           string `json:"userId,omitempty" description:"user id"`
+	UserName         string `json:"userName,omitempty" description:"user name"`
+}
+
+type ChangeSetEntry struct {
+	CommitID      string                   `json:"commitId" description:"commit id"`
+	Message       string                   `json:"message" description:"commit message"`
+	AuthorID      string                   `json:"authorId,omitempty" description:"the id of the author"`
+	AuthorName    string                   `json:"authorName,omitempty" description:"the full name of the author"`
+	Timestamp     *jenkins.JenkinsBlueTime `json:"timestamp,omitempty" description:"the time of the commit"`
+	URL           string                   `json:"url,omitempty" description:"commit url"`
+	AffectedPaths []string                 `json:"affectedPaths" description:"the paths changed by the commit"`
+}
+
+type Branch struct {
+	IsPrimary bool   `json:"isPrimary" description:"primary or not"`
+	URL       string `json:"url,omitempty" description:"url"`
+}
+
+type PullRequest struct {
+	ID     string `json:"id" description:"id"`
+	Title  string `json:"title,omitempty" description:"title"`
+	Author string `json:"author,omitempty" description:"author"`
+	URL    string `json:"url,omitempty" description:"url"`
+}
+
+// BranchPipeline is a branch or a pull request of multi-branch pipeline
+type BranchPipeline struct {
+	Name                      string                `json:"name" description:"name"`
+	DisplayName               string                `json:"displayName,omitempty" description:"display name"`
+	FullName                  string                `json:"fullName,omitempty" description:"full name"`
+	FullDisplayName           string                `json:"fullDisplayName,omitempty" description:"full display name"`
+	Organization              string                `json:"organization,omitempty" description:"the name of organization"`
+	Disabled                  bool                  `json:"disabled" description:"disable or not, if disabled, can not do any action"`
+	Parameters                []ParameterDefinition `json:"parameters" description:"the parameters the pipeline expects"`
+	Permissions               Permissions           `json:"permissions" description:"permissions"`
+	EstimatedDurationInMillis int64                 `json:"estimatedDurationInMillis" description:"estimated duration time in millis, -1 if unknown"`
+	WeatherScore              int                   `json:"weatherScore" description:"the score to description the result of pipeline"`
+	Branch                    *Branch               `json:"branch,omitempty" description:"branch"`
+	LatestRun                 *PipelineRun          `json:"latestRun,omitempty" description:"the latest run, absent if the branch never ran"`
+}
+
+// PipelineRunNode is a stage or a parallel branch of a pipeline run
+type PipelineRunNode struct {
+	ID                 string                   `json:"id" description:"id"`
+	DisplayName        string                   `json:"displayName" description:"display name"`
+	DisplayDescription string                   `json:"displayDescription,omitempty" description:"display description"`
+	Type               string                   `json:"type" description:"type, e.g. STAGE or PARALLEL"`
+	State              string                   `json:"state,omitempty" description:"run state. e.g. FINISHED"`
+	Result             string                   `json:"result,omitempty" description:"the result of the node. e.g. SUCCESS"`
+	StartTime          *jenkins.JenkinsBlueTime `json:"startTime,omitempty" description:"the time of start"`
+	DurationInMillis   int64                    `json:"durationInMillis" description:"duration time in millis"`
+	CauseOfBlockage    string                   `json:"causeOfBlockage,omitempty" description:"the cause of blockage"`
+	FirstParent        string                   `json:"firstParent,omitempty" description:"the id of the first parent node"`
+	Edges              []NodeEdge               `json:"edges" description:"the nodes run after this node"`
+	Restartable        bool                     `json:"restartable" description:"restartable or not"`
+	Input              *Input                   `json:"input,omitempty" description:"the input the node waits for"`
+}
+
+type NodeEdge struct {
+	ID   string `json:"id" description:"the id of the node"`
+	Type string `json:"type" description:"the type of the node"`
+}
+
+type NodeStep struct {
+	ID                 string                   `json:"id" description:"id"`
+	DisplayName        string                   `json:"displayName" description:"display name"`
+	DisplayDescription string                   `json:"displayDescription,omitempty" description:"display description"`
+	Type               string                   `json:"type,omitempty" description:"type"`
+	State              string                   `json:"state,omitempty" description:"run state. e.g. SKIPPED"`
+	Result             string                   `json:"result,omitempty" description:"the result of the step. e.g. SUCCESS"`
+	StartTime          *jenkins.JenkinsBlueTime `json:"startTime,omitempty" description:"the time of start"`
+	DurationInMillis   int64                    `json:"durationInMillis" description:"duration time in millis"`
+	Input              *Input                   `json:"input,omitempty" description:"the input the step waits for"`
+	Approvable         bool                     `json:"approvable" description:"indicate if this step can be approved by current user"`
+}
+
+type Input struct {
+	ID         string                `json:"id" description:"the id of the input"`
+	Message    string                `json:"message,omitempty" description:"the message of the input"`
+	Ok         string                `json:"ok,omitempty" description:"the caption of the proceed button. e.g. \"Proceed\""`
+	Parameters []ParameterDefinition `json:"parameters" description:"the parameters of the input"`
+	Submitters []string              `json:"submitters" description:"the users or groups who can submit the input, anyone if empty"`
+}
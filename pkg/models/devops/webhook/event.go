@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook receives the webhooks of the SCM providers, and triggers the multi-branch pipelines of the
+// repositories
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The SCM providers of webhooks
+const (
+	ProviderGithub          = "github"
+	ProviderGitlab          = "gitlab"
+	ProviderBitbucketServer = "bitbucket_server"
+	ProviderGitea           = "gitea"
+)
+
+type EventType string
+
+const (
+	EventTypePush        EventType = "push"
+	EventTypeTag         EventType = "tag"
+	EventTypePullRequest EventType = "pull_request"
+	EventTypePing        EventType = "ping"
+)
+
+// Event is a push, a tag or a pull request event of any SCM provider
+type Event struct {
+	Provider   string     `json:"provider"`
+	Type       EventType  `json:"type"`
+	Repository Repository `json:"repository"`
+	// Branch is the pushed branch, or the source branch of the pull request
+	Branch string `json:"branch,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	// Deleted indicates the branch or the tag was deleted
+	Deleted     bool         `json:"deleted,omitempty"`
+	PullRequest *PullRequest `json:"pullRequest,omitempty"`
+	Sender      string       `json:"sender,omitempty"`
+}
+
+type Repository struct {
+	// FullName is owner/repo, or project/repo of Bitbucket Server
+	FullName  string   `json:"fullName"`
+	CloneURLs []string `json:"cloneURLs,omitempty"`
+}
+
+type PullRequest struct {
+	Number       int    `json:"number"`
+	Action       string `json:"action,omitempty"`
+	TargetBranch string `json:"targetBranch,omitempty"`
+	// Closed indicates the pull request was merged, declined or closed
+	Closed bool `json:"closed,omitempty"`
+}
+
+// JobName returns the name of the job Jenkins creates in the multi-branch pipeline for the event
+func (e *Event) JobName() string {
+	switch e.Type {
+	case EventTypeTag:
+		return e.Tag
+	case EventTypePullRequest:
+		return fmt.Sprintf("PR-%d", e.PullRequest.Number)
+	default:
+		return e.Branch
+	}
+}
+
+// eventName returns the event name in the headers of the webhook
+func eventName(provider string, header http.Header) string {
+	switch provider {
+	case ProviderGithub:
+		return header.Get("X-GitHub-Event")
+	case ProviderGitlab:
+		return header.Get("X-Gitlab-Event")
+	case ProviderBitbucketServer:
+		return header.Get("X-Event-Key")
+	case ProviderGitea:
+		return header.Get("X-Gitea-Event")
+	}
+	return ""
+}
+
+// deliveryID returns the id of the delivery in the headers of the webhook
+func deliveryID(provider string, header http.Header) string {
+	switch provider {
+	case ProviderGithub:
+		return header.Get("X-GitHub-Delivery")
+	case ProviderGitlab:
+		return header.Get("X-Gitlab-Event-UUID")
+	case ProviderBitbucketServer:
+		return header.Get("X-Request-Id")
+	case ProviderGitea:
+		return header.Get("X-Gitea-Delivery")
+	}
+	return ""
+}
+
+// refName splits a ref into the branch or the tag name
+func refName(ref string) (name string, isTag bool) {
+	if strings.HasPrefix(ref, "refs/tags/") {
+		return strings.TrimPrefix(ref, "refs/tags/"), true
+	}
+	return strings.TrimPrefix(ref, "refs/heads/"), false
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"net/url"
+	"strings"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+)
+
+// matchPipeline checks if the multi-branch pipeline builds the repository of the event. The GitHub, GitLab and
+// Bitbucket Server sources are matched by owner/repo, the git source is matched by its url.
+func matchPipeline(pipeline *devopsv1alpha3.Pipeline, event *Event) bool {
+	multiBranch := pipeline.Spec.MultiBranchPipeline
+	if pipeline.Spec.Type != devopsv1alpha3.MultiBranchPipelineType || multiBranch == nil {
+		return false
+	}
+
+	switch multiBranch.SourceType {
+	case devopsv1alpha3.SourceTypeGit:
+		if multiBranch.GitSource == nil {
+			return false
+		}
+		expected := normalizeURL(multiBranch.GitSource.Url)
+		for _, cloneURL := range event.Repository.CloneURLs {
+			if normalizeURL(cloneURL) == expected {
+				return true
+			}
+		}
+	case devopsv1alpha3.SourceTypeGithub:
+		source := multiBranch.GitHubSource
+		return event.Provider == ProviderGithub && source != nil && matchFullName(source.Owner, source.Repo, event)
+	case devopsv1alpha3.SourceTypeGitlab:
+		source := multiBranch.GitlabSource
+		return event.Provider == ProviderGitlab && source != nil && matchFullName(source.Owner, source.Repo, event)
+	case devopsv1alpha3.SourceTypeBitbucket:
+		source := multiBranch.BitbucketServerSource
+		return event.Provider == ProviderBitbucketServer && source != nil && matchFullName(source.Owner, source.Repo, event)
+	}
+	return false
+}
+
+func matchFullName(owner, repo string, event *Event) bool {
+	return owner != "" && repo != "" && strings.EqualFold(owner+"/"+repo, event.Repository.FullName)
+}
+
+// regexFilter returns the regex filter of the branches of the multi-branch pipeline
+func regexFilter(multiBranch *devopsv1alpha3.MultiBranchPipeline) string {
+	switch multiBranch.SourceType {
+	case devopsv1alpha3.SourceTypeGit:
+		return multiBranch.GitSource.RegexFilter
+	case devopsv1alpha3.SourceTypeGithub:
+		return multiBranch.GitHubSource.RegexFilter
+	case devopsv1alpha3.SourceTypeGitlab:
+		return multiBranch.GitlabSource.RegexFilter
+	case devopsv1alpha3.SourceTypeBitbucket:
+		return multiBranch.BitbucketServerSource.RegexFilter
+	}
+	return ""
+}
+
+// discoverTags checks if the multi-branch pipeline builds tags
+func discoverTags(multiBranch *devopsv1alpha3.MultiBranchPipeline) bool {
+	switch multiBranch.SourceType {
+	case devopsv1alpha3.SourceTypeGit:
+		return multiBranch.GitSource.DiscoverTags
+	case devopsv1alpha3.SourceTypeGithub:
+		return multiBranch.GitHubSource.DiscoverTags
+	case devopsv1alpha3.SourceTypeGitlab:
+		return multiBranch.GitlabSource.DiscoverTags
+	case devopsv1alpha3.SourceTypeBitbucket:
+		return multiBranch.BitbucketServerSource.DiscoverTags
+	}
+	return false
+}
+
+// normalizeURL reduces the https, ssh and scp-like urls of a repository to host/path, e.g.
+// git@github.com:kubesphere/devops.git and https://github.com/kubesphere/devops are both github.com/kubesphere/devops
+func normalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		// scp-like syntax user@host:path
+		if i := strings.Index(rawURL, ":"); i > 0 {
+			rawURL = "ssh://" + rawURL[:i] + "/" + rawURL[i+1:]
+		}
+	}
+
+	var host, path string
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.Host != "" {
+		host, path = parsed.Hostname(), parsed.Path
+	} else {
+		path = rawURL
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host + "/" + path)
+}
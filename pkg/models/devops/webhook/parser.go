@@ -0,0 +1,302 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// zeroCommit is the commit of the deleted refs in the push events of GitHub, GitLab and Gitea
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+// Parse reads the webhook into events, the events which can't trigger pipelines, e.g. issue comments, are
+// ignored, so the result may be empty. Bitbucket Server sends the changes of several refs in one webhook.
+func Parse(provider string, header http.Header, body []byte) ([]Event, error) {
+	name := eventName(provider, header)
+	var events []Event
+	var err error
+	switch provider {
+	case ProviderGithub:
+		events, err = parseGithub(name, body)
+	case ProviderGitlab:
+		events, err = parseGitlab(name, body)
+	case ProviderBitbucketServer:
+		events, err = parseBitbucketServer(name, body)
+	case ProviderGitea:
+		// the payloads of Gitea are compatible with GitHub
+		events, err = parseGithub(name, body)
+	default:
+		return nil, fmt.Errorf("unsupported SCM provider %s", provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s event %s: %v", provider, name, err)
+	}
+	for i := range events {
+		events[i].Provider = provider
+	}
+	return events, nil
+}
+
+type githubRepository struct {
+	FullName string `json:"full_name"`
+	CloneURL string `json:"clone_url"`
+	SSHURL   string `json:"ssh_url"`
+	HTMLURL  string `json:"html_url"`
+}
+
+func (r *githubRepository) toRepository() Repository {
+	return Repository{FullName: r.FullName, CloneURLs: nonEmpty(r.CloneURL, r.SSHURL, r.HTMLURL)}
+}
+
+type githubPayload struct {
+	Ref        string           `json:"ref"`
+	After      string           `json:"after"`
+	Deleted    bool             `json:"deleted"`
+	Action     string           `json:"action"`
+	Number     int              `json:"number"`
+	Repository githubRepository `json:"repository"`
+	Sender     struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	PullRequest struct {
+		Head struct {
+			Ref string `json:"ref"`
+			Sha string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+}
+
+func parseGithub(name string, body []byte) ([]Event, error) {
+	var payload githubPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	event := Event{
+		Repository: payload.Repository.toRepository(),
+		Sender:     payload.Sender.Login,
+	}
+
+	switch name {
+	case "ping":
+		event.Type = EventTypePing
+	case "push":
+		ref, isTag := refName(payload.Ref)
+		event.Type, event.Branch, event.Commit = EventTypePush, ref, payload.After
+		if isTag {
+			event.Type, event.Branch, event.Tag = EventTypeTag, "", ref
+		}
+		event.Deleted = payload.Deleted || payload.After == zeroCommit
+	case "pull_request":
+		switch payload.Action {
+		case "opened", "reopened", "synchronize", "synchronized", "closed":
+		default:
+			// labels, assignees, reviews and so on change nothing to build
+			return nil, nil
+		}
+		event.Type = EventTypePullRequest
+		event.Branch, event.Commit = payload.PullRequest.Head.Ref, payload.PullRequest.Head.Sha
+		event.PullRequest = &PullRequest{
+			Number:       payload.Number,
+			Action:       payload.Action,
+			TargetBranch: payload.PullRequest.Base.Ref,
+			Closed:       payload.Action == "closed",
+		}
+	default:
+		return nil, nil
+	}
+	return []Event{event}, nil
+}
+
+type gitlabPayload struct {
+	ObjectKind   string `json:"object_kind"`
+	Ref          string `json:"ref"`
+	After        string `json:"after"`
+	UserUsername string `json:"user_username"`
+	User         struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		GitHTTPURL        string `json:"git_http_url"`
+		GitSSHURL         string `json:"git_ssh_url"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Action       string `json:"action"`
+		State        string `json:"state"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		LastCommit   struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+func parseGitlab(name string, body []byte) ([]Event, error) {
+	var payload gitlabPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	event := Event{
+		Repository: Repository{
+			FullName:  payload.Project.PathWithNamespace,
+			CloneURLs: nonEmpty(payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL),
+		},
+		Sender: payload.UserUsername,
+	}
+
+	switch name {
+	case "Push Hook", "Tag Push Hook":
+		ref, isTag := refName(payload.Ref)
+		event.Type, event.Branch, event.Commit = EventTypePush, ref, payload.After
+		if isTag {
+			event.Type, event.Branch, event.Tag = EventTypeTag, "", ref
+		}
+		event.Deleted = payload.After == zeroCommit
+	case "Merge Request Hook":
+		attributes := payload.ObjectAttributes
+		switch attributes.Action {
+		case "open", "reopen", "update", "close", "merge":
+		default:
+			return nil, nil
+		}
+		event.Type, event.Sender = EventTypePullRequest, payload.User.Username
+		event.Branch, event.Commit = attributes.SourceBranch, attributes.LastCommit.ID
+		event.PullRequest = &PullRequest{
+			Number:       attributes.IID,
+			Action:       attributes.Action,
+			TargetBranch: attributes.TargetBranch,
+			Closed:       attributes.Action == "close" || attributes.Action == "merge",
+		}
+	default:
+		return nil, nil
+	}
+	return []Event{event}, nil
+}
+
+type bitbucketRepository struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []struct {
+			Href string `json:"href"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+func (r *bitbucketRepository) toRepository() Repository {
+	repository := Repository{FullName: r.Project.Key + "/" + r.Slug}
+	for _, link := range r.Links.Clone {
+		repository.CloneURLs = append(repository.CloneURLs, link.Href)
+	}
+	return repository
+}
+
+type bitbucketRef struct {
+	ID           string              `json:"id"`
+	DisplayID    string              `json:"displayId"`
+	LatestCommit string              `json:"latestCommit"`
+	Repository   bitbucketRepository `json:"repository"`
+}
+
+type bitbucketPayload struct {
+	Actor struct {
+		Name string `json:"name"`
+	} `json:"actor"`
+	Repository bitbucketRepository `json:"repository"`
+	Changes    []struct {
+		Ref struct {
+			ID        string `json:"id"`
+			DisplayID string `json:"displayId"`
+			Type      string `json:"type"`
+		} `json:"ref"`
+		ToHash string `json:"toHash"`
+		Type   string `json:"type"`
+	} `json:"changes"`
+	PullRequest struct {
+		ID      int          `json:"id"`
+		FromRef bitbucketRef `json:"fromRef"`
+		ToRef   bitbucketRef `json:"toRef"`
+	} `json:"pullRequest"`
+}
+
+func parseBitbucketServer(name string, body []byte) ([]Event, error) {
+	// the test connection of Bitbucket Server sends no body
+	if name == "diagnostics:ping" {
+		return []Event{{Type: EventTypePing}}, nil
+	}
+
+	var payload bitbucketPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	switch name {
+	case "repo:refs_changed":
+		for _, change := range payload.Changes {
+			event := Event{
+				Type:       EventTypePush,
+				Repository: payload.Repository.toRepository(),
+				Branch:     change.Ref.DisplayID,
+				Commit:     change.ToHash,
+				Deleted:    change.Type == "DELETE",
+				Sender:     payload.Actor.Name,
+			}
+			if change.Ref.Type == "TAG" {
+				event.Type, event.Branch, event.Tag = EventTypeTag, "", change.Ref.DisplayID
+			}
+			events = append(events, event)
+		}
+	case "pr:opened", "pr:from_ref_updated", "pr:merged", "pr:declined", "pr:deleted":
+		action := strings.TrimPrefix(name, "pr:")
+		events = append(events, Event{
+			Type: EventTypePullRequest,
+			// the pull requests are built by the jobs of the target repository
+			Repository: payload.PullRequest.ToRef.Repository.toRepository(),
+			Branch:     payload.PullRequest.FromRef.DisplayID,
+			Commit:     payload.PullRequest.FromRef.LatestCommit,
+			Sender:     payload.Actor.Name,
+			PullRequest: &PullRequest{
+				Number:       payload.PullRequest.ID,
+				Action:       action,
+				TargetBranch: payload.PullRequest.ToRef.DisplayID,
+				Closed:       action == "merged" || action == "declined" || action == "deleted",
+			},
+		})
+	}
+	return events, nil
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/rand"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	devopslister "devops.kubesphere.io/plugin/pkg/client/listers/devops/v1alpha3"
+)
+
+// DefaultMaxDeliveries is the number of the latest deliveries kept for debugging
+const DefaultMaxDeliveries = 100
+
+// The actions taken on a pipeline for an event
+const (
+	ActionRun     = "run"
+	ActionScan    = "scan"
+	ActionSkip    = "skip"
+	ActionFailure = "failure"
+)
+
+// Delivery is the record of a webhook, with the actions taken on the pipelines of its repository
+type Delivery struct {
+	ID         string    `json:"id"`
+	Provider   string    `json:"provider"`
+	Event      string    `json:"event"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	// Error is the reason the whole webhook was rejected, e.g. the payload is invalid
+	Error    string    `json:"error,omitempty"`
+	Events   []Event   `json:"events"`
+	Triggers []Trigger `json:"triggers"`
+}
+
+// Trigger is the action taken on a pipeline matching the repository of an event
+type Trigger struct {
+	Namespace string `json:"namespace"`
+	Pipeline  string `json:"pipeline"`
+	// Job is the branch, the tag or the pull request job in the multi-branch pipeline
+	Job    string `json:"job,omitempty"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Receipt is the response to the SCM, the delivery itself is only listed to the members of the DevOps projects,
+// because the triggers name the pipelines and the secrets of every matching project
+type Receipt struct {
+	ID string `json:"id"`
+	// Triggered is the number of the pipelines scanned or run
+	Triggered int `json:"triggered"`
+}
+
+// Receipt returns the receipt of the delivery
+func (d *Delivery) Receipt() Receipt {
+	receipt := Receipt{ID: d.ID}
+	for _, trigger := range d.Triggers {
+		if trigger.Action == ActionRun || trigger.Action == ActionScan {
+			receipt.Triggered++
+		}
+	}
+	return receipt
+}
+
+type Receiver interface {
+	// Receive verifies the webhook, and then scans or runs the multi-branch pipelines of the repository
+	Receive(provider string, header http.Header, body []byte) (*Delivery, error)
+
+	// ListDeliveries returns the latest deliveries triggering the pipelines of the DevOps project, the newest
+	// first, with only the triggers of the project
+	ListDeliveries(namespace string, limit int) []Delivery
+}
+
+type receiver struct {
+	devopsClient   devops.Interface
+	pipelineLister devopslister.PipelineLister
+	secretLister   corev1lister.SecretLister
+
+	lock          sync.Mutex
+	deliveries    []Delivery
+	maxDeliveries int
+}
+
+func NewReceiver(devopsClient devops.Interface, pipelineLister devopslister.PipelineLister,
+	secretLister corev1lister.SecretLister, maxDeliveries int) Receiver {
+	return &receiver{
+		devopsClient:   devopsClient,
+		pipelineLister: pipelineLister,
+		secretLister:   secretLister,
+		maxDeliveries:  maxDeliveries,
+	}
+}
+
+func (r *receiver) Receive(provider string, header http.Header, body []byte) (*Delivery, error) {
+	delivery := &Delivery{
+		ID:         deliveryID(provider, header),
+		Provider:   provider,
+		Event:      eventName(provider, header),
+		ReceivedAt: time.Now(),
+		Events:     make([]Event, 0),
+		Triggers:   make([]Trigger, 0),
+	}
+	if delivery.ID == "" {
+		delivery.ID = rand.String(16)
+	}
+	defer r.record(delivery)
+
+	events, err := Parse(provider, header, body)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery, restful.NewError(http.StatusBadRequest, err.Error())
+	}
+	delivery.Events = append(delivery.Events, events...)
+
+	pipelines, err := r.pipelineLister.List(labels.Everything())
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery, err
+	}
+
+	verified, rejected := 0, 0
+	for i := range events {
+		event := &events[i]
+		if event.Type == EventTypePing {
+			continue
+		}
+		for _, pipeline := range pipelines {
+			if !matchPipeline(pipeline, event) {
+				continue
+			}
+			trigger := Trigger{Namespace: pipeline.Namespace, Pipeline: pipeline.Name, Job: event.JobName()}
+			if err := r.verify(pipeline, provider, header, body); err != nil {
+				rejected++
+				trigger.Action, trigger.Reason = ActionSkip, err.Error()
+			} else {
+				verified++
+				r.trigger(pipeline, event, &trigger)
+			}
+			delivery.Triggers = append(delivery.Triggers, trigger)
+		}
+	}
+
+	// a webhook signed by none of the secrets of its pipelines is likely forged
+	if verified == 0 && rejected > 0 {
+		delivery.Error = "the webhook matches none of the secrets of the pipelines"
+		return delivery, restful.NewError(http.StatusForbidden, delivery.Error)
+	}
+	return delivery, nil
+}
+
+// verify checks the webhook against the secret of the pipeline, the pipelines without a secret are never
+// triggered by webhooks
+func (r *receiver) verify(pipeline *devopsv1alpha3.Pipeline, provider string, header http.Header, body []byte) error {
+	secretName := pipeline.Annotations[devopsv1alpha3.PipelineWebhookSecretAnnoKey]
+	if secretName == "" {
+		return fmt.Errorf("the pipeline has no webhook secret")
+	}
+	secret, err := r.secretLister.Secrets(pipeline.Namespace).Get(secretName)
+	if err != nil {
+		return fmt.Errorf("failed to get the webhook secret %s: %v", secretName, err)
+	}
+	value := secret.Data[devopsv1alpha3.PipelineWebhookSecretKey]
+	if len(value) == 0 {
+		return fmt.Errorf("the webhook secret %s has no key %s", secretName, devopsv1alpha3.PipelineWebhookSecretKey)
+	}
+	return Verify(provider, header, body, value)
+}
+
+// trigger runs the job of the event if Jenkins knows it, otherwise scans the multi-branch pipeline, so the new,
+// the deleted and the closed branches, tags and pull requests are discovered
+func (r *receiver) trigger(pipeline *devopsv1alpha3.Pipeline, event *Event, trigger *Trigger) {
+	multiBranch := pipeline.Spec.MultiBranchPipeline
+	if event.Type == EventTypeTag && !discoverTags(multiBranch) {
+		trigger.Action, trigger.Reason = ActionSkip, "the pipeline does not discover tags"
+		return
+	}
+	if filter := regexFilter(multiBranch); filter != "" && !event.Deleted {
+		matched, err := regexp.MatchString("^(?:"+filter+")$", trigger.Job)
+		if err != nil || !matched {
+			trigger.Action, trigger.Reason = ActionSkip, fmt.Sprintf("%s does not match the regex filter %s", trigger.Job, filter)
+			return
+		}
+	}
+
+	closed := event.Deleted || (event.PullRequest != nil && event.PullRequest.Closed)
+	if !closed && event.Type != EventTypeTag {
+		if _, err := r.devopsClient.GetBranchPipeline(pipeline.Namespace, pipeline.Name, trigger.Job, devops.NewHttpParameters(http.MethodGet, nil)); err == nil {
+			_, err = r.devopsClient.RunBranchPipeline(pipeline.Namespace, pipeline.Name, trigger.Job,
+				devops.NewHttpParameters(http.MethodPost, []byte(`{"parameters":[]}`)))
+			if err != nil {
+				klog.Error(err)
+				trigger.Action, trigger.Reason = ActionFailure, err.Error()
+				return
+			}
+			trigger.Action = ActionRun
+			return
+		}
+	}
+
+	httpParameters := devops.NewHttpParameters(http.MethodPost, nil)
+	httpParameters.Url.RawQuery = "delay=0"
+	if _, err := r.devopsClient.ScanBranch(pipeline.Namespace, pipeline.Name, httpParameters); err != nil {
+		klog.Error(err)
+		trigger.Action, trigger.Reason = ActionFailure, err.Error()
+		return
+	}
+	trigger.Action = ActionScan
+}
+
+func (r *receiver) record(delivery *Delivery) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.deliveries = append(r.deliveries, *delivery)
+	if len(r.deliveries) > r.maxDeliveries {
+		r.deliveries = r.deliveries[len(r.deliveries)-r.maxDeliveries:]
+	}
+}
+
+func (r *receiver) ListDeliveries(namespace string, limit int) []Delivery {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	result := make([]Delivery, 0)
+	for i := len(r.deliveries) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		delivery := r.deliveries[i]
+		triggers := make([]Trigger, 0)
+		for _, trigger := range delivery.Triggers {
+			if trigger.Namespace == namespace {
+				triggers = append(triggers, trigger)
+			}
+		}
+		if len(triggers) == 0 {
+			continue
+		}
+		delivery.Triggers = triggers
+		result = append(result, delivery)
+	}
+	return result
+}
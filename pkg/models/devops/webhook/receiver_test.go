@@ -0,0 +1,249 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	"devops.kubesphere.io/plugin/pkg/client/devops"
+	fakedevops "devops.kubesphere.io/plugin/pkg/client/devops/fake"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+)
+
+// triggerDevops records the runs and the scans, the jobs in knownJobs exist in Jenkins
+type triggerDevops struct {
+	*fakedevops.Devops
+	knownJobs map[string]bool
+	actions   []string
+}
+
+func (d *triggerDevops) GetBranchPipeline(projectName, pipelineName, branchName string, httpParameters *devops.HttpParameters) (*devops.BranchPipeline, error) {
+	if !d.knownJobs[branchName] {
+		return nil, fmt.Errorf("branch %s not found", branchName)
+	}
+	return &devops.BranchPipeline{Name: branchName}, nil
+}
+
+func (d *triggerDevops) RunBranchPipeline(projectName, pipelineName, branchName string, httpParameters *devops.HttpParameters) (*devops.RunPipeline, error) {
+	d.actions = append(d.actions, fmt.Sprintf("run %s/%s/%s", projectName, pipelineName, branchName))
+	return &devops.RunPipeline{}, nil
+}
+
+func (d *triggerDevops) ScanBranch(projectName, pipelineName string, httpParameters *devops.HttpParameters) ([]byte, error) {
+	d.actions = append(d.actions, fmt.Sprintf("scan %s/%s", projectName, pipelineName))
+	return nil, nil
+}
+
+func newMultiBranchPipeline(name string, secret string, source devopsv1alpha3.MultiBranchPipeline) *devopsv1alpha3.Pipeline {
+	pipeline := &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project"},
+		Spec: devopsv1alpha3.PipelineSpec{
+			Type:                devopsv1alpha3.MultiBranchPipelineType,
+			MultiBranchPipeline: &source,
+		},
+	}
+	if secret != "" {
+		pipeline.Annotations = map[string]string{devopsv1alpha3.PipelineWebhookSecretAnnoKey: secret}
+	}
+	return pipeline
+}
+
+func newTestReceiver(knownJobs ...string) (Receiver, *triggerDevops) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "project"},
+		Data:       map[string][]byte{devopsv1alpha3.PipelineWebhookSecretKey: []byte("s3cr3t")},
+	}
+	pipelines := []*devopsv1alpha3.Pipeline{
+		newMultiBranchPipeline("github", "webhook", devopsv1alpha3.MultiBranchPipeline{
+			SourceType:   devopsv1alpha3.SourceTypeGithub,
+			GitHubSource: &devopsv1alpha3.GithubSource{Owner: "kubesphere", Repo: "devops", DiscoverTags: true},
+		}),
+		newMultiBranchPipeline("git", "webhook", devopsv1alpha3.MultiBranchPipeline{
+			SourceType: devopsv1alpha3.SourceTypeGit,
+			GitSource:  &devopsv1alpha3.GitSource{Url: "git@github.com:kubesphere/devops.git", RegexFilter: "master|release-.*"},
+		}),
+		newMultiBranchPipeline("unsecured", "", devopsv1alpha3.MultiBranchPipeline{
+			SourceType:   devopsv1alpha3.SourceTypeGithub,
+			GitHubSource: &devopsv1alpha3.GithubSource{Owner: "kubesphere", Repo: "devops"},
+		}),
+		newMultiBranchPipeline("other", "webhook", devopsv1alpha3.MultiBranchPipeline{
+			SourceType:   devopsv1alpha3.SourceTypeGithub,
+			GitHubSource: &devopsv1alpha3.GithubSource{Owner: "kubesphere", Repo: "other"},
+		}),
+	}
+
+	k8sInformerFactory := k8sinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0)
+	k8sInformerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(secret)
+	ksInformerFactory := ksinformers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	for _, pipeline := range pipelines {
+		ksInformerFactory.Devops().V1alpha3().Pipelines().Informer().GetIndexer().Add(pipeline)
+	}
+
+	devopsClient := &triggerDevops{Devops: fakedevops.New("project"), knownJobs: map[string]bool{}}
+	for _, job := range knownJobs {
+		devopsClient.knownJobs[job] = true
+	}
+	return NewReceiver(devopsClient, ksInformerFactory.Devops().V1alpha3().Pipelines().Lister(),
+		k8sInformerFactory.Core().V1().Secrets().Lister(), 2), devopsClient
+}
+
+func TestReceive(t *testing.T) {
+	repository := `"repository": {"full_name": "kubesphere/devops", "clone_url": "https://github.com/kubesphere/devops.git"}`
+
+	tests := []struct {
+		description     string
+		event           string
+		body            string
+		secret          string
+		knownJobs       []string
+		expectedActions []string
+		expectedStatus  int
+	}{
+		{
+			description:     "push to a known branch runs it",
+			event:           "push",
+			body:            `{"ref": "refs/heads/master", "after": "abc", ` + repository + `}`,
+			knownJobs:       []string{"master"},
+			expectedActions: []string{"run project/github/master", "run project/git/master"},
+		},
+		{
+			description:     "push to a new branch scans the pipeline, unless the regex filter excludes it",
+			event:           "push",
+			body:            `{"ref": "refs/heads/feature", "after": "abc", ` + repository + `}`,
+			expectedActions: []string{"scan project/github"},
+		},
+		{
+			description:     "tags are only scanned by the pipelines discovering tags",
+			event:           "push",
+			body:            `{"ref": "refs/tags/v1.0", "after": "abc", ` + repository + `}`,
+			expectedActions: []string{"scan project/github"},
+		},
+		{
+			description:     "closed pull request scans the pipeline",
+			event:           "pull_request",
+			body:            `{"action": "closed", "number": 7, "pull_request": {"head": {"ref": "release-1"}}, ` + repository + `}`,
+			knownJobs:       []string{"PR-7"},
+			expectedActions: []string{"scan project/github"},
+		},
+		{
+			description:    "webhook signed by another secret is rejected",
+			event:          "push",
+			body:           `{"ref": "refs/heads/master", "after": "abc", ` + repository + `}`,
+			secret:         "guess",
+			knownJobs:      []string{"master"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			description:    "invalid payload is rejected",
+			event:          "push",
+			body:           `{"ref": `,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description: "ping does nothing",
+			event:       "ping",
+			body:        `{"zen": "Keep it logically awesome.", ` + repository + `}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			receiver, devopsClient := newTestReceiver(test.knownJobs...)
+			secret := test.secret
+			if secret == "" {
+				secret = "s3cr3t"
+			}
+			header := newHeader("X-GitHub-Event", test.event, "X-GitHub-Delivery", "delivery-1",
+				"X-Hub-Signature-256", "sha256="+sign(sha256.New, secret, test.body))
+
+			delivery, err := receiver.Receive(ProviderGithub, header, []byte(test.body))
+			if test.expectedStatus != 0 {
+				if serviceErr, ok := err.(restful.ServiceError); !ok || serviceErr.Code != test.expectedStatus {
+					t.Fatalf("expected status %d, got %v", test.expectedStatus, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			// the pipelines are listed in no particular order
+			sort.Strings(devopsClient.actions)
+			sort.Strings(test.expectedActions)
+			if len(devopsClient.actions) != len(test.expectedActions) ||
+				(len(test.expectedActions) > 0 && !reflect.DeepEqual(devopsClient.actions, test.expectedActions)) {
+				t.Errorf("expected actions %v, got %v", test.expectedActions, devopsClient.actions)
+			}
+			if delivery.ID != "delivery-1" {
+				t.Errorf("expected the delivery delivery-1, got %+v", delivery)
+			}
+		})
+	}
+}
+
+func TestReceiveTriggers(t *testing.T) {
+	receiver, _ := newTestReceiver("master")
+	body := `{"ref": "refs/heads/feature", "after": "abc", "repository": {"full_name": "kubesphere/devops", ` +
+		`"clone_url": "https://github.com/kubesphere/devops.git"}}`
+	header := newHeader("X-GitHub-Event", "push", "X-Hub-Signature-256", "sha256="+sign(sha256.New, "s3cr3t", body))
+
+	delivery, err := receiver.Receive(ProviderGithub, header, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[string]string{}
+	for _, trigger := range delivery.Triggers {
+		if trigger.Job != "feature" {
+			t.Errorf("expected the job feature, got %s", trigger.Job)
+		}
+		actions[trigger.Pipeline] = trigger.Action
+	}
+	expected := map[string]string{"github": ActionScan, "git": ActionSkip, "unsecured": ActionSkip}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected actions %v, got %v", expected, actions)
+	}
+	if receipt := delivery.Receipt(); receipt.Triggered != 1 {
+		t.Errorf("expected 1 triggered pipeline, got %+v", receipt)
+	}
+
+	// only the latest deliveries are kept, the newest first
+	for i := 0; i < 3; i++ {
+		header.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", i))
+		receiver.Receive(ProviderGithub, header, []byte(body))
+	}
+	deliveries := receiver.ListDeliveries("project", 0)
+	if len(deliveries) != 2 || deliveries[0].ID != "delivery-2" || deliveries[1].ID != "delivery-1" {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+	if deliveries = receiver.ListDeliveries("project", 1); len(deliveries) != 1 || deliveries[0].ID != "delivery-2" {
+		t.Errorf("unexpected deliveries %+v", deliveries)
+	}
+	// the deliveries are not listed to the other DevOps projects
+	if deliveries = receiver.ListDeliveries("another", 0); len(deliveries) != 0 {
+		t.Errorf("expected no deliveries of another project, got %+v", deliveries)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrSignatureMissing  = errors.New("the signature of the webhook is missing")
+	ErrSignatureMismatch = errors.New("the signature of the webhook does not match the secret")
+)
+
+// Verify checks the webhook against the secret. GitLab sends the secret as a token, the others sign the body
+// with HMAC.
+func Verify(provider string, header http.Header, body, secret []byte) error {
+	switch provider {
+	case ProviderGithub:
+		if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+			return verifyHMAC(sha256.New, strings.TrimPrefix(signature, "sha256="), body, secret)
+		}
+		if signature := header.Get("X-Hub-Signature"); signature != "" {
+			return verifyHMAC(sha1.New, strings.TrimPrefix(signature, "sha1="), body, secret)
+		}
+		return ErrSignatureMissing
+	case ProviderGitlab:
+		token := header.Get("X-Gitlab-Token")
+		if token == "" {
+			return ErrSignatureMissing
+		}
+		if subtle.ConstantTimeCompare([]byte(token), secret) != 1 {
+			return ErrSignatureMismatch
+		}
+		return nil
+	case ProviderBitbucketServer:
+		signature := header.Get("X-Hub-Signature")
+		if signature == "" {
+			return ErrSignatureMissing
+		}
+		return verifyHMAC(sha256.New, strings.TrimPrefix(signature, "sha256="), body, secret)
+	case ProviderGitea:
+		signature := header.Get("X-Gitea-Signature")
+		if signature == "" {
+			return ErrSignatureMissing
+		}
+		return verifyHMAC(sha256.New, signature, body, secret)
+	}
+	return fmt.Errorf("unsupported SCM provider %s", provider)
+}
+
+func verifyHMAC(newHash func() hash.Hash, signature string, body, secret []byte) error {
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrSignatureMismatch
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write(body)
+	if !hmac.Equal(actual, mac.Sum(nil)) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
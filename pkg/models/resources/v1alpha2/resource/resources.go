@@ -19,8 +19,11 @@ package resource
 import (
 	"errors"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
 
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
 	"devops.kubesphere.io/plugin/pkg/informers"
 	"devops.kubesphere.io/plugin/pkg/models"
 	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha2"
@@ -98,6 +101,11 @@ func (r *ResourceGetter) ListResources(namespace, resource string, conditions *p
 		return nil, err
 	}
 
+	if result, err = filterByRequirements(r.resourcesGetters[resource], namespace, conditions, result); err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+
 	if limit == -1 || limit+offset > len(result) {
 		limit = len(result) - offset
 	}
@@ -106,3 +114,59 @@ func (r *ResourceGetter) ListResources(namespace, resource string, conditions *p
 
 	return &models.PageableResponse{TotalCount: len(result), Items: items}, nil
 }
+
+// filterByRequirements keeps the items which meet the requirements and field selector of conditions,
+// a requirement value is matched in the same way as the exact query of searcher.
+func filterByRequirements(searcher v1alpha2.Interface, namespace string, conditions *params.Conditions, items []interface{}) ([]interface{}, error) {
+	matcher, err := conditions.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	if matcher.Empty() {
+		return items, nil
+	}
+
+	// keys of the items which match the exact query, cached by field and value
+	matched := make(map[query.Filter]sets.String)
+	var searchErr error
+	test := func(key string) func(query.Filter) bool {
+		return func(filter query.Filter) bool {
+			keys, ok := matched[filter]
+			if !ok {
+				keys = sets.NewString()
+				exact := &params.Conditions{
+					Match: map[string]string{string(filter.Field): string(filter.Value)},
+					Fuzzy: map[string]string{},
+				}
+				found, err := searcher.Search(namespace, exact, "", false)
+				if err != nil && searchErr == nil {
+					searchErr = err
+				}
+				for _, item := range found {
+					keys.Insert(itemKey(item))
+				}
+				matched[filter] = keys
+			}
+			return keys.Has(key)
+		}
+	}
+
+	filtered := make([]interface{}, 0)
+	for _, item := range items {
+		if matcher.Matches(item, test(itemKey(item))) {
+			filtered = append(filtered, item)
+		}
+	}
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	return filtered, nil
+}
+
+func itemKey(item interface{}) string {
+	object, err := meta.Accessor(item)
+	if err != nil {
+		return ""
+	}
+	return object.GetNamespace() + "/" + object.GetName()
+}
@@ -50,7 +50,7 @@ func (n devopsGetter) List(_ string, query *query.Query) (*api.ListResult, error
 		result = append(result, project)
 	}
 
-	return v1alpha3.DefaultList(result, query, n.compare, n.filter), nil
+	return v1alpha3.DefaultList(result, query, n.compare, n.filter)
 }
 
 func (n devopsGetter) filter(item runtime.Object, filter query.Filter) bool {
@@ -17,11 +17,15 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
 
 	"devops.kubesphere.io/plugin/pkg/api"
 	"devops.kubesphere.io/plugin/pkg/apiserver/query"
@@ -42,9 +46,17 @@ type FilterFunc func(runtime.Object, query.Filter) bool
 
 type TransformFunc func(runtime.Object) runtime.Object
 
-func DefaultList(objects []runtime.Object, q *query.Query, compareFunc CompareFunc, filterFunc FilterFunc, transformFuncs ...TransformFunc) *api.ListResult {
+// DefaultList filters, sorts and paginates the objects by the query. It fails with BadRequest if the continue
+// token is invalid, or Expired if the position of the token is lost.
+func DefaultList(objects []runtime.Object, q *query.Query, compareFunc CompareFunc, filterFunc FilterFunc, transformFuncs ...TransformFunc) (*api.ListResult, error) {
 	// selected matched ones
 	var filtered []runtime.Object
+	matcher, err := q.Matcher()
+	if err != nil {
+		// the handlers reject the invalid filters, nothing matches them anyway
+		klog.Error(err)
+		return &api.ListResult{Items: []interface{}{}}, nil
+	}
 	for _, object := range objects {
 		selected := true
 		for field, value := range q.Filters {
@@ -54,6 +66,12 @@ func DefaultList(objects []runtime.Object, q *query.Query, compareFunc CompareFu
 			}
 		}
 
+		if selected && !matcher.Empty() {
+			selected = matcher.Matches(object, func(filter query.Filter) bool {
+				return filterFunc(object, filter)
+			})
+		}
+
 		if selected {
 			for _, transform := range transformFuncs {
 				object = transform(object)
@@ -78,10 +96,77 @@ func DefaultList(objects []runtime.Object, q *query.Query, compareFunc CompareFu
 
 	start, end := q.Pagination.GetValidPagination(total)
 
-	return &api.ListResult{
+	// the continue token takes precedence over the offset
+	token, err := q.ContinueToken()
+	if err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+	if token != nil {
+		metas := make([]metav1.ObjectMeta, len(filtered))
+		for i, object := range filtered {
+			if accessor, err := meta.Accessor(object); err == nil {
+				metas[i] = metav1.ObjectMeta{
+					Namespace:         accessor.GetNamespace(),
+					Name:              accessor.GetName(),
+					UID:               accessor.GetUID(),
+					CreationTimestamp: accessor.GetCreationTimestamp(),
+				}
+			}
+		}
+		if start, err = ContinueIndex(metas, token, q); err != nil {
+			return nil, err
+		}
+		end = total
+		if q.Pagination.Limit >= 0 && start+q.Pagination.Limit < total {
+			end = start + q.Pagination.Limit
+		}
+	}
+
+	result := &api.ListResult{
 		TotalItems: len(filtered),
 		Items:      objectsToInterfaces(filtered[start:end]),
 	}
+
+	// more items are left, issue the token which starts after the last item of this page
+	if q.Pagination.Limit > 0 && end > 0 && end < total {
+		if accessor, err := meta.Accessor(filtered[end-1]); err == nil {
+			result.Continue = query.NewContinueToken(q, accessor).Encode()
+		}
+		remaining := int64(total - end)
+		result.RemainingItemCount = &remaining
+	}
+	return result, nil
+}
+
+// ContinueIndex returns the index of the first item after the last item of previous page. The last item
+// is found by its identity, if it has gone, by its sort key for the metadata sorts. The position of a
+// deleted item is lost in the lists sorted by the other fields, the token is expired then.
+func ContinueIndex(items []metav1.ObjectMeta, token *query.ContinueToken, q *query.Query) (int, error) {
+	for i, item := range items {
+		if token.UID != "" && string(item.UID) == token.UID {
+			return i + 1, nil
+		}
+		if token.UID == "" && item.Namespace == token.Namespace && item.Name == token.Name {
+			return i + 1, nil
+		}
+	}
+
+	if !token.IsMetadataSort() {
+		return 0, errors.NewResourceExpired(fmt.Sprintf("the last item %s/%s of previous page has gone, "+
+			"the list has to be restarted without the continue token", token.Namespace, token.Name))
+	}
+	last := token.ObjectMeta()
+	for i, item := range items {
+		// objects are sorted in descending order by default
+		after := DefaultObjectMetaCompare(last, item, q.SortBy)
+		if q.Ascending {
+			after = DefaultObjectMetaCompare(item, last, q.SortBy)
+		}
+		if after {
+			return i, nil
+		}
+	}
+	return len(items), nil
 }
 
 // DefaultObjectMetaCompare return true is left great than right
@@ -89,6 +174,10 @@ func DefaultObjectMetaCompare(left, right metav1.ObjectMeta, sortBy query.Field)
 	switch sortBy {
 	// ?sortBy=name
 	case query.FieldName:
+		// compare by namespace if name is equal, so the order of the objects is total
+		if left.Name == right.Name {
+			return strings.Compare(left.Namespace, right.Namespace) > 0
+		}
 		return strings.Compare(left.Name, right.Name) > 0
 	//	?sortBy=creationTimestamp
 	default:
@@ -98,12 +187,27 @@ func DefaultObjectMetaCompare(left, right metav1.ObjectMeta, sortBy query.Field)
 	case query.FieldCreationTimeStamp:
 		// compare by name if creation timestamp is equal
 		if left.CreationTimestamp.Equal(&right.CreationTimestamp) {
-			return strings.Compare(left.Name, right.Name) > 0
+			return DefaultObjectMetaCompare(left, right, query.FieldName)
 		}
 		return left.CreationTimestamp.After(right.CreationTimestamp.Time)
 	}
 }
 
+// DefaultTimeCompare return true is left great than right, the nil time is the earliest one.
+// Objects with the same time are compared by their creation timestamps.
+func DefaultTimeCompare(left, right *metav1.Time, leftMeta, rightMeta metav1.ObjectMeta) bool {
+	if left.Equal(right) {
+		return DefaultObjectMetaCompare(leftMeta, rightMeta, query.FieldCreationTimeStamp)
+	}
+	if left == nil {
+		return false
+	}
+	if right == nil {
+		return true
+	}
+	return left.After(right.Time)
+}
+
 //  Default metadata filter
 func DefaultObjectMetaFilter(item metav1.ObjectMeta, filter query.Filter) bool {
 	switch filter.Field {
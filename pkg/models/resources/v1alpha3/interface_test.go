@@ -18,7 +18,18 @@
 
 package v1alpha3
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+)
 
 func TestLabelMatch(t *testing.T) {
 	tests := []struct {
@@ -83,3 +94,167 @@ func TestLabelMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestDefaultListWithRequirements(t *testing.T) {
+	var objects []runtime.Object
+	for _, name := range []string{"foo1", "foo2", "bar1"} {
+		objects = append(objects, &metav1.PartialObjectMetadata{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		})
+	}
+	compare := func(left, right runtime.Object, field query.Field) bool {
+		return DefaultObjectMetaCompare(left.(*metav1.PartialObjectMetadata).ObjectMeta,
+			right.(*metav1.PartialObjectMetadata).ObjectMeta, field)
+	}
+	filter := func(object runtime.Object, filter query.Filter) bool {
+		return DefaultObjectMetaFilter(object.(*metav1.PartialObjectMetadata).ObjectMeta, filter)
+	}
+
+	tests := []struct {
+		description string
+		query       *query.Query
+		expected    []string
+	}{
+		{
+			"in",
+			&query.Query{
+				SortBy:       query.FieldName,
+				Ascending:    true,
+				Requirements: []query.Requirement{{Field: query.FieldName, Operator: query.OperatorIn, Values: []string{"2", "bar"}}},
+			},
+			[]string{"bar1", "foo2"},
+		},
+		{
+			"prefix and negation",
+			&query.Query{
+				SortBy:    query.FieldName,
+				Ascending: true,
+				Requirements: []query.Requirement{
+					{Field: query.FieldName, Operator: query.OperatorPrefix, Values: []string{"foo"}},
+					{Field: query.FieldName, Operator: query.OperatorIn, Values: []string{"2"}, Negated: true},
+				},
+			},
+			[]string{"foo1"},
+		},
+		{
+			"field selector",
+			&query.Query{
+				SortBy:        query.FieldName,
+				Ascending:     true,
+				FieldSelector: "metadata.name!=foo1",
+			},
+			[]string{"bar1", "foo2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			result, err := DefaultList(objects, test.query, compare, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range result.Items {
+				got = append(got, item.(*metav1.PartialObjectMetadata).Name)
+			}
+			if len(got) != len(test.expected) || result.TotalItems != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+			for i := range got {
+				if got[i] != test.expected[i] {
+					t.Fatalf("expected %v, got %v", test.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultListWithContinue(t *testing.T) {
+	objectOf := func(name string) runtime.Object {
+		return &metav1.PartialObjectMetadata{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+		}
+	}
+	compare := func(left, right runtime.Object, field query.Field) bool {
+		return DefaultObjectMetaCompare(left.(*metav1.PartialObjectMetadata).ObjectMeta,
+			right.(*metav1.PartialObjectMetadata).ObjectMeta, field)
+	}
+	filter := func(object runtime.Object, filter query.Filter) bool {
+		return DefaultObjectMetaFilter(object.(*metav1.PartialObjectMetadata).ObjectMeta, filter)
+	}
+	namesOf := func(result *api.ListResult) (names []string) {
+		for _, item := range result.Items {
+			names = append(names, item.(*metav1.PartialObjectMetadata).Name)
+		}
+		return
+	}
+	newQuery := func(continueToken string) *query.Query {
+		return &query.Query{
+			SortBy:     query.FieldName,
+			Ascending:  true,
+			Pagination: &query.Pagination{Limit: 2},
+			Continue:   continueToken,
+		}
+	}
+
+	list := func(objects []runtime.Object, q *query.Query) *api.ListResult {
+		result, err := DefaultList(objects, q, compare, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	objects := []runtime.Object{objectOf("a"), objectOf("b"), objectOf("c"), objectOf("d"), objectOf("e")}
+	first := list(objects, newQuery(""))
+	if diff := cmp.Diff([]string{"a", "b"}, namesOf(first)); diff != "" {
+		t.Fatalf("first page differ (-expected, +got): %s", diff)
+	}
+	if first.Continue == "" || first.RemainingItemCount == nil || *first.RemainingItemCount != 3 {
+		t.Fatalf("unexpected continue %q and remaining items %v", first.Continue, first.RemainingItemCount)
+	}
+
+	// an item is created before the last item, the second page is not shifted
+	objects = append(objects, objectOf("aa"))
+	second := list(objects, newQuery(first.Continue))
+	if diff := cmp.Diff([]string{"c", "d"}, namesOf(second)); diff != "" {
+		t.Fatalf("second page differ (-expected, +got): %s", diff)
+	}
+
+	// the last item is deleted, the last page starts after its sort key
+	objects = []runtime.Object{objectOf("a"), objectOf("b"), objectOf("c"), objectOf("e")}
+	last := list(objects, newQuery(second.Continue))
+	if diff := cmp.Diff([]string{"e"}, namesOf(last)); diff != "" {
+		t.Fatalf("last page differ (-expected, +got): %s", diff)
+	}
+	if last.Continue != "" || last.RemainingItemCount != nil {
+		t.Fatalf("unexpected continue %q and remaining items %v on the last page", last.Continue, last.RemainingItemCount)
+	}
+
+	// the objects of the same name are ordered by their namespaces
+	other := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "other", UID: "other-b"}}
+	objects = []runtime.Object{objectOf("a"), other, objectOf("b"), objectOf("c")}
+	first = list(objects, newQuery(""))
+	objects = []runtime.Object{objectOf("a"), other, objectOf("c")}
+	second = list(objects, newQuery(first.Continue))
+	var got []string
+	for _, item := range second.Items {
+		object := item.(*metav1.PartialObjectMetadata)
+		got = append(got, object.Namespace+"/"+object.Name)
+	}
+	if diff := cmp.Diff([]string{"other/b", "default/c"}, got); diff != "" {
+		t.Fatalf("second page differ (-expected, +got): %s", diff)
+	}
+
+	if _, err := DefaultList(objects, newQuery("foo!"), compare, filter); !errors.IsBadRequest(err) {
+		t.Errorf("expected BadRequest of the invalid token, got %v", err)
+	}
+
+	// the position of the deleted item is lost if the objects are not sorted by the metadata
+	byStatus := &query.Query{SortBy: "status", Ascending: true, Pagination: &query.Pagination{Limit: 2}}
+	first = list(objects, byStatus)
+	byStatus.Continue = first.Continue
+	if _, err := DefaultList(objects[2:], byStatus, compare, filter); !errors.IsResourceExpired(err) {
+		t.Errorf("expected Expired of the token after the deleted item, got %v", err)
+	}
+}
@@ -54,7 +54,7 @@ func (d *loginrecordsGetter) List(_ string, query *query.Query) (*api.ListResult
 		result = append(result, user)
 	}
 
-	return v1alpha3.DefaultList(result, query, d.compare, d.filter), nil
+	return v1alpha3.DefaultList(result, query, d.compare, d.filter)
 }
 
 func (d *loginrecordsGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
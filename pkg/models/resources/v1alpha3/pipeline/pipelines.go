@@ -0,0 +1,113 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+type pipelinesGetter struct {
+	informers ksinformers.SharedInformerFactory
+}
+
+func New(ksinformer ksinformers.SharedInformerFactory) v1alpha3.Interface {
+	return &pipelinesGetter{informers: ksinformer}
+}
+
+func (p *pipelinesGetter) Get(namespace, name string) (runtime.Object, error) {
+	return p.informers.Devops().V1alpha3().Pipelines().Lister().Pipelines(namespace).Get(name)
+}
+
+func (p *pipelinesGetter) List(namespace string, query *query.Query) (*api.ListResult, error) {
+	pipelines, err := p.informers.Devops().V1alpha3().Pipelines().Lister().Pipelines(namespace).List(query.Selector())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []runtime.Object
+	for _, pipeline := range pipelines {
+		result = append(result, pipeline)
+	}
+
+	return v1alpha3.DefaultList(result, query, p.compare, p.filter)
+}
+
+func (p *pipelinesGetter) filter(object runtime.Object, filter query.Filter) bool {
+	pipeline, ok := object.(*devopsv1alpha3.Pipeline)
+	if !ok {
+		return false
+	}
+
+	switch filter.Field {
+	// /pipelines?type=multi-branch-pipeline
+	case query.FieldType:
+		return pipeline.Spec.Type == string(filter.Value)
+	// /pipelines?sourceType=github
+	case query.FieldSourceType:
+		return pipeline.Spec.MultiBranchPipeline != nil &&
+			pipeline.Spec.MultiBranchPipeline.SourceType == string(filter.Value)
+	// /pipelines?syncStatus=successful
+	case query.FieldSyncStatus:
+		return pipeline.Annotations[devopsv1alpha3.PipelineSyncStatusAnnoKey] == string(filter.Value)
+	default:
+		return v1alpha3.DefaultObjectMetaFilter(pipeline.ObjectMeta, filter)
+	}
+}
+
+func (p *pipelinesGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
+	leftPipeline, ok := left.(*devopsv1alpha3.Pipeline)
+	if !ok {
+		return false
+	}
+
+	rightPipeline, ok := right.(*devopsv1alpha3.Pipeline)
+	if !ok {
+		return true
+	}
+
+	switch field {
+	// ?sortBy=syncTime
+	case query.FieldSyncTime:
+		return v1alpha3.DefaultTimeCompare(syncTime(leftPipeline), syncTime(rightPipeline),
+			leftPipeline.ObjectMeta, rightPipeline.ObjectMeta)
+	default:
+		return v1alpha3.DefaultObjectMetaCompare(leftPipeline.ObjectMeta, rightPipeline.ObjectMeta, field)
+	}
+}
+
+// syncTime returns the time when the pipeline was synced to Jenkins, nil if it was never synced
+func syncTime(pipeline *devopsv1alpha3.Pipeline) *metav1.Time {
+	value, ok := pipeline.Annotations[devopsv1alpha3.PipelineSyncTimeAnnoKey]
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: t}
+}
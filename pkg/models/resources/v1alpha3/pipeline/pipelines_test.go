@@ -0,0 +1,179 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	informers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+func TestListPipelines(t *testing.T) {
+	tests := []struct {
+		description string
+		namespace   string
+		query       *query.Query
+		expected    *api.ListResult
+		expectedErr error
+	}{
+		{
+			"test type filter",
+			"bar",
+			&query.Query{
+				Pagination: query.NoPagination,
+				SortBy:     query.FieldName,
+				Ascending:  true,
+				Filters:    map[query.Field]query.Value{query.FieldType: devopsv1alpha3.MultiBranchPipelineType},
+			},
+			&api.ListResult{
+				Items:      []interface{}{foo2, foo3},
+				TotalItems: 2,
+			},
+			nil,
+		},
+		{
+			"test source type filter",
+			"bar",
+			&query.Query{
+				Pagination: query.NoPagination,
+				SortBy:     query.FieldName,
+				Filters:    map[query.Field]query.Value{query.FieldSourceType: devopsv1alpha3.SourceTypeGithub},
+			},
+			&api.ListResult{
+				Items:      []interface{}{foo3},
+				TotalItems: 1,
+			},
+			nil,
+		},
+		{
+			"test sync status filter",
+			"bar",
+			&query.Query{
+				Pagination: query.NoPagination,
+				SortBy:     query.FieldName,
+				Filters:    map[query.Field]query.Value{query.FieldSyncStatus: "failed"},
+			},
+			&api.ListResult{
+				Items:      []interface{}{foo2},
+				TotalItems: 1,
+			},
+			nil,
+		},
+		{
+			"test sort by sync time",
+			"bar",
+			&query.Query{
+				Pagination: query.NoPagination,
+				SortBy:     query.FieldSyncTime,
+				Ascending:  false,
+				Filters:    map[query.Field]query.Value{},
+			},
+			&api.ListResult{
+				Items:      []interface{}{foo2, foo1, foo3},
+				TotalItems: 3,
+			},
+			nil,
+		},
+	}
+
+	getter := prepare()
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+
+			got, err := getter.List(test.namespace, test.query)
+
+			if test.expectedErr != nil && err != test.expectedErr {
+				t.Errorf("expected error, got nothing")
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(got, test.expected); diff != "" {
+				t.Errorf("%T differ (-got, +want): %s", test.expected, diff)
+			}
+		})
+	}
+}
+
+var (
+	foo1 = &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo1",
+			Namespace: "bar",
+			Annotations: map[string]string{
+				devopsv1alpha3.PipelineSyncStatusAnnoKey: "successful",
+				devopsv1alpha3.PipelineSyncTimeAnnoKey:   "2021-05-01T10:00:00Z",
+			},
+		},
+		Spec: devopsv1alpha3.PipelineSpec{
+			Type: devopsv1alpha3.NoScmPipelineType,
+		},
+	}
+
+	foo2 = &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo2",
+			Namespace: "bar",
+			Annotations: map[string]string{
+				devopsv1alpha3.PipelineSyncStatusAnnoKey: "failed",
+				devopsv1alpha3.PipelineSyncTimeAnnoKey:   "2021-05-02T10:00:00Z",
+			},
+		},
+		Spec: devopsv1alpha3.PipelineSpec{
+			Type: devopsv1alpha3.MultiBranchPipelineType,
+			MultiBranchPipeline: &devopsv1alpha3.MultiBranchPipeline{
+				SourceType: devopsv1alpha3.SourceTypeGit,
+			},
+		},
+	}
+
+	// never synced
+	foo3 = &devopsv1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo3",
+			Namespace: "bar",
+		},
+		Spec: devopsv1alpha3.PipelineSpec{
+			Type: devopsv1alpha3.MultiBranchPipelineType,
+			MultiBranchPipeline: &devopsv1alpha3.MultiBranchPipeline{
+				SourceType: devopsv1alpha3.SourceTypeGithub,
+			},
+		},
+	}
+
+	pipelines = []interface{}{foo1, foo2, foo3}
+)
+
+func prepare() v1alpha3.Interface {
+	client := fake.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 0)
+
+	for _, pipeline := range pipelines {
+		informer.Devops().V1alpha3().Pipelines().Informer().GetIndexer().Add(pipeline)
+	}
+	return New(informer)
+}
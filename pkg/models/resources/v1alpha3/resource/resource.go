@@ -25,6 +25,7 @@ import (
 	"errors"
 	iamv1alpha2 "kubesphere.io/api/iam/v1alpha2"
 
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
 	devopsv1alpha3 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha3"
 	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/workspace"
 	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/workspacetemplate"
@@ -39,6 +40,11 @@ import (
 	"devops.kubesphere.io/plugin/pkg/informers"
 	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
 	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/devops"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/pipeline"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/s2ibinary"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/s2ibuilder"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/s2ibuildertemplate"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3/s2irun"
 )
 
 var ErrResourceNotSupported = errors.New("resource is not supported")
@@ -61,6 +67,13 @@ func NewResourceGetter(factory informers.InformerFactory, cache cache.Cache) *Re
 	clusterResourceGetters[iamv1alpha2.SchemeGroupVersion.WithResource(iamv1alpha2.ResourcesPluralUser)] = user.New(factory.KubeSphereSharedInformerFactory(), factory.KubernetesSharedInformerFactory())
 	clusterResourceGetters[iamv1alpha2.SchemeGroupVersion.WithResource(iamv1alpha2.ResourcesPluralGlobalRoleBinding)] = globalrolebinding.New(factory.KubeSphereSharedInformerFactory())
 	clusterResourceGetters[iamv1alpha2.SchemeGroupVersion.WithResource(iamv1alpha2.ResourcesPluralWorkspaceRoleBinding)] = workspacerolebinding.New(factory.KubeSphereSharedInformerFactory())
+	clusterResourceGetters[devopsv1alpha1.SchemeGroupVersion.WithResource(devopsv1alpha1.ResourcePluralS2iBuilderTemplate)] = s2ibuildertemplate.New(factory.KubeSphereSharedInformerFactory())
+
+	// devops resources
+	namespacedResourceGetters[devopsv1alpha3.GroupVersion.WithResource(devopsv1alpha3.ResourcePluralPipeline)] = pipeline.New(factory.KubeSphereSharedInformerFactory())
+	namespacedResourceGetters[devopsv1alpha1.SchemeGroupVersion.WithResource(devopsv1alpha1.ResourcePluralS2iBuilder)] = s2ibuilder.New(factory.KubeSphereSharedInformerFactory())
+	namespacedResourceGetters[devopsv1alpha1.SchemeGroupVersion.WithResource(devopsv1alpha1.ResourcePluralS2iRun)] = s2irun.New(factory.KubeSphereSharedInformerFactory())
+	namespacedResourceGetters[devopsv1alpha1.SchemeGroupVersion.WithResource(devopsv1alpha1.ResourcePluralS2iBinary)] = s2ibinary.New(factory.KubeSphereSharedInformerFactory())
 	return &ResourceGetter{
 		namespacedResourceGetters: namespacedResourceGetters,
 		clusterResourceGetters:    clusterResourceGetters,
@@ -65,7 +65,7 @@ func (d *rolesGetter) List(namespace string, query *query.Query) (*api.ListResul
 		result = append(result, role)
 	}
 
-	return v1alpha3.DefaultList(result, query, d.compare, d.filter), nil
+	return v1alpha3.DefaultList(result, query, d.compare, d.filter)
 }
 
 func (d *rolesGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
@@ -51,7 +51,7 @@ func (d *rolebindingsGetter) List(namespace string, query *query.Query) (*api.Li
 		result = append(result, roleBinding)
 	}
 
-	return v1alpha3.DefaultList(result, query, d.compare, d.filter), nil
+	return v1alpha3.DefaultList(result, query, d.compare, d.filter)
 }
 
 func (d *rolebindingsGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
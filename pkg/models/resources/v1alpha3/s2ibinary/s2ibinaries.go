@@ -0,0 +1,90 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibinary
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+type s2iBinariesGetter struct {
+	informers ksinformers.SharedInformerFactory
+}
+
+func New(ksinformer ksinformers.SharedInformerFactory) v1alpha3.Interface {
+	return &s2iBinariesGetter{informers: ksinformer}
+}
+
+func (s *s2iBinariesGetter) Get(namespace, name string) (runtime.Object, error) {
+	return s.informers.Devops().V1alpha1().S2iBinaries().Lister().S2iBinaries(namespace).Get(name)
+}
+
+func (s *s2iBinariesGetter) List(namespace string, query *query.Query) (*api.ListResult, error) {
+	binaries, err := s.informers.Devops().V1alpha1().S2iBinaries().Lister().S2iBinaries(namespace).List(query.Selector())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []runtime.Object
+	for _, binary := range binaries {
+		result = append(result, binary)
+	}
+
+	return v1alpha3.DefaultList(result, query, s.compare, s.filter)
+}
+
+func (s *s2iBinariesGetter) filter(object runtime.Object, filter query.Filter) bool {
+	binary, ok := object.(*devopsv1alpha1.S2iBinary)
+	if !ok {
+		return false
+	}
+
+	switch filter.Field {
+	// /s2ibinaries?status=Ready
+	case query.FieldStatus:
+		return binary.Status.Phase == string(filter.Value)
+	default:
+		return v1alpha3.DefaultObjectMetaFilter(binary.ObjectMeta, filter)
+	}
+}
+
+func (s *s2iBinariesGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
+	leftBinary, ok := left.(*devopsv1alpha1.S2iBinary)
+	if !ok {
+		return false
+	}
+
+	rightBinary, ok := right.(*devopsv1alpha1.S2iBinary)
+	if !ok {
+		return true
+	}
+
+	switch field {
+	// ?sortBy=uploadTime
+	case query.FieldUploadTime:
+		return v1alpha3.DefaultTimeCompare(leftBinary.Spec.UploadTimeStamp, rightBinary.Spec.UploadTimeStamp,
+			leftBinary.ObjectMeta, rightBinary.ObjectMeta)
+	default:
+		return v1alpha3.DefaultObjectMetaCompare(leftBinary.ObjectMeta, rightBinary.ObjectMeta, field)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibuilder
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+type s2iBuildersGetter struct {
+	informers ksinformers.SharedInformerFactory
+}
+
+func New(ksinformer ksinformers.SharedInformerFactory) v1alpha3.Interface {
+	return &s2iBuildersGetter{informers: ksinformer}
+}
+
+func (s *s2iBuildersGetter) Get(namespace, name string) (runtime.Object, error) {
+	return s.informers.Devops().V1alpha1().S2iBuilders().Lister().S2iBuilders(namespace).Get(name)
+}
+
+func (s *s2iBuildersGetter) List(namespace string, query *query.Query) (*api.ListResult, error) {
+	builders, err := s.informers.Devops().V1alpha1().S2iBuilders().Lister().S2iBuilders(namespace).List(query.Selector())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []runtime.Object
+	for _, builder := range builders {
+		result = append(result, builder)
+	}
+
+	return v1alpha3.DefaultList(result, query, s.compare, s.filter)
+}
+
+func (s *s2iBuildersGetter) filter(object runtime.Object, filter query.Filter) bool {
+	builder, ok := object.(*devopsv1alpha1.S2iBuilder)
+	if !ok {
+		return false
+	}
+
+	switch filter.Field {
+	// /s2ibuilders?state=Failed, state of the last run
+	case query.FieldState:
+		return string(builder.Status.LastRunState) == string(filter.Value)
+	// /s2ibuilders?templateName=java
+	case query.FieldTemplateName:
+		return builder.Spec.FromTemplate != nil && builder.Spec.FromTemplate.Name == string(filter.Value)
+	default:
+		return v1alpha3.DefaultObjectMetaFilter(builder.ObjectMeta, filter)
+	}
+}
+
+func (s *s2iBuildersGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
+	leftBuilder, ok := left.(*devopsv1alpha1.S2iBuilder)
+	if !ok {
+		return false
+	}
+
+	rightBuilder, ok := right.(*devopsv1alpha1.S2iBuilder)
+	if !ok {
+		return true
+	}
+
+	switch field {
+	// ?sortBy=startTime, start time of the last run
+	case query.FieldStartTime:
+		return v1alpha3.DefaultTimeCompare(leftBuilder.Status.LastRunStartTime, rightBuilder.Status.LastRunStartTime,
+			leftBuilder.ObjectMeta, rightBuilder.ObjectMeta)
+	// ?sortBy=runCount
+	case query.FieldRunCount:
+		if leftBuilder.Status.RunCount == rightBuilder.Status.RunCount {
+			return v1alpha3.DefaultObjectMetaCompare(leftBuilder.ObjectMeta, rightBuilder.ObjectMeta, query.FieldCreationTimeStamp)
+		}
+		return leftBuilder.Status.RunCount > rightBuilder.Status.RunCount
+	default:
+		return v1alpha3.DefaultObjectMetaCompare(leftBuilder.ObjectMeta, rightBuilder.ObjectMeta, field)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2ibuildertemplate
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+type s2iBuilderTemplatesGetter struct {
+	informers ksinformers.SharedInformerFactory
+}
+
+func New(ksinformer ksinformers.SharedInformerFactory) v1alpha3.Interface {
+	return &s2iBuilderTemplatesGetter{informers: ksinformer}
+}
+
+func (s *s2iBuilderTemplatesGetter) Get(_, name string) (runtime.Object, error) {
+	return s.informers.Devops().V1alpha1().S2iBuilderTemplates().Lister().Get(name)
+}
+
+func (s *s2iBuilderTemplatesGetter) List(_ string, query *query.Query) (*api.ListResult, error) {
+	templates, err := s.informers.Devops().V1alpha1().S2iBuilderTemplates().Lister().List(query.Selector())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []runtime.Object
+	for _, template := range templates {
+		result = append(result, template)
+	}
+
+	return v1alpha3.DefaultList(result, query, s.compare, s.filter)
+}
+
+func (s *s2iBuilderTemplatesGetter) filter(object runtime.Object, filter query.Filter) bool {
+	template, ok := object.(*devopsv1alpha1.S2iBuilderTemplate)
+	if !ok {
+		return false
+	}
+
+	switch filter.Field {
+	// /s2ibuildertemplates?codeFramework=java
+	case query.FieldCodeFramework:
+		return string(template.Spec.CodeFramework) == string(filter.Value)
+	default:
+		return v1alpha3.DefaultObjectMetaFilter(template.ObjectMeta, filter)
+	}
+}
+
+func (s *s2iBuilderTemplatesGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
+	leftTemplate, ok := left.(*devopsv1alpha1.S2iBuilderTemplate)
+	if !ok {
+		return false
+	}
+
+	rightTemplate, ok := right.(*devopsv1alpha1.S2iBuilderTemplate)
+	if !ok {
+		return true
+	}
+
+	return v1alpha3.DefaultObjectMetaCompare(leftTemplate.ObjectMeta, rightTemplate.ObjectMeta, field)
+}
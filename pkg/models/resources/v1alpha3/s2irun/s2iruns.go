@@ -0,0 +1,97 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	ksinformers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+type s2iRunsGetter struct {
+	informers ksinformers.SharedInformerFactory
+}
+
+func New(ksinformer ksinformers.SharedInformerFactory) v1alpha3.Interface {
+	return &s2iRunsGetter{informers: ksinformer}
+}
+
+func (s *s2iRunsGetter) Get(namespace, name string) (runtime.Object, error) {
+	return s.informers.Devops().V1alpha1().S2iRuns().Lister().S2iRuns(namespace).Get(name)
+}
+
+func (s *s2iRunsGetter) List(namespace string, query *query.Query) (*api.ListResult, error) {
+	runs, err := s.informers.Devops().V1alpha1().S2iRuns().Lister().S2iRuns(namespace).List(query.Selector())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []runtime.Object
+	for _, run := range runs {
+		result = append(result, run)
+	}
+
+	return v1alpha3.DefaultList(result, query, s.compare, s.filter)
+}
+
+func (s *s2iRunsGetter) filter(object runtime.Object, filter query.Filter) bool {
+	run, ok := object.(*devopsv1alpha1.S2iRun)
+	if !ok {
+		return false
+	}
+
+	switch filter.Field {
+	// /s2iruns?state=Running
+	case query.FieldState:
+		return string(run.Status.RunState) == string(filter.Value)
+	// /s2iruns?builderName=java-builder
+	case query.FieldBuilderName:
+		return run.Spec.BuilderName == string(filter.Value)
+	default:
+		return v1alpha3.DefaultObjectMetaFilter(run.ObjectMeta, filter)
+	}
+}
+
+func (s *s2iRunsGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
+	leftRun, ok := left.(*devopsv1alpha1.S2iRun)
+	if !ok {
+		return false
+	}
+
+	rightRun, ok := right.(*devopsv1alpha1.S2iRun)
+	if !ok {
+		return true
+	}
+
+	switch field {
+	// ?sortBy=startTime
+	case query.FieldStartTime:
+		return v1alpha3.DefaultTimeCompare(leftRun.Status.StartTime, rightRun.Status.StartTime,
+			leftRun.ObjectMeta, rightRun.ObjectMeta)
+	// ?sortBy=completionTime
+	case query.FieldCompletionTime:
+		return v1alpha3.DefaultTimeCompare(leftRun.Status.CompletionTime, rightRun.Status.CompletionTime,
+			leftRun.ObjectMeta, rightRun.ObjectMeta)
+	default:
+		return v1alpha3.DefaultObjectMetaCompare(leftRun.ObjectMeta, rightRun.ObjectMeta, field)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 KubeSphere Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s2irun
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	devopsv1alpha1 "devops.kubesphere.io/plugin/pkg/api/devops/v1alpha1"
+
+	"devops.kubesphere.io/plugin/pkg/api"
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
+	"devops.kubesphere.io/plugin/pkg/client/clientset/versioned/fake"
+	informers "devops.kubesphere.io/plugin/pkg/client/informers/externalversions"
+	"devops.kubesphere.io/plugin/pkg/models/resources/v1alpha3"
+)
+
+func TestListS2iRuns(t *testing.T) {
+	tests := []struct {
+		description string
+		namespace   string
+		query       *query.Query
+		expected    *api.ListResult
+		expectedErr error
+	}{
+		{
+			"test state filter",
+			"bar",
+			&query.Query{
+				Pagination: query.NoPagination,
+				SortBy:     query.FieldName,
+				Filters:    map[query.Field]query.Value{query.FieldState: query.Value(devopsv1alpha1.Running)},
+			},
+			&api.ListResult{
+				Items:      []interface{}{foo2},
+				TotalItems: 1,
+			},
+			nil,
+		},
+		{
+			"test builder name filter",
+			"bar",
+			&query.Query{
+				Pagination: query.NoPagination,
+				SortBy:     query.FieldName,
+				Ascending:  true,
+				Filters:    map[query.Field]query.Value{query.FieldBuilderName: "builder1"},
+			},
+			&api.ListResult{
+				Items:      []interface{}{foo1, foo2},
+				TotalItems: 2,
+			},
+			nil,
+		},
+		{
+			"test sort by start time",
+			"bar",
+			&query.Query{
+				Pagination: query.NoPagination,
+				SortBy:     query.FieldStartTime,
+				Ascending:  true,
+				Filters:    map[query.Field]query.Value{},
+			},
+			&api.ListResult{
+				Items:      []interface{}{bar1, foo1, foo2},
+				TotalItems: 3,
+			},
+			nil,
+		},
+	}
+
+	getter := prepare()
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+
+			got, err := getter.List(test.namespace, test.query)
+
+			if test.expectedErr != nil && err != test.expectedErr {
+				t.Errorf("expected error, got nothing")
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(got, test.expected); diff != "" {
+				t.Errorf("%T differ (-got, +want): %s", test.expected, diff)
+			}
+		})
+	}
+}
+
+var (
+	now = time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	foo1 = &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo1",
+			Namespace: "bar",
+		},
+		Spec: devopsv1alpha1.S2iRunSpec{
+			BuilderName: "builder1",
+		},
+		Status: devopsv1alpha1.S2iRunStatus{
+			StartTime: &metav1.Time{Time: now},
+			RunState:  devopsv1alpha1.Successful,
+		},
+	}
+
+	foo2 = &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo2",
+			Namespace: "bar",
+		},
+		Spec: devopsv1alpha1.S2iRunSpec{
+			BuilderName: "builder1",
+		},
+		Status: devopsv1alpha1.S2iRunStatus{
+			StartTime: &metav1.Time{Time: now.Add(time.Hour)},
+			RunState:  devopsv1alpha1.Running,
+		},
+	}
+
+	// not started yet
+	bar1 = &devopsv1alpha1.S2iRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bar1",
+			Namespace: "bar",
+		},
+		Spec: devopsv1alpha1.S2iRunSpec{
+			BuilderName: "builder2",
+		},
+	}
+
+	runs = []interface{}{foo1, foo2, bar1}
+)
+
+func prepare() v1alpha3.Interface {
+	client := fake.NewSimpleClientset()
+	informer := informers.NewSharedInformerFactory(client, 0)
+
+	for _, run := range runs {
+		informer.Devops().V1alpha1().S2iRuns().Informer().GetIndexer().Add(run)
+	}
+	return New(informer)
+}
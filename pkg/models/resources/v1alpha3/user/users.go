@@ -82,7 +82,7 @@ func (d *usersGetter) List(_ string, query *query.Query) (*api.ListResult, error
 		result = append(result, user)
 	}
 
-	return v1alpha3.DefaultList(result, query, d.compare, d.filter), nil
+	return v1alpha3.DefaultList(result, query, d.compare, d.filter)
 }
 
 func (d *usersGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
@@ -65,7 +65,7 @@ func (d *workspacerolesGetter) List(_ string, queryParam *query.Query) (*api.Lis
 		result = append(result, role)
 	}
 
-	return v1alpha3.DefaultList(result, queryParam, d.compare, d.filter), nil
+	return v1alpha3.DefaultList(result, queryParam, d.compare, d.filter)
 }
 
 func (d *workspacerolesGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
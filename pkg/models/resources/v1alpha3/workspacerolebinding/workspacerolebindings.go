@@ -54,7 +54,7 @@ func (d *workspacerolebindingsGetter) List(_ string, query *query.Query) (*api.L
 		result = append(result, globalRoleBinding)
 	}
 
-	return v1alpha3.DefaultList(result, query, d.compare, d.filter), nil
+	return v1alpha3.DefaultList(result, query, d.compare, d.filter)
 }
 
 func (d *workspacerolebindingsGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
@@ -51,7 +51,7 @@ func (d *workspaceGetter) List(_ string, query *query.Query) (*api.ListResult, e
 		result = append(result, workspace)
 	}
 
-	return v1alpha3.DefaultList(result, query, d.compare, d.filter), nil
+	return v1alpha3.DefaultList(result, query, d.compare, d.filter)
 }
 
 func (d *workspaceGetter) compare(left runtime.Object, right runtime.Object, field query.Field) bool {
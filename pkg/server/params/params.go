@@ -23,6 +23,8 @@ import (
 	"strings"
 
 	"github.com/emicklei/go-restful"
+
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
 )
 
 const (
@@ -79,11 +81,15 @@ func parseConditions(conditionsStr string) (*Conditions, error) {
 	// string likes: key1=value1,key2~value2,key3=
 	// exact query: key=value, if value is empty means label value must be ""
 	// fuzzy query: key~value, if value is empty means label value is "" or label key not exist
+	// requirements: key=in(value1,value2), key=notin(value1,value2), key!=value, key=prefix(value),
+	// key=regex(expression) or the exact query with the same key repeated, see query.Requirement
 	var conditions = &Conditions{Match: make(map[string]string, 0), Fuzzy: make(map[string]string, 0)}
+	var matchKeys []string
+	matchValues := make(map[string][]string)
 
 	for conditionsStr != "" {
 		key := conditionsStr
-		if i := strings.Index(key, ","); i >= 0 {
+		if i := indexSeparator(key); i >= 0 {
 			key, conditionsStr = key[:i], key[i+1:]
 		} else {
 			conditionsStr = ""
@@ -105,19 +111,68 @@ func parseConditions(conditionsStr string) (*Conditions, error) {
 		if isFuzzy {
 			conditions.Fuzzy[key] = value
 		} else {
-			conditions.Match[key] = value
+			if _, ok := matchValues[key]; !ok {
+				matchKeys = append(matchKeys, key)
+			}
+			matchValues[key] = append(matchValues[key], value)
+		}
+	}
+
+	for _, key := range matchKeys {
+		if requirement, ok := query.ParseRequirement(key, matchValues[key]); ok {
+			conditions.Requirements = append(conditions.Requirements, requirement)
+		} else {
+			conditions.Match[key] = matchValues[key][0]
 		}
 	}
 	return conditions, nil
 }
 
+// indexSeparator returns the index of the first comma which is not in parentheses
+func indexSeparator(conditionsStr string) int {
+	depth := 0
+	for i, c := range conditionsStr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 func ParseConditions(req *restful.Request) (*Conditions, error) {
-	return parseConditions(req.QueryParameter(ConditionsParam))
+	conditions, err := parseConditions(req.QueryParameter(ConditionsParam))
+	if err != nil {
+		return nil, err
+	}
+	conditions.FieldSelector = req.QueryParameter(query.ParameterFieldSelector)
+	if _, err = conditions.Matcher(); err != nil {
+		return nil, err
+	}
+	return conditions, nil
 }
 
 type Conditions struct {
 	Match map[string]string
 	Fuzzy map[string]string
+
+	// Requirements are the exact queries with multiple values, negation, prefix or regex
+	Requirements []query.Requirement
+	// FieldSelector selects objects by the JSON paths of them
+	FieldSelector string
+}
+
+// Matcher returns the matcher of requirements and field selector
+func (c *Conditions) Matcher() (*query.Matcher, error) {
+	return query.NewMatcher(c.Requirements, c.FieldSelector)
 }
 
 func GetBoolValueWithDefault(req *restful.Request, name string, dv bool) bool {
@@ -25,6 +25,8 @@ import (
 	"gotest.tools/assert"
 
 	"github.com/emicklei/go-restful"
+
+	"devops.kubesphere.io/plugin/pkg/apiserver/query"
 )
 
 func TestParseConditions(t *testing.T) {
@@ -112,6 +114,14 @@ func TestParseConditions(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"bad case 5",
+			args{&restful.Request{Request: &http.Request{URL: &url.URL{
+				RawQuery: "conditions=status%3Ddraft&fieldSelector=spec.type",
+			}}}},
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -153,6 +163,23 @@ func Test_parseConditions(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"good case 2",
+			args{"key1=in(value1,value2),key2!=value2,key3=value3,key3=value4,key4=value4,key5=regex(^a,b$)"},
+			&Conditions{
+				Match: map[string]string{
+					"key4": "value4",
+				},
+				Fuzzy: map[string]string{},
+				Requirements: []query.Requirement{
+					{Field: "key1", Operator: query.OperatorIn, Values: []string{"value1", "value2"}},
+					{Field: "key2", Operator: query.OperatorIn, Values: []string{"value2"}, Negated: true},
+					{Field: "key3", Operator: query.OperatorIn, Values: []string{"value3", "value4"}},
+					{Field: "key5", Operator: query.OperatorRegex, Values: []string{"^a,b$"}},
+				},
+			},
+			false,
+		},
 		{
 			"bad case 1",
 			args{"key1 error=value1,key2~value2,key3=,key4~,key5"},